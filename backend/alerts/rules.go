@@ -0,0 +1,194 @@
+// Package alerts evaluates the alert rules of a school against its chemicals, turning expiring,
+// low and badly stored chemicals into typed alerts that the monitor and the API report.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// RulesFor returns the alert rules of a school, or the default rules when it has not set any
+func RulesFor(ctx context.Context, repo repository.AlertRuleRepository, school string) (models.AlertRules, error) {
+	rules, err := repo.Get(ctx, school)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultAlertRules(school), nil
+	}
+	return rules, err
+}
+
+// Evaluate returns the alerts the rules raise for a school's chemicals as of now, most severe first.
+// Disposed chemicals are left out, and chemicals flagged for disposal only raise storage alerts
+// since they are already on their way out.
+func Evaluate(rules models.AlertRules, chemicals []models.Chemical, now time.Time) []models.Alert {
+	today := models.NewDate(now)
+	var alerts []models.Alert
+	var school []models.Chemical
+	for _, chemical := range chemicals {
+		if chemical.School != rules.School {
+			continue
+		}
+		school = append(school, chemical)
+		if chemical.IsDisposed() || chemical.Disposal != nil {
+			continue
+		}
+		if alert, ok := expiration(rules, chemical, today); ok {
+			alerts = append(alerts, alert)
+		}
+		if alert, ok := lowStock(rules, chemical); ok {
+			alerts = append(alerts, alert)
+		}
+		if alert, ok := sds(rules, chemical, today); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	if rules.StorageConflicts != models.ConflictsNone {
+		alerts = append(alerts, storage(rules, school)...)
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.Severity != b.Severity {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.ChemicalName != b.ChemicalName {
+			return a.ChemicalName < b.ChemicalName
+		}
+		return a.ChemicalID < b.ChemicalID
+	})
+	return alerts
+}
+
+// newAlert returns an alert about a chemical
+func newAlert(kind models.AlertKind, severity models.AlertSeverity, c models.Chemical, message string) models.Alert {
+	return models.Alert{Kind: kind, Severity: severity, School: c.School, ChemicalID: c.ID, ChemicalName: c.Name,
+		CAS: c.CAS, Room: c.Room, Message: message}
+}
+
+// expiration raises an expired alert, or an expiring one at the shortest lead time the
+// chemical has reached. An expired chemical is never reported as expiring.
+func expiration(rules models.AlertRules, c models.Chemical, today models.Date) (models.Alert, bool) {
+	if c.ExpirationDate.IsZero() {
+		return models.Alert{}, false
+	}
+	expires := c.ExpirationDate
+	daysLeft := int(expires.Sub(today.Time).Hours() / 24)
+	if daysLeft < 0 {
+		if !rules.Expired {
+			return models.Alert{}, false
+		}
+		alert := newAlert(models.AlertExpired, models.SeverityCritical, c,
+			fmt.Sprintf("%s expired on %s", c.Name, expires.Format("2006-01-02")))
+		alert.ExpirationDate = &expires
+		return alert, true
+	}
+
+	lead := 0
+	for _, days := range rules.ExpiringLeadDays {
+		if daysLeft <= days && (lead == 0 || days < lead) {
+			lead = days
+		}
+	}
+	if lead == 0 {
+		return models.Alert{}, false
+	}
+	message := fmt.Sprintf("%s expires in %d days, on %s", c.Name, daysLeft, expires.Format("2006-01-02"))
+	switch daysLeft {
+	case 0:
+		message = fmt.Sprintf("%s expires today", c.Name)
+	case 1:
+		message = fmt.Sprintf("%s expires tomorrow", c.Name)
+	}
+	alert := newAlert(models.AlertExpiring, models.SeverityWarning, c, message)
+	alert.ExpirationDate, alert.LeadDays = &expires, lead
+	return alert, true
+}
+
+// lowStock raises an alert for a chemical at its reorder threshold or below the share of its
+// container the rules ask for. An empty container is critical.
+func lowStock(rules models.AlertRules, c models.Chemical) (models.Alert, bool) {
+	if c.Remaining.IsZero() {
+		return models.Alert{}, false
+	}
+	remaining := c.Remaining
+	severity := models.SeverityWarning
+	if remaining.Amount <= 0 {
+		severity = models.SeverityCritical
+	}
+
+	if rules.LowStock && c.LowStock() {
+		threshold := c.ReorderThreshold
+		alert := newAlert(models.AlertLowStock, severity, c,
+			fmt.Sprintf("%s is low, %s left with a reorder threshold of %s", c.Name, remaining, threshold))
+		alert.Remaining, alert.ReorderThreshold = &remaining, &threshold
+		return alert, true
+	}
+	if rules.LowStockPercent <= 0 || c.ContainerSize.IsZero() || c.ContainerSize.Amount <= 0 {
+		return models.Alert{}, false
+	}
+	left, err := remaining.Convert(c.ContainerSize.Unit)
+	if err != nil {
+		return models.Alert{}, false
+	}
+	percent := left.Amount / c.ContainerSize.Amount * 100
+	if percent > rules.LowStockPercent {
+		return models.Alert{}, false
+	}
+	alert := newAlert(models.AlertLowStock, severity, c,
+		fmt.Sprintf("%s is low, %s left, %.0f%% of its container", c.Name, remaining, percent))
+	alert.Remaining = &remaining
+	return alert, true
+}
+
+// sds raises an alert for a chemical without a safety data sheet, or with one revised longer
+// ago than the rules allow. Sheets uploaded without a revision date are not judged by age.
+func sds(rules models.AlertRules, c models.Chemical, today models.Date) (models.Alert, bool) {
+	if c.SDSURL == "" {
+		if !rules.SDSMissing {
+			return models.Alert{}, false
+		}
+		return newAlert(models.AlertSDSMissing, models.SeverityInfo, c,
+			fmt.Sprintf("%s has no safety data sheet", c.Name)), true
+	}
+	if rules.SDSMaxAgeYears <= 0 || c.SDSDate.IsZero() || !c.SDSDate.Before(today.AddDate(-rules.SDSMaxAgeYears, 0, 0)) {
+		return models.Alert{}, false
+	}
+	revised := c.SDSDate
+	alert := newAlert(models.AlertSDSOutdated, models.SeverityInfo, c,
+		fmt.Sprintf("The safety data sheet of %s was revised on %s, more than %d years ago", c.Name,
+			revised.Format("2006-01-02"), rules.SDSMaxAgeYears))
+	alert.SDSDate = &revised
+	return alert, true
+}
+
+// storage raises an alert for each pair of incompatible chemicals sharing a shelf, critical for
+// the pairs that must never be stored together
+func storage(rules models.AlertRules, chemicals []models.Chemical) []models.Alert {
+	var alerts []models.Alert
+	for _, shelf := range models.ShelfGroups(chemicals) {
+		for i, chemical := range shelf {
+			for _, conflict := range chemical.StorageConflicts(shelf[i+1:]) {
+				severity := models.SeverityWarning
+				if conflict.Severity == models.StorageBlocked {
+					severity = models.SeverityCritical
+				} else if rules.StorageConflicts == models.ConflictsBlocked {
+					continue
+				}
+				conflict := conflict
+				alert := newAlert(models.AlertStorageConflict, severity, chemical,
+					fmt.Sprintf("%s is stored next to %s: %s", conflict.ChemicalName, conflict.OtherName, conflict.Reason))
+				alert.Conflict = &conflict
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,105 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// Sync brings the stored alerts of a school in line with the alerts found for it at now. A
+// condition found for the first time is stored as a new open alert, one found again updates its
+// alert, and the active alerts whose condition was not found are resolved by the system. Sync
+// returns the alerts the admins should be sent, new or escalated, most severe first.
+func Sync(ctx context.Context, repo repository.AlertRepository, school string, found []models.Alert, now time.Time) ([]models.Alert, error) {
+	stored, err := repo.List(ctx, repository.AlertFilter{School: school, Statuses: models.ActiveAlertStatuses})
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]models.Alert, len(stored))
+	for _, alert := range stored {
+		active[alert.Key()] = alert
+	}
+
+	var notify []models.Alert
+	for _, current := range found {
+		alert, ok := active[current.Key()]
+		if ok {
+			delete(active, current.Key())
+			alert.Observe(current, now)
+			if err := repo.Update(ctx, alert); err != nil {
+				return nil, err
+			}
+		} else {
+			alert = current
+			alert.Status, alert.FirstSeen, alert.LastSeen = models.AlertOpen, now, now
+			if err := repo.Create(ctx, &alert); err != nil {
+				return nil, err
+			}
+		}
+		if alert.NeedsNotice() {
+			notify = append(notify, alert)
+		}
+	}
+
+	for _, alert := range active {
+		if err := alert.Resolve(models.SystemActor, now); err != nil {
+			return nil, err
+		}
+		if err := repo.Update(ctx, alert); err != nil {
+			return nil, err
+		}
+	}
+	return notify, nil
+}
+
+// Refresh evaluates the alert rules of a school against its chemicals, or of every school when
+// school is empty, and syncs the stored alerts with what was found. Schools left without chemicals
+// are included so their alerts resolve. Refresh returns the alerts to send to each school's admins.
+func Refresh(ctx context.Context, chemicals repository.ChemicalRepository, rules repository.AlertRuleRepository,
+	stored repository.AlertRepository, school string, now time.Time) (map[string][]models.Alert, error) {
+	list, err := chemicals.List(ctx, repository.ChemicalFilter{School: school})
+	if err != nil {
+		return nil, fmt.Errorf("fetching chemicals: %w", err)
+	}
+	bySchool := make(map[string][]models.Chemical)
+	for _, chemical := range list {
+		if chemical.School == "" {
+			log.Printf("Skipping incomplete chemical: %s", chemical.ID)
+			continue
+		}
+		bySchool[chemical.School] = append(bySchool[chemical.School], chemical)
+	}
+	open, err := stored.List(ctx, repository.AlertFilter{School: school, Statuses: models.ActiveAlertStatuses})
+	if err != nil {
+		return nil, fmt.Errorf("fetching open alerts: %w", err)
+	}
+	for _, alert := range open {
+		if _, ok := bySchool[alert.School]; !ok {
+			bySchool[alert.School] = nil
+		}
+	}
+
+	notices := make(map[string][]models.Alert)
+	for name, chemicals := range bySchool {
+		// Stop between schools when the scheduler is shutting down
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		schoolRules, err := RulesFor(ctx, rules, name)
+		if err != nil {
+			return nil, fmt.Errorf("fetching the alert rules of %s: %w", name, err)
+		}
+		notify, err := Sync(ctx, stored, name, Evaluate(schoolRules, chemicals, now), now)
+		if err != nil {
+			return nil, fmt.Errorf("storing the alerts of %s: %w", name, err)
+		}
+		if len(notify) > 0 {
+			notices[name] = notify
+		}
+	}
+	return notices, nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles a caller can have, from most to least privileged
+const (
+	RoleMaster = "master"
+	RoleAdmin  = "admin"
+	RoleUser   = "user"
+)
+
+// ErrInvalidToken is returned when an access token is malformed, expired or badly signed
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	UserID string `json:"user_id"`
+	School string `json:"school"`
+	Role   string `json:"role"`
+}
+
+// RoleFor maps the user flags stored on a user document to a role
+func RoleFor(isAdmin, isMaster bool) string {
+	switch {
+	case isMaster:
+		return RoleMaster
+	case isAdmin:
+		return RoleAdmin
+	default:
+		return RoleUser
+	}
+}
+
+// Claims are the JWT claims carried by an access token
+type Claims struct {
+	School string `json:"school"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager signs and verifies access tokens and mints refresh tokens
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewTokenManager creates a TokenManager signing with the given HMAC secret
+func NewTokenManager(secret string, accessTTL, refreshTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// AccessTTL returns how long issued access tokens stay valid
+func (tm *TokenManager) AccessTTL() time.Duration {
+	return tm.accessTTL
+}
+
+// RefreshTTL returns how long issued refresh tokens stay valid
+func (tm *TokenManager) RefreshTTL() time.Duration {
+	return tm.refreshTTL
+}
+
+// IssueAccessToken signs a short-lived access token for the principal
+func (tm *TokenManager) IssueAccessToken(p Principal) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		School: p.School,
+		Role:   p.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   p.UserID,
+			Issuer:    "chemtrack",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tm.accessTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(tm.secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return signed, nil
+}
+
+// ParseAccessToken verifies an access token and returns the principal it was issued to
+func (tm *TokenManager) ParseAccessToken(tokenString string) (Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return tm.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer("chemtrack"))
+	if err != nil || claims.Subject == "" {
+		return Principal{}, ErrInvalidToken
+	}
+
+	return Principal{UserID: claims.Subject, School: claims.School, Role: claims.Role}, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token and the hash to store for it
+func (tm *TokenManager) NewRefreshToken() (token string, hash string, err error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token = hex.EncodeToString(tokenBytes)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of an opaque token, which is what gets persisted
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
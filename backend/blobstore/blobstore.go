@@ -0,0 +1,46 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"time"
+)
+
+// ErrNotExist is returned when the requested object is not in the store
+var ErrNotExist = errors.New("object does not exist")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Updated     time.Time `json:"updated"`
+}
+
+// BlobStore stores the files the API produces or receives: QR codes, labels, SDS PDFs and profile pictures.
+// Keys are slash separated paths such as "sds/<chemicalID>.pdf".
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object, returning ErrNotExist if it was not there
+	Delete(ctx context.Context, key string) error
+	// Stat returns the object's metadata, or ErrNotExist
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// URL returns the address clients can download the object from
+	URL(key string) string
+}
+
+// contentTypeFor guesses the content type of a key from its extension
+func contentTypeFor(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,115 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSStore keeps objects in a Google Cloud Storage bucket
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCSStore connects to Google Cloud Storage. credentialsFile may be empty to use the default credentials.
+func NewGCSStore(ctx context.Context, bucket, credentialsFile string) (*GCSStore, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &GCSStore{client: client, bucket: bucket}, nil
+}
+
+// Close releases the underlying storage client
+func (s *GCSStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *GCSStore) object(key string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(key)
+}
+
+// Put uploads the object to the bucket
+func (s *GCSStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	writer := s.object(key).NewWriter(ctx)
+	writer.ContentType = contentType
+	if _, err := io.Copy(writer, r); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to copy file to GCS: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload file to GCS: %w", err)
+	}
+	return nil
+}
+
+// Get opens a reader on the object
+func (s *GCSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotExist
+	}
+	return reader, err
+}
+
+// Delete removes the object from the bucket
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	err := s.object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrNotExist
+	}
+	return err
+}
+
+// Stat returns the object's attributes
+func (s *GCSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	attrs, err := s.object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ObjectInfo{}, ErrNotExist
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return infoFromAttrs(attrs), nil
+}
+
+// List returns the objects under prefix
+func (s *GCSStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	iter := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	var objects []ObjectInfo
+	for {
+		attrs, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, infoFromAttrs(attrs))
+	}
+	return objects, nil
+}
+
+// URL returns the public URL of the object
+// Example URL: https://storage.googleapis.com/chemtrack-deployment/sds/12345.pdf
+func (s *GCSStore) URL(key string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.bucket, key)
+}
+
+func infoFromAttrs(attrs *storage.ObjectAttrs) ObjectInfo {
+	contentType := attrs.ContentType
+	if contentType == "" {
+		contentType = contentTypeFor(attrs.Name)
+	}
+	return ObjectInfo{Key: attrs.Name, Size: attrs.Size, ContentType: contentType, Updated: attrs.Updated}
+}
@@ -0,0 +1,93 @@
+// Command migrate-legacy converts chemical data still stored in older formats: purchase and
+// expiration dates kept as strings, and CAS numbers kept as integers. It reads the same
+// environment as the server, can safely be run more than once, and lists every value it could
+// not convert or validate so it can be corrected by hand.
+//
+// On Firestore it then stores the lower-cased names and rooms and the expiration dates chemicals
+// are sorted by, which listings skip the documents without.
+//
+// It also builds the location hierarchy from the free-text school, room, cabinet and shelf
+// fields, and files every chemical that has no location yet under it.
+//
+//	go run ./cmd/migrate-legacy
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	"github.com/joho/godotenv"
+	"google.golang.org/api/option"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/config"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, relying on environment variables.")
+	}
+	cfg := config.Load()
+	ctx := context.Background()
+
+	var converted, placed int
+	var problems []repository.ConversionProblem
+	var err error
+
+	switch cfg.DatabaseBackend {
+	case "firestore":
+		client, clientErr := firestore.NewClient(ctx, cfg.FirestoreProject, option.WithCredentialsFile(cfg.FirestoreCredentialsFile))
+		if clientErr != nil {
+			log.Fatalf("Failed to create Firestore client: %v", clientErr)
+		}
+		defer client.Close()
+		converted, problems, err = repository.MigrateFirestoreDates(ctx, client)
+		if err == nil {
+			var cas int
+			var casProblems []repository.ConversionProblem
+			cas, casProblems, err = repository.MigrateFirestoreCAS(ctx, client)
+			converted += cas
+			problems = append(problems, casProblems...)
+		}
+		if err == nil {
+			var keyed int
+			keyed, err = repository.MigrateFirestoreSortKeys(ctx, client)
+			converted += keyed
+		}
+		if err == nil {
+			placed, err = repository.BuildLocations(ctx, repository.NewFirestore(client))
+		}
+	case "sqlite", "postgres":
+		dialect := repository.Dialect(cfg.DatabaseBackend)
+		db, openErr := repository.OpenSQL(ctx, dialect, cfg.DatabaseURL)
+		if openErr != nil {
+			log.Fatalf("Failed to open %s database: %v", cfg.DatabaseBackend, openErr)
+		}
+		defer db.Close()
+		// Opening the database already applied the schema migrations, which convert CAS numbers
+		converted, problems, err = repository.MigrateSQLDates(ctx, db, dialect)
+		if err == nil {
+			placed, err = repository.BuildLocations(ctx, repository.NewSQL(db, dialect))
+		}
+	default:
+		log.Fatalf("Unknown DATABASE_BACKEND %q", cfg.DatabaseBackend)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	log.Printf("Converted %d chemical records", converted)
+	log.Printf("Filed %d chemicals under locations", placed)
+	if len(problems) == 0 {
+		return
+	}
+
+	log.Printf("%d values need to be corrected by hand:", len(problems))
+	for _, p := range problems {
+		fmt.Printf("%s\t%s\t%q\n", p.ChemicalID, p.Field, p.Value)
+	}
+	os.Exit(1)
+}
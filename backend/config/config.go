@@ -0,0 +1,139 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the runtime settings of the API, read from environment variables
+type Config struct {
+	JWTSecret       string        // secret used to sign access tokens, required unless JWTEphemeral is set
+	JWTEphemeral    bool          // sign with a secret generated at startup, for development and tests only
+	AccessTokenTTL  time.Duration // lifetime of an access token
+	RefreshTokenTTL time.Duration // lifetime of a refresh token
+
+	DatabaseBackend          string // where records are kept: "firestore", "sqlite" or "postgres"
+	DatabaseURL              string // SQLite file path or PostgreSQL connection URL used by the SQL backends
+	FirestoreProject         string // Google Cloud project used by the firestore backend
+	FirestoreCredentialsFile string // service account key used by the firestore backend
+
+	StorageBackend  string // where files are kept: "gcs" or "local"
+	GCSBucket       string // bucket used by the gcs backend
+	LocalStorageDir string // directory used by the local backend
+	PublicBaseURL   string // address clients use to reach the API, used in local file URLs
+
+	TrashRetention time.Duration // how long deleted chemicals and users can be restored before they are purged
+
+	ChemicalMonitorSchedule string        // cron schedule of the low stock and expiration alerts, "off" to only run them by hand
+	AlertDigestSchedule     string        // cron schedule of the weekly alert digest, "off" to only send it by hand
+	TrashPurgeSchedule      string        // cron schedule of the trash purge, "off" to only run it by hand
+	SchedulerTimezone       string        // IANA time zone the schedules are read in
+	JobLockLease            time.Duration // how long a running job keeps other replicas from taking it over
+	ShutdownTimeout         time.Duration // how long requests and job runs in progress get to finish on shutdown
+
+	NotificationLocale string // language of the emails and push notifications, unless a request asks for another
+
+	MailTransport  string        // how emails are sent: "sendgrid", "smtp", or "capture" to keep them in memory
+	MailFrom       string        // sender of the emails, such as "ChemTrack <alerts@example.org>", required unless capturing them
+	MailReplyTo    string        // where replies to the emails go, the sender when empty
+	SendGridAPIKey string        // API key used by the sendgrid transport
+	SMTPHost       string        // server used by the smtp transport
+	SMTPPort       int           // port of the SMTP server, 465 for TLS or one that offers STARTTLS
+	SMTPUsername   string        // account on the SMTP server, no authentication when empty
+	SMTPPassword   string        // password of the SMTP account
+	SMTPTimeout    time.Duration // how long connecting to the SMTP server and sending an email may take
+}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() Config {
+	cfg := Config{
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		JWTEphemeral:    boolEnv("JWT_EPHEMERAL_SECRET", false),
+		AccessTokenTTL:  durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		DatabaseBackend:          stringEnv("DATABASE_BACKEND", "firestore"),
+		DatabaseURL:              stringEnv("DATABASE_URL", "data/chemtrack.db"),
+		FirestoreProject:         stringEnv("FIRESTORE_PROJECT", "chemtrack-encina"),
+		FirestoreCredentialsFile: stringEnv("FIRESTORE_CREDENTIALS_FILE", "/tmp/key.json"),
+
+		StorageBackend:  stringEnv("STORAGE_BACKEND", "gcs"),
+		GCSBucket:       stringEnv("GCS_BUCKET", "chemtrack-deployment"),
+		LocalStorageDir: stringEnv("LOCAL_STORAGE_DIR", "data/blobs"),
+		PublicBaseURL:   stringEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+
+		TrashRetention: durationEnv("TRASH_RETENTION", 30*24*time.Hour),
+
+		ChemicalMonitorSchedule: stringEnv("CHEMICAL_MONITOR_SCHEDULE", "0 7 1 * *"),
+		AlertDigestSchedule:     stringEnv("ALERT_DIGEST_SCHEDULE", "0 7 * * 1"),
+		TrashPurgeSchedule:      stringEnv("TRASH_PURGE_SCHEDULE", "0 3 * * *"),
+		SchedulerTimezone:       stringEnv("SCHEDULER_TIMEZONE", "UTC"),
+		JobLockLease:            durationEnv("JOB_LOCK_LEASE", time.Hour),
+		ShutdownTimeout:         durationEnv("SHUTDOWN_TIMEOUT", 8*time.Second),
+
+		NotificationLocale: stringEnv("NOTIFICATION_LOCALE", "en"),
+
+		MailTransport:  stringEnv("MAIL_TRANSPORT", "sendgrid"),
+		MailFrom:       os.Getenv("MAIL_FROM"),
+		MailReplyTo:    os.Getenv("MAIL_REPLY_TO"),
+		SendGridAPIKey: os.Getenv("SENDGRID_API_KEY"),
+		SMTPHost:       stringEnv("SMTP_HOST", "localhost"),
+		SMTPPort:       intEnv("SMTP_PORT", 587),
+		SMTPUsername:   os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:   os.Getenv("SMTP_PASSWORD"),
+		SMTPTimeout:    durationEnv("SMTP_TIMEOUT", 30*time.Second),
+	}
+	return cfg
+}
+
+// stringEnv reads a string from the environment
+func stringEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// durationEnv parses a duration such as "15m" or "720h" from the environment
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// boolEnv parses a boolean such as "true" or "1" from the environment
+func boolEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s (%q), using default %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// intEnv parses an integer from the environment
+func intEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number for %s (%q), using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
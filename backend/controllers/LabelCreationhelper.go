@@ -3,20 +3,22 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"os"
 
-	"cloud.google.com/go/storage"
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pdf/fpdf"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
 )
 
 // AddLabel godoc
 // @Summary Create a PDF label with a QR code and text
-// @Description Creates a PDF label with a QR code (retrieved via the QR code API) and chemical name, then uploads it to Google Cloud Storage under the "label" folder
+// @Description Creates a PDF label with the chemical's QR code and ID, then uploads it to file storage under the "label" folder
 // @Tags Label
 // @Produce json
 // @Param chemicalIdNumber path string true "Chemical ID Number"
@@ -25,9 +27,12 @@ import (
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /label/{chemicalIdNumber} [post]
-func AddLabel(c *gin.Context) {
+func (h *Handler) AddLabel(c *gin.Context) {
 	chemicalId := c.Param("chemicalIdNumber")
-	err := GenerateAndUploadLabel(chemicalId)
+	if !h.authorizeChemical(c, chemicalId, policy.CanManageChemicals) {
+		return
+	}
+	err := h.GenerateAndUploadLabel(chemicalId)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "not found") {
@@ -44,40 +49,31 @@ func AddLabel(c *gin.Context) {
 }
 
 
-func GenerateAndUploadLabel(chemicalId string) error {
-	// Skip actual qr label generation and uploading if its test cases
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock creating QR Label while adding chemical")
-		return nil
-	}
+// GenerateAndUploadLabel renders the printable label for a chemical and stores it
+func (h *Handler) GenerateAndUploadLabel(chemicalId string) error {
 	ctx := context.Background()
 
-	// Initialize Google Cloud Storage client
-	storageClient, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to init storage client: %w", err)
-	}
-	defer storageClient.Close()
-
 	// Fetch the document to confirm existence
-	doc, err := client.Collection("chemicals").Doc(chemicalId).Get(ctx)
+	chemical, err := h.chemicals.Get(ctx, chemicalId)
 	if err != nil {
 		return fmt.Errorf("chemical not found: %w", err)
 	}
-	chemID := doc.Ref.ID
+	chemID := chemical.ID
 
 
-	// Fetch the QR code
-	//TODO: update this 
-	qrCodeURL := fmt.Sprintf("http://localhost:8080/api/v1/files/qrcode/%s", chemID)
-	resp, err := http.Get(qrCodeURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	// Fetch the QR code from storage, generating it first if the chemical does not have one yet
+	qrReader, err := h.blobs.Get(ctx, qrCodeKey(chemID))
+	if errors.Is(err, blobstore.ErrNotExist) {
+		h.GenerateQRCode(chemID)
+		qrReader, err = h.blobs.Get(ctx, qrCodeKey(chemID))
+	}
+	if err != nil {
 		return fmt.Errorf("failed to fetch QR code: %w", err)
 	}
-	defer resp.Body.Close()
+	defer qrReader.Close()
 
 	var qrCodeBuffer bytes.Buffer
-	if _, err := io.Copy(&qrCodeBuffer, resp.Body); err != nil {
+	if _, err := io.Copy(&qrCodeBuffer, qrReader); err != nil {
 		return fmt.Errorf("failed to read QR code image: %w", err)
 	}
 
@@ -98,6 +94,12 @@ func GenerateAndUploadLabel(chemicalId string) error {
 	imgY := 8.0
 	pdf.ImageOptions("qrcode", imgX, imgY, imgWidth, imgHeight, false, imgOptions, 0, "")
 
+	// GHS signal word and pictogram codes above the QR code
+	if header := strings.TrimSpace(chemical.Hazards.SignalWord + " " + strings.Join(chemical.Hazards.Pictograms, " ")); header != "" {
+		pdf.SetFont("Arial", "B", 8)
+		pdf.Text((width-pdf.GetStringWidth(header))/2, 5, header)
+	}
+
 	pdf.SetFont("Arial", "B", 12)
 	text := chemID
 	textWidth := pdf.GetStringWidth(text)
@@ -110,21 +112,16 @@ func GenerateAndUploadLabel(chemicalId string) error {
 		return fmt.Errorf("failed to generate PDF: %w", err)
 	}
 
-	objectName := fmt.Sprintf("label/%s.pdf", chemID)
-	writer := storageClient.Bucket("chemtrack-deployment").Object(objectName).NewWriter(ctx)
-	if _, err := io.Copy(writer, &buf); err != nil {
+	if err := h.blobs.Put(ctx, labelKey(chemID), &buf, "application/pdf"); err != nil {
 		return fmt.Errorf("failed to upload PDF: %w", err)
 	}
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close writer: %w", err)
-	}
 
 	return nil
 }
 
 // GetLabel godoc
 // @Summary Retrieve a label PDF
-// @Description Fetches a label PDF from Google Cloud Storage for a given chemical ID
+// @Description Fetches a label PDF from file storage for a given chemical ID
 // @Tags Label
 // @Produce application/pdf
 // @Param chemicalIdNumber path string true "Chemical ID Number"
@@ -132,101 +129,42 @@ func GenerateAndUploadLabel(chemicalId string) error {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /label/{chemicalIdNumber} [get]
-func GetLabel(c *gin.Context) {
-	ctx := context.Background()
-
+func (h *Handler) GetLabel(c *gin.Context) {
 	// Get the chemicalIdNumber from the request parameter
 	chemicalIdNumber := c.Param("chemicalIdNumber")
-
-	// Define the bucket and object name
-	bucketName := "chemtrack-deployment" // Replace with your bucket name
-	objectName := "label/" + chemicalIdNumber + ".pdf"
-
-	// for testing early retrieval
-	if os.Getenv("ENVIRONMENT") == "test" {
-	    fmt.Println("Mock getting QR Label")
-	    c.Header("Content-Type", "application/pdf")
-	    c.Status(http.StatusOK)
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanViewSchool) {
 		return
 	}
 
-	// Create a new storage client
-	storageClient, err := storage.NewClient(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create storage client"})
-		return
-	}
-	defer storageClient.Close()
-
-	// Get the object from the bucket
-	labelObject := storageClient.Bucket(bucketName).Object(objectName)
-	reader, err := labelObject.NewReader(ctx)
-	if err != nil {
-		if err == storage.ErrObjectNotExist {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label from cloud storage"})
-		}
-		return
-	}
-	defer reader.Close()
-
-	// Set the content type to application/pdf and stream the label back to the client
-	c.Header("Content-Type", "application/pdf")
-	c.Status(http.StatusOK)
-	if _, err := io.Copy(c.Writer, reader); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream label PDF"})
-		return
-	}
+	// Stream the label back to the client
+	h.streamBlob(c, labelKey(chemicalIdNumber), "application/pdf", "Label not found")
 }
 
 // DeleteLabel godoc
 // @Summary Delete a label PDF
-// @Description Deletes a label PDF from Google Cloud Storage for a given chemical ID
+// @Description Deletes a label PDF from file storage for a given chemical ID
 // @Tags Label
 // @Param chemicalIdNumber path string true "Chemical ID Number"
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /label/{chemicalIdNumber} [delete]
-func DeleteLabel(c *gin.Context) {
+func (h *Handler) DeleteLabel(c *gin.Context) {
 	ctx := context.Background()
 
 	// Get the chemicalIdNumber from the request parameter
 	chemicalIdNumber := c.Param("chemicalIdNumber")
-
-	// Define the bucket and object name
-	bucketName := "chemtrack-deployment"
-	objectName := "label/" + chemicalIdNumber + ".pdf"
-
-	// for testing early retrieval
-	if os.Getenv("ENVIRONMENT") == "test" {
-	    fmt.Println("Mock deleting QR Label")
-	    // mock success res
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Label deleted successfully",
-		})
-		return
-	}
-
-	// Create a new storage client
-	storageClient, err := storage.NewClient(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create storage client"})
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanManageChemicals) {
 		return
 	}
-	defer storageClient.Close()
-
-	// Get the object from the bucket
-	labelObject := storageClient.Bucket(bucketName).Object(objectName)
 
 	// Attempt to delete the object
-	err = labelObject.Delete(ctx)
+	err := h.blobs.Delete(ctx, labelKey(chemicalIdNumber))
 	if err != nil {
-		if err == storage.ErrObjectNotExist {
+		if errors.Is(err, blobstore.ErrNotExist) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete label from cloud storage"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete label from storage"})
 		}
 		return
 	}
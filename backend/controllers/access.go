@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// requireUser returns the authenticated caller, responding with 401 when there is none
+func requireUser(c *gin.Context) (auth.Principal, bool) {
+	principal, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
+
+// denyAccess responds with 403 when the caller is not allowed to perform the action
+func denyAccess(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+}
+
+// userSubject builds the policy subject for a user
+func userSubject(user models.User) policy.Subject {
+	return policy.Subject{ID: user.ID, School: user.School, IsAdmin: user.IsAdmin, IsMaster: user.IsMaster}
+}
+
+// authorizeChemical loads the school of a chemical and checks it against the rule.
+// It responds with 404 or 403 and returns false when the request should stop.
+func (h *Handler) authorizeChemical(c *gin.Context, chemicalID string, allowed func(auth.Principal, string) bool) bool {
+	principal, ok := requireUser(c)
+	if !ok {
+		return false
+	}
+	// Masters can act on every chemical, so there is nothing to look up
+	if principal.Role == auth.RoleMaster {
+		return true
+	}
+	chemical, err := h.chemicals.Get(context.Background(), chemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return false
+	}
+	if !allowed(principal, chemical.School) {
+		denyAccess(c)
+		return false
+	}
+	return true
+}
+
+// authorizeUser loads a user and checks it against the rule.
+// It responds with 404 or 403 and returns false when the request should stop.
+func (h *Handler) authorizeUser(c *gin.Context, userID string, allowed func(auth.Principal, policy.Subject) bool) bool {
+	principal, ok := requireUser(c)
+	if !ok {
+		return false
+	}
+	if principal.Role == auth.RoleMaster {
+		return true
+	}
+	user, err := h.users.Get(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+	if !allowed(principal, userSubject(user)) {
+		denyAccess(c)
+		return false
+	}
+	return true
+}
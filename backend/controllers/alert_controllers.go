@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/alerts"
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// alertRulesSchool returns the school an alert rules request is about, the caller's own by
+// default, responding with 400 or 403 and returning false when the request should stop
+func alertRulesSchool(c *gin.Context, allowed func(auth.Principal, string) bool) (string, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return "", false
+	}
+	school := c.DefaultQuery("school", principal.School)
+	if school == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A school is required"})
+		return "", false
+	}
+	if !allowed(principal, school) {
+		denyAccess(c)
+		return "", false
+	}
+	return school, true
+}
+
+// GetAlertRules godoc
+// @Summary Get the alert rules of a school
+// @Description Get what the chemical monitor alerts a school's admins about: expired chemicals, lead times before expiration, low stock, missing or outdated safety data sheets and storage conflicts. Schools that have not set rules get the defaults, without updated_at.
+// @Tags alerts
+// @Produce json
+// @Param school query string false "School, the caller's own by default"
+// @Success 200 {object} models.AlertRules
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/rules [get]
+func (h *Handler) GetAlertRules(c *gin.Context) {
+	school, ok := alertRulesSchool(c, policy.CanViewSchool)
+	if !ok {
+		return
+	}
+	rules, err := alerts.RulesFor(context.Background(), h.alertRules, school)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the alert rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// UpdateAlertRules godoc
+// @Summary Set the alert rules of a school
+// @Description Replace what the chemical monitor alerts a school's admins about. Lead times are days before expiration, from 1 to 3650, and an expiring chemical is reported at the shortest one it has reached. low_stock_percent alerts chemicals with at most that share of their container left, and storage_conflicts is "warning" for every conflict, "blocked" for the dangerous ones only, or empty. Admins set the rules of their own school.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param school query string false "School, the caller's own by default"
+// @Param rules body models.AlertRules true "Alert rules; school, updated_by and updated_at are ignored"
+// @Success 200 {object} models.AlertRules
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/rules [put]
+func (h *Handler) UpdateAlertRules(c *gin.Context) {
+	school, ok := alertRulesSchool(c, policy.CanManageAlertRules)
+	if !ok {
+		return
+	}
+	var rules models.AlertRules
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	// Normalize names the setting that is out of range
+	if err := rules.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := requireUser(c)
+	now := time.Now().UTC()
+	rules.School, rules.UpdatedBy, rules.UpdatedAt = school, principal.UserID, &now
+
+	if err := h.alertRules.Put(context.Background(), rules); err != nil {
+		log.Printf("Failed to store the alert rules of %s: %v", school, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store the alert rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// ResetAlertRules godoc
+// @Summary Reset the alert rules of a school
+// @Description Drop a school's own alert rules so it is alerted about expired chemicals, those expiring within 180 days and low stock again. Admins reset the rules of their own school.
+// @Tags alerts
+// @Produce json
+// @Param school query string false "School, the caller's own by default"
+// @Success 200 {object} models.AlertRules
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/rules [delete]
+func (h *Handler) ResetAlertRules(c *gin.Context) {
+	school, ok := alertRulesSchool(c, policy.CanManageAlertRules)
+	if !ok {
+		return
+	}
+	if err := h.alertRules.Delete(context.Background(), school); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset the alert rules"})
+		return
+	}
+	c.JSON(http.StatusOK, models.DefaultAlertRules(school))
+}
+
+// alertStatuses returns the statuses asked for by the status query parameter: the active ones by
+// default, or every status for "all"
+func alertStatuses(status string) ([]models.AlertStatus, bool) {
+	switch models.AlertStatus(status) {
+	case "", "active":
+		return models.ActiveAlertStatuses, true
+	case "all":
+		return nil, true
+	case models.AlertOpen, models.AlertAcknowledged, models.AlertSnoozed, models.AlertResolved:
+		return []models.AlertStatus{models.AlertStatus(status)}, true
+	}
+	return nil, false
+}
+
+// GetAlerts godoc
+// @Summary List the alerts of a school
+// @Description List the stored alerts of a school, most severe first. The chemical monitor job evaluates the alert rules and brings the stored alerts up to date; masters run it at once with POST /api/v1/jobs/chemical_monitor/run. Alerts are open until an admin acknowledges, snoozes or resolves them, and resolve by themselves once their condition clears. Masters can list any school or every school at once.
+// @Tags alerts
+// @Produce json
+// @Param school query string false "School to check"
+// @Param status query string false "active (the default: open, acknowledged or snoozed), open, acknowledged, snoozed, resolved or all"
+// @Param kind query string false "Only alerts of this kind: expired, expiring, low_stock, sds_missing, sds_outdated or storage_conflict"
+// @Param severity query string false "Only alerts of this severity: info, warning or critical"
+// @Param chemical_id query string false "Only alerts about this chemical"
+// @Success 200 {array} models.Alert
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts [get]
+func (h *Handler) GetAlerts(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+	statuses, ok := alertStatuses(c.Query("status"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active, open, acknowledged, snoozed, resolved or all"})
+		return
+	}
+
+	// Only the chemical monitor job evaluates the rules and writes alerts, so lists never race it
+	found, err := h.alerts.List(ctx, repository.AlertFilter{
+		School:     school,
+		ChemicalID: c.Query("chemical_id"),
+		Statuses:   statuses,
+		Kind:       models.AlertKind(c.Query("kind")),
+		Severity:   models.AlertSeverity(c.Query("severity")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the alerts"})
+		return
+	}
+	if found == nil {
+		found = []models.Alert{}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		return a.Severity.Rank() > b.Severity.Rank()
+	})
+	c.JSON(http.StatusOK, found)
+}
+
+// GetAlert godoc
+// @Summary Get an alert
+// @Description Get a stored alert with its status and who acknowledged, snoozed or resolved it
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} models.Alert
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/v1/alerts/{id} [get]
+func (h *Handler) GetAlert(c *gin.Context) {
+	alert, ok := h.loadAlert(c, policy.CanViewSchool)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// AlertSnooze is the body of a request to snooze an alert
+type AlertSnooze struct {
+	Until string `json:"until" binding:"required" example:"2025-06-01"` // a date, or an RFC 3339 time
+}
+
+// AcknowledgeAlert godoc
+// @Summary Acknowledge an alert
+// @Description Record that an admin has seen an alert, ending any snooze. The monitor only sends it again if it escalates: its severity rises or an expiring chemical reaches a shorter lead time. Admins act on the alerts of their own school.
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} models.Alert
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/{id}/acknowledge [post]
+func (h *Handler) AcknowledgeAlert(c *gin.Context) {
+	h.actOnAlert(c, func(alert *models.Alert, by string, now time.Time) error {
+		return alert.Acknowledge(by, now)
+	})
+}
+
+// SnoozeAlert godoc
+// @Summary Snooze an alert
+// @Description Put an alert off until a date, or an RFC 3339 time. The monitor opens and sends it again then if its condition still holds, or earlier if it escalates. A date snoozes until the start of that day in UTC. Admins act on the alerts of their own school.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param snooze body AlertSnooze true "When the snooze ends"
+// @Success 200 {object} models.Alert
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/{id}/snooze [post]
+func (h *Handler) SnoozeAlert(c *gin.Context) {
+	var input AlertSnooze
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until is required"})
+		return
+	}
+	until, err := time.Parse(time.RFC3339, input.Until)
+	if err != nil {
+		date, dateErr := models.ParseDate(input.Until)
+		if dateErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be a date or an RFC 3339 time"})
+			return
+		}
+		until = date.Time
+	}
+	h.actOnAlert(c, func(alert *models.Alert, by string, now time.Time) error {
+		return alert.Snooze(by, until.UTC(), now)
+	})
+}
+
+// ResolveAlert godoc
+// @Summary Resolve an alert
+// @Description Close an alert an admin has dealt with. Should its condition still hold on the next check, it is raised and sent again as a new alert. Admins act on the alerts of their own school.
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} models.Alert
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/alerts/{id}/resolve [post]
+func (h *Handler) ResolveAlert(c *gin.Context) {
+	h.actOnAlert(c, func(alert *models.Alert, by string, now time.Time) error {
+		return alert.Resolve(by, now)
+	})
+}
+
+// loadAlert fetches the alert named in the path and checks the caller may act on its school. It
+// responds with 403 or 404 and returns false when the request should stop.
+func (h *Handler) loadAlert(c *gin.Context, allowed func(auth.Principal, string) bool) (models.Alert, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return models.Alert{}, false
+	}
+	alert, err := h.alerts.Get(context.Background(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return models.Alert{}, false
+	}
+	if !allowed(principal, alert.School) {
+		denyAccess(c)
+		return models.Alert{}, false
+	}
+	return alert, true
+}
+
+// actOnAlert applies an admin's action to the alert named in the path and stores it, responding
+// with the alert or the reason it could not be changed
+func (h *Handler) actOnAlert(c *gin.Context, act func(alert *models.Alert, by string, now time.Time) error) {
+	alert, ok := h.loadAlert(c, policy.CanManageAlerts)
+	if !ok {
+		return
+	}
+	principal, _ := requireUser(c)
+	switch err := act(&alert, principal.UserID, time.Now().UTC()); {
+	case errors.Is(err, models.ErrAlertResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrInvalidSnooze):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the alert"})
+		return
+	}
+	if err := h.alerts.Update(context.Background(), alert); err != nil {
+		log.Printf("Failed to store alert %s: %v", alert.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the alert"})
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
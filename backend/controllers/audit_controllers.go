@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// Audit trail pages hold this many entries unless the request asks for fewer
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// GetChemicalHistory godoc
+// @Summary Get the change history of a chemical
+// @Description Get every recorded create, update and delete of a chemical, newest first, with the changed fields. The history stays available after the chemical is deleted.
+// @Tags audit
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param limit query int false "Maximum number of entries, 50 by default and at most 500"
+// @Param before query string false "Only entries older than this RFC 3339 time, to page back through the history"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/history [get]
+func (h *Handler) GetChemicalHistory(c *gin.Context) {
+	chemicalID := c.Param("id")
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.EntityType = models.AuditChemical
+	filter.EntityID = chemicalID
+
+	entries, err := h.audit.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+
+	// A deleted chemical keeps its history, which then tells which school it belonged to
+	var school string
+	if chemical, err := h.chemicals.Get(ctx, chemicalID); err == nil {
+		school = chemical.School
+	} else if len(entries) > 0 {
+		school = entries[0].School
+	} else {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
+	}
+	if !policy.CanViewSchool(principal, school) {
+		denyAccess(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetAuditLog godoc
+// @Summary Get the recent activity of a school
+// @Description Get the recorded changes to the chemicals and users of a school, newest first. Admins see their own school; masters can ask for any school or every school at once.
+// @Tags audit
+// @Produce json
+// @Param school query string false "School to list activity for"
+// @Param entity_type query string false "Only changes to chemical or user records"
+// @Param limit query int false "Maximum number of entries, 50 by default and at most 500"
+// @Param before query string false "Only entries older than this RFC 3339 time, to page back through the activity"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/audit [get]
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil || !policy.CanViewAudit(principal, school) {
+		denyAccess(c)
+		return
+	}
+
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.School = school
+	filter.EntityType = c.Query("entity_type")
+
+	entries, err := h.audit.List(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// auditFilter reads the limit and before query parameters, responding with 400 when one is invalid
+func auditFilter(c *gin.Context) (repository.AuditFilter, bool) {
+	filter := repository.AuditFilter{Limit: defaultAuditLimit}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number from 1 to 500"})
+			return repository.AuditFilter{}, false
+		}
+		filter.Limit = n
+	}
+	if before := c.Query("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before time, expected RFC 3339 such as 2006-01-02T15:04:05Z"})
+			return repository.AuditFilter{}, false
+		}
+		filter.Before = t
+	}
+	return filter, true
+}
+
+// auditChemical records a change to a chemical made by the caller
+func (h *Handler) auditChemical(c *gin.Context, action models.AuditAction, before, after *models.Chemical) {
+	entry := chemicalAuditEntry(action, before, after)
+	principal, _ := middleware.CurrentUser(c)
+	entry.Actor = principal.UserID
+	h.writeAudit(c, entry)
+}
+
+// auditUser records a change to a user made by the caller
+func (h *Handler) auditUser(c *gin.Context, action models.AuditAction, before, after *models.User) {
+	entry := userAuditEntry(action, before, after)
+	// Sign up and password resets are anonymous, the user is acting on their own account
+	principal, ok := middleware.CurrentUser(c)
+	entry.Actor = principal.UserID
+	if !ok {
+		entry.Actor = entry.EntityID
+	}
+	h.writeAudit(c, entry)
+}
+
+// chemicalAuditEntry describes a change to a chemical. before is nil for creates and after is nil for purges.
+func chemicalAuditEntry(action models.AuditAction, before, after *models.Chemical) models.AuditEntry {
+	entry := models.AuditEntry{EntityType: models.AuditChemical, Action: action}
+	for _, record := range []*models.Chemical{before, after} {
+		if record != nil {
+			entry.EntityID, entry.School = record.ID, record.School
+		}
+	}
+	changes, err := models.Diff(before, after)
+	if err != nil {
+		log.Printf("Failed to compare chemical %s for the audit trail: %v", entry.EntityID, err)
+	}
+	entry.Changes = changes
+	return entry
+}
+
+// userAuditEntry describes a change to a user. before is nil for creates and after is nil for purges.
+// Password changes are recorded without the hashes.
+func userAuditEntry(action models.AuditAction, before, after *models.User) models.AuditEntry {
+	entry := models.AuditEntry{EntityType: models.AuditUser, Action: action}
+	for _, record := range []*models.User{before, after} {
+		if record != nil {
+			entry.EntityID, entry.School = record.ID, record.School
+		}
+	}
+	changes, err := models.Diff(before, after)
+	if err != nil {
+		log.Printf("Failed to compare user %s for the audit trail: %v", entry.EntityID, err)
+	}
+	switch {
+	case before == nil && after != nil && after.Password != "":
+		changes = append(changes, models.FieldChange{Field: "password", After: models.Redacted})
+	case before != nil && after != nil && before.Password != after.Password:
+		changes = append(changes, models.FieldChange{Field: "password", Before: models.Redacted, After: models.Redacted})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	entry.Changes = changes
+	return entry
+}
+
+// writeAudit stores an audit entry for a change made by a request
+func (h *Handler) writeAudit(c *gin.Context, entry models.AuditEntry) {
+	entry.RequestID = middleware.CurrentRequestID(c)
+	h.appendAudit(context.Background(), entry)
+}
+
+// appendAudit stores an audit entry. The change it describes is already saved, so a failure is
+// logged rather than failing the request or job. Updates that changed no visible field are skipped.
+func (h *Handler) appendAudit(ctx context.Context, entry models.AuditEntry) {
+	if entry.Action == models.AuditUpdate && len(entry.Changes) == 0 {
+		return
+	}
+	entry.CreatedAt = time.Now().UTC()
+	if err := h.audit.Append(ctx, &entry); err != nil {
+		log.Printf("Failed to write audit entry for %s %s (request %s): %v", entry.EntityType, entry.EntityID, entry.RequestID, err)
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshRequest carries a refresh token to rotate or revoke
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// principalFromUser builds the token principal for a user
+func principalFromUser(user models.User) auth.Principal {
+	return auth.Principal{UserID: user.ID, School: user.School, Role: auth.RoleFor(user.IsAdmin, user.IsMaster)}
+}
+
+// issueSession creates an access token and a new refresh token family for the user
+func (h *Handler) issueSession(ctx context.Context, user models.User) (gin.H, error) {
+	familyBytes := make([]byte, 16)
+	if _, err := rand.Read(familyBytes); err != nil {
+		return nil, err
+	}
+	return h.issueTokens(ctx, user, hex.EncodeToString(familyBytes))
+}
+
+// issueTokens signs an access token and stores a new refresh token in the given family
+func (h *Handler) issueTokens(ctx context.Context, user models.User, familyID string) (gin.H, error) {
+	accessToken, err := h.tokens.IssueAccessToken(principalFromUser(user))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := h.tokens.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = h.refreshTokens.Create(ctx, models.RefreshToken{
+		Hash:      refreshHash,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.tokens.RefreshTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(h.tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+// RefreshToken godoc
+// @Summary Rotate a refresh token
+// @Description Exchanges a valid refresh token for a new access token and refresh token. The old refresh token is revoked; presenting it again revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/auth/refresh [post]
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	ctx := context.Background()
+
+	// Revoke the presented token in the same step as reading it, so it can only be rotated once
+	old, err := h.refreshTokens.Consume(ctx, auth.HashToken(req.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if old.Revoked {
+		// A rotated token was presented again, so it may have been stolen: end the whole session
+		if err := h.refreshTokens.RevokeFamily(ctx, old.FamilyID); err != nil {
+			log.Println("Failed to revoke refresh tokens:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
+	}
+	if time.Now().After(old.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	// Load the user again so role or school changes are picked up by the new access token
+	user, err := h.users.Get(ctx, old.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	session, err := h.issueTokens(ctx, user, old.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// Logout godoc
+// @Summary Revoke a refresh token
+// @Description Revokes the given refresh token so it can no longer be used to obtain access tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/auth/logout [post]
+func (h *Handler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	principal, _ := middleware.CurrentUser(c)
+	ctx := context.Background()
+
+	token, err := h.refreshTokens.Get(ctx, auth.HashToken(req.RefreshToken))
+	if err != nil || token.UserID != principal.UserID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if err := h.refreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary Revoke every session of the caller
+// @Description Revokes all refresh tokens issued to the authenticated user, signing them out on every device
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /api/v1/auth/logout-all [post]
+func (h *Handler) LogoutAll(c *gin.Context) {
+	principal, _ := middleware.CurrentUser(c)
+	h.revokeUserSessions(context.Background(), principal.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// revokeUserSessions revokes every refresh token that belongs to a user
+func (h *Handler) revokeUserSessions(ctx context.Context, userID string) {
+	if err := h.refreshTokens.RevokeUser(ctx, userID); err != nil {
+		log.Println("Failed to revoke refresh tokens:", err)
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/gin-gonic/gin"
+)
+
+// Object keys for the files kept for chemicals and users
+func qrCodeKey(chemicalID string) string     { return "QRcodes/" + chemicalID + ".png" }
+func labelKey(chemicalID string) string      { return "label/" + chemicalID + ".pdf" }
+func sdsKey(chemicalID string) string        { return "sds/" + chemicalID + ".pdf" }
+func profilePictureKey(userID string) string { return "profile_pictures/" + userID + ".jpg" }
+
+// ServeBlob godoc
+// @Summary Download a stored file
+// @Description Streams a file from the configured file storage. This is the address behind the URLs handed out by the local storage backend.
+// @Tags files
+// @Produce octet-stream
+// @Param key path string true "Object key, e.g. sds/12345.pdf"
+// @Success 200 {file} file "File contents"
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /blobs/{key} [get]
+func (h *Handler) ServeBlob(c *gin.Context) {
+	ctx := context.Background()
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	info, err := h.blobs.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		}
+		return
+	}
+
+	reader, err := h.blobs.Get(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
+
+// streamBlob writes a stored object to the response, answering 404 with notFound when it is missing
+func (h *Handler) streamBlob(c *gin.Context, key, contentType, notFound string) {
+	reader, err := h.blobs.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file from storage"})
+		}
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
@@ -2,86 +2,147 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"strconv"
+	"strings"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 
-	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
 )
 
 // #TODO add the functionality of adding a PDF for sds @AggressiveGas
-// #TODO Currently Dates are held as strings, should be changed to date objects @AggressiveGas
 
-// Chemical represents the structure of a chemical
+// Chemical is the request body for adding or updating a chemical
 type Chemical struct {
-	ID             string `json:"id"`
-	QRcode         string `json:"qrcode"`
-	Name           string `json:"name"`
-	CAS            int    `json:"CAS"`
-	School         string `json:"school"`
-	PurchaseDate   string `json:"purchase_date"`
-	ExpirationDate string `json:"expiration_date"`
-	Status         string `json:"status"`
-	Quantity       string `json:"quantity"`
-	Room           string `json:"room"`
-	Cabinet        int    `json:"cabinet"`
-	Shelf          int    `json:"shelf"`
+	ID             string          `json:"id"`
+	QRcode         string          `json:"qrcode"`
+	Name           string          `json:"name"`
+	Synonyms       *[]string       `json:"synonyms"` // other names the chemical goes by, searched along with the name. On update it replaces the stored ones.
+	CAS            models.CASInput `json:"CAS" swaggertype:"string" example:"7647-14-5"` // with or without hyphens, or as a number
+	School         string          `json:"school"`
+	PurchaseDate   string          `json:"purchase_date" example:"2024-01-31"`   // ISO 8601 date or date-time
+	ExpirationDate string          `json:"expiration_date" example:"2026-01-31"` // ISO 8601 date or date-time, not before the purchase date
+	Status         string          `json:"status"`
+	LocationID     string          `json:"location_id"` // room, cabinet or shelf from /api/v1/locations, sets room, cabinet and shelf
+	Room           string          `json:"room"`
+	Cabinet        int             `json:"cabinet"`
+	Shelf          int             `json:"shelf"`
+
+	// Quantities are given as {"amount": 500, "unit": "mL"} or as "500 mL", in g, kg, mL, L or units
+	Quantity         models.Quantity `json:"quantity"` // a full container, sets container_size and remaining
+	ContainerSize    models.Quantity `json:"container_size"`
+	Remaining        models.Quantity `json:"remaining"`
+	ReorderThreshold models.Quantity `json:"reorder_threshold"` // stock is reported low at or below this amount
+
+	// GHS classification, validated against the reference table at /api/v1/ghs. On update it replaces the stored hazards.
+	Hazards *models.Hazards `json:"hazards"`
+	// Storage groups such as acid or base, on top of those implied by the hazard classes. Placements mixing incompatible groups on one shelf are refused or warned about.
+	StorageGroups *[]string `json:"storage_groups"`
+	// Free-form notes, searched along with the name
+	Notes *string `json:"notes"`
 }
 
 // AddChemical godoc
 // @Summary Add a new chemical
-// @Description Add a new chemical to the database
+// @Description Add a new chemical to the database. A shelf holding chemicals it must be kept apart from is refused with 409; lesser storage conflicts are returned as storage_warnings.
 // @Tags chemicals
 // @Accept json
 // @Produce json
 // @Param chemical body Chemical true "Chemical data"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/chemicals/ [post]
-func AddChemical(c *gin.Context) {
+func (h *Handler) AddChemical(c *gin.Context) {
 	var chemical Chemical
 
-	if err := c.ShouldBindJSON(&chemical); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	principal, ok := requireUser(c)
+	if !ok {
 		return
 	}
 
-	// Check the length of the CAS number
-	casStr := strconv.Itoa(chemical.CAS)
-	if len(casStr) < 5 || len(casStr) > 9 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "CAS number must be between 5 and 9 digits"})
+	if !bindChemical(c, &chemical) {
 		return
 	}
 
-	ctx := context.Background()
-	docRef, _, err := client.Collection("chemicals").Add(ctx, map[string]interface{}{
-		"name":            chemical.Name,
-		"CAS":             chemical.CAS,
-		"school":          chemical.School,
-		"purchase_date":   chemical.PurchaseDate,
-		"expiration_date": chemical.ExpirationDate,
-		"status":          chemical.Status,
-		"quantity":        chemical.Quantity,
-		"room":            chemical.Room,
-		"cabinet":         chemical.Cabinet,
-		"shelf":           chemical.Shelf,
-	})
+	// Chemicals are added to the caller's school unless a master picks another one
+	if chemical.School == "" {
+		chemical.School = principal.School
+	}
+	if !policy.CanManageChemicals(principal, chemical.School) {
+		denyAccess(c)
+		return
+	}
+
+	// Validate the check digit and store the hyphenated form
+	cas, err := models.ParseCAS(string(chemical.CAS))
+	if err == nil && cas == "" {
+		err = models.ErrInvalidCAS
+	}
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseDate, expirationDate, ok := parseChemicalDates(c, chemical)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	record := models.Chemical{
+		Name:           chemical.Name,
+		CAS:            cas,
+		School:         chemical.School,
+		PurchaseDate:   purchaseDate,
+		ExpirationDate: expirationDate,
+		Status:         chemical.Status,
+		Room:           chemical.Room,
+		Cabinet:        chemical.Cabinet,
+		Shelf:          chemical.Shelf,
+	}
+	applyDescription(chemical, &record)
+	if !checkDateOrder(c, record) {
+		return
+	}
+	if !applyHazards(c, chemical, &record) || !applyStorageGroups(c, chemical, &record) {
+		return
+	}
+	if !h.applyLocation(c, chemical, &record, nil) {
+		return
+	}
+	warnings, ok := h.checkStorage(c, record, nil)
+	if !ok {
+		return
+	}
+	// A new container is full unless told otherwise
+	if chemical.Remaining.IsZero() && chemical.Quantity.IsZero() {
+		chemical.Remaining = chemical.ContainerSize
+	}
+	if !applyQuantities(c, chemical, &record) {
+		return
+	}
+	if err := h.chemicals.Create(ctx, &record); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add chemical"})
 		return
 	}
-	chemical.ID = docRef.ID // Set the document ID as the chemical ID
+	h.auditChemical(c, models.AuditCreate, nil, &record)
 
 	// Generate a QR code for the chemical
-	GenerateQRCode(chemical.ID)
+	h.GenerateQRCode(record.ID)
 	// Creating the label upon chemical creation
-	GenerateAndUploadLabel(chemical.ID)
+	h.GenerateAndUploadLabel(record.ID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Chemical added successfully", "chemical": chemical})
+	response := gin.H{"message": "Chemical added successfully", "chemical": record}
+	if len(warnings) > 0 {
+		response["storage_warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // GetChemical godoc
@@ -90,61 +151,97 @@ func AddChemical(c *gin.Context) {
 // @Tags chemicals
 // @Produce json
 // @Param id path string true "Chemical ID"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.Chemical
+// @Failure 403 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /api/v1/chemicals/{id} [get]
-func GetChemical(c *gin.Context) {
+func (h *Handler) GetChemical(c *gin.Context) {
 	chemicalID := c.Param("id")
 	ctx := context.Background()
 
-	doc, err := client.Collection("chemicals").Doc(chemicalID).Get(ctx)
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	chemical, err := h.chemicals.Get(ctx, chemicalID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
 		return
 	}
 
-	chemical := doc.Data()
-	chemical["id"] = doc.Ref.ID // Add the document ID to the chemical data
+	if !policy.CanViewSchool(principal, chemical.School) {
+		denyAccess(c)
+		return
+	}
 
 	c.JSON(http.StatusOK, chemical)
 }
 
 // GetChemicals godoc
 // @Summary Get all chemicals
-// @Description Get a list of all chemicals. Can query by school to filter chemicals from a specific school.
+// @Description Get a list of all chemicals. Can query by school to filter chemicals from a specific school. Only masters can list other schools or every school at once.
 // @Tags chemicals
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param school query string false "School to list chemicals for"
+// @Param location_id query string false "Only chemicals kept directly in this location"
+// @Param hazard_class query string false "Only chemicals of this GHS hazard class, such as flammable_liquid"
+// @Param hazard_statement query string false "Only chemicals with this H-statement, such as H225"
+// @Param pictogram query string false "Only chemicals with this GHS pictogram, such as GHS02"
+// @Param signal_word query string false "Only chemicals with this signal word, Danger or Warning"
+// @Param status query string false "Only chemicals in this condition, such as Good"
+// @Param room query string false "Only chemicals kept in this room"
+// @Param expiring_before query string false "Only chemicals expiring before this date (YYYY-MM-DD)"
+// @Param low_stock query bool false "Only chemicals at or below their reorder threshold"
+// @Param sort query string false "name, expiration_date, CAS or location, with a leading - for descending order"
+// @Param limit query int false "Chemicals per page, at most 500. Without limit or cursor every chemical is returned"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} models.Chemical
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/chemicals/ [get]
-func GetChemicals(c *gin.Context) {
+func (h *Handler) GetChemicals(c *gin.Context) {
 	ctx := context.Background()
-	school := c.DefaultQuery("school", "")
 
-	var iter *firestore.DocumentIterator
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
 
-	if school != "" {
-		// If school is provided, filter the chemicals by school
-		iter = client.Collection("chemicals").Where("school", "==", school).Documents(ctx)
-	} else {
-		// If no school is provided, get all chemicals
-		iter = client.Collection("chemicals").Documents(ctx)
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
 	}
 
-	var chemicals []map[string]interface{}
+	filter, ok := chemicalListFilter(c)
+	if !ok {
+		return
+	}
+	limit, cursor, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter.School = school
+	filter.LocationID = c.Query("location_id")
+	filter.After = cursor
+	if limit > 0 {
+		// One more than the page tells whether there is a next page
+		filter.Limit = limit + 1
+	}
 
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chemicals"})
-			return
-		}
-		chemical := doc.Data()
-		chemical["id"] = doc.Ref.ID // Add the document ID to the chemical data
-		chemicals = append(chemicals, chemical)
+	// An empty school lists the chemicals of every school
+	chemicals, err := h.chemicals.List(ctx, filter)
+	if err != nil {
+		listPageError(c, err, "Failed to fetch chemicals")
+		return
+	}
+	if limit > 0 && len(chemicals) > limit {
+		chemicals = chemicals[:limit]
+		c.Header(HeaderNextCursor, repository.ChemicalCursor(filter, chemicals[limit-1]))
 	}
 
 	c.JSON(http.StatusOK, chemicals)
@@ -152,7 +249,7 @@ func GetChemicals(c *gin.Context) {
 
 // UpdateChemical godoc
 // @Summary Update a chemical by ID
-// @Description Update a specific chemical by its ID
+// @Description Update a specific chemical by its ID. Its school cannot be changed here, chemicals move to another school through a transfer. Moving it to a shelf holding chemicals it must be kept apart from is refused with 409; lesser storage conflicts are returned as storage_warnings.
 // @Tags chemicals
 // @Accept json
 // @Produce json
@@ -160,80 +257,259 @@ func GetChemicals(c *gin.Context) {
 // @Param chemical body Chemical true "Chemical data"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/chemicals/{id} [put]
-func UpdateChemical(c *gin.Context) {
+func (h *Handler) UpdateChemical(c *gin.Context) {
 	chemicalID := c.Param("id")
 	var chemical Chemical
 
-	if err := c.ShouldBindJSON(&chemical); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !h.authorizeChemical(c, chemicalID, policy.CanManageChemicals) {
+		return
+	}
+
+	if !bindChemical(c, &chemical) {
+		return
+	}
+
+	purchaseDate, expirationDate, ok := parseChemicalDates(c, chemical)
+	if !ok {
 		return
 	}
 
 	ctx := context.Background()
-	updateData := map[string]interface{}{}
-	if chemical.Name != "" {
-		updateData["name"] = chemical.Name
+	record, err := h.chemicals.Get(ctx, chemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
 	}
-	if chemical.CAS != 0 {
-		updateData["CAS"] = chemical.CAS
+	before := record
+
+	// Another school has to agree to take a chemical, so it moves through a transfer
+	if chemical.School != "" && chemical.School != record.School {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chemicals move to another school through a transfer"})
+		return
 	}
-	if chemical.School != "" {
-		updateData["school"] = chemical.School
+
+	// Only the fields present in the request are changed
+	if chemical.Name != "" {
+		record.Name = chemical.Name
 	}
-	if chemical.PurchaseDate != "" {
-		updateData["purchase_date"] = chemical.PurchaseDate
+	if chemical.CAS != "" {
+		cas, err := models.ParseCAS(string(chemical.CAS))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		record.CAS = cas
 	}
-	if chemical.ExpirationDate != "" {
-		updateData["expiration_date"] = chemical.ExpirationDate
+	if !purchaseDate.IsZero() {
+		record.PurchaseDate = purchaseDate
 	}
-	if chemical.Status != "" {
-		updateData["status"] = chemical.Status
+	if !expirationDate.IsZero() {
+		record.ExpirationDate = expirationDate
 	}
-	if chemical.Quantity != "" {
-		updateData["quantity"] = chemical.Quantity
+	if chemical.Status != "" {
+		record.Status = chemical.Status
 	}
 	if chemical.Room != "" {
-		updateData["room"] = chemical.Room
+		record.Room = chemical.Room
 	}
 	if chemical.Cabinet != 0 {
-		updateData["cabinet"] = chemical.Cabinet
+		record.Cabinet = chemical.Cabinet
 	}
 	if chemical.Shelf != 0 {
-		updateData["shelf"] = chemical.Shelf
+		record.Shelf = chemical.Shelf
 	}
+	applyDescription(chemical, &record)
 
-	_, err := client.Collection("chemicals").Doc(chemicalID).Set(ctx, updateData, firestore.MergeAll)
-	if err != nil {
+	if !checkDateOrder(c, record) {
+		return
+	}
+	if !applyHazards(c, chemical, &record) || !applyStorageGroups(c, chemical, &record) {
+		return
+	}
+	if !applyQuantities(c, chemical, &record) {
+		return
+	}
+	if !h.applyLocation(c, chemical, &record, &before) {
+		return
+	}
+	warnings, ok := h.checkStorage(c, record, &before)
+	if !ok {
+		return
+	}
+
+	if err := h.chemicals.Update(ctx, record); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chemical"})
 		return
 	}
+	h.auditChemical(c, models.AuditUpdate, &before, &record)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Chemical updated successfully"})
+	response := gin.H{"message": "Chemical updated successfully"}
+	if len(warnings) > 0 {
+		response["storage_warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DeleteChemical godoc
-// @Summary Delete a chemical by ID
-// @Description Delete a specific chemical by its ID
+// @Summary Move a chemical to the trash
+// @Description Move a specific chemical to the trash. It disappears from the inventory but can be restored until it is purged, by hand or once the trash retention period has passed.
 // @Tags chemicals
 // @Produce json
 // @Param id path string true "Chemical ID"
 // @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/chemicals/{id} [delete]
-func DeleteChemical(c *gin.Context) {
+func (h *Handler) DeleteChemical(c *gin.Context) {
 	chemicalID := c.Param("id")
 	ctx := context.Background()
 
-	_, err := client.Collection("chemicals").Doc(chemicalID).Delete(ctx)
+	if !h.authorizeChemical(c, chemicalID, policy.CanManageChemicals) {
+		return
+	}
+
+	before, _ := h.chemicals.Get(ctx, chemicalID)
+	deletion := trashDeletion(c)
+	err := h.chemicals.Trash(ctx, chemicalID, deletion)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chemical"})
 		return
 	}
-
-	// Delete the QR code from Google Cloud Storage
-	helpers.DeleteFileFromGCS(ctx, "chemtrack-deployment", "QRcodes/"+chemicalID+".png")
+	// The QR code and label are kept until the chemical is purged
+	after := before
+	after.Deleted = &deletion
+	h.auditChemical(c, models.AuditDelete, &before, &after)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Chemical deleted successfully"})
 }
+
+// parseChemicalDates reads the ISO 8601 dates of a request, responding with 400 when one is invalid
+func parseChemicalDates(c *gin.Context, chemical Chemical) (models.Date, models.Date, bool) {
+	purchaseDate, err := models.ParseDate(chemical.PurchaseDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase date, expected an ISO 8601 date such as 2006-01-02"})
+		return models.Date{}, models.Date{}, false
+	}
+	expirationDate, err := models.ParseDate(chemical.ExpirationDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiration date, expected an ISO 8601 date such as 2006-01-02"})
+		return models.Date{}, models.Date{}, false
+	}
+	return purchaseDate, expirationDate, true
+}
+
+// errExpirationBeforePurchase is returned for a chemical that expires before it was purchased
+var errExpirationBeforePurchase = errors.New("Expiration date cannot be before the purchase date")
+
+// checkDateOrder rejects a chemical that expires before it was purchased, responding with 400
+func checkDateOrder(c *gin.Context, chemical models.Chemical) bool {
+	if err := dateOrder(chemical); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// dateOrder reports a chemical that expires before it was purchased
+func dateOrder(chemical models.Chemical) error {
+	if chemical.PurchaseDate.IsZero() || chemical.ExpirationDate.IsZero() {
+		return nil
+	}
+	if chemical.ExpirationDate.Before(chemical.PurchaseDate.Time) {
+		return errExpirationBeforePurchase
+	}
+	return nil
+}
+
+// applyDescription copies the synonyms and notes present in a request onto the record
+func applyDescription(chemical Chemical, record *models.Chemical) {
+	if chemical.Synonyms != nil {
+		record.Synonyms = models.NormalizeSynonyms(*chemical.Synonyms)
+	}
+	if chemical.Notes != nil {
+		record.Notes = strings.TrimSpace(*chemical.Notes)
+	}
+}
+
+// bindChemical reads a chemical request body, responding with 400 when it is malformed
+func bindChemical(c *gin.Context, chemical *Chemical) bool {
+	err := c.ShouldBindJSON(chemical)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, models.ErrInvalidQuantity) || errors.Is(err, models.ErrInvalidUnit) || errors.Is(err, models.ErrInvalidCAS) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	return false
+}
+
+// Quantities of a chemical that do not fit together
+var (
+	errRemainingUnit    = errors.New("Remaining amount and container size must use compatible units")
+	errRemainingTooMuch = errors.New("Remaining amount cannot exceed the container size")
+	errReorderUnit      = errors.New("Reorder threshold must use a unit compatible with the remaining amount")
+)
+
+// applyQuantities copies the quantities present in a request onto the record and checks that
+// they fit together, responding with 400 when they do not
+func applyQuantities(c *gin.Context, chemical Chemical, record *models.Chemical) bool {
+	if err := setQuantities(chemical, record); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// setQuantities copies the quantities present in a request onto the record and checks that they fit together
+func setQuantities(chemical Chemical, record *models.Chemical) error {
+	// The single quantity field describes a full container
+	if !chemical.Quantity.IsZero() {
+		if chemical.ContainerSize.IsZero() {
+			chemical.ContainerSize = chemical.Quantity
+		}
+		if chemical.Remaining.IsZero() {
+			chemical.Remaining = chemical.Quantity
+		}
+	}
+	if !chemical.ContainerSize.IsZero() {
+		record.ContainerSize = chemical.ContainerSize
+	}
+	if !chemical.Remaining.IsZero() {
+		record.Remaining = chemical.Remaining
+	}
+	if !chemical.ReorderThreshold.IsZero() {
+		record.ReorderThreshold = chemical.ReorderThreshold
+	}
+
+	if !record.Remaining.IsZero() && !record.ContainerSize.IsZero() {
+		cmp, err := record.Remaining.Compare(record.ContainerSize)
+		if err != nil {
+			return errRemainingUnit
+		}
+		if cmp > 0 {
+			return errRemainingTooMuch
+		}
+	}
+	if !record.ReorderThreshold.IsZero() {
+		reference := record.Remaining
+		if reference.IsZero() {
+			reference = record.ContainerSize
+		}
+		if _, err := reference.Compare(record.ReorderThreshold); !reference.IsZero() && err != nil {
+			return errReorderUnit
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// DisposalRequest is the request body for the steps of a chemical's disposal
+type DisposalRequest struct {
+	Reason         string `json:"reason" example:"Expired"`               // flagging: why the chemical is disposed of
+	Method         string `json:"method" example:"Lab pack"`              // approval, required: how it is disposed of
+	Vendor         string `json:"vendor" example:"Clean Harbors"`         // approval, required: waste vendor collecting it
+	ManifestNumber string `json:"manifest_number" example:"012345678JJK"` // completion, required: hazardous waste manifest number
+}
+
+// DisposalReport lists the chemicals of a school at a disposal step within a date range
+type DisposalReport struct {
+	School    string               `json:"school,omitempty"` // empty when the report covers every school
+	State     models.DisposalState `json:"state"`
+	From      string               `json:"from,omitempty"`
+	To        string               `json:"to,omitempty"`
+	Count     int                  `json:"count"`
+	ByMethod  map[string]int       `json:"by_method"` // number of chemicals per disposal method
+	Chemicals []models.Chemical    `json:"chemicals"` // oldest first
+}
+
+// FlagChemicalForDisposal godoc
+// @Summary Flag a chemical for disposal
+// @Description Mark an expired or unwanted chemical for disposal. Every user of the school can flag a chemical; an admin then approves it for pickup.
+// @Tags disposal
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param disposal body DisposalRequest false "Reason for the disposal"
+// @Success 200 {object} models.Chemical
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/disposal/flag [post]
+func (h *Handler) FlagChemicalForDisposal(c *gin.Context) {
+	h.advanceDisposal(c, policy.CanLogUsage, func(chemical *models.Chemical, req DisposalRequest, by string, now time.Time) error {
+		return chemical.FlagForDisposal(by, req.Reason, now)
+	})
+}
+
+// ApproveChemicalDisposal godoc
+// @Summary Approve the disposal of a chemical
+// @Description Approve a flagged chemical for pickup by a waste vendor, recording the disposal method, the vendor and the approving admin
+// @Tags disposal
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param disposal body DisposalRequest true "Disposal method and waste vendor"
+// @Success 200 {object} models.Chemical
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/disposal/approve [post]
+func (h *Handler) ApproveChemicalDisposal(c *gin.Context) {
+	h.advanceDisposal(c, policy.CanManageChemicals, func(chemical *models.Chemical, req DisposalRequest, by string, now time.Time) error {
+		if req.Method == "" || req.Vendor == "" {
+			return errMissingDisposalField
+		}
+		return chemical.ApproveDisposal(by, req.Method, req.Vendor, now)
+	})
+}
+
+// CompleteChemicalDisposal godoc
+// @Summary Record the pickup of a chemical for disposal
+// @Description Record that the waste vendor collected a chemical approved for disposal, with the hazardous waste manifest number. Nothing remains of a disposed chemical and no more usage can be logged for it.
+// @Tags disposal
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param disposal body DisposalRequest true "Manifest number"
+// @Success 200 {object} models.Chemical
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/disposal/complete [post]
+func (h *Handler) CompleteChemicalDisposal(c *gin.Context) {
+	h.advanceDisposal(c, policy.CanManageChemicals, func(chemical *models.Chemical, req DisposalRequest, by string, now time.Time) error {
+		if req.ManifestNumber == "" {
+			return errMissingDisposalField
+		}
+		return chemical.CompleteDisposal(req.ManifestNumber, now)
+	})
+}
+
+// CancelChemicalDisposal godoc
+// @Summary Cancel the disposal of a chemical
+// @Description Keep a chemical that was flagged or approved for disposal. Chemicals that were already collected cannot be taken back.
+// @Tags disposal
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Success 200 {object} models.Chemical
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/disposal [delete]
+func (h *Handler) CancelChemicalDisposal(c *gin.Context) {
+	h.advanceDisposal(c, policy.CanManageChemicals, func(chemical *models.Chemical, _ DisposalRequest, _ string, _ time.Time) error {
+		return chemical.CancelDisposal()
+	})
+}
+
+// GetDisposalReport godoc
+// @Summary Get the disposal report of a school
+// @Description Get the chemicals of a school that reached a disposal step within a date range, with a count per disposal method. Covers disposed chemicals unless another state is asked for. Admins see their own school; masters can ask for any school or every school at once.
+// @Tags disposal
+// @Produce json
+// @Param school query string false "School to report on"
+// @Param state query string false "Disposal step: flagged, pending_pickup or disposed (default)"
+// @Param from query string false "First day to include, such as 2024-01-01"
+// @Param to query string false "Last day to include, such as 2024-12-31"
+// @Success 200 {object} DisposalReport
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/disposals [get]
+func (h *Handler) GetDisposalReport(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil || !policy.CanManageChemicals(principal, school) {
+		denyAccess(c)
+		return
+	}
+
+	state := models.DisposalState(c.DefaultQuery("state", string(models.DisposalDisposed)))
+	switch state {
+	case models.DisposalFlagged, models.DisposalPendingPickup, models.DisposalDisposed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state, expected flagged, pending_pickup or disposed"})
+		return
+	}
+	from, to, ok := dateRange(c)
+	if !ok {
+		return
+	}
+
+	chemicals, err := h.chemicals.List(context.Background(), repository.ChemicalFilter{School: school, Disposal: state})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disposals"})
+		return
+	}
+
+	report := DisposalReport{School: school, State: state, From: c.Query("from"), To: c.Query("to"),
+		ByMethod: map[string]int{}, Chemicals: []models.Chemical{}}
+	for _, chemical := range chemicals {
+		since := chemical.Disposal.Since()
+		if since.Before(from) || !to.IsZero() && !since.Before(to) {
+			continue
+		}
+		report.Chemicals = append(report.Chemicals, chemical)
+		if chemical.Disposal.Method != "" {
+			report.ByMethod[chemical.Disposal.Method]++
+		}
+	}
+	sort.SliceStable(report.Chemicals, func(i, j int) bool {
+		return report.Chemicals[i].Disposal.Since().Before(report.Chemicals[j].Disposal.Since())
+	})
+	report.Count = len(report.Chemicals)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// errMissingDisposalField is returned by a disposal step when the request lacks a required field
+var errMissingDisposalField = errors.New("method and vendor are required to approve a disposal, and manifest_number to complete one")
+
+// advanceDisposal moves a chemical to another disposal step. It checks the caller against the
+// rule for the chemical's school, applies the step and saves and audits the result.
+func (h *Handler) advanceDisposal(c *gin.Context, allowed func(auth.Principal, string) bool,
+	step func(*models.Chemical, DisposalRequest, string, time.Time) error) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional for flagging and cancelling
+	var request DisposalRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	before, err := h.chemicals.Get(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
+	}
+	if !allowed(principal, before.School) {
+		denyAccess(c)
+		return
+	}
+
+	after := before
+	err = step(&after, request, principal.UserID, time.Now().UTC())
+	switch {
+	case errors.Is(err, errMissingDisposalField):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrDisposalState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disposal"})
+		return
+	}
+
+	if err := h.chemicals.Update(ctx, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disposal"})
+		return
+	}
+	h.auditChemical(c, models.AuditUpdate, &before, &after)
+
+	c.JSON(http.StatusOK, after)
+}
@@ -3,24 +3,35 @@ package controllers
 import (
 	"net/http"
 	"github.com/gin-gonic/gin"
-	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
 )
 
 type SendEmailRequest struct {
 	To      string `json:"to" binding:"required,email"`
 	Subject string `json:"subject" binding:"required"`
-	Body    string `json:"body" binding:"required"`
+	Body    string `json:"body" binding:"required"` // plain text, escaped in the HTML part of the email
 }
 
-func SendEmail(c *gin.Context) {
+func (h *Handler) SendEmail(c *gin.Context) {
 	var req SendEmailRequest
 
+	// Only admins and masters can send emails through the API
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	if !policy.CanSendEmail(principal) {
+		denyAccess(c)
+		return
+	}
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	if err := helpers.SendEmailHelper(req.To, req.Subject, req.Body); err != nil {
+	if err := h.mailer.Send(c.Request.Context(), mailer.Message{To: req.To, Subject: req.Subject, Text: req.Body}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to send email",
 			"details": err.Error(),
@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// exportPageSize is how many chemicals an export reads from the repository at a time
+const exportPageSize = 500
+
+// exportColumn is a column of an inventory export. Its header is the field name the import
+// reads it back under.
+type exportColumn struct {
+	header string
+	value  func(c models.Chemical, location string) interface{} // a string, int, bool or []string
+}
+
+var exportColumns = []exportColumn{
+	{"id", func(c models.Chemical, _ string) interface{} { return c.ID }},
+	{"name", func(c models.Chemical, _ string) interface{} { return c.Name }},
+	{"synonyms", func(c models.Chemical, _ string) interface{} { return exportList(c.Synonyms) }},
+	{"CAS", func(c models.Chemical, _ string) interface{} { return c.CAS }},
+	{"school", func(c models.Chemical, _ string) interface{} { return c.School }},
+	{"location", func(_ models.Chemical, location string) interface{} { return location }},
+	{"location_id", func(c models.Chemical, _ string) interface{} { return c.LocationID }},
+	{"room", func(c models.Chemical, _ string) interface{} { return c.Room }},
+	{"cabinet", func(c models.Chemical, _ string) interface{} { return c.Cabinet }},
+	{"shelf", func(c models.Chemical, _ string) interface{} { return c.Shelf }},
+	{"status", func(c models.Chemical, _ string) interface{} { return c.Status }},
+	{"remaining", func(c models.Chemical, _ string) interface{} { return c.Remaining.String() }},
+	{"container_size", func(c models.Chemical, _ string) interface{} { return c.ContainerSize.String() }},
+	{"reorder_threshold", func(c models.Chemical, _ string) interface{} { return c.ReorderThreshold.String() }},
+	{"low_stock", func(c models.Chemical, _ string) interface{} { return c.LowStock() }},
+	{"purchase_date", func(c models.Chemical, _ string) interface{} { return c.PurchaseDate.String() }},
+	{"expiration_date", func(c models.Chemical, _ string) interface{} { return c.ExpirationDate.String() }},
+	{"signal_word", func(c models.Chemical, _ string) interface{} { return c.Hazards.SignalWord }},
+	{"hazard_classes", func(c models.Chemical, _ string) interface{} { return exportList(c.Hazards.Classes) }},
+	{"hazard_statements", func(c models.Chemical, _ string) interface{} { return exportList(c.Hazards.HazardStatements) }},
+	{"precautionary_statements", func(c models.Chemical, _ string) interface{} { return exportList(c.Hazards.PrecautionaryStatements) }},
+	{"pictograms", func(c models.Chemical, _ string) interface{} { return exportList(c.Hazards.Pictograms) }},
+	{"storage_groups", func(c models.Chemical, _ string) interface{} { return exportList(c.EffectiveStorageGroups()) }},
+	{"sds_url", func(c models.Chemical, _ string) interface{} { return c.SDSURL }},
+	{"sds_date", func(c models.Chemical, _ string) interface{} { return c.SDSDate.String() }},
+	{"notes", func(c models.Chemical, _ string) interface{} { return c.Notes }},
+}
+
+// exportList returns the values of a list column, empty rather than nil so JSON exports hold []
+func exportList(values []string) []string {
+	return append([]string{}, values...)
+}
+
+// exportWriter writes the rows of an inventory export in one format
+type exportWriter interface {
+	row(c models.Chemical, location string) error
+	close() error
+}
+
+// ExportChemicals godoc
+// @Summary Export the chemical inventory
+// @Description Download the chemicals of a school, or of every school for masters, as a CSV, XLSX or JSON file for auditors and fire marshals. Each chemical comes with its location, quantities, dates, GHS hazards, storage groups and SDS link. The export takes the same filters and sort order as the chemical list, without paging, and its column names are the ones the import reads.
+// @Tags chemicals
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce json
+// @Param format query string false "csv (default), xlsx or json"
+// @Param school query string false "School to export, every school by default for masters and the caller's own for everyone else"
+// @Param location_id query string false "Only chemicals kept in this location"
+// @Param status query string false "Only chemicals with this status"
+// @Param room query string false "Only chemicals in this room"
+// @Param expiring_before query string false "Only chemicals expiring before this date, YYYY-MM-DD"
+// @Param low_stock query bool false "Only chemicals at or below their reorder threshold"
+// @Param sort query string false "name, expiration_date, CAS or location, with a leading minus for descending order"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/export [get]
+func (h *Handler) ExportChemicals(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	contentType, ok := map[string]string{
+		"csv":  "text/csv; charset=utf-8",
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"json": "application/json; charset=utf-8",
+	}[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv, xlsx or json"})
+		return
+	}
+
+	filter, ok := chemicalListFilter(c)
+	if !ok {
+		return
+	}
+	filter.School = school
+	filter.LocationID = c.Query("location_id")
+	filter.Limit = exportPageSize
+
+	locations, err := h.locationNames(ctx, school)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	// The first page is read before anything is sent, so a failure can still be answered with an error
+	page, err := h.chemicals.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chemicals"})
+		return
+	}
+
+	name := school
+	if name == "" {
+		name = "district"
+	}
+	filename := fmt.Sprintf("inventory-%s-%s.%s", headerKey(name), time.Now().Format("2006-01-02"), format)
+	// Nothing reaches the response until the writer is set up, so a setup error can still answer with a status
+	// The writers only write to the response once rows come, so setting one up can still fail with an error
+	var out exportWriter
+	switch format {
+	case "csv":
+		out = newCSVExport(c.Writer)
+	case "xlsx":
+		out, err = newXLSXExport(c.Writer)
+	case "json":
+		out = newJSONExport(c.Writer)
+	}
+	if err != nil {
+		log.Printf("Failed to start the %s export of %q: %v", format, school, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export the inventory"})
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	for err == nil {
+		for _, chemical := range page {
+			if err = out.row(chemical, locations[chemical.LocationID]); err != nil {
+				break
+			}
+		}
+		if err != nil || len(page) < exportPageSize {
+			break
+		}
+		filter.After = repository.ChemicalCursor(filter, page[len(page)-1])
+		page, err = h.chemicals.List(ctx, filter)
+	}
+	if err == nil {
+		err = out.close()
+	}
+	if err != nil {
+		// The response has started, so all that is left is to cut it short
+		log.Printf("Failed to export the inventory of %q: %v", school, err)
+		c.Abort()
+	}
+}
+
+// locationNames returns the full name of every location of a school, or of every school, by ID,
+// such as "Science Building / Prep Room / Cabinet 1 / Shelf 2"
+func (h *Handler) locationNames(ctx context.Context, school string) (map[string]string, error) {
+	locations, err := h.locations.List(ctx, repository.LocationFilter{School: school})
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]models.Location{}
+	for _, l := range locations {
+		byID[l.ID] = l
+	}
+	names := map[string]string{}
+	for _, l := range locations {
+		var parts []string
+		// Walking up stops at the school, which every location of a school shares
+		for at, ok := l, true; ok && at.Kind != models.LocationSchool && len(parts) < len(locations); at, ok = byID[at.ParentID] {
+			parts = append([]string{at.Name}, parts...)
+		}
+		names[l.ID] = strings.Join(parts, " / ")
+	}
+	return names, nil
+}
+
+// cellText formats a value for a spreadsheet cell, with list items separated by semicolons
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, "; ")
+	case int:
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvExport struct {
+	w    *csv.Writer
+	rows int
+}
+
+func newCSVExport(w io.Writer) *csvExport {
+	out := &csvExport{w: csv.NewWriter(w)}
+	header := make([]string, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column.header
+	}
+	out.w.Write(header)
+	return out
+}
+
+func (x *csvExport) row(c models.Chemical, location string) error {
+	record := make([]string, len(exportColumns))
+	for i, column := range exportColumns {
+		text := cellText(column.value(c, location))
+		// Spreadsheets run cells starting with these as formulas
+		if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+			text = "'" + text
+		}
+		record[i] = text
+	}
+	if err := x.w.Write(record); err != nil {
+		return err
+	}
+	// Flushing every page keeps large exports streaming
+	if x.rows++; x.rows%exportPageSize == 0 {
+		x.w.Flush()
+	}
+	return x.w.Error()
+}
+
+func (x *csvExport) close() error {
+	x.w.Flush()
+	return x.w.Error()
+}
+
+// xlsxExport builds the workbook with a stream writer, which keeps only the current row in memory,
+// and writes it out once complete since an XLSX file is a zip archive with its index at the end
+type xlsxExport struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func newXLSXExport(w io.Writer) (*xlsxExport, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName(file.GetSheetName(0), "Inventory"); err != nil {
+		return nil, err
+	}
+	stream, err := file.NewStreamWriter("Inventory")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column.header
+	}
+	if err := stream.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return nil, err
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+	return &xlsxExport{w: w, file: file, stream: stream, rows: 1}, nil
+}
+
+func (x *xlsxExport) row(c models.Chemical, location string) error {
+	cells := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		switch value := column.value(c, location).(type) {
+		case int:
+			if value != 0 {
+				cells[i] = value
+			}
+		case bool:
+			cells[i] = value
+		default:
+			cells[i] = cellText(value)
+		}
+	}
+	x.rows++
+	cell, err := excelize.CoordinatesToCellName(1, x.rows)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, cells)
+}
+
+func (x *xlsxExport) close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+// jsonExport writes an array of objects keyed by the column headers, in column order
+type jsonExport struct {
+	w    io.Writer
+	rows int
+}
+
+func newJSONExport(w io.Writer) *jsonExport {
+	return &jsonExport{w: w}
+}
+
+func (x *jsonExport) row(c models.Chemical, location string) error {
+	var b strings.Builder
+	if x.rows == 0 {
+		b.WriteString("[\n")
+	} else {
+		b.WriteString(",\n")
+	}
+	b.WriteByte('{')
+	for i, column := range exportColumns {
+		key, _ := json.Marshal(column.header)
+		value, err := json.Marshal(column.value(c, location))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	x.rows++
+	_, err := io.WriteString(x.w, b.String())
+	return err
+}
+
+func (x *jsonExport) close() error {
+	end := "\n]\n"
+	if x.rows == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(x.w, end)
+	return err
+}
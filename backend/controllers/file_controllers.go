@@ -2,47 +2,60 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"strings"
+	"errors"
 	"time"
 
-	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
 
 	"log"
 	"net/http"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
 // AddSDS godoc
 // @Summary Add a new SDS file
-// @Description Uploads a new SDS file to Google Cloud Storage and updates the Firestore document with the SDS URL- key is sds for file for data
+// @Description Uploads a new SDS file to file storage and updates the chemical record with the SDS URL- key is sds for file for data
 // @Tags SDS
 // @Accept multipart/form-data
 // @Produce json
 // @Param chemicalIdNumber path string true "Chemical ID Number"
 // @Param sds formData file true "SDS File"
+// @Param revision_date formData string false "Revision date printed on the SDS, today by default"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /sds/{chemicalIdNumber} [post]
-func AddSDS(c *gin.Context) {
+func (h *Handler) AddSDS(c *gin.Context) {
 	ctx := context.Background()
 
 	// Get chemicalIdNumber from the request parameter
 	chemicalIdNumber := c.Param("chemicalIdNumber")
 
-	// Check if the chemical exists in Firestore
-	doc, err := client.Collection("chemicals").Doc(chemicalIdNumber).Get(ctx)
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanManageChemicals) {
+		return
+	}
+
+	// Check if the chemical exists
+	chemical, err := h.chemicals.Get(ctx, chemicalIdNumber)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
 		return
 	}
 
-	chemID := doc.Ref.ID // Retrieve the actual document ID
+	chemID := chemical.ID
+
+	// The revision date tells the alert rules how old the sheet is
+	revised := models.NewDate(time.Now())
+	if raw := c.PostForm("revision_date"); raw != "" {
+		if revised, err = models.ParseDate(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision_date: " + err.Error()})
+			return
+		}
+	}
 
 	// Get the file from the request
 	file, err := c.FormFile("sds")
@@ -59,37 +72,24 @@ func AddSDS(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Skip actual uploading SDS for test env
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock Adding SDS")
-		c.JSON(http.StatusOK, gin.H{
-			"message": "SDS uploaded successfully",
-			"url":     "testingURL",
-		})
-		return
-	}
-
-	// Define GCS bucket and object name
-	bucketName := "chemtrack-deployment"
-	objectName := "sds/" + chemID + ".pdf"
-
 	// Upload the file directly from memory
-	uploadURL, err := helpers.UploadFileToGCSFromReader(ctx, bucketName, objectName, src)
-	if err != nil {
-		log.Println("Failed to upload SDS to Google Cloud Storage:", err)
+	objectName := sdsKey(chemID)
+	if err := h.blobs.Put(ctx, objectName, src, "application/pdf"); err != nil {
+		log.Println("Failed to upload SDS to storage:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload SDS"})
 		return
 	}
+	uploadURL := h.blobs.URL(objectName)
 
-	// Update Firestore document with the SDS URL
-	_, err = client.Collection("chemicals").Doc(chemID).Update(ctx, []firestore.Update{
-		{Path: "sdsURL", Value: uploadURL},
-	})
-	if err != nil {
-		log.Println("Failed to update Firestore:", err)
+	// Update the chemical record with the SDS URL
+	before := chemical
+	chemical.SDSURL, chemical.SDSDate = uploadURL, revised
+	if err := h.chemicals.Update(ctx, chemical); err != nil {
+		log.Println("Failed to update chemical record:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chemical record"})
 		return
 	}
+	h.auditChemical(c, models.AuditUpdate, &before, &chemical)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "SDS uploaded successfully",
@@ -106,20 +106,22 @@ func AddSDS(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /sds/{chemicalIdNumber} [get]
-func GetSDS(c *gin.Context) {
+func (h *Handler) GetSDS(c *gin.Context) {
 	ctx := context.Background()
 	chemicalIdNumber := c.Param("chemicalIdNumber")
 
-	// Fetch the document from Firestore
-	doc, err := client.Collection("chemicals").Doc(chemicalIdNumber).Get(ctx)
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanViewSchool) {
+		return
+	}
+
+	// Fetch the chemical record
+	chemical, err := h.chemicals.Get(ctx, chemicalIdNumber)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
 		return
 	}
 
-	// Extract the SDS URL from the document
-	sdsURL, exists := doc.Data()["sdsURL"]
-	if !exists {
+	if chemical.SDSURL == "" {
 		c.JSON(http.StatusNotFound, gin.H{"error": "SDS file not found for this chemical"})
 		return
 	}
@@ -127,13 +129,13 @@ func GetSDS(c *gin.Context) {
 	// Respond with the SDS URL
 	c.JSON(http.StatusOK, gin.H{
 		"chemicalIdNumber": chemicalIdNumber,
-		"sdsURL":           sdsURL,
+		"sdsURL":           chemical.SDSURL,
 	})
 }
 
 // DeleteSDS godoc
 // @Summary Delete the SDS file
-// @Description Deletes the SDS file from Google Cloud Storage and removes the SDS URL from the Firestore document
+// @Description Deletes the SDS file from file storage and removes the SDS URL from the chemical record
 // @Tags SDS
 // @Produce json
 // @Param chemicalIdNumber path string true "Chemical ID Number"
@@ -141,60 +143,41 @@ func GetSDS(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /sds/{chemicalIdNumber} [delete]
-func DeleteSDS(c *gin.Context) {
+func (h *Handler) DeleteSDS(c *gin.Context) {
 	ctx := context.Background()
 	chemicalIdNumber := c.Param("chemicalIdNumber")
 
-	// Fetch the document from Firestore
-	doc, err := client.Collection("chemicals").Doc(chemicalIdNumber).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanManageChemicals) {
 		return
 	}
 
-	// Extract the SDS URL from Firestore
-	sdsURL, exists := doc.Data()["sdsURL"]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "SDS file not found for this chemical"})
-		return
-	}
-
-	// Convert sdsURL to string
-	sdsURLStr, ok := sdsURL.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid SDS URL format"})
+	// Fetch the chemical record
+	chemical, err := h.chemicals.Get(ctx, chemicalIdNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
 		return
 	}
 
-	// Skip actual uploading SDS for test env
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock Deleting SDS")
-		c.JSON(http.StatusOK, gin.H{
-			"message": "SDS file deleted successfully",
-		})
+	if chemical.SDSURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SDS file not found for this chemical"})
 		return
 	}
 
-	// Extract the object name from the URL
-	// Example URL: https://storage.googleapis.com/chemtrack-deployment/sds/12345.pdf
-	objectName := sdsURLStr[strings.LastIndex(sdsURLStr, "sds/"):]
-
-	// Delete the file from Google Cloud Storage
-	bucketName := "chemtrack-deployment"
-	err = helpers.DeleteFileFromGCS(ctx, bucketName, objectName)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SDS file from GCS"})
+	// Delete the file from storage, an already missing file only needs the record cleaned up
+	err = h.blobs.Delete(ctx, sdsKey(chemicalIdNumber))
+	if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SDS file from storage"})
 		return
 	}
 
-	// Remove the SDS URL from Firestore
-	_, err = client.Collection("chemicals").Doc(chemicalIdNumber).Update(ctx, []firestore.Update{
-		{Path: "sdsURL", Value: nil}, // Remove the SDS URL
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Firestore record"})
+	// Remove the SDS URL from the chemical record
+	before := chemical
+	chemical.SDSURL = ""
+	if err := h.chemicals.Update(ctx, chemical); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chemical record"})
 		return
 	}
+	h.auditChemical(c, models.AuditUpdate, &before, &chemical)
 
 	// Respond with success
 	c.JSON(http.StatusOK, gin.H{
@@ -204,7 +187,7 @@ func DeleteSDS(c *gin.Context) {
 
 // AddProfilePicture godoc
 // @Summary Add a new profile picture
-// @Description Uploads a new profile picture to Google Cloud Storage and updates the Firestore document with the profile picture URL
+// @Description Uploads a new profile picture to file storage and updates the chemical record with the profile picture URL
 // @Tags Profile
 // @Accept multipart/form-data
 // @Produce json
@@ -215,20 +198,24 @@ func DeleteSDS(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /profile/{userId}/picture [post]
-func AddProfilePicture(c *gin.Context) {
+func (h *Handler) AddProfilePicture(c *gin.Context) {
 	ctx := context.Background()
 
 	// Get userId from the request parameter
 	userId := c.Param("userId")
 
-	// Check if the user exists in Firestore
-	doc, err := client.Collection("users").Doc(userId).Get(ctx)
+	if !h.authorizeUser(c, userId, policy.CanEditProfile) {
+		return
+	}
+
+	// Check if the user exists
+	user, err := h.users.Get(ctx, userId)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	userID := doc.Ref.ID // Retrieve the actual document ID
+	userID := user.ID
 
 	// Get the file from the request
 	file, err := c.FormFile("profilePicture")
@@ -245,50 +232,29 @@ func AddProfilePicture(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Skip actual upload for the profile picture for test env
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock Adding Profile picture")
-		// Update Firestore document with the profile picture URL
-		_, err = client.Collection("users").Doc(userID).Update(ctx, []firestore.Update{
-			{Path: "profilePictureURL", Value: "testURL"},
-		})
-		if err != nil {
-			log.Println("Failed to update Firestore:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user record"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Profile picture uploaded successfully",
-		})
-		return
-	}
-
-	// Define GCS bucket and object name
-	bucketName := "chemtrack-deployment"
-	objectName := "profile_pictures/" + userID + ".jpg"
-
 	// Upload the file directly from memory
-	uploadURL, err := helpers.UploadFileToGCSFromReader(ctx, bucketName, objectName, src)
-	if err != nil {
-		log.Println("Failed to upload profile picture to Google Cloud Storage:", err)
+	objectName := profilePictureKey(userID)
+	if err := h.blobs.Put(ctx, objectName, src, "image/jpeg"); err != nil {
+		log.Println("Failed to upload profile picture to storage:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload profile picture"})
 		return
 	}
+	uploadURL := h.blobs.URL(objectName)
 
 	// Append a timestamp to the URL to prevent caching
 	// Ensures image is up to date in the frontend
 	t := time.Now()
 	uploadURL += "?t=" + t.Format("20060102150405")
 
-	// Update Firestore document with the profile picture URL
-	_, err = client.Collection("users").Doc(userID).Update(ctx, []firestore.Update{
-		{Path: "profilePictureURL", Value: uploadURL},
-	})
-	if err != nil {
-		log.Println("Failed to update Firestore:", err)
+	// Update the user record with the profile picture URL
+	before := user
+	user.ProfilePictureURL = uploadURL
+	if err := h.users.Update(ctx, user); err != nil {
+		log.Println("Failed to update user record:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user record"})
 		return
 	}
+	h.auditUser(c, models.AuditUpdate, &before, &user)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Profile picture uploaded successfully",
@@ -309,20 +275,24 @@ func AddProfilePicture(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /profile/{userId}/picture [put]
-func UpdateProfilePicture(c *gin.Context) {
+func (h *Handler) UpdateProfilePicture(c *gin.Context) {
 	ctx := context.Background()
 
 	// Get userId from the request parameter
 	userId := c.Param("userId")
 
-	// Check if the user exists in Firestore
-	doc, err := client.Collection("users").Doc(userId).Get(ctx)
+	if !h.authorizeUser(c, userId, policy.CanEditProfile) {
+		return
+	}
+
+	// Check if the user exists
+	user, err := h.users.Get(ctx, userId)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	userID := doc.Ref.ID // Retrieve the actual document ID
+	userID := user.ID
 
 	// Get the file from the request
 	file, err := c.FormFile("profilePicture")
@@ -339,51 +309,29 @@ func UpdateProfilePicture(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Define GCS bucket and object name
-	bucketName := "chemtrack-deployment"
-	objectName := "profile_pictures/" + userID + ".jpg"
-
-	// Skip actual upload for the profile picture for test env
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock Updating Profile picture")
-		// Update Firestore document with the profile picture URL
-		_, err = client.Collection("users").Doc(userID).Update(ctx, []firestore.Update{
-			{Path: "profilePictureURL", Value: "testURL"},
-		})
-		if err != nil {
-			log.Println("Failed to update Firestore:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user record"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Profile picture updated successfully",
-			"url":     "testURL",
-		})
-		return
-	}
-
 	// Upload the file directly from memory
-	uploadURL, err := helpers.UploadFileToGCSFromReader(ctx, bucketName, objectName, src)
-	if err != nil {
-		log.Println("Failed to upload profile picture to Google Cloud Storage:", err)
+	objectName := profilePictureKey(userID)
+	if err := h.blobs.Put(ctx, objectName, src, "image/jpeg"); err != nil {
+		log.Println("Failed to upload profile picture to storage:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload profile picture"})
 		return
 	}
+	uploadURL := h.blobs.URL(objectName)
 
 	// Append a timestamp to the URL to prevent caching
 	// Ensures image is up to date in the frontend
 	t := time.Now()
 	uploadURL += "?t=" + t.Format("20060102150405")
 
-	// Update Firestore document with the profile picture URL
-	_, err = client.Collection("users").Doc(userID).Update(ctx, []firestore.Update{
-		{Path: "profilePictureURL", Value: uploadURL},
-	})
-	if err != nil {
-		log.Println("Failed to update Firestore:", err)
+	// Update the user record with the profile picture URL
+	before := user
+	user.ProfilePictureURL = uploadURL
+	if err := h.users.Update(ctx, user); err != nil {
+		log.Println("Failed to update user record:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user record"})
 		return
 	}
+	h.auditUser(c, models.AuditUpdate, &before, &user)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Profile picture updated successfully",
@@ -393,7 +341,7 @@ func UpdateProfilePicture(c *gin.Context) {
 
 // DeleteProfilePicture godoc
 // @Summary Delete the profile picture
-// @Description Deletes the profile picture from Google Cloud Storage and removes the profile picture URL from the Firestore document
+// @Description Deletes the profile picture from file storage and removes the profile picture URL from the user record
 // @Tags Profile
 // @Produce json
 // @Param userId path string true "User ID"
@@ -401,69 +349,41 @@ func UpdateProfilePicture(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /profile/{userId}/picture [delete]
-func DeleteProfilePicture(c *gin.Context) {
+func (h *Handler) DeleteProfilePicture(c *gin.Context) {
 	ctx := context.Background()
 	userId := c.Param("userId")
 
-	// Fetch the document from Firestore
-	doc, err := client.Collection("users").Doc(userId).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Extract the profile picture URL from Firestore
-	profilePictureURL, exists := doc.Data()["profilePictureURL"]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Profile picture not found for this user"})
+	if !h.authorizeUser(c, userId, policy.CanEditProfile) {
 		return
 	}
 
-	// Convert profilePictureURL to string
-	profilePictureURLStr, ok := profilePictureURL.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid profile picture URL format"})
+	// Fetch the user record
+	user, err := h.users.Get(ctx, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Skip actual delete for the profile picture for test env
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock Deleting Profile picture")
-		// Remove the profile picture URL from Firestore
-		_, err = client.Collection("users").Doc(userId).Update(ctx, []firestore.Update{
-			{Path: "profilePictureURL", Value: nil}, // Remove the profile picture URL
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Firestore record"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Profile picture deleted successfully",
-		})
+	if user.ProfilePictureURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile picture not found for this user"})
 		return
 	}
 
-	// Extract the object name from the URL
-	// Example URL: https://storage.googleapis.com/chemtrack-deployment/profile_pictures/12345.jpg
-	objectName := profilePictureURLStr[strings.LastIndex(profilePictureURLStr, "profile_pictures/"):]
-	objectName = strings.Split(objectName, "?t=")[0] // Remove the timestamp
-
-	// Delete the file from Google Cloud Storage
-	bucketName := "chemtrack-deployment"
-	err = helpers.DeleteFileFromGCS(ctx, bucketName, objectName)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete profile picture from GCS"})
+	// Delete the file from storage, an already missing file only needs the record cleaned up
+	err = h.blobs.Delete(ctx, profilePictureKey(userId))
+	if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete profile picture from storage"})
 		return
 	}
 
-	// Remove the profile picture URL from Firestore
-	_, err = client.Collection("users").Doc(userId).Update(ctx, []firestore.Update{
-		{Path: "profilePictureURL", Value: nil}, // Remove the profile picture URL
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Firestore record"})
+	// Remove the profile picture URL from the user record
+	before := user
+	user.ProfilePictureURL = ""
+	if err := h.users.Update(ctx, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user record"})
 		return
 	}
+	h.auditUser(c, models.AuditUpdate, &before, &user)
 
 	// Respond with success
 	c.JSON(http.StatusOK, gin.H{
@@ -480,20 +400,22 @@ func DeleteProfilePicture(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /profile/{userId}/picture [get]
-func GetProfilePicture(c *gin.Context) {
+func (h *Handler) GetProfilePicture(c *gin.Context) {
 	ctx := context.Background()
 	userId := c.Param("userId")
 
-	// Fetch the document from Firestore
-	doc, err := client.Collection("users").Doc(userId).Get(ctx)
+	if !h.authorizeUser(c, userId, policy.CanViewUser) {
+		return
+	}
+
+	// Fetch the user record
+	user, err := h.users.Get(ctx, userId)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Extract the profile picture URL from the document
-	profilePictureURL, exists := doc.Data()["profilePictureURL"]
-	if !exists {
+	if user.ProfilePictureURL == "" {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Profile picture not found for this user"})
 		return
 	}
@@ -501,6 +423,6 @@ func GetProfilePicture(c *gin.Context) {
 	// Respond with the profile picture URL
 	c.JSON(http.StatusOK, gin.H{
 		"userId":            userId,
-		"profilePictureURL": profilePictureURL,
+		"profilePictureURL": user.ProfilePictureURL,
 	})
 }
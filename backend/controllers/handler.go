@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/scheduler"
+)
+
+// Dependencies are the stores and services the handlers are built on
+type Dependencies struct {
+	Repositories repository.Repositories // chemical, user, session, usage, audit, location, transfer, job, alert rule and alert records
+	Tokens       *auth.TokenManager      // signs access tokens and creates refresh tokens
+	Blobs        blobstore.BlobStore     // QR codes, labels, SDS files and profile pictures
+	Jobs         *scheduler.Scheduler    // background jobs; the job routes answer 503 when nil
+	Templates    *notify.Renderer        // renders emails and push notifications; English when nil
+	Mailer       mailer.Mailer           // sends emails; they are only captured in memory when nil
+}
+
+// Handler serves the API. Every route is a method so its storage can be swapped, for example for in-memory repositories in tests.
+type Handler struct {
+	chemicals     repository.ChemicalRepository
+	users         repository.UserRepository
+	refreshTokens repository.RefreshTokenRepository
+	usage         repository.UsageRepository
+	audit         repository.AuditRepository
+	locations     repository.LocationRepository
+	transfers     repository.TransferRepository
+	alertRules    repository.AlertRuleRepository
+	alerts        repository.AlertRepository
+	tokens        *auth.TokenManager
+	blobs         blobstore.BlobStore
+	jobs          *scheduler.Scheduler
+	templates     *notify.Renderer
+	mailer        mailer.Mailer
+}
+
+// NewHandler creates the API handlers on top of the given dependencies
+func NewHandler(deps Dependencies) *Handler {
+	templates := deps.Templates
+	if templates == nil {
+		templates = notify.Default()
+	}
+	mail := deps.Mailer
+	if mail == nil {
+		mail = mailer.NewCapture(mailer.Sender{})
+	}
+	return &Handler{
+		chemicals:     deps.Repositories.Chemicals,
+		users:         deps.Repositories.Users,
+		refreshTokens: deps.Repositories.RefreshTokens,
+		usage:         deps.Repositories.Usage,
+		audit:         deps.Repositories.Audit,
+		locations:     deps.Repositories.Locations,
+		transfers:     deps.Repositories.Transfers,
+		alertRules:    deps.Repositories.AlertRules,
+		alerts:        deps.Repositories.Alerts,
+		tokens:        deps.Tokens,
+		blobs:         deps.Blobs,
+		jobs:          deps.Jobs,
+		templates:     templates,
+		mailer:        mail,
+	}
+}
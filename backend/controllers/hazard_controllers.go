@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// GetGHSReference godoc
+// @Summary Get the GHS reference table
+// @Description Get the GHS hazard classes, hazard and precautionary statements, pictograms and signal words that the hazard data of chemicals is validated against
+// @Tags chemicals
+// @Produce json
+// @Success 200 {object} models.GHSReference
+// @Router /api/v1/ghs [get]
+func (h *Handler) GetGHSReference(c *gin.Context) {
+	c.JSON(http.StatusOK, models.GHSTable())
+}
+
+// applyHazards validates the hazards present in a request and copies them onto the record,
+// responding with 400 when they use unknown codes
+func applyHazards(c *gin.Context, chemical Chemical, record *models.Chemical) bool {
+	if chemical.Hazards == nil {
+		return true
+	}
+	hazards, err := chemical.Hazards.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	record.Hazards = hazards
+	return true
+}
+
+// hazardFilter reads the hazard query parameters of a chemical search, responding with 400 when
+// they use unknown codes
+func hazardFilter(c *gin.Context) (repository.ChemicalFilter, bool) {
+	query := models.Hazards{SignalWord: c.Query("signal_word")}
+	if class := c.Query("hazard_class"); class != "" {
+		query.Classes = []string{class}
+	}
+	if statement := c.Query("hazard_statement"); statement != "" {
+		query.HazardStatements = []string{statement}
+	}
+	if pictogram := c.Query("pictogram"); pictogram != "" {
+		query.Pictograms = []string{pictogram}
+	}
+
+	hazards, err := query.Normalize()
+	if err == nil && len(hazards.HazardStatements) > 1 {
+		err = models.ErrInvalidHazard
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return repository.ChemicalFilter{}, false
+	}
+
+	var filter repository.ChemicalFilter
+	if len(hazards.Classes) > 0 {
+		filter.HazardClass = hazards.Classes[0]
+	}
+	if len(hazards.HazardStatements) > 0 {
+		filter.HazardStatement = hazards.HazardStatements[0]
+	}
+	if len(hazards.Pictograms) > 0 {
+		filter.Pictogram = hazards.Pictograms[0]
+	}
+	filter.SignalWord = hazards.SignalWord
+	return filter, true
+}
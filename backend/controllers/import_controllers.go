@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+const (
+	maxImportSize   = 10 << 20 // bytes
+	maxImportRows   = 5000
+	importBatchSize = 100 // chemicals stored per transaction
+)
+
+// importFields are the chemical fields an import column can fill, by the header names they are recognised
+// under. Headers are compared lower case with anything but letters and digits turned into underscores.
+var importFields = map[string][]string{
+	"name":                     {"name", "chemical", "chemical_name", "product", "product_name"},
+	"synonyms":                 {"synonyms", "synonym", "other_names", "aka"},
+	"CAS":                      {"cas", "cas_number", "cas_no", "cas_rn", "cas_registry_number"},
+	"purchase_date":            {"purchase_date", "purchased", "date_purchased", "received", "date_received"},
+	"expiration_date":          {"expiration_date", "expiration", "expiry", "expiry_date", "expires", "exp_date"},
+	"status":                   {"status", "condition"},
+	"location_id":              {"location_id"},
+	"room":                     {"room", "room_number", "lab"},
+	"cabinet":                  {"cabinet", "cabinet_number"},
+	"shelf":                    {"shelf", "shelf_number"},
+	"quantity":                 {"quantity", "amount", "size"},
+	"container_size":           {"container_size", "container"},
+	"remaining":                {"remaining", "remaining_amount", "amount_left"},
+	"reorder_threshold":        {"reorder_threshold", "reorder_at", "reorder_level", "minimum"},
+	"hazard_classes":           {"hazard_classes", "hazard_class", "ghs_classes"},
+	"hazard_statements":        {"hazard_statements", "h_statements", "h_codes"},
+	"precautionary_statements": {"precautionary_statements", "p_statements", "p_codes"},
+	"signal_word":              {"signal_word"},
+	"pictograms":               {"pictograms", "ghs_pictograms"},
+	"storage_groups":           {"storage_groups", "storage_group"},
+	"notes":                    {"notes", "comments", "comment", "remarks"},
+}
+
+// importAliases maps every recognised header to its field
+var importAliases = func() map[string]string {
+	aliases := map[string]string{}
+	for field, names := range importFields {
+		for _, name := range names {
+			aliases[name] = field
+		}
+	}
+	return aliases
+}()
+
+// ImportReport describes an import, or what an import would do on a dry run
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Columns  map[string]string `json:"columns"`         // header of each column read and the field it fills
+	Ignored  []string          `json:"ignored_columns"` // headers that fill no field
+	Rows     int               `json:"rows"`
+	Valid    int               `json:"valid"`
+	Invalid  int               `json:"invalid"`
+	Imported int               `json:"imported"`
+	Results  []ImportRow       `json:"results"`
+}
+
+// ImportRow is the outcome of one row of an import
+type ImportRow struct {
+	Row             int                      `json:"row"`                // row number in the file, the header being row 1
+	Chemical        *models.Chemical         `json:"chemical,omitempty"` // as it is or would be stored, absent when the row is invalid
+	Errors          []ImportProblem          `json:"errors,omitempty"`
+	StorageWarnings []models.StorageConflict `json:"storage_warnings,omitempty"`
+}
+
+// ImportProblem is a reason a row cannot be imported
+type ImportProblem struct {
+	Column string `json:"column"` // header of the column at fault, or the field when the file has no such column
+	Error  string `json:"error"`
+}
+
+// importColumn is a column of an import file that fills a field
+type importColumn struct {
+	index  int
+	header string
+	field  string
+}
+
+// ImportChemicals godoc
+// @Summary Import chemicals from a CSV or Excel file
+// @Description Add the chemicals listed in a CSV or XLSX file to a school, one per row below a header row. Columns are matched to chemical fields by their headers, such as name, CAS, expiration_date, room or quantity, or by an explicit mapping. Every row is checked like a chemical added by hand, including its CAS check digit, dates, location and storage compatibility. With dry_run nothing is stored and the report previews each row with its errors. Otherwise the chemicals are stored in batches and get their QR codes and labels; any invalid row stops the import unless skip_invalid is set.
+// @Tags chemicals
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file, at most 10 MB and 5000 rows"
+// @Param mapping formData string false "JSON object mapping file headers to chemical fields, such as {\"Bottle\": \"name\"}"
+// @Param school query string false "School to import into, the caller's own by default and required for masters without one"
+// @Param dry_run query bool false "Check the file and preview the import without storing anything"
+// @Param skip_invalid query bool false "Import the valid rows even when others are invalid"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/import [post]
+func (h *Handler) ImportChemicals(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	// Every row is stored in this school, so a master without one has to name it
+	school := strings.TrimSpace(c.DefaultQuery("school", principal.School))
+	if school == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A school is required"})
+		return
+	}
+	if !policy.CanManageChemicals(principal, school) {
+		denyAccess(c)
+		return
+	}
+	dryRun, err1 := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	skipInvalid, err2 := strconv.ParseBool(c.DefaultQuery("skip_invalid", "false"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run or skip_invalid, expected true or false"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file, upload it in the file field"})
+		return
+	}
+	if file.Size > maxImportSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file is larger than 10 MB"})
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the file"})
+		return
+	}
+	defer reader.Close()
+	rows, err := readImportRows(reader, file.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) > maxImportRows+1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The file has more than %d rows, split it up", maxImportRows)})
+		return
+	}
+
+	var mapping map[string]string
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping, expected a JSON object of headers to fields"})
+			return
+		}
+	}
+	report := ImportReport{DryRun: dryRun, Columns: map[string]string{}, Ignored: []string{}, Results: []ImportRow{}}
+	columns, err := mapImportColumns(rows[0], mapping, &report)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	check, err := h.newImportCheck(ctx, school)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the inventory to check the file against"})
+		return
+	}
+	for i, cells := range rows[1:] {
+		if blankRow(cells) {
+			continue
+		}
+		result := check.row(ctx, i+2, cells, columns)
+		report.Rows++
+		if result.Chemical != nil {
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"message": "Dry run, nothing was imported", "report": report})
+		return
+	}
+	if report.Invalid > 0 && !skipInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d rows are invalid and nothing was imported, fix them or set skip_invalid to import the rest", report.Invalid), "report": report})
+		return
+	}
+	if err := h.commitImport(c, &report); err != nil {
+		log.Printf("Failed to import chemicals into %s: %v", school, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to import chemicals, %d were imported before the failure", report.Imported), "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Imported %d chemicals", report.Imported), "report": report})
+}
+
+// readImportRows reads the rows of a CSV or XLSX file, told apart by the file name, header first
+func readImportRows(file io.Reader, name string) ([][]string, error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".txt":
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimPrefix(data, []byte("\ufeff"))
+		reader := csv.NewReader(bytes.NewReader(data))
+		// Spreadsheets saved in European locales separate fields with semicolons
+		header, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+			reader.Comma = ';'
+		}
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		if rows, err = reader.ReadAll(); err != nil {
+			return nil, fmt.Errorf("Invalid CSV file: %v", err)
+		}
+	case ".xlsx":
+		// Raw values keep dates as serial numbers rather than in the display format of the sheet
+		workbook, err := excelize.OpenReader(file, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, errors.New("Invalid XLSX file")
+		}
+		defer workbook.Close()
+		if rows, err = workbook.GetRows(workbook.GetSheetName(0), excelize.Options{RawCellValue: true}); err != nil {
+			return nil, errors.New("Invalid XLSX file")
+		}
+	default:
+		return nil, errors.New("Unsupported file type, expected a .csv or .xlsx file")
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("The file has no rows below its header")
+	}
+	return rows, nil
+}
+
+// mapImportColumns matches the header row to chemical fields, by the explicit mapping when it
+// names a header and by the recognised header names otherwise
+func mapImportColumns(header []string, mapping map[string]string, report *ImportReport) ([]importColumn, error) {
+	for _, field := range mapping {
+		if _, ok := importFields[field]; !ok {
+			return nil, fmt.Errorf("Unknown field %q in the mapping", field)
+		}
+	}
+	var columns []importColumn
+	filled := map[string]string{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		field, ok := mapping[name]
+		if !ok {
+			field, ok = importAliases[headerKey(name)]
+		}
+		if !ok || name == "" {
+			if name != "" {
+				report.Ignored = append(report.Ignored, name)
+			}
+			continue
+		}
+		if other, ok := filled[field]; ok {
+			return nil, fmt.Errorf("Columns %q and %q both fill %s", other, name, field)
+		}
+		filled[field] = name
+		report.Columns[name] = field
+		columns = append(columns, importColumn{index: i, header: name, field: field})
+	}
+	if filled["name"] == "" || filled["CAS"] == "" {
+		return nil, errors.New("The file needs a name and a CAS column")
+	}
+	return columns, nil
+}
+
+// headerKey is the form headers are compared in: lower case, with runs of anything but letters
+// and digits turned into one underscore
+func headerKey(header string) string {
+	words := strings.FieldsFunc(strings.ToLower(header), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "_")
+}
+
+func blankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// importCheck checks the rows of an import against the school's inventory and the rows before them
+type importCheck struct {
+	h          *Handler
+	school     string
+	neighbours []models.Chemical // chemicals on a shelf, to check storage compatibility against
+	counts     map[string]int    // chemicals in each location, to check capacity against
+}
+
+func (h *Handler) newImportCheck(ctx context.Context, school string) (*importCheck, error) {
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{School: school})
+	if err != nil {
+		return nil, err
+	}
+	counts, err := h.occupancy(ctx, school, "")
+	if err != nil {
+		return nil, err
+	}
+	return &importCheck{h: h, school: school, neighbours: stored(chemicals), counts: counts}, nil
+}
+
+// row checks one row of the file and returns the chemical it adds, or why it cannot be added.
+// Valid rows count towards the storage compatibility and capacity checks of the rows after them.
+func (x *importCheck) row(ctx context.Context, number int, cells []string, columns []importColumn) ImportRow {
+	result := ImportRow{Row: number}
+	values, headers := map[string]string{}, map[string]string{}
+	for _, column := range columns {
+		if column.index < len(cells) {
+			values[column.field] = strings.TrimSpace(cells[column.index])
+		}
+		headers[column.field] = column.header
+	}
+	fail := func(field string, err error) {
+		column := headers[field]
+		if column == "" {
+			column = field
+		}
+		result.Errors = append(result.Errors, ImportProblem{Column: column, Error: err.Error()})
+	}
+
+	record := models.Chemical{
+		Name:     values["name"],
+		Synonyms: models.NormalizeSynonyms(splitImportList(values["synonyms"], ";|")),
+		School:   x.school,
+		Status:   values["status"],
+		Room:     values["room"],
+		Notes:    values["notes"],
+	}
+	if record.Name == "" {
+		fail("name", errors.New("Name is required"))
+	}
+	cas, err := models.ParseCAS(values["CAS"])
+	if err == nil && cas == "" {
+		err = models.ErrInvalidCAS
+	}
+	if err != nil {
+		fail("CAS", err)
+	}
+	record.CAS = cas
+
+	for field, date := range map[string]*models.Date{"purchase_date": &record.PurchaseDate, "expiration_date": &record.ExpirationDate} {
+		if *date, err = parseImportDate(values[field]); err != nil {
+			fail(field, err)
+		}
+	}
+	if err := dateOrder(record); err != nil {
+		fail("expiration_date", err)
+	}
+	for field, number := range map[string]*int{"cabinet": &record.Cabinet, "shelf": &record.Shelf} {
+		if values[field] == "" {
+			continue
+		}
+		if *number, err = strconv.Atoi(values[field]); err != nil || *number < 0 {
+			fail(field, fmt.Errorf("Invalid %s, expected a whole number", field))
+		}
+	}
+
+	var request Chemical
+	quantities := map[string]*models.Quantity{"quantity": &request.Quantity, "container_size": &request.ContainerSize,
+		"remaining": &request.Remaining, "reorder_threshold": &request.ReorderThreshold}
+	quantitiesOK := true
+	for field, quantity := range quantities {
+		if values[field] == "" {
+			continue
+		}
+		if *quantity, err = models.ParseQuantity(values[field]); err != nil {
+			fail(field, err)
+			quantitiesOK = false
+		}
+	}
+	if quantitiesOK {
+		// A new container is full unless told otherwise
+		if request.Remaining.IsZero() && request.Quantity.IsZero() {
+			request.Remaining = request.ContainerSize
+		}
+		if err := setQuantities(request, &record); err != nil {
+			fail("remaining", err)
+		}
+	}
+
+	hazards, err := models.Hazards{
+		Classes:                 splitImportList(values["hazard_classes"], ",;|"),
+		HazardStatements:        splitImportList(values["hazard_statements"], ",;|"),
+		PrecautionaryStatements: splitImportList(values["precautionary_statements"], ",;|"),
+		SignalWord:              values["signal_word"],
+		Pictograms:              splitImportList(values["pictograms"], ",;|"),
+	}.Normalize()
+	if err != nil {
+		fail("hazards", err)
+	}
+	record.Hazards = hazards
+	if record.StorageGroups, err = models.NormalizeStorageGroups(splitImportList(values["storage_groups"], ",;|")); err != nil {
+		fail("storage_groups", err)
+	}
+	if len(result.Errors) > 0 {
+		return result
+	}
+
+	// Rows naming a room rather than a location are filed under it when they are stored, and the
+	// locations that do not exist yet are created then, so only named locations have a capacity to check
+	var path []models.Location
+	if id := values["location_id"]; id != "" {
+		if path, err = x.h.storagePath(ctx, id, x.school); err != nil {
+			fail("location_id", err)
+			return result
+		}
+		record.LocationID = id
+		record.Room, record.Cabinet, record.Shelf = placement(path)
+		for _, l := range path {
+			if l.Capacity > 0 && x.counts[l.ID] >= l.Capacity {
+				fail("location_id", fmt.Errorf("%s is full", l.Name))
+				return result
+			}
+		}
+	}
+
+	// Rows have no ID yet, so they are told apart by their row number
+	record.ID = "row-" + strconv.Itoa(number)
+	conflicts := record.StorageConflicts(x.neighbours)
+	result.StorageWarnings = conflicts
+	if models.HasBlockingConflict(conflicts) {
+		fail("room", errors.New("The chemical cannot be stored on this shelf next to incompatible chemicals"))
+		return result
+	}
+	if record.Room != "" {
+		x.neighbours = append(x.neighbours, record)
+	}
+	for _, l := range path {
+		x.counts[l.ID]++
+	}
+	record.ID = ""
+	result.Chemical = &record
+	return result
+}
+
+// splitImportList splits a cell holding several values at any of the separators
+func splitImportList(value, separators string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// importDateLayouts are the date formats spreadsheets commonly hold on top of ISO 8601
+var importDateLayouts = []string{"1/2/2006", "1/2/06"}
+
+// parseImportDate parses an ISO 8601 date, a US month/day/year date, or the serial number Excel keeps dates as
+func parseImportDate(value string) (models.Date, error) {
+	if date, err := models.ParseDate(value); err == nil {
+		return date, nil
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 && serial < 2958466 {
+		t, err := excelize.ExcelDateToTime(serial, false)
+		if err == nil {
+			return models.NewDate(t), nil
+		}
+	}
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return models.NewDate(t), nil
+		}
+	}
+	return models.Date{}, errors.New("Invalid date, expected a date such as 2006-01-02")
+}
+
+// commitImport stores the valid rows of an import in batches, filing them under their locations,
+// and then records them in the audit trail and generates their QR codes and labels
+func (h *Handler) commitImport(c *gin.Context, report *ImportReport) error {
+	ctx := context.Background()
+	var pending []*models.Chemical
+	for _, result := range report.Results {
+		if result.Chemical != nil {
+			pending = append(pending, result.Chemical)
+		}
+	}
+
+	for start := 0; start < len(pending); start += importBatchSize {
+		batch := pending[start:min(start+importBatchSize, len(pending))]
+		for _, record := range batch {
+			if record.LocationID != "" {
+				continue
+			}
+			id, err := repository.PlaceChemical(ctx, h.locations, *record)
+			if err != nil {
+				return err
+			}
+			record.LocationID = id
+		}
+		if err := h.chemicals.CreateMany(ctx, batch); err != nil {
+			for _, record := range batch {
+				record.ID = ""
+			}
+			return err
+		}
+		report.Imported += len(batch)
+
+		for _, record := range batch {
+			h.auditChemical(c, models.AuditCreate, nil, record)
+			h.GenerateQRCode(record.ID)
+			if err := h.GenerateAndUploadLabel(record.ID); err != nil {
+				log.Printf("Failed to create the label of imported chemical %s: %v", record.ID, err)
+			}
+		}
+	}
+	return nil
+}
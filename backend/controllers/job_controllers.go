@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/scheduler"
+)
+
+const (
+	defaultRunLimit = 20
+	maxRunLimit     = 200
+)
+
+// authorizeJobs checks that the caller is a master and the scheduler is running. It responds
+// with 403 or 503 and returns false when the request should stop.
+func (h *Handler) authorizeJobs(c *gin.Context) bool {
+	principal, ok := requireUser(c)
+	if !ok {
+		return false
+	}
+	if !policy.CanManageJobs(principal) {
+		denyAccess(c)
+		return false
+	}
+	if h.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Background jobs are not running on this server"})
+		return false
+	}
+	return true
+}
+
+// GetJobs godoc
+// @Summary List the background jobs
+// @Description List the background jobs, such as the chemical monitor and the trash purge, with their cron schedules and next run. Masters only.
+// @Tags jobs
+// @Produce json
+// @Success 200 {array} scheduler.JobInfo
+// @Failure 403 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/v1/jobs [get]
+func (h *Handler) GetJobs(c *gin.Context) {
+	if !h.authorizeJobs(c) {
+		return
+	}
+	c.JSON(http.StatusOK, h.jobs.Jobs())
+}
+
+// GetJobRuns godoc
+// @Summary Get the run history of a job
+// @Description Get the runs of a background job across every replica, newest first, with what triggered them, their status, duration and error. Masters only.
+// @Tags jobs
+// @Produce json
+// @Param name path string true "Job name"
+// @Param limit query int false "Maximum number of runs, 20 by default and at most 200"
+// @Success 200 {array} models.JobRun
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/v1/jobs/{name}/runs [get]
+func (h *Handler) GetJobRuns(c *gin.Context) {
+	ctx := context.Background()
+
+	if !h.authorizeJobs(c) {
+		return
+	}
+	name := c.Param("name")
+	if !h.jobs.Has(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	limit := defaultRunLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxRunLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number from 1 to 200"})
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.jobs.Runs(ctx, repository.JobRunFilter{Job: name, Limit: limit})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job runs"})
+		return
+	}
+	if runs == nil {
+		runs = []models.JobRun{}
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// RunJob godoc
+// @Summary Run a background job now
+// @Description Start a background job outside its schedule, for example to send the chemical alerts at once. The job runs in the background; its outcome appears in the run history. Masters only.
+// @Tags jobs
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 202 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/v1/jobs/{name}/run [post]
+func (h *Handler) RunJob(c *gin.Context) {
+	ctx := context.Background()
+
+	if !h.authorizeJobs(c) {
+		return
+	}
+	principal, _ := requireUser(c)
+
+	run, err := h.jobs.Trigger(ctx, c.Param("name"), principal.UserID)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "The job is already running"})
+		return
+	case errors.Is(err, scheduler.ErrStopped):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The server is shutting down"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job started", "run": run})
+}
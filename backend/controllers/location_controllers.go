@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// LocationRequest is the request body for creating or changing a location
+type LocationRequest struct {
+	ParentID   string              `json:"parent_id"`                         // creation only: the location it is in, empty for a school
+	Kind       models.LocationKind `json:"kind" example:"cabinet"`            // creation only: school, building, room, cabinet or shelf
+	Name       string              `json:"name" example:"Flammables cabinet"` // required on creation
+	Capacity   *int                `json:"capacity" example:"40"`             // most chemical containers it holds, 0 for no limit
+	Attributes *[]string           `json:"attributes" example:"ventilated"`   // such as ventilated or flammables_cabinet
+}
+
+// LocationDetails is a location with its place in the hierarchy and what it holds
+type LocationDetails struct {
+	models.Location
+	Path      []models.Location `json:"path"`      // the school down to the parent of the location
+	Children  []models.Location `json:"children"`  // the locations directly inside it
+	Chemicals int               `json:"chemicals"` // chemical containers kept in it or in the locations inside it
+}
+
+// CreateLocation godoc
+// @Summary Add a location
+// @Description Add a school, or a building, room, cabinet or shelf inside an existing location. Rooms go in a school or building, cabinets in a room and shelves in a cabinet. Admins manage the locations of their own school.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param location body LocationRequest true "Location"
+// @Success 200 {object} models.Location
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/locations [post]
+func (h *Handler) CreateLocation(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var request LocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || !request.Kind.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A name and a kind of school, building, room, cabinet or shelf are required"})
+		return
+	}
+
+	location := models.Location{Kind: request.Kind, Name: request.Name, Attributes: []string{}}
+	if request.Kind == models.LocationSchool {
+		if request.ParentID != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrLocationKind.Error()})
+			return
+		}
+		location.School = request.Name
+	} else {
+		parent, err := h.locations.Get(ctx, request.ParentID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent location not found"})
+			return
+		}
+		if !parent.Kind.CanHold(request.Kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrLocationKind.Error()})
+			return
+		}
+		location.ParentID, location.School = parent.ID, parent.School
+	}
+	if !policy.CanManageLocations(principal, location.School) {
+		denyAccess(c)
+		return
+	}
+	if !applyLocationRequest(c, request, &location) {
+		return
+	}
+	if !h.checkLocationName(c, location) {
+		return
+	}
+
+	if err := h.locations.Create(ctx, &location); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, location)
+}
+
+// GetLocations godoc
+// @Summary List locations
+// @Description List the locations of a school, optionally only those of one kind or directly inside one location. Masters can list any school or every school at once.
+// @Tags locations
+// @Produce json
+// @Param school query string false "School to list locations for"
+// @Param parent_id query string false "Only locations directly inside this one"
+// @Param kind query string false "Only locations of this kind: school, building, room, cabinet or shelf"
+// @Success 200 {array} models.Location
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/locations [get]
+func (h *Handler) GetLocations(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+	kind := models.LocationKind(c.Query("kind"))
+	if kind != "" && !kind.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kind, expected school, building, room, cabinet or shelf"})
+		return
+	}
+
+	locations, err := h.locations.List(context.Background(), repository.LocationFilter{School: school, ParentID: c.Query("parent_id"), Kind: kind})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	if locations == nil {
+		locations = []models.Location{}
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+// GetLocation godoc
+// @Summary Get a location
+// @Description Get a location with the locations above and directly inside it, and the number of chemical containers it holds
+// @Tags locations
+// @Produce json
+// @Param id path string true "Location ID"
+// @Success 200 {object} LocationDetails
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/locations/{id} [get]
+func (h *Handler) GetLocation(c *gin.Context) {
+	ctx := context.Background()
+
+	location, ok := h.authorizeLocation(c, c.Param("id"), policy.CanViewSchool)
+	if !ok {
+		return
+	}
+
+	path, err := repository.LocationPath(ctx, h.locations, location.ParentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return
+	}
+	children, err := h.locations.List(ctx, repository.LocationFilter{School: location.School, ParentID: location.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return
+	}
+	counts, err := h.occupancy(ctx, location.School, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return
+	}
+
+	details := LocationDetails{Location: location, Path: path, Children: children, Chemicals: counts[location.ID]}
+	if details.Path == nil {
+		details.Path = []models.Location{}
+	}
+	if details.Children == nil {
+		details.Children = []models.Location{}
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// UpdateLocation godoc
+// @Summary Change a location
+// @Description Rename a location or change its capacity or attributes. Chemicals inside a renamed room, cabinet or shelf follow it. Schools cannot be renamed, since users and chemicals refer to them by name.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param id path string true "Location ID"
+// @Param location body LocationRequest true "Fields to change"
+// @Success 200 {object} models.Location
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/locations/{id} [put]
+func (h *Handler) UpdateLocation(c *gin.Context) {
+	ctx := context.Background()
+
+	location, ok := h.authorizeLocation(c, c.Param("id"), policy.CanManageLocations)
+	if !ok {
+		return
+	}
+
+	var request LocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if request.ParentID != "" && request.ParentID != location.ParentID || request.Kind != "" && request.Kind != location.Kind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The kind and parent of a location cannot be changed"})
+		return
+	}
+
+	renamed := false
+	if name := strings.TrimSpace(request.Name); name != "" && name != location.Name {
+		if location.Kind == models.LocationSchool {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Schools cannot be renamed"})
+			return
+		}
+		location.Name, renamed = name, true
+		if !h.checkLocationName(c, location) {
+			return
+		}
+	}
+	if !applyLocationRequest(c, request, &location) {
+		return
+	}
+
+	if err := h.locations.Update(ctx, location); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
+		return
+	}
+	if renamed {
+		if err := h.refilePlacements(c, location); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move the chemicals of the location"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, location)
+}
+
+// DeleteLocation godoc
+// @Summary Delete a location
+// @Description Delete an empty location. Locations still holding other locations or chemicals cannot be deleted.
+// @Tags locations
+// @Produce json
+// @Param id path string true "Location ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/locations/{id} [delete]
+func (h *Handler) DeleteLocation(c *gin.Context) {
+	ctx := context.Background()
+
+	location, ok := h.authorizeLocation(c, c.Param("id"), policy.CanManageLocations)
+	if !ok {
+		return
+	}
+
+	children, err := h.locations.List(ctx, repository.LocationFilter{School: location.School, ParentID: location.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+		return
+	}
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{LocationID: location.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+		return
+	}
+	if len(children) > 0 || len(chemicals) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The location still holds other locations or chemicals"})
+		return
+	}
+
+	if err := h.locations.Delete(ctx, location.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Location deleted successfully"})
+}
+
+// authorizeLocation loads a location and checks its school against the rule.
+// It responds with 404 or 403 and returns false when the request should stop.
+func (h *Handler) authorizeLocation(c *gin.Context, id string, allowed func(auth.Principal, string) bool) (models.Location, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return models.Location{}, false
+	}
+	location, err := h.locations.Get(context.Background(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return models.Location{}, false
+	}
+	if !allowed(principal, location.School) {
+		denyAccess(c)
+		return models.Location{}, false
+	}
+	return location, true
+}
+
+// applyLocationRequest copies the capacity and attributes present in a request onto the location
+func applyLocationRequest(c *gin.Context, request LocationRequest, location *models.Location) bool {
+	if request.Capacity != nil {
+		if *request.Capacity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Capacity cannot be negative"})
+			return false
+		}
+		location.Capacity = *request.Capacity
+	}
+	if request.Attributes != nil {
+		location.Attributes = models.NormalizeAttributes(*request.Attributes)
+	}
+	return true
+}
+
+// checkLocationName responds with 409 when the parent already holds a location of the same
+// kind and name, counting names such as "Room 101" and "rm 101" as the same
+func (h *Handler) checkLocationName(c *gin.Context, location models.Location) bool {
+	existing, err := repository.FindLocation(context.Background(), h.locations, location.School, location.ParentID, location.Kind, location.Name)
+	switch {
+	case errors.Is(err, repository.ErrNotFound), err == nil && existing.ID == location.ID:
+		return true
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the location name"})
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "A location with this name already exists", "location": existing})
+	return false
+}
+
+// placement returns the room, cabinet and shelf fields of a chemical kept at the end of a
+// location path. Cabinets and shelves are numbered by the number in their name.
+func placement(path []models.Location) (room string, cabinet, shelf int) {
+	for _, l := range path {
+		number, _ := strconv.Atoi(models.LocationKey(l.Name))
+		switch l.Kind {
+		case models.LocationRoom:
+			room = l.Name
+		case models.LocationCabinet:
+			cabinet = number
+		case models.LocationShelf:
+			shelf = number
+		}
+	}
+	return room, cabinet, shelf
+}
+
+// applyLocation files a chemical under a location. A location ID in the request sets the
+// room, cabinet and shelf; otherwise they are filed under the matching location, which is
+// created when missing. It responds with 400 or 409 and returns false when the request should stop.
+func (h *Handler) applyLocation(c *gin.Context, chemical Chemical, record *models.Chemical, before *models.Chemical) bool {
+	ctx := context.Background()
+
+	if chemical.LocationID == "" {
+		unchanged := before != nil && before.LocationID != "" && before.School == record.School &&
+			before.Room == record.Room && before.Cabinet == record.Cabinet && before.Shelf == record.Shelf
+		if !unchanged {
+			id, err := repository.PlaceChemical(ctx, h.locations, *record)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file the chemical under its location"})
+				return false
+			}
+			record.LocationID = id
+		}
+	} else {
+		path, err := h.storagePath(ctx, chemical.LocationID, record.School)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		record.LocationID = path[len(path)-1].ID
+		record.Room, record.Cabinet, record.Shelf = placement(path)
+	}
+
+	if record.LocationID == "" || before != nil && before.LocationID == record.LocationID {
+		return true
+	}
+	return h.checkCapacity(c, *record)
+}
+
+// storagePath returns the path to a location a chemical of the school can be kept in, school first
+func (h *Handler) storagePath(ctx context.Context, id, school string) ([]models.Location, error) {
+	path, err := repository.LocationPath(ctx, h.locations, id)
+	if err != nil {
+		return nil, errors.New("Location not found")
+	}
+	location := path[len(path)-1]
+	if !location.Kind.Stores() {
+		return nil, errors.New("Chemicals are kept in a room, cabinet or shelf")
+	}
+	if location.School != school {
+		return nil, errors.New("The location belongs to another school")
+	}
+	return path, nil
+}
+
+// checkCapacity responds with 409 when a chemical does not fit in its location or in one of
+// the locations around it
+func (h *Handler) checkCapacity(c *gin.Context, record models.Chemical) bool {
+	ctx := context.Background()
+	path, err := repository.LocationPath(ctx, h.locations, record.LocationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the location capacity"})
+		return false
+	}
+	counts, err := h.occupancy(ctx, record.School, record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the location capacity"})
+		return false
+	}
+	for _, l := range path {
+		if l.Capacity > 0 && counts[l.ID] >= l.Capacity {
+			c.JSON(http.StatusConflict, gin.H{"error": "The location is full", "location": l})
+			return false
+		}
+	}
+	return true
+}
+
+// occupancy counts the chemical containers kept in each location of a school, including those
+// in the locations inside it. The chemical with the skipped ID is left out.
+func (h *Handler) occupancy(ctx context.Context, school, skip string) (map[string]int, error) {
+	locations, err := h.locations.List(ctx, repository.LocationFilter{School: school})
+	if err != nil {
+		return nil, err
+	}
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{School: school})
+	if err != nil {
+		return nil, err
+	}
+
+	parents := map[string]string{}
+	for _, l := range locations {
+		parents[l.ID] = l.ParentID
+	}
+	counts := map[string]int{}
+	for _, chemical := range chemicals {
+		if chemical.ID == skip || chemical.IsDisposed() {
+			continue
+		}
+		// The hierarchy is at most five levels deep
+		for id, depth := chemical.LocationID, 0; id != "" && depth < 5; id, depth = parents[id], depth+1 {
+			counts[id]++
+		}
+	}
+	return counts, nil
+}
+
+// refilePlacements updates the room, cabinet and shelf fields of the chemicals inside a renamed location
+func (h *Handler) refilePlacements(c *gin.Context, renamed models.Location) error {
+	ctx := context.Background()
+	locations, err := h.locations.List(ctx, repository.LocationFilter{School: renamed.School})
+	if err != nil {
+		return err
+	}
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{School: renamed.School})
+	if err != nil {
+		return err
+	}
+
+	byID := map[string]models.Location{}
+	for _, l := range locations {
+		byID[l.ID] = l
+	}
+	for _, chemical := range chemicals {
+		var path []models.Location
+		inside := false
+		for id := chemical.LocationID; id != "" && len(path) < 5; id = byID[id].ParentID {
+			path = append([]models.Location{byID[id]}, path...)
+			inside = inside || id == renamed.ID
+		}
+		if !inside {
+			continue
+		}
+		before := chemical
+		chemical.Room, chemical.Cabinet, chemical.Shelf = placement(path)
+		if chemical.Room == before.Room && chemical.Cabinet == before.Cabinet && chemical.Shelf == before.Shelf {
+			continue
+		}
+		if err := h.chemicals.Update(ctx, chemical); err != nil {
+			return err
+		}
+		h.auditChemical(c, models.AuditUpdate, &before, &chemical)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+)
+
+// NotificationTemplates lists the notification templates and the languages they render in
+type NotificationTemplates struct {
+	Templates     []string `json:"templates"`
+	Locales       []string `json:"locales"`
+	DefaultLocale string   `json:"default_locale"`
+}
+
+// GetNotificationTemplates godoc
+// @Summary List the notification templates
+// @Description List the templates of the emails and push notifications ChemTrack sends (alerts, digest, invitation, password_reset and transfer) and the languages they render in. Only admins and masters can list them.
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} NotificationTemplates
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/v1/notifications/templates [get]
+func (h *Handler) GetNotificationTemplates(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	if !policy.CanPreviewNotifications(principal) {
+		denyAccess(c)
+		return
+	}
+	c.JSON(http.StatusOK, NotificationTemplates{Templates: notify.Templates(), Locales: h.templates.Locales(), DefaultLocale: h.templates.Locale("")})
+}
+
+// PreviewNotificationTemplate godoc
+// @Summary Preview a notification template
+// @Description Render a template with sample data, as the subject, HTML and plain text email and push notification it sends. format=html or format=text returns only that part of the email, to open in a browser. Languages without a catalog fall back to the default one. Only admins and masters can preview templates.
+// @Tags notifications
+// @Produce json,html,plain
+// @Param name path string true "Template: alerts, digest, invitation, password_reset or transfer"
+// @Param locale query string false "Language, such as es; the default one when empty"
+// @Param format query string false "json (the default), html or text"
+// @Success 200 {object} notify.Message
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/notifications/templates/{name}/preview [get]
+func (h *Handler) PreviewNotificationTemplate(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	if !policy.CanPreviewNotifications(principal) {
+		denyAccess(c)
+		return
+	}
+	data, ok := notify.Sample(c.Param("name"), time.Now())
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "html" && format != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, html or text"})
+		return
+	}
+
+	msg, err := h.templates.Render(c.Query("locale"), data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render the template", "details": err.Error()})
+		return
+	}
+	switch format {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.String(http.StatusOK, msg.Text)
+	default:
+		c.JSON(http.StatusOK, msg)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderNextCursor carries the cursor of the next page of a listing, and is left out on the last page
+const HeaderNextCursor = "X-Next-Cursor"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// pageParams reads the limit and cursor query parameters, responding with 400 when the limit is
+// invalid. Without either the whole listing is returned, as it was before listings were paged;
+// a cursor without a limit gets the default page size.
+func pageParams(c *gin.Context) (limit int, cursor string, ok bool) {
+	cursor = c.Query("cursor")
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number from 1 to 500"})
+			return 0, "", false
+		}
+		return n, cursor, true
+	}
+	if cursor != "" {
+		return defaultPageSize, cursor, true
+	}
+	return 0, "", true
+}
+
+// chemicalListFilter reads the sorting and filtering query parameters of a chemical listing on top
+// of the hazard ones, responding with 400 when one is invalid
+func chemicalListFilter(c *gin.Context) (repository.ChemicalFilter, bool) {
+	filter, ok := hazardFilter(c)
+	if !ok {
+		return filter, false
+	}
+	filter.Status = c.Query("status")
+	filter.Room = c.Query("room")
+
+	// A leading minus sorts in descending order, as in sort=-expiration_date
+	sortBy := c.Query("sort")
+	filter.Descending = strings.HasPrefix(sortBy, "-")
+	filter.Sort = repository.ChemicalSort(strings.TrimPrefix(sortBy, "-"))
+	if !filter.Sort.Valid() || sortBy == "-" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected name, expiration_date, CAS or location"})
+		return filter, false
+	}
+
+	if raw := c.Query("expiring_before"); raw != "" {
+		date, err := models.ParseDate(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiring_before date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.ExpiringBefore = date.Time
+	}
+	if raw := c.Query("low_stock"); raw != "" {
+		lowStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid low_stock, expected true or false"})
+			return filter, false
+		}
+		filter.LowStock = lowStock
+	}
+	return filter, true
+}
+
+// listPageError responds to a failed listing, with 400 for a cursor that cannot be used
+func listPageError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor, start again from the first page"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
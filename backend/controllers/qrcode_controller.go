@@ -1,32 +1,22 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 
-	//"encoding/json"
-	//"fmt"
-	//"net/http"
-	"cloud.google.com/go/storage"
-	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
 	"github.com/gin-gonic/gin"
 	"github.com/skip2/go-qrcode"
 )
 
 // GenerateQRCode generates a QR code for a chemical
-func GenerateQRCode(chemicalIdNumber string) {
-	// Skip actual qr generation and uploading if its test cases
-	if os.Getenv("ENVIRONMENT") == "test" {
-		fmt.Println("Mock creating QR while adding chemical")
-		return
-	}
+func (h *Handler) GenerateQRCode(chemicalIdNumber string) {
 	ctx := context.Background()
 
 	// more of a check to verify the chemical exists
-	doc, err := client.Collection("chemicals").Doc(chemicalIdNumber).Get(ctx)
+	chemical, err := h.chemicals.Get(ctx, chemicalIdNumber)
 
 	if err != nil {
 		//c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
@@ -35,49 +25,27 @@ func GenerateQRCode(chemicalIdNumber string) {
 	}
 
 	// Get the chemical ID | seems redundant but we need to so the Document check above doesnt throw an cry errors
-	chemID := doc.Ref.ID
-
-	// Create the folder if it doesn't exist
-	qrCodeFolder := `qrcodes/`
-	err = os.MkdirAll(qrCodeFolder, os.ModePerm) // Create the folder with permissions if it doesn't exist
-	if err != nil {
-		fmt.Println("Failed to create QR code folder:", err)
-		return
-	}
-
-	filepath := qrCodeFolder + chemID + `.png` // creating a filepath for the QR code to be saved to based on their id
+	chemID := chemical.ID
 
-	// Generate the QR code
-	err = qrcode.WriteFile(chemID, qrcode.Medium, 256, filepath)
+	// Generate the QR code in memory
+	png, err := qrcode.Encode(chemID, qrcode.Medium, 256)
 	if err != nil {
 		//c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
 		fmt.Println("Failed to generate QR code")
 		return
 	}
 
-	// Upload the QR code to Google Cloud Storage
-	bucketName := "chemtrack-deployment"
-	objectName := "QRcodes/" + chemID + ".png"
-
-	err = helpers.UploadFileToGCS(ctx, bucketName, objectName, filepath)
+	// Upload the QR code to storage
+	err = h.blobs.Put(ctx, qrCodeKey(chemID), bytes.NewReader(png), "image/png")
 	if err != nil {
-		fmt.Println("Failed to upload QR code to Google Cloud Storage")
-		return
-	}
-
-	// Delete the QR code file
-	deletePath := qrCodeFolder + chemID + `.png`
-	fileDelete := os.Remove(deletePath)
-	if fileDelete != nil {
-		fmt.Println("Failed to delete QR code file")
+		fmt.Println("Failed to upload QR code to storage:", err)
 		return
 	}
-
 }
 
 // GetQRCode godoc
 // @Summary Retrieve a QR code
-// @Description Fetches a QR code image from Google Cloud Storage for a given chemical ID
+// @Description Fetches a QR code image from file storage for a given chemical ID
 // @Tags QRCode
 // @Produce image/png
 // @Param chemicalIdNumber path string true "Chemical ID Number"
@@ -85,44 +53,15 @@ func GenerateQRCode(chemicalIdNumber string) {
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /qrcode/{chemicalIdNumber} [get]
-func GetQRCode(c *gin.Context) {
-	ctx := context.Background()
-
+func (h *Handler) GetQRCode(c *gin.Context) {
 	// Get the chemicalIdNumber from the request parameter
 	chemicalIdNumber := c.Param("chemicalIdNumber")
-
-	// Define the bucket and object name
-	bucketName := "chemtrack-deployment" // Replace with your bucket name
-	objectName := "QRcodes/" + chemicalIdNumber + ".png"
-
-	// Create a new storage client
-	storageClient, err := storage.NewClient(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create storage client"})
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanViewSchool) {
 		return
 	}
-	defer storageClient.Close()
 
-	// Get the object from the bucket
-	qrObject := storageClient.Bucket(bucketName).Object(objectName)
-	reader, err := qrObject.NewReader(ctx)
-	if err != nil {
-		if err == storage.ErrObjectNotExist {
-			c.JSON(http.StatusNotFound, gin.H{"error": "QR code not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QR code from cloud storage"})
-		}
-		return
-	}
-	defer reader.Close()
-
-	// Set the content type to image/png and stream the QR code back to the client
-	c.Header("Content-Type", "image/png")
-	c.Status(http.StatusOK)
-	if _, err := io.Copy(c.Writer, reader); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream QR code"})
-		return
-	}
+	// Stream the QR code back to the client
+	h.streamBlob(c, qrCodeKey(chemicalIdNumber), "image/png", "QR code not found")
 }
 
 // GetQRCodeURL godoc
@@ -134,20 +73,21 @@ func GetQRCode(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /qrcode/{chemicalIdNumber} [get]
-func GetQRCodeURL(c *gin.Context) {
+func (h *Handler) GetQRCodeURL(c *gin.Context) {
 	chemicalIdNumber := c.Param("chemicalIdNumber")
+	if !h.authorizeChemical(c, chemicalIdNumber, policy.CanViewSchool) {
+		return
+	}
 
-	bucketName := "chemtrack-deployment"
-	objectName := "QRcodes/" + chemicalIdNumber + ".png"
-
-	doesQRExist := helpers.DoesFileExistGCS(context.Background(), bucketName, objectName)
+	objectName := qrCodeKey(chemicalIdNumber)
+	_, err := h.blobs.Stat(context.Background(), objectName)
 
 	// Empty string if QR code does not exist, otherwise the URL to the QR code
 	// Example URL: https://storage.googleapis.com/chemtrack-deployment/QRcodes/12345.png
 	QRCodeURL := ""
 
-	if doesQRExist {
-		QRCodeURL = fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucketName, objectName)
+	if err == nil {
+		QRCodeURL = h.blobs.URL(objectName)
 	}
 
 	// Respond with the QR Code URL
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/search"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchResult is a chemical found by a search, with how well it matched
+type SearchResult struct {
+	Chemical models.Chemical `json:"chemical"`
+	Score    float64         `json:"score"`   // higher is better, results come best first
+	Matched  []string        `json:"matched"` // fields the query matched in: name, synonyms, CAS, location or notes
+}
+
+// SearchChemicals godoc
+// @Summary Search chemicals
+// @Description Search the chemicals by name, synonyms, CAS number, location and notes. Every word of the query has to match; words may be partly typed or have a typo, and CAS numbers match with or without hyphens or in part. Results are ranked, matches in the name and CAS number first. Only masters can search other schools or every school at once.
+// @Tags chemicals
+// @Produce json
+// @Param q query string true "Search text, such as acetn or 64-17"
+// @Param school query string false "School to search, the caller's own by default"
+// @Param limit query int false "Maximum number of results, 20 by default and at most 100"
+// @Success 200 {array} SearchResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/search [get]
+func (h *Handler) SearchChemicals(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+
+	text := c.Query("q")
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing search query q"})
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number from 1 to 100"})
+			return
+		}
+		limit = n
+	}
+
+	// Search the chemicals as they are stored, so the results agree with the chemical list
+	// whichever replica answers
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{School: school})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chemicals"})
+		return
+	}
+	byID := make(map[string]models.Chemical, len(chemicals))
+	for _, chemical := range chemicals {
+		byID[chemical.ID] = chemical
+	}
+
+	results := []SearchResult{}
+	for _, hit := range search.Build(chemicals).Search(search.Query{Text: text, School: school, Limit: limit}) {
+		results = append(results, SearchResult{Chemical: byID[hit.ID], Score: hit.Score, Matched: hit.Fields})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// StorageLocation is a shelf holding chemicals that should not be stored together
+type StorageLocation struct {
+	School    string                   `json:"school"`
+	Room      string                   `json:"room"`
+	Cabinet   int                      `json:"cabinet"`
+	Shelf     int                      `json:"shelf"`
+	Conflicts []models.StorageConflict `json:"conflicts"`
+}
+
+// StorageReport lists the shelves of a school holding incompatible chemicals
+type StorageReport struct {
+	School    string            `json:"school,omitempty"` // empty when the report covers every school
+	Count     int               `json:"count"`            // number of conflicting pairs of chemicals
+	Blocked   int               `json:"blocked"`          // number of those pairs that must be separated
+	Locations []StorageLocation `json:"locations"`
+}
+
+// GetStorageConflicts godoc
+// @Summary Scan a school for incompatible storage
+// @Description List every shelf of a school holding chemicals from incompatible storage groups, such as an oxidizer next to a flammable. Masters can scan any school or every school at once.
+// @Tags chemicals
+// @Produce json
+// @Param school query string false "School to scan"
+// @Success 200 {object} StorageReport
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/storage/conflicts [get]
+func (h *Handler) GetStorageConflicts(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+
+	chemicals, err := h.chemicals.List(context.Background(), repository.ChemicalFilter{School: school})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chemicals"})
+		return
+	}
+
+	// Check each pair on a shelf once
+	report := StorageReport{School: school, Locations: []StorageLocation{}}
+	for _, chemicals := range models.ShelfGroups(chemicals) {
+		var conflicts []models.StorageConflict
+		for i, chemical := range chemicals {
+			conflicts = append(conflicts, chemical.StorageConflicts(chemicals[i+1:])...)
+		}
+		if len(conflicts) == 0 {
+			continue
+		}
+		for _, conflict := range conflicts {
+			if conflict.Severity == models.StorageBlocked {
+				report.Blocked++
+			}
+		}
+		report.Count += len(conflicts)
+		first := chemicals[0]
+		report.Locations = append(report.Locations, StorageLocation{School: first.School, Room: first.Room,
+			Cabinet: first.Cabinet, Shelf: first.Shelf, Conflicts: conflicts})
+	}
+	sort.Slice(report.Locations, func(i, j int) bool {
+		a, b := report.Locations[i], report.Locations[j]
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		if a.Room != b.Room {
+			return a.Room < b.Room
+		}
+		if a.Cabinet != b.Cabinet {
+			return a.Cabinet < b.Cabinet
+		}
+		return a.Shelf < b.Shelf
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// stored filters out the chemicals that have left their shelf for good
+func stored(chemicals []models.Chemical) []models.Chemical {
+	var out []models.Chemical
+	for _, chemical := range chemicals {
+		if chemical.Room != "" && !chemical.IsDisposed() {
+			out = append(out, chemical)
+		}
+	}
+	return out
+}
+
+// applyStorageGroups validates the storage groups present in a request and copies them onto
+// the record, responding with 400 when they are unknown
+func applyStorageGroups(c *gin.Context, chemical Chemical, record *models.Chemical) bool {
+	if chemical.StorageGroups == nil {
+		return true
+	}
+	groups, err := models.NormalizeStorageGroups(*chemical.StorageGroups)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	record.StorageGroups = groups
+	return true
+}
+
+// checkStorage checks the placement of a chemical against the other chemicals on its shelf. It
+// responds with 409 when the placement is blocked and otherwise returns the conflicts to warn
+// about. Changes that leave the shelf and storage groups alone are not checked again.
+func (h *Handler) checkStorage(c *gin.Context, record models.Chemical, before *models.Chemical) ([]models.StorageConflict, bool) {
+	if record.Room == "" {
+		return nil, true
+	}
+	if before != nil && before.SameShelf(record) &&
+		reflect.DeepEqual(before.EffectiveStorageGroups(), record.EffectiveStorageGroups()) {
+		return nil, true
+	}
+
+	neighbours, err := h.chemicals.List(context.Background(), repository.ChemicalFilter{School: record.School})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage compatibility"})
+		return nil, false
+	}
+	conflicts := record.StorageConflicts(stored(neighbours))
+	if models.HasBlockingConflict(conflicts) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The chemical cannot be stored on this shelf next to incompatible chemicals", "conflicts": conflicts})
+		return nil, false
+	}
+	return conflicts, true
+}
@@ -0,0 +1,444 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// TransferRequest is the request body for proposing a transfer to another school
+type TransferRequest struct {
+	ChemicalID string          `json:"chemical_id" binding:"required"`
+	ToSchool   string          `json:"to_school" binding:"required"`
+	Amount     models.Quantity `json:"amount" swaggertype:"string" example:"250 mL"` // part of the remaining amount to send, empty for the whole container
+	Notes      string          `json:"notes"`
+}
+
+// TransferDecision is the request body for accepting, rejecting or cancelling a transfer
+type TransferDecision struct {
+	LocationID string `json:"location_id"` // acceptance only: room, cabinet or shelf of the receiving school to keep the chemical in
+	Room       string `json:"room"`        // acceptance only, when no location is given
+	Cabinet    int    `json:"cabinet"`
+	Shelf      int    `json:"shelf"`
+	Reason     string `json:"reason"` // rejection and cancellation only
+}
+
+// ProposeTransfer godoc
+// @Summary Propose a transfer to another school
+// @Description Offer a chemical, or part of its remaining amount, to another school. Nothing moves until an admin of the receiving school accepts the transfer, and the admins of that school are notified. A chemical can have one pending transfer at a time.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param transfer body TransferRequest true "Transfer"
+// @Success 200 {object} models.Transfer
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/transfers [post]
+func (h *Handler) ProposeTransfer(c *gin.Context) {
+	ctx := context.Background()
+
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var request TransferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if errors.Is(err, models.ErrInvalidQuantity) || errors.Is(err, models.ErrInvalidUnit) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A chemical and a receiving school are required"})
+		return
+	}
+	request.ToSchool = strings.TrimSpace(request.ToSchool)
+
+	chemical, err := h.chemicals.Get(ctx, request.ChemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
+	}
+	if !policy.CanManageChemicals(principal, chemical.School) {
+		denyAccess(c)
+		return
+	}
+	if request.ToSchool == chemical.School {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The chemical is already at this school"})
+		return
+	}
+	exists, err := h.schoolExists(ctx, request.ToSchool)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find the receiving school"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiving school not found"})
+		return
+	}
+
+	transfer := models.Transfer{
+		ChemicalID:   chemical.ID,
+		ChemicalName: chemical.Name,
+		FromSchool:   chemical.School,
+		ToSchool:     request.ToSchool,
+		Amount:       request.Amount,
+		Notes:        request.Notes,
+		Status:       models.TransferPending,
+		RequestedBy:  principal.UserID,
+		RequestedAt:  time.Now().UTC(),
+	}
+	// Checked again on acceptance, since the chemical can change in the meantime
+	if err := chemical.CheckTransfer(transfer); err != nil {
+		transferError(c, err)
+		return
+	}
+	if err := h.transfers.Create(ctx, &transfer); err != nil {
+		transferError(c, err)
+		return
+	}
+
+	h.notifyAdmins(ctx, transfer.ToSchool, transfer)
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// GetTransfers godoc
+// @Summary List transfers
+// @Description List the transfers sent or received by a school, newest first. Masters can list any school or every school at once.
+// @Tags transfers
+// @Produce json
+// @Param school query string false "School to list transfers for"
+// @Param status query string false "Only transfers with this status: pending, accepted, rejected or cancelled"
+// @Param chemical_id query string false "Only transfers of this chemical"
+// @Success 200 {array} models.Transfer
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/transfers [get]
+func (h *Handler) GetTransfers(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+	status := models.TransferStatus(c.Query("status"))
+	switch status {
+	case "", models.TransferPending, models.TransferAccepted, models.TransferRejected, models.TransferCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status, expected pending, accepted, rejected or cancelled"})
+		return
+	}
+
+	transfers, err := h.transfers.List(context.Background(), repository.TransferFilter{School: school, Status: status, ChemicalID: c.Query("chemical_id")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfers"})
+		return
+	}
+	if transfers == nil {
+		transfers = []models.Transfer{}
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// GetTransfer godoc
+// @Summary Get a transfer
+// @Description Get a transfer sent or received by the caller's school
+// @Tags transfers
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.Transfer
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/v1/transfers/{id} [get]
+func (h *Handler) GetTransfer(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	transfer, err := h.transfers.Get(context.Background(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	if !policy.CanViewSchool(principal, transfer.FromSchool) && !policy.CanViewSchool(principal, transfer.ToSchool) {
+		denyAccess(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// AcceptTransfer godoc
+// @Summary Accept a transfer
+// @Description Accept a pending transfer to the caller's school. The chemical moves to the receiving school, or for part of its remaining amount a new chemical is added there and the amount taken from the sending school's container, all at once. The chemical is kept in the given location, which must have room and hold nothing it cannot be stored next to. Both schools' histories record the transfer and their admins are notified.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Param decision body TransferDecision false "Where the receiving school keeps the chemical"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/transfers/{id}/accept [post]
+func (h *Handler) AcceptTransfer(c *gin.Context) {
+	ctx := context.Background()
+
+	transfer, decision, ok := h.decideTransfer(c, func(t models.Transfer) string { return t.ToSchool })
+	if !ok {
+		return
+	}
+	principal, _ := middleware.CurrentUser(c)
+
+	// Work out where the chemical goes and check it fits there before moving anything
+	transfer.LocationID, transfer.Room, transfer.Cabinet, transfer.Shelf = decision.LocationID, decision.Room, decision.Cabinet, decision.Shelf
+	chemical, err := h.chemicals.Get(ctx, transfer.ChemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return
+	}
+	preview := transfer
+	received, err := chemical.ApplyTransfer(&preview)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+	if !h.applyLocation(c, Chemical{LocationID: decision.LocationID}, &received, nil) {
+		return
+	}
+	warnings, ok := h.checkStorage(c, received, nil)
+	if !ok {
+		return
+	}
+	transfer.LocationID, transfer.Room, transfer.Cabinet, transfer.Shelf = received.LocationID, received.Room, received.Cabinet, received.Shelf
+
+	now := time.Now().UTC()
+	transfer.Status, transfer.DecidedBy, transfer.DecidedAt = models.TransferAccepted, principal.UserID, &now
+	requestID := middleware.CurrentRequestID(c)
+	outcome, err := h.transfers.Accept(ctx, &transfer, func(outcome repository.TransferOutcome) []models.AuditEntry {
+		// Each school's activity shows its side of the transfer, stored along with the move itself
+		sent := chemicalAuditEntry(models.AuditTransferOut, &outcome.Before, &outcome.Sent)
+		sent.School = transfer.FromSchool
+		var arrived models.AuditEntry
+		if transfer.Whole() {
+			arrived = chemicalAuditEntry(models.AuditTransferIn, &outcome.Before, &outcome.Received)
+		} else {
+			arrived = chemicalAuditEntry(models.AuditTransferIn, nil, &outcome.Received)
+		}
+		arrived.School = transfer.ToSchool
+		entries := []models.AuditEntry{sent, arrived}
+		for i := range entries {
+			entries[i].Actor, entries[i].RequestID, entries[i].CreatedAt = principal.UserID, requestID, now
+		}
+		return entries
+	})
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+	// Part of a container arrives as a new chemical, which needs its own QR code. Either way the
+	// label is printed again to show the receiving school and location.
+	if !transfer.Whole() {
+		h.GenerateQRCode(outcome.Received.ID)
+	}
+	if err := h.GenerateAndUploadLabel(outcome.Received.ID); err != nil {
+		log.Printf("Failed to create the label of transferred chemical %s: %v", outcome.Received.ID, err)
+	}
+
+	h.notifyAdmins(ctx, transfer.FromSchool, transfer)
+	h.notifyAdmins(ctx, transfer.ToSchool, transfer)
+
+	response := gin.H{"message": "Transfer accepted", "transfer": transfer, "chemical": outcome.Received}
+	if len(warnings) > 0 {
+		response["storage_warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RejectTransfer godoc
+// @Summary Reject a transfer
+// @Description Turn down a pending transfer to the caller's school. Nothing moves and the admins of the sending school are notified.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Param decision body TransferDecision false "Reason"
+// @Success 200 {object} models.Transfer
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/transfers/{id}/reject [post]
+func (h *Handler) RejectTransfer(c *gin.Context) {
+	transfer, decision, ok := h.decideTransfer(c, func(t models.Transfer) string { return t.ToSchool })
+	if !ok {
+		return
+	}
+	if !h.closeTransfer(c, &transfer, models.TransferRejected, decision.Reason) {
+		return
+	}
+
+	h.notifyAdmins(context.Background(), transfer.FromSchool, transfer)
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CancelTransfer godoc
+// @Summary Cancel a transfer
+// @Description Withdraw a pending transfer offered by the caller's school. Nothing moves and the admins of the receiving school are notified.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Param decision body TransferDecision false "Reason"
+// @Success 200 {object} models.Transfer
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/transfers/{id}/cancel [post]
+func (h *Handler) CancelTransfer(c *gin.Context) {
+	transfer, decision, ok := h.decideTransfer(c, func(t models.Transfer) string { return t.FromSchool })
+	if !ok {
+		return
+	}
+	if !h.closeTransfer(c, &transfer, models.TransferCancelled, decision.Reason) {
+		return
+	}
+
+	h.notifyAdmins(context.Background(), transfer.ToSchool, transfer)
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// decideTransfer loads a pending transfer and checks the caller manages the school that decides
+// it, returning the transfer and the request body. It responds with 400, 403, 404 or 409 and
+// returns false when the request should stop.
+func (h *Handler) decideTransfer(c *gin.Context, decider func(models.Transfer) string) (models.Transfer, TransferDecision, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return models.Transfer{}, TransferDecision{}, false
+	}
+	transfer, err := h.transfers.Get(context.Background(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return models.Transfer{}, TransferDecision{}, false
+	}
+	if !policy.CanManageChemicals(principal, decider(transfer)) {
+		denyAccess(c)
+		return models.Transfer{}, TransferDecision{}, false
+	}
+	if transfer.Status != models.TransferPending {
+		c.JSON(http.StatusConflict, gin.H{"error": models.ErrTransferDecided.Error()})
+		return models.Transfer{}, TransferDecision{}, false
+	}
+
+	// The body is optional
+	var decision TransferDecision
+	if err := c.ShouldBindJSON(&decision); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return models.Transfer{}, TransferDecision{}, false
+	}
+	return transfer, decision, true
+}
+
+// closeTransfer stores a transfer as rejected or cancelled by the caller
+func (h *Handler) closeTransfer(c *gin.Context, transfer *models.Transfer, status models.TransferStatus, reason string) bool {
+	principal, _ := middleware.CurrentUser(c)
+	now := time.Now().UTC()
+	transfer.Status, transfer.DecidedBy, transfer.DecidedAt, transfer.Reason = status, principal.UserID, &now, strings.TrimSpace(reason)
+	if err := h.transfers.Decide(context.Background(), *transfer); err != nil {
+		transferError(c, err)
+		return false
+	}
+	return true
+}
+
+// transferError responds to an error from proposing or accepting a transfer
+func transferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+	case errors.Is(err, models.ErrTransferPending), errors.Is(err, models.ErrTransferDecided), errors.Is(err, models.ErrTransferMoved),
+		errors.Is(err, models.ErrTransferCheckedOut), errors.Is(err, models.ErrTransferDisposal), errors.Is(err, models.ErrDisposed),
+		errors.Is(err, models.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrIncompatibleUnits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must use a unit compatible with the remaining amount"})
+	case errors.Is(err, models.ErrUnknownRemaining), errors.Is(err, models.ErrInvalidQuantity), errors.Is(err, models.ErrInvalidUnit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the transfer"})
+	}
+}
+
+// schoolExists reports whether a school is in the location hierarchy or has users
+func (h *Handler) schoolExists(ctx context.Context, school string) (bool, error) {
+	locations, err := h.locations.List(ctx, repository.LocationFilter{School: school, Kind: models.LocationSchool})
+	if err != nil || len(locations) > 0 {
+		return len(locations) > 0, err
+	}
+	users, err := h.users.List(ctx, repository.UserFilter{School: school})
+	return len(users) > 0, err
+}
+
+// notifyAdmins tells the admins of a school about a transfer as it now stands, by email and push
+// notification as each of them allows. The change is already saved, so failures are logged
+// rather than failing the request.
+func (h *Handler) notifyAdmins(ctx context.Context, school string, transfer models.Transfer) {
+	users, err := h.users.List(ctx, repository.UserFilter{School: school})
+	if err != nil {
+		log.Printf("Failed to find the admins of %s to notify: %v", school, err)
+		return
+	}
+	// Each admin gets the notice in their own language
+	notice := h.templates.Localize(notify.TransferData{Transfer: transfer})
+	for _, user := range users {
+		if !user.IsAdmin {
+			continue
+		}
+		msg, err := notice.In(user.Locale)
+		if err != nil {
+			log.Printf("Failed to render the notification of transfer %s: %v", transfer.ID, err)
+			return
+		}
+		if user.AllowEmail && user.Email != "" {
+			if err := h.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text}); err != nil {
+				log.Printf("Failed to email %s: %v", user.Email, err)
+			}
+		}
+		if user.AllowPush && user.ExpoPushToken != "" {
+			if err := helpers.SendPushNotification(user.ExpoPushToken, msg.PushTitle, msg.PushBody); err != nil {
+				log.Printf("Failed to send a push notification to user %s: %v", user.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// GetTrashedChemicals godoc
+// @Summary Get the chemicals in the trash
+// @Description Get the deleted chemicals of a school that can still be restored. Admins see their own school; masters can ask for any school or every school at once.
+// @Tags trash
+// @Produce json
+// @Param school query string false "School to list deleted chemicals for"
+// @Success 200 {array} models.Chemical
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/trash/chemicals [get]
+func (h *Handler) GetTrashedChemicals(c *gin.Context) {
+	school, ok := trashSchool(c)
+	if !ok {
+		return
+	}
+
+	chemicals, err := h.chemicals.List(context.Background(), repository.ChemicalFilter{School: school, Trashed: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted chemicals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, chemicals)
+}
+
+// GetTrashedUsers godoc
+// @Summary Get the users in the trash
+// @Description Get the deleted users of a school that can still be restored. Admins see their own school; masters can ask for any school or every school at once.
+// @Tags trash
+// @Produce json
+// @Param school query string false "School to list deleted users for"
+// @Success 200 {array} models.User
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/trash/users [get]
+func (h *Handler) GetTrashedUsers(c *gin.Context) {
+	school, ok := trashSchool(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.users.List(context.Background(), repository.UserFilter{School: school, Trashed: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// RestoreChemical godoc
+// @Summary Restore a chemical from the trash
+// @Description Take a deleted chemical out of the trash and back into the inventory
+// @Tags trash
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Success 200 {object} models.Chemical
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/restore [post]
+func (h *Handler) RestoreChemical(c *gin.Context) {
+	ctx := context.Background()
+
+	before, ok := h.authorizeTrashedChemical(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	err := h.chemicals.Restore(ctx, before.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore chemical"})
+		return
+	}
+	after := before
+	after.Deleted = nil
+	h.auditChemical(c, models.AuditRestore, &before, &after)
+
+	c.JSON(http.StatusOK, after)
+}
+
+// PurgeChemical godoc
+// @Summary Remove a chemical from the trash for good
+// @Description Permanently delete a chemical in the trash along with its QR code, label and SDS. This cannot be undone.
+// @Tags trash
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/purge [delete]
+func (h *Handler) PurgeChemical(c *gin.Context) {
+	chemical, ok := h.authorizeTrashedChemical(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	entry, err := h.purgeChemical(context.Background(), chemical)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge chemical"})
+		return
+	}
+	principal, _ := middleware.CurrentUser(c)
+	entry.Actor = principal.UserID
+	h.writeAudit(c, entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chemical purged successfully"})
+}
+
+// RestoreUser godoc
+// @Summary Restore a user from the trash
+// @Description Take a deleted user out of the trash so they can log in again. Fails when another user has taken their email address in the meantime.
+// @Tags trash
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/users/{id}/restore [post]
+func (h *Handler) RestoreUser(c *gin.Context) {
+	ctx := context.Background()
+
+	before, ok := h.authorizeTrashedUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	// Email addresses of deleted users are free to reuse, so the address may be taken by now
+	if before.Email != "" {
+		if _, err := h.users.GetByEmail(ctx, before.Email); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another user already has this email address"})
+			return
+		}
+	}
+
+	err := h.users.Restore(ctx, before.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+	after := before
+	after.Deleted = nil
+	h.auditUser(c, models.AuditRestore, &before, &after)
+
+	c.JSON(http.StatusOK, after)
+}
+
+// PurgeUser godoc
+// @Summary Remove a user from the trash for good
+// @Description Permanently delete a user in the trash along with their profile picture. This cannot be undone.
+// @Tags trash
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/users/{id}/purge [delete]
+func (h *Handler) PurgeUser(c *gin.Context) {
+	user, ok := h.authorizeTrashedUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	entry, err := h.purgeUser(context.Background(), user)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge user"})
+		return
+	}
+	principal, _ := middleware.CurrentUser(c)
+	entry.Actor = principal.UserID
+	h.writeAudit(c, entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User purged successfully"})
+}
+
+// PurgeExpiredTrash removes for good the chemicals and users that were moved to the trash before
+// the cutoff, with their stored files, and returns how many records it removed. It keeps going
+// past records that fail and returns the first error.
+func (h *Handler) PurgeExpiredTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	purged := 0
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	chemicals, err := h.chemicals.List(ctx, repository.ChemicalFilter{Trashed: true})
+	if err != nil {
+		return 0, err
+	}
+	for _, chemical := range chemicals {
+		if !chemical.Deleted.At.Before(cutoff) {
+			continue
+		}
+		entry, err := h.purgeChemical(ctx, chemical)
+		if err != nil {
+			log.Printf("Failed to purge chemical %s from the trash: %v", chemical.ID, err)
+			fail(err)
+			continue
+		}
+		entry.Actor = models.SystemActor
+		h.appendAudit(ctx, entry)
+		purged++
+	}
+
+	users, err := h.users.List(ctx, repository.UserFilter{Trashed: true})
+	if err != nil {
+		return purged, err
+	}
+	for _, user := range users {
+		if !user.Deleted.At.Before(cutoff) {
+			continue
+		}
+		entry, err := h.purgeUser(ctx, user)
+		if err != nil {
+			log.Printf("Failed to purge user %s from the trash: %v", user.ID, err)
+			fail(err)
+			continue
+		}
+		entry.Actor = models.SystemActor
+		h.appendAudit(ctx, entry)
+		purged++
+	}
+
+	return purged, firstErr
+}
+
+// purgeChemical deletes a trashed chemical and its files, and returns the audit entry to record
+func (h *Handler) purgeChemical(ctx context.Context, chemical models.Chemical) (models.AuditEntry, error) {
+	if err := h.chemicals.Delete(ctx, chemical.ID); err != nil {
+		return models.AuditEntry{}, err
+	}
+	for _, key := range []string{qrCodeKey(chemical.ID), labelKey(chemical.ID), sdsKey(chemical.ID)} {
+		h.deleteBlob(ctx, key)
+	}
+	return chemicalAuditEntry(models.AuditPurge, &chemical, nil), nil
+}
+
+// purgeUser deletes a trashed user and their profile picture, and returns the audit entry to record
+func (h *Handler) purgeUser(ctx context.Context, user models.User) (models.AuditEntry, error) {
+	if err := h.users.Delete(ctx, user.ID); err != nil {
+		return models.AuditEntry{}, err
+	}
+	h.deleteBlob(ctx, profilePictureKey(user.ID))
+	return userAuditEntry(models.AuditPurge, &user, nil), nil
+}
+
+// deleteBlob removes a stored file that may not exist. The record it belonged to is already
+// gone, so a failure is only logged.
+func (h *Handler) deleteBlob(ctx context.Context, key string) {
+	if err := h.blobs.Delete(ctx, key); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+		log.Printf("Failed to delete %s from storage: %v", key, err)
+	}
+}
+
+// trashSchool returns the school a trash listing is limited to, responding with 403 when the
+// caller cannot manage its trash
+func trashSchool(c *gin.Context) (string, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return "", false
+	}
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil || !policy.CanManageTrash(principal, school) {
+		denyAccess(c)
+		return "", false
+	}
+	return school, true
+}
+
+// authorizeTrashedChemical loads a chemical in the trash and checks that the caller can manage it.
+// It responds with 404 or 403 and returns false when the request should stop.
+func (h *Handler) authorizeTrashedChemical(c *gin.Context, chemicalID string) (models.Chemical, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return models.Chemical{}, false
+	}
+	chemical, err := h.chemicals.GetTrashed(context.Background(), chemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found in the trash"})
+		return models.Chemical{}, false
+	}
+	if !policy.CanManageTrash(principal, chemical.School) {
+		denyAccess(c)
+		return models.Chemical{}, false
+	}
+	return chemical, true
+}
+
+// authorizeTrashedUser loads a user in the trash and checks that the caller can manage them.
+// It responds with 404 or 403 and returns false when the request should stop.
+func (h *Handler) authorizeTrashedUser(c *gin.Context, userID string) (models.User, bool) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return models.User{}, false
+	}
+	user, err := h.users.GetTrashed(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found in the trash"})
+		return models.User{}, false
+	}
+	if !policy.CanManageUser(principal, userSubject(user)) {
+		denyAccess(c)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// trashDeletion records the caller moving a record to the trash now
+func trashDeletion(c *gin.Context) models.Deletion {
+	principal, _ := middleware.CurrentUser(c)
+	return models.Deletion{By: principal.UserID, At: time.Now().UTC()}
+}
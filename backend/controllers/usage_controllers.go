@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+// UsageRequest is the request body for logging usage and checking containers out and in
+type UsageRequest struct {
+	Amount models.Quantity `json:"amount" swaggertype:"string" example:"50 mL"` // amount used, required for withdrawals
+	UserID string          `json:"user_id"`                                     // check-outs only: who takes the container, the caller by default
+	Room   string          `json:"room"`                                        // check-outs: where the container is taken, check-ins: where it was returned
+	Notes  string          `json:"notes"`
+}
+
+// LogUsage godoc
+// @Summary Log usage of a chemical
+// @Description Record that an amount was taken from a container. The amount is subtracted from the remaining amount and added to the chemical's usage ledger.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param usage body UsageRequest true "Amount used"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/usage [post]
+func (h *Handler) LogUsage(c *gin.Context) {
+	h.recordUsage(c, models.UsageWithdrawal, "Usage logged successfully")
+}
+
+// CheckOutChemical godoc
+// @Summary Check out a chemical container
+// @Description Check a container out to a user and room. Only admins can check a container out to someone else. An amount, if given, is subtracted from the remaining amount.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param usage body UsageRequest true "Room, user and optional amount"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/checkout [post]
+func (h *Handler) CheckOutChemical(c *gin.Context) {
+	h.recordUsage(c, models.UsageCheckOut, "Chemical checked out successfully")
+}
+
+// CheckInChemical godoc
+// @Summary Check in a chemical container
+// @Description Return a checked out container. The amount used while it was out, if given, is subtracted from the remaining amount.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param usage body UsageRequest false "Amount used while checked out"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/checkin [post]
+func (h *Handler) CheckInChemical(c *gin.Context) {
+	h.recordUsage(c, models.UsageCheckIn, "Chemical checked in successfully")
+}
+
+// GetChemicalUsage godoc
+// @Summary Get the usage ledger of a chemical
+// @Description Get the usage events of a chemical, oldest first. Can be narrowed to a user and a date range.
+// @Tags usage
+// @Produce json
+// @Param id path string true "Chemical ID"
+// @Param user_id query string false "Only events of this user"
+// @Param from query string false "First day to include, such as 2024-01-01"
+// @Param to query string false "Last day to include, such as 2024-01-31"
+// @Success 200 {array} models.UsageEvent
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/chemicals/{id}/usage [get]
+func (h *Handler) GetChemicalUsage(c *gin.Context) {
+	chemicalID := c.Param("id")
+
+	if !h.authorizeChemical(c, chemicalID, policy.CanViewSchool) {
+		return
+	}
+
+	filter, ok := usageFilter(c)
+	if !ok {
+		return
+	}
+	filter.ChemicalID = chemicalID
+
+	h.listUsage(c, filter)
+}
+
+// GetUsage godoc
+// @Summary Get usage events
+// @Description Get the usage events of a school, oldest first, filtered by chemical, user or date range. Only masters can list other schools or every school at once.
+// @Tags usage
+// @Produce json
+// @Param school query string false "School to list usage for"
+// @Param chemical_id query string false "Only events of this chemical"
+// @Param user_id query string false "Only events of this user"
+// @Param from query string false "First day to include, such as 2024-01-01"
+// @Param to query string false "Last day to include, such as 2024-01-31"
+// @Success 200 {array} models.UsageEvent
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/usage [get]
+func (h *Handler) GetUsage(c *gin.Context) {
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+
+	filter, ok := usageFilter(c)
+	if !ok {
+		return
+	}
+	filter.School = school
+	filter.ChemicalID = c.Query("chemical_id")
+
+	h.listUsage(c, filter)
+}
+
+// recordUsage records a usage event of the given kind for the chemical in the path
+func (h *Handler) recordUsage(c *gin.Context, kind models.UsageKind, message string) {
+	chemicalID := c.Param("id")
+	ctx := context.Background()
+
+	if !h.authorizeChemical(c, chemicalID, policy.CanLogUsage) {
+		return
+	}
+	principal, _ := middleware.CurrentUser(c)
+
+	// A check-in needs no body at all
+	var request UsageRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		if errors.Is(err, models.ErrInvalidQuantity) || errors.Is(err, models.ErrInvalidUnit) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	event := models.UsageEvent{
+		ChemicalID: chemicalID,
+		Kind:       kind,
+		UserID:     principal.UserID,
+		RecordedBy: principal.UserID,
+		Amount:     request.Amount,
+		Room:       request.Room,
+		Notes:      request.Notes,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if kind == models.UsageCheckOut {
+		if request.Room == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Room is required to check out a container"})
+			return
+		}
+		if request.UserID != "" && request.UserID != principal.UserID {
+			if !h.checkOutToUser(c, chemicalID, request.UserID) {
+				return
+			}
+			event.UserID = request.UserID
+		}
+	}
+
+	// Record reads the chemical again in its transaction, this copy is only the audit's before state
+	before, err := h.chemicals.Get(ctx, chemicalID)
+	if err != nil {
+		usageError(c, err)
+		return
+	}
+	chemical, err := h.usage.Record(ctx, &event)
+	if err != nil {
+		usageError(c, err)
+		return
+	}
+	h.auditChemical(c, models.AuditUpdate, &before, &chemical)
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "event": event, "chemical": chemical})
+}
+
+// checkOutToUser checks that the caller can check a chemical out to another user of its school,
+// responding with 403, 404 or 400 and returning false when they cannot
+func (h *Handler) checkOutToUser(c *gin.Context, chemicalID, userID string) bool {
+	ctx := context.Background()
+	principal, _ := middleware.CurrentUser(c)
+
+	chemical, err := h.chemicals.Get(ctx, chemicalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+		return false
+	}
+	// Handing a container to someone else is for those who manage the school's chemicals
+	if !policy.CanManageChemicals(principal, chemical.School) {
+		denyAccess(c)
+		return false
+	}
+	user, err := h.users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+	if user.School != chemical.School {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Containers can only be checked out to users of the chemical's school"})
+		return false
+	}
+	return true
+}
+
+// usageError responds to a failed usage event with the matching status
+func usageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chemical not found"})
+	case errors.Is(err, models.ErrInsufficientStock), errors.Is(err, models.ErrAlreadyCheckedOut), errors.Is(err, models.ErrNotCheckedOut),
+		errors.Is(err, models.ErrDisposed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrIncompatibleUnits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must use a unit compatible with the remaining amount"})
+	case errors.Is(err, models.ErrUnknownRemaining), errors.Is(err, models.ErrInvalidQuantity), errors.Is(err, models.ErrInvalidUnit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record usage"})
+	}
+}
+
+// usageFilter reads the user_id, from and to query parameters, responding with 400 when a date is invalid.
+// Both dates are inclusive.
+func usageFilter(c *gin.Context) (repository.UsageFilter, bool) {
+	from, to, ok := dateRange(c)
+	if !ok {
+		return repository.UsageFilter{}, false
+	}
+	return repository.UsageFilter{UserID: c.Query("user_id"), From: from, To: to}, true
+}
+
+// dateRange reads the inclusive from and to dates of the query, responding with 400 when one is invalid.
+// It returns the start of the from day and the start of the day after to, zero when not given.
+func dateRange(c *gin.Context) (time.Time, time.Time, bool) {
+	from, err := models.ParseDate(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected an ISO 8601 date such as 2006-01-02"})
+		return time.Time{}, time.Time{}, false
+	}
+	to, err := models.ParseDate(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected an ISO 8601 date such as 2006-01-02"})
+		return time.Time{}, time.Time{}, false
+	}
+	if to.IsZero() {
+		return from.Time, time.Time{}, true
+	}
+	return from.Time, to.AddDate(0, 0, 1), true
+}
+
+// listUsage responds with the usage events matching the filter
+func (h *Handler) listUsage(c *gin.Context, filter repository.UsageFilter) {
+	events, err := h.usage.List(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -5,17 +5,25 @@ import (
     "crypto/rand"
     "crypto/sha256"
     "encoding/hex"
+    "errors"
+    "log"
     "net/http"
+    "sort"
+    "strings"
     "time"
 
-    "cloud.google.com/go/firestore"
     "github.com/gin-gonic/gin"
-    "github.com/ekjyotshinh/ChemTrack/backend/helpers"  // Re-enabled this import
+    "github.com/ekjyotshinh/ChemTrack/backend/auth"
+    "github.com/ekjyotshinh/ChemTrack/backend/mailer"
+    "github.com/ekjyotshinh/ChemTrack/backend/middleware"
+    "github.com/ekjyotshinh/ChemTrack/backend/models"
+    "github.com/ekjyotshinh/ChemTrack/backend/notify"
+    "github.com/ekjyotshinh/ChemTrack/backend/policy"
+    "github.com/ekjyotshinh/ChemTrack/backend/repository"
     "golang.org/x/crypto/bcrypt"
-    "google.golang.org/api/iterator"
 )
 
-// User represents the structure of a user
+// User is the request body for adding or updating a user
 type User struct {
 	First         string `json:"first"`
 	Last          string `json:"last"`
@@ -27,6 +35,7 @@ type User struct {
 	IsMaster      bool   `json:"is_master"`   // Flag for master
 	AllowEmail    bool   `json:"allow_email"` // flag for email notifications
 	AllowPush     bool   `json:"allow_push"`  // flag for push notifications
+	Locale        string `json:"locale"`      // language of the notifications, such as es
 }
 
 // Request types for password reset
@@ -41,13 +50,6 @@ type ResetPasswordRequest struct {
 
 
 
-var client *firestore.Client
-
-// SetClient allows the Firestore client to be used in controllers
-func SetClient(c *firestore.Client) {
-	client = c
-}
-
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -67,23 +69,35 @@ func CheckPasswordHash(password, hash string) bool {
 // @Param user body User true "User data"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 409
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/users [post]
-func AddUser(c *gin.Context) {
+func (h *Handler) AddUser(c *gin.Context) {
 	var user User
 
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	if !h.validLocale(c, user.Locale) {
+		return
+	}
+
+	// Sign up is anonymous and can only create regular users; admins invite users to their own school
+	principal, _ := middleware.CurrentUser(c)
+	if user.School == "" && principal.Role == auth.RoleAdmin {
+		user.School = principal.School
+	}
+	if !policy.CanAssignUser(principal, user.School, user.IsAdmin, user.IsMaster) {
+		denyAccess(c)
+		return
+	}
 
 	ctx := context.Background()
 
 	// Check if a user with the same email already exists
-	iter := client.Collection("users").Where("email", "==", user.Email).Documents(ctx)
-	existingUser, err := iter.Next()
-	if err == nil && existingUser.Exists() {
+	if _, err := h.users.GetByEmail(ctx, user.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
 		return
 	}
@@ -97,101 +111,156 @@ func AddUser(c *gin.Context) {
 	
 
 	// Add the new user
-	doc, _, err := client.Collection("users").Add(ctx, map[string]interface{}{
-		"first":           user.First,
-		"last":            user.Last,
-		"email":           user.Email,
-		"password":        hashedPassword,
-		"school":          user.School,
-		"is_admin":        user.IsAdmin,
-		"is_master":       user.IsMaster,
-		"expo_push_token": user.ExpoPushToken,
-		"allow_email":     user.AllowEmail,
-		"allow_push":      user.AllowPush,
-	})
-	if err != nil {
+	record := models.User{
+		First:         user.First,
+		Last:          user.Last,
+		Email:         user.Email,
+		Password:      hashedPassword,
+		School:        user.School,
+		IsAdmin:       user.IsAdmin,
+		IsMaster:      user.IsMaster,
+		ExpoPushToken: user.ExpoPushToken,
+		AllowEmail:    user.AllowEmail,
+		AllowPush:     user.AllowPush,
+	}
+	if user.Locale != "" {
+		record.Locale = h.templates.Locale(user.Locale)
+	}
+	if err := h.users.Create(ctx, &record); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user"})
 		return
 	}
+	h.auditUser(c, models.AuditCreate, nil, &record)
+	if principal.UserID != "" {
+		h.sendInvitation(ctx, principal, record)
+	}
 
 	response := gin.H{
-		"id": doc.ID,
+		"id": record.ID,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User added successfully", "user": response})
 }
 
+// sendInvitation welcomes a user an admin or master added. The user is already saved, so failures
+// are logged rather than failing the request.
+func (h *Handler) sendInvitation(ctx context.Context, inviter auth.Principal, user models.User) {
+	if user.Email == "" {
+		return
+	}
+	data := notify.InvitationData{Name: user.First, Email: user.Email, School: user.School, Admin: user.IsAdmin, Master: user.IsMaster}
+	if by, err := h.users.Get(ctx, inviter.UserID); err == nil {
+		data.InvitedBy = strings.TrimSpace(by.First + " " + by.Last)
+	}
+	msg, err := h.templates.Render(user.Locale, data)
+	if err == nil {
+		err = h.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text})
+	}
+	if err != nil {
+		log.Printf("Failed to send the invitation of user %s: %v", user.ID, err)
+	}
+}
+
+// validLocale checks that notifications can be rendered in the language a user asks for,
+// responding with 400 when they cannot
+func (h *Handler) validLocale(c *gin.Context, locale string) bool {
+	if locale == "" || h.templates.Supports(locale) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "locale must be one of " + strings.Join(h.templates.Locales(), ", ")})
+	return false
+}
+
 // GetUsers godoc
 // @Summary Get all users
-// @Description Get a list of all users
+// @Description Get a list of all users. Only masters can list users of other schools or every school at once.
 // @Tags users
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param school query string false "School to list users for"
+// @Param limit query int false "Users per page, at most 500. Without limit or cursor every user is returned"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} models.User
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/users [get]
-func GetUsers(c *gin.Context) {
+func (h *Handler) GetUsers(c *gin.Context) {
 	ctx := context.Background()
-	iter := client.Collection("users").Documents(ctx)
-	var users []map[string]interface{}
 
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-			return
-		}
-		user := doc.Data()
-		user["id"] = doc.Ref.ID // Add the document ID to the user data
-		users = append(users, user)
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Non masters are limited to their own school
+	school, err := policy.ListSchool(principal, c.DefaultQuery("school", ""))
+	if err != nil {
+		denyAccess(c)
+		return
+	}
+
+	limit, cursor, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := repository.UserFilter{School: school, After: cursor}
+	if limit > 0 {
+		filter.Limit = limit + 1
+	}
+
+	users, err := h.users.List(ctx, filter)
+	if err != nil {
+		listPageError(c, err, "Failed to fetch users")
+		return
+	}
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+		c.Header(HeaderNextCursor, repository.UserCursor(users[limit-1]))
 	}
 
 	c.JSON(http.StatusOK, users)
 }
 
 // GetUserSchools godoc
-// @Summary Get all distinct schools from users
-// @Description Get a list of distinct schools from every users
+// @Summary Get all schools
+// @Description Get the names of the schools in the location hierarchy, along with any school a user belongs to that has no location yet
 // @Tags users
 // @Produce json
 // @Success 200 {array} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/users/schools [get]
-func GetUserSchools(c *gin.Context) {
-	if client == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Firestore client is not initialized"})
+func (h *Handler) GetUserSchools(c *gin.Context) {
+	ctx := context.Background()
+	users, err := h.users.List(ctx, repository.UserFilter{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
 
-	ctx := context.Background()
-	iter := client.Collection("users").Documents(ctx)
+	locations, err := h.locations.List(ctx, repository.LocationFilter{Kind: models.LocationSchool})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schools"})
+		return
+	}
 
 	// store unique schools in a set
 	schoolSet := make(map[string]struct{})
-
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-			return
-		}
-		user := doc.Data()
-		// ensure school exists and is a string then add to set
-		if school, ok := user["school"].(string); ok {
-			schoolSet[school] = struct{}{}
+	for _, location := range locations {
+		schoolSet[location.Name] = struct{}{}
+	}
+	for _, user := range users {
+		if user.School != "" {
+			schoolSet[user.School] = struct{}{}
 		}
 	}
 
-	// convert set to slice
+	// convert set to a sorted slice
 	var schools []string
 	for school := range schoolSet {
 		schools = append(schools, school)
 	}
+	sort.Strings(schools)
 
 	c.JSON(http.StatusOK, schools)
 }
@@ -202,21 +271,29 @@ func GetUserSchools(c *gin.Context) {
 // @Tags users
 // @Produce json
 // @Param id path string true "User ID"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /api/v1/users/{id} [get]
-func GetUser(c *gin.Context) {
+func (h *Handler) GetUser(c *gin.Context) {
 	userID := c.Param("id")
 	ctx := context.Background()
 
-	doc, err := client.Collection("users").Doc(userID).Get(ctx)
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	user := doc.Data()
-	user["id"] = doc.Ref.ID // Add the document ID to the user data
+	if !policy.CanViewUser(principal, userSubject(user)) {
+		denyAccess(c)
+		return
+	}
 
 	c.JSON(http.StatusOK, user)
 }
@@ -231,50 +308,73 @@ func GetUser(c *gin.Context) {
 // @Param user body User true "User data"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/users/{id} [put]
-func UpdateUser(c *gin.Context) {
+func (h *Handler) UpdateUser(c *gin.Context) {
 	userID := c.Param("id")
 	var user User
 
+	principal, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	if !h.validLocale(c, user.Locale) {
+		return
+	}
+
+	ctx := context.Background()
+	existing, err := h.users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	before := existing
+	target := userSubject(existing)
+	if !policy.CanEditProfile(principal, target) {
+		denyAccess(c)
+		return
+	}
+
+	// Changing the school or granting a role is an administrative change, even on your own profile
+	newSchool := target.School
+	if user.School != "" {
+		newSchool = user.School
+	}
+	changesAssignment := newSchool != target.School || (user.IsAdmin && !target.IsAdmin) || (user.IsMaster && !target.IsMaster)
+	if changesAssignment && (!policy.CanManageUser(principal, target) ||
+		!policy.CanAssignUser(principal, newSchool, user.IsAdmin || target.IsAdmin, user.IsMaster || target.IsMaster)) {
+		denyAccess(c)
+		return
+	}
 
 	// Check if email is already in use
 	if user.Email != "" {
-		ctx := context.Background()
-		iter := client.Collection("users").Where("email", "==", user.Email).Documents(ctx)
-		var existingUserID string
-
-		for {
-			doc, err := iter.Next()
-			if err != nil {
-				break // No user with the given email found -- so the users email can be update to this email
-			}
-			existingUserID = doc.Ref.ID
-			if existingUserID != userID {
-				// Found another user with the same email so cannot update the email to this email
-				c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
-				return
-			}
+		owner, err := h.users.GetByEmail(ctx, user.Email)
+		if err == nil && owner.ID != userID {
+			// Found another user with the same email so cannot update the email to this email
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+			return
 		}
 	}
 
 
 	// Proceed with updating the user data
-	updateData := make(map[string]interface{})
-
 	if user.First != "" {
-		updateData["first"] = user.First
+		existing.First = user.First
 	}
 	if user.Last != "" {
-		updateData["last"] = user.Last
+		existing.Last = user.Last
 	}
 	if user.Email != "" {
-		updateData["email"] = user.Email
+		existing.Email = user.Email
 	}
 	if user.Password != "" {
 		hashedPassword, err := HashPassword(user.Password)
@@ -282,63 +382,94 @@ func UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 			return
 		}
-		updateData["password"] = hashedPassword
+		existing.Password = hashedPassword
 	}
 	if user.School != "" {
-		updateData["school"] = user.School
+		existing.School = user.School
 	}
 	if user.ExpoPushToken != "" {
-		updateData["expo_push_token"] = user.ExpoPushToken
+		existing.ExpoPushToken = user.ExpoPushToken
 	}
 	if user.IsAdmin {
-		updateData["is_admin"] = user.IsAdmin
+		existing.IsAdmin = user.IsAdmin
 	}
 	if user.IsMaster {
-		updateData["is_master"] = user.IsMaster
+		existing.IsMaster = user.IsMaster
 	}
 
 	if user.AllowEmail{
-	    updateData["allow_email"] = user.AllowEmail
+	    existing.AllowEmail = user.AllowEmail
 	}
 	if user.AllowPush{
-	    updateData["allow_push"] = user.AllowPush
+	    existing.AllowPush = user.AllowPush
+	}
+	if user.Locale != "" {
+		existing.Locale = h.templates.Locale(user.Locale)
 	}
 
 
-	ctx := context.Background()
-	_, err := client.Collection("users").Doc(userID).Set(ctx, updateData, firestore.MergeAll)
-	if err != nil {
+	if err := h.users.Update(ctx, existing); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	h.auditUser(c, models.AuditUpdate, &before, &existing)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
 // DeleteUser godoc
-// @Summary Delete a user by ID
-// @Description Delete a specific user by their ID
+// @Summary Move a user to the trash
+// @Description Move a specific user to the trash and sign them out everywhere. They cannot log in, but can be restored until they are purged, by hand or once the trash retention period has passed.
 // @Tags users
 // @Produce json
 // @Param id path string true "User ID"
 // @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/users/{id} [delete]
-func DeleteUser(c *gin.Context) {
+func (h *Handler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 	ctx := context.Background()
 
-	_, err := client.Collection("users").Doc(userID).Delete(ctx)
+	if !h.authorizeUser(c, userID, policy.CanManageUser) {
+		return
+	}
+
+	before, _ := h.users.Get(ctx, userID)
+	deletion := trashDeletion(c)
+	err := h.users.Trash(ctx, userID, deletion)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	after := before
+	after.Deleted = &deletion
+	h.auditUser(c, models.AuditDelete, &before, &after)
+
+	// Sign the deleted user out everywhere
+	h.revokeUserSessions(ctx, userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
-// Login authenticates a user by email and password and return the user data
-func Login(c *gin.Context) {
+// Login godoc
+// @Summary Log in
+// @Description Authenticates a user by email and password and returns the user data with an access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body map[string]string true "Email and password"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/login [post]
+func (h *Handler) Login(c *gin.Context) {
 	var loginDetails struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -350,45 +481,48 @@ func Login(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	iter := client.Collection("users").Where("email", "==", loginDetails.Email).Documents(ctx)
-	var user map[string]interface{}
-	var userID string
-
 	// Find the user by email
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			return
-		}
-		user = doc.Data()
-		userID = doc.Ref.ID
-		break
+	user, err := h.users.GetByEmail(ctx, loginDetails.Email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
 	}
 
 	// Verify the password
-	if !CheckPasswordHash(loginDetails.Password, user["password"].(string)) {
+	if !CheckPasswordHash(loginDetails.Password, user.Password) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
 		return
 	}
 
 	// Return user info upon successful login
 	response := gin.H{
-		"first":           user["first"],
-		"last":            user["last"],
-		"email":           user["email"],
-		"school":          user["school"],
-		"is_admin":        user["is_admin"],
-		"is_master":       user["is_master"],
-		"allow_email":     user["allow_email"],
-		"allow_push":      user["allow_push"],
-		"expo_push_token": user["expo_push_token"],
-		"id":              userID,
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": response})
+		"first":           user.First,
+		"last":            user.Last,
+		"email":           user.Email,
+		"school":          user.School,
+		"is_admin":        user.IsAdmin,
+		"is_master":       user.IsMaster,
+		"allow_email":     user.AllowEmail,
+		"allow_push":      user.AllowPush,
+		"expo_push_token": user.ExpoPushToken,
+		"id":              user.ID,
+	}
+
+	// Issue the access token and start a new refresh token family for this device
+	session, err := h.issueSession(ctx, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+	session["message"] = "Login successful"
+	session["user"] = response
+
+	c.JSON(http.StatusOK, session)
 }
 
+// resetTokenTTL is how long a password reset token can be used
+const resetTokenTTL = 15 * time.Minute
+
 // ForgotPassword handles password reset requests
 // @Summary Sends a password reset email
 // @Description Generates a reset token and sends an email to the user
@@ -396,13 +530,12 @@ func Login(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param request body ForgotPasswordRequest true "User email"
+// @Param Accept-Language header string false "Language of the email, such as es; the user's locale or the configured NOTIFICATION_LOCALE by default"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/auth/forgot-password [post]
-
-// ForgotPassword handles password reset requests
-func ForgotPassword(c *gin.Context) {
+func (h *Handler) ForgotPassword(c *gin.Context) {
 	var req ForgotPasswordRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -425,49 +558,26 @@ func ForgotPassword(c *gin.Context) {
 	hasher.Write([]byte(resetToken))
 	hashedToken := hex.EncodeToString(hasher.Sum(nil))
 	
-	// Set expiration time (1 hour)
-	expiryTime := time.Now().Add(15 * time.Minute)
+	// Set expiration time (15 minutes)
+	expiryTime := time.Now().Add(resetTokenTTL)
 	
 	// Find user by email
 	ctx := context.Background()
-	iter := client.Collection("users").Where("email", "==", req.Email).Documents(ctx)
-	
-	var userFound bool
-	var userID string
-	
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			// Log the error but don't expose it to the client
-			userFound = false
-			break
-		}
-		
-		userID = doc.Ref.ID
-		userFound = true
-		
-		// Store the reset token and expiration time
-		_, err = client.Collection("users").Doc(userID).Set(ctx, map[string]interface{}{
-			"reset_token": hashedToken,
-			"reset_expiry": expiryTime,
-		}, firestore.MergeAll)
-		
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
-			return
-		}
-		
-		break
-	}
+	user, err := h.users.GetByEmail(ctx, req.Email)
 	
 	// Always return a success message even if user not found (for security)
-	if !userFound {
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"message": "If that email exists in our system, we have sent a password reset link"})
 		return
 	}
+
+	// Store the reset token and expiration time
+	user.ResetToken = hashedToken
+	user.ResetExpiry = expiryTime
+	if err := h.users.Update(ctx, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
 	
 	// Create reset URL
 	// Create reset URL (still needed for the token in the email instructions)
@@ -475,43 +585,19 @@ func ForgotPassword(c *gin.Context) {
 	// for testing it you can update it to yours
 	resetURL := "chemtrack://resetPassword?token=" + resetToken
 
-	// Email body with reset link - Format the email in HTML for better presentation
-	emailBody := `
-	<html>
-	<body style="font-family: Arial, sans-serif; line-height: 1.6;">
-		<h2>ChemTrack Password Reset</h2>
-		<p>You requested a password reset for your ChemTrack account.</p>
-		
-		<div style="margin: 20px 0; padding: 15px; border: 1px solid #e0e0e0; background-color: #f8f8f8; border-radius: 5px;">
-			<p><strong>Instructions:</strong></p>
-			<ol>
-				<li>Open the ChemTrack app on your device</li>
-				<li>Go to the Login screen</li>
-				<li>Tap "Forgot Password"</li>
-				<li>Click on "Click to enter Token"</li>
-				<li>In the input field add this reset token:</li>
-			</ol>
-			<div style="padding: 10px; background-color: #f0f0f0; border: 1px dashed #ccc; font-family: monospace; margin: 10px 0;">
-				` + resetToken + `
-			</div>
-			<p>Clink this button to open in App</p>
-			<div style="margin: 15px 0;">
-				<a href="` + resetURL + `" style="background-color: #4285f4; color: white; padding: 10px 15px; text-decoration: none; border-radius: 4px; display: inline-block;">Open in App</a>
-			</div>
-			If the button above doesn't work, copy and paste this link into your browser:
-			<br>
-			<strong>` + resetURL + `</strong>
-		</div>
-		
-		<p>This reset token will expire in 15 minutes.</p>
-		<p>Don't share this link with anyone.</p>
-		<p>If you didn't request this reset, please ignore this email.</p>
-	</body>
-	</html>
-	`
-
-	// Send the email - make sure to use HTML content type
-	if err := helpers.SendEmailHelper(req.Email, "ChemTrack Password Reset", emailBody); err != nil {
+	// Render the email in the language of the app asking for the reset, or the one the user chose
+	locale := user.Locale
+	if c.GetHeader("Accept-Language") != "" {
+		locale = h.templates.Negotiate(c.GetHeader("Accept-Language"))
+	}
+	msg, err := h.templates.Render(locale,
+		notify.PasswordResetData{Token: resetToken, URL: resetURL, ExpiresIn: resetTokenTTL})
+	if err != nil {
+		log.Printf("Failed to render the password reset email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+	if err := h.mailer.Send(c.Request.Context(), mailer.Message{To: req.Email, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
 		return
 	}
@@ -531,7 +617,7 @@ func ForgotPassword(c *gin.Context) {
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/auth/reset-password [post]
-func ResetPassword(c *gin.Context) {
+func (h *Handler) ResetPassword(c *gin.Context) {
 	var req ResetPasswordRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -546,44 +632,22 @@ func ResetPassword(c *gin.Context) {
 	
 	// Find user with this token
 	ctx := context.Background()
-	iter := client.Collection("users").Where("reset_token", "==", hashedToken).Documents(ctx)
-	
-	var userFound bool
-	var userID string
-	var userData map[string]interface{}
-	
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			userFound = false
-			break
-		}
-		
-		userID = doc.Ref.ID
-		userData = doc.Data()
-		userFound = true
-		break
-	}
-	
-	if !userFound {
+	user, err := h.users.GetByResetToken(ctx, hashedToken)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
 		return
 	}
 	
 	// Check if token is expired
-	if expiry, ok := userData["reset_expiry"].(time.Time); ok {
-		if time.Now().After(expiry) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
-			return
-		}
-	} else {
-		// If we can't parse the expiry time, consider the token invalid
+	if user.ResetExpiry.IsZero() {
+		// Without an expiry time, consider the token invalid
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
 		return
 	}
+	if time.Now().After(user.ResetExpiry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
+		return
+	}
 	
 	// Hash the new password
 	hashedPassword, err := HashPassword(req.NewPassword)
@@ -593,16 +657,19 @@ func ResetPassword(c *gin.Context) {
 	}
 	
 	// Update the password and clear reset token fields
-	_, err = client.Collection("users").Doc(userID).Set(ctx, map[string]interface{}{
-		"password": hashedPassword,
-		"reset_token": firestore.Delete,
-		"reset_expiry": firestore.Delete,
-	}, firestore.MergeAll)
+	before := user
+	user.Password = hashedPassword
+	user.ResetToken = ""
+	user.ResetExpiry = time.Time{}
 	
-	if err != nil {
+	if err := h.users.Update(ctx, user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
+	h.auditUser(c, models.AuditUpdate, &before, &user)
+
+	// Sessions opened with the old password should not survive the reset
+	h.revokeUserSessions(ctx, user.ID)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
 }
@@ -618,7 +685,7 @@ func ResetPassword(c *gin.Context) {
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/auth/verify-token [post]
-func VerifyResetToken(c *gin.Context) {
+func (h *Handler) VerifyResetToken(c *gin.Context) {
 	var req struct {
 		Token string `json:"token" binding:"required"`
 	}
@@ -635,42 +702,26 @@ func VerifyResetToken(c *gin.Context) {
 	
 	// Find user with this token
 	ctx := context.Background()
-	iter := client.Collection("users").Where("reset_token", "==", hashedToken).Documents(ctx)
-	
-	var userFound bool
-	var userData map[string]interface{}
-	
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
-			return
-		}
-		
-		userData = doc.Data()
-		userFound = true
-		break
-	}
-	
-	if !userFound {
+	user, err := h.users.GetByResetToken(ctx, hashedToken)
+	if errors.Is(err, repository.ErrNotFound) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+		return
+	}
 	
 	// Check if token is expired
-	if expiry, ok := userData["reset_expiry"].(time.Time); ok {
-		if time.Now().After(expiry) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
-			return
-		}
-	} else {
-		// If we can't parse the expiry time, consider the token invalid
+	if user.ResetExpiry.IsZero() {
+		// Without an expiry time, consider the token invalid
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token format"})
 		return
 	}
+	if time.Now().After(user.ResetExpiry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
+		return
+	}
 	
 	// Token is valid and not expired
 	c.JSON(http.StatusOK, gin.H{"message": "Token is valid"})
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Capture keeps the emails it is given in memory instead of sending them, for tests to check
+// and for running the API locally without an email account
+type Capture struct {
+	sender Sender
+	mu     sync.Mutex
+	sent   []Message
+}
+
+// NewCapture creates a transport capturing emails as the given sender would send them
+func NewCapture(sender Sender) *Capture {
+	return &Capture{sender: sender}
+}
+
+func (c *Capture) Send(ctx context.Context, msg Message) error {
+	msg, err := c.sender.prepare(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	log.Printf("Captured email %q to %s", msg.Subject, msg.To)
+	return nil
+}
+
+// Messages returns the emails captured so far, oldest first
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.sent...)
+}
+
+// To returns the emails captured for an address, oldest first
+func (c *Capture) To(address string) []Message {
+	var out []Message
+	for _, msg := range c.Messages() {
+		if msg.To == address {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// Reset forgets the captured emails
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = nil
+}
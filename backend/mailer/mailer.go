@@ -0,0 +1,81 @@
+// Package mailer sends the emails of the API through a transport chosen per deployment: SendGrid,
+// an SMTP server, or an in-memory capture for tests and local development.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+)
+
+// ErrNoRecipient is returned for a message without a valid To address
+var ErrNoRecipient = errors.New("the email has no valid recipient")
+
+// Message is an email with an HTML body and the plain text shown by clients without HTML
+type Message struct {
+	From    mail.Address  `json:"from"`               // the sender of the deployment when empty
+	ReplyTo *mail.Address `json:"reply_to,omitempty"` // the reply-to of the deployment when nil
+	To      string        `json:"to"`
+	Subject string        `json:"subject"`
+	HTML    string        `json:"html"` // taken from the text when empty
+	Text    string        `json:"text"` // taken from the HTML when empty
+}
+
+// Mailer sends emails
+type Mailer interface {
+	// Send delivers the message, or returns why it could not be sent
+	Send(ctx context.Context, msg Message) error
+}
+
+// Sender is who the emails of a deployment come from, and where replies go
+type Sender struct {
+	From    mail.Address
+	ReplyTo *mail.Address // replies go to From when nil
+}
+
+// ParseSender reads a sender from addresses such as "ChemTrack <alerts@example.org>". The
+// reply-to address is optional.
+func ParseSender(from, replyTo string) (Sender, error) {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return Sender{}, fmt.Errorf("from address %q: %w", from, err)
+	}
+	sender := Sender{From: *address}
+	if strings.TrimSpace(replyTo) != "" {
+		if sender.ReplyTo, err = mail.ParseAddress(replyTo); err != nil {
+			return Sender{}, fmt.Errorf("reply-to address %q: %w", replyTo, err)
+		}
+	}
+	return sender, nil
+}
+
+// prepare fills in what the message leaves to the deployment and checks its recipient
+func (s Sender) prepare(msg Message) (Message, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: %q", ErrNoRecipient, msg.To)
+	}
+	msg.To = to.Address
+	if msg.From.Address == "" {
+		msg.From = s.From
+	}
+	if msg.ReplyTo == nil {
+		msg.ReplyTo = s.ReplyTo
+	}
+	if msg.Text == "" {
+		msg.Text = notify.PlainText(msg.HTML)
+	} else if msg.HTML == "" {
+		msg.HTML = textHTML(msg.Text)
+	}
+	return msg, nil
+}
+
+// textHTML is the HTML of a plain text body, escaped and keeping its line breaks
+func textHTML(text string) string {
+	return "<p>" + strings.ReplaceAll(html.EscapeString(strings.TrimSpace(text)), "\n", "<br>\n") + "</p>"
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sendgrid/sendgrid-go"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// SendGrid sends emails through the SendGrid API. The from address must be a verified sender
+// of the account.
+type SendGrid struct {
+	client *sendgrid.Client
+	sender Sender
+}
+
+// NewSendGrid creates a transport sending with the given API key
+func NewSendGrid(apiKey string, sender Sender) *SendGrid {
+	return &SendGrid{client: sendgrid.NewSendClient(apiKey), sender: sender}
+}
+
+func (s *SendGrid) Send(ctx context.Context, msg Message) error {
+	msg, err := s.sender.prepare(msg)
+	if err != nil {
+		return err
+	}
+	email := sgmail.NewSingleEmail(sgmail.NewEmail(msg.From.Name, msg.From.Address), msg.Subject,
+		sgmail.NewEmail("", msg.To), msg.Text, msg.HTML)
+	if msg.ReplyTo != nil {
+		email.SetReplyTo(sgmail.NewEmail(msg.ReplyTo.Name, msg.ReplyTo.Address))
+	}
+
+	response, err := s.client.SendWithContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("SendGrid API error, status_code: %d", response.StatusCode)
+	}
+	return nil
+}
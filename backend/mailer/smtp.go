@@ -0,0 +1,151 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig is the server an SMTP transport sends through
+type SMTPConfig struct {
+	Host     string
+	Port     int    // 465 connects over TLS, other ports upgrade with STARTTLS when the server offers it
+	Username string // no authentication when empty
+	Password string
+	Timeout  time.Duration // for connecting and sending each email, DefaultSMTPTimeout when zero
+}
+
+// DefaultSMTPTimeout bounds an email to a server that stops answering
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTP sends emails through an SMTP server, a new connection for each email
+type SMTP struct {
+	config SMTPConfig
+	sender Sender
+}
+
+// NewSMTP creates a transport sending through the server of config
+func NewSMTP(config SMTPConfig, sender Sender) *SMTP {
+	return &SMTP{config: config, sender: sender}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	msg, err := s.sender.prepare(msg)
+	if err != nil {
+		return err
+	}
+	body, err := encode(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	timeout := s.config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	// The whole exchange has to finish in time, or sooner when the caller's context says so
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+	if s.config.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && s.config.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS with %s: %w", addr, err)
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return fmt.Errorf("authenticating with %s: %w", addr, err)
+		}
+	}
+	if err := client.Mail(msg.From.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// encode writes the message as a MIME email with an HTML and a plain text alternative
+func encode(msg Message, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	header := func(key, value string) { fmt.Fprintf(&out, "%s: %s\r\n", key, value) }
+	header("From", msg.From.String())
+	header("To", msg.To)
+	if msg.ReplyTo != nil {
+		header("Reply-To", msg.ReplyTo.String())
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
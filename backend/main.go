@@ -2,18 +2,24 @@ package main
 
 
 import (
+    "context"
+    "errors"
     "log"
-    "time"
+    "net/http"
     "os"
+    "os/signal"
+    "syscall"
 
     "github.com/swaggo/gin-swagger"
     "github.com/swaggo/files"
     "github.com/gin-gonic/gin"
+    "github.com/ekjyotshinh/ChemTrack/backend/config"
+    "github.com/ekjyotshinh/ChemTrack/backend/controllers"
+    "github.com/ekjyotshinh/ChemTrack/backend/middleware"
     "github.com/ekjyotshinh/ChemTrack/backend/routes"
     _ "github.com/ekjyotshinh/ChemTrack/backend/docs" // Import generated docs
 	"github.com/gin-contrib/cors"
 	"github.com/joho/godotenv"
-    //"github.com/ekjyotshinh/ChemTrack/backend/services"
 )
 func setupCredentials() {
 	// Handle GOOGLE_APPLICATION_CREDENTIALS_JSON → /tmp/bucketkey.json
@@ -47,6 +53,7 @@ func main() {
         log.Println("No .env file found, relying on environment variables.")
     }
     setupCredentials()
+    cfg := config.Load()
 	// Create a Gin router
     router := gin.Default()
 
@@ -54,20 +61,38 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Authorization", middleware.HeaderRequestID},
+		ExposeHeaders: []string{middleware.HeaderRequestID, controllers.HeaderNextCursor},
 	}))
-
-
-	// Initialize Firestore
-	routes.InitFirestore()
-	// create a subroutine
-	//go startBackgroundJobs()
+	// Tag every request with an ID for the logs and the audit trail
+	router.Use(middleware.RequestID())
+
+
+	// Initialize the database (Firestore, SQLite or PostgreSQL)
+	repos := routes.InitDatabase(cfg)
+	// Initialize token signing for authentication
+	tokens := routes.InitAuth(cfg)
+	// Initialize file storage for QR codes, labels, SDS files and profile pictures
+	store := routes.InitStorage(cfg)
+	// Schedule the background jobs, such as the chemical monitor
+	jobs := routes.InitJobs(cfg, repos)
+	// Load the templates of the emails and push notifications
+	templates := routes.InitTemplates(cfg)
+	// Set up sending email through SendGrid, SMTP or the in-memory capture
+	mail := routes.InitMailer(cfg)
+	// Build the handlers on top of them
+	handler := controllers.NewHandler(controllers.Dependencies{Repositories: repos, Tokens: tokens, Blobs: store, Jobs: jobs, Templates: templates, Mailer: mail})
+	routes.StartJobs(cfg, jobs, repos, templates, mail, handler)
 
     // Register routes
-    routes.RegisterRoutesUser(router)
-    routes.RegisterRoutesChemical(router)
-    routes.RegisterRoutesEmail(router)
-    routes.RegisterRoutesFiles(router)
+    routes.RegisterRoutesUser(router, handler)
+    routes.RegisterRoutesChemical(router, handler)
+    routes.RegisterRoutesLocation(router, handler)
+    routes.RegisterRoutesTransfer(router, handler)
+    routes.RegisterRoutesAlerts(router, handler)
+    routes.RegisterRoutesEmail(router, handler)
+    routes.RegisterRoutesFiles(router, handler)
+    routes.RegisterRoutesJobs(router, handler)
     //routes.RegisterRoutesQRCode(router)
 
 	// Swagger Documentation: http://localhost:8080/swagger/index.html
@@ -75,22 +100,25 @@ func main() {
     router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
     // Start the server on port 8080
-    if err := router.Run(":8080"); err != nil {
-        log.Fatalf("Failed to run server: %v", err)
+    server := &http.Server{Addr: ":8080", Handler: router}
+    go func() {
+        if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Fatalf("Failed to run server: %v", err)
+        }
+    }()
+
+    // On SIGINT or SIGTERM, as Cloud Run sends before stopping an instance, finish the requests
+    // and job runs in progress before exiting
+    quit := make(chan os.Signal, 1)
+    signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+    <-quit
+    log.Println("Shutting down...")
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+    defer cancel()
+    if err := server.Shutdown(ctx); err != nil {
+        log.Printf("Failed to finish the requests in progress: %v", err)
     }
-}
-// startBackgroundJobs runs scheduled tasks in a separate goroutine.
-func startBackgroundJobs() {
-    ticker := time.NewTicker(30 * 24 * time.Hour) 
-    defer ticker.Stop()
-
-    for {
-        log.Println("Running CheckCriticalChemicalStatus...")
-        //services.CheckCriticalChemicalStatus()
-        log.Println("Finished execution. Waiting for next cycle...")
-        <-ticker.C
+    if err := jobs.Stop(ctx); err != nil {
+        log.Printf("Cancelled the background jobs still running: %v", err)
     }
 }
-
-
-
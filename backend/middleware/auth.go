@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set for authenticated requests
+const (
+	ContextUserID    = "user_id"
+	ContextSchool    = "school"
+	ContextRole      = "role"
+	contextPrincipal = "principal"
+)
+
+// RequireAuth rejects requests without a valid bearer access token and
+// stores the caller's user ID, school and role in the context
+func RequireAuth(tm *auth.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := authenticate(c, tm)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// OptionalAuth identifies the caller when a valid token is sent but lets anonymous requests through.
+// Used on public routes such as sign up that behave differently for authenticated callers.
+func OptionalAuth(tm *auth.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := authenticate(c, tm); ok {
+			setPrincipal(c, principal)
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the authenticated caller of the request, if any
+func CurrentUser(c *gin.Context) (auth.Principal, bool) {
+	value, exists := c.Get(contextPrincipal)
+	if !exists {
+		return auth.Principal{}, false
+	}
+	principal, ok := value.(auth.Principal)
+	return principal, ok
+}
+
+// authenticate reads and verifies the "Authorization: Bearer <token>" header
+func authenticate(c *gin.Context, tm *auth.TokenManager) (auth.Principal, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return auth.Principal{}, false
+	}
+
+	principal, err := tm.ParseAccessToken(strings.TrimSpace(token))
+	if err != nil {
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
+
+func setPrincipal(c *gin.Context, p auth.Principal) {
+	c.Set(contextPrincipal, p)
+	c.Set(ContextUserID, p.UserID)
+	c.Set(ContextSchool, p.School)
+	c.Set(ContextRole, p.Role)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID carries the ID of a request, from the client or a proxy, and back in the response
+const HeaderRequestID = "X-Request-ID"
+
+const contextRequestID = "request_id"
+
+// RequestID gives every request an ID, reusing a sensible X-Request-ID sent by the client or
+// a proxy, and echoes it in the response so log lines and audit entries can be matched up
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(contextRequestID, id)
+		c.Header(HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+// CurrentRequestID returns the ID of the request, or "" when the RequestID middleware is not installed
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(contextRequestID)
+}
+
+// validRequestID accepts short IDs of letters, digits and common separators, so a client
+// cannot write arbitrary text into logs and audit entries
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrInvalidAlertRules is returned for alert rules with out of range settings
+var ErrInvalidAlertRules = errors.New("invalid alert rules")
+
+// ErrAlertResolved is returned when acknowledging, snoozing or resolving an alert that is already resolved
+var ErrAlertResolved = errors.New("the alert is already resolved")
+
+// ErrInvalidSnooze is returned when snoozing an alert until a time that has already passed
+var ErrInvalidSnooze = errors.New("an alert can only be snoozed until a time in the future")
+
+// AlertKind is the condition an alert is raised for
+type AlertKind string
+
+const (
+	AlertExpired         AlertKind = "expired"          // past its expiration date
+	AlertExpiring        AlertKind = "expiring"         // expiring within one of the lead times
+	AlertLowStock        AlertKind = "low_stock"        // at or below its reorder threshold, or nearly empty
+	AlertSDSMissing      AlertKind = "sds_missing"      // no safety data sheet uploaded
+	AlertSDSOutdated     AlertKind = "sds_outdated"     // safety data sheet revised too long ago
+	AlertStorageConflict AlertKind = "storage_conflict" // sharing a shelf with an incompatible chemical
+)
+
+// AlertSeverity is how urgently an alert needs attention
+type AlertSeverity string
+
+const (
+	SeverityInfo     AlertSeverity = "info"
+	SeverityWarning  AlertSeverity = "warning"
+	SeverityCritical AlertSeverity = "critical"
+)
+
+// Rank orders severities from info to critical
+func (s AlertSeverity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// AlertStatus is where an alert stands with the admins of its school
+type AlertStatus string
+
+const (
+	AlertOpen         AlertStatus = "open"         // raised, and sent to the admins when new or escalated
+	AlertAcknowledged AlertStatus = "acknowledged" // seen by an admin, only sent again if it escalates
+	AlertSnoozed      AlertStatus = "snoozed"      // put off until SnoozedUntil, when it opens again
+	AlertResolved     AlertStatus = "resolved"     // the condition cleared, or an admin dealt with it
+)
+
+// ActiveAlertStatuses are the statuses of the alerts whose condition still holds
+var ActiveAlertStatuses = []AlertStatus{AlertOpen, AlertAcknowledged, AlertSnoozed}
+
+// Alert is a condition of a chemical that the admins of its school are told about. The monitor
+// keeps one alert per condition while it lasts, so the admins hear about it once.
+type Alert struct {
+	ID           string        `json:"id,omitempty"`
+	Kind         AlertKind     `json:"kind"`
+	Severity     AlertSeverity `json:"severity"`
+	School       string        `json:"school"`
+	ChemicalID   string        `json:"chemical_id"`
+	ChemicalName string        `json:"chemical_name"`
+	CAS          string        `json:"CAS"`
+	Room         string        `json:"room,omitempty"`
+	Message      string        `json:"message"` // one line summary, such as "Acetone expires in 12 days"
+
+	// Details of the condition, set for the kinds they apply to
+	ExpirationDate   *Date            `json:"expiration_date,omitempty" swaggertype:"string" format:"date"`
+	LeadDays         int              `json:"lead_days,omitempty"` // the expiration lead time reached
+	Remaining        *Quantity        `json:"remaining,omitempty"`
+	ReorderThreshold *Quantity        `json:"reorder_threshold,omitempty"`
+	SDSDate          *Date            `json:"sds_date,omitempty" swaggertype:"string" format:"date"`
+	Conflict         *StorageConflict `json:"conflict,omitempty"`
+
+	Status           AlertStatus   `json:"status,omitempty"`
+	FirstSeen        time.Time     `json:"first_seen"`
+	LastSeen         time.Time     `json:"last_seen"`
+	NotifiedAt       *time.Time    `json:"notified_at,omitempty"`        // when the admins were last sent the alert
+	NotifiedSeverity AlertSeverity `json:"notified_severity,omitempty"`  // its severity then
+	NotifiedLeadDays int           `json:"notified_lead_days,omitempty"` // and its lead time
+	AcknowledgedBy   string        `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt   *time.Time    `json:"acknowledged_at,omitempty"`
+	SnoozedBy        string        `json:"snoozed_by,omitempty"`
+	SnoozedUntil     *time.Time    `json:"snoozed_until,omitempty"`
+	ResolvedBy       string        `json:"resolved_by,omitempty"` // a user, or SystemActor when the condition cleared
+	ResolvedAt       *time.Time    `json:"resolved_at,omitempty"`
+}
+
+// Key identifies the condition an alert is about: its kind, chemical and, for storage
+// conflicts, the other chemical
+func (a Alert) Key() string {
+	key := string(a.Kind) + ":" + a.ChemicalID
+	if a.Conflict != nil {
+		key += ":" + a.Conflict.OtherID
+	}
+	return key
+}
+
+// Active reports whether the alert's condition still holds
+func (a Alert) Active() bool {
+	return a.Status != AlertResolved
+}
+
+// escalatedFrom reports whether the alert has become more severe than the given severity, or
+// an expiring chemical has reached a shorter lead time
+func (a Alert) escalatedFrom(severity AlertSeverity, leadDays int) bool {
+	return a.Severity.Rank() > severity.Rank() || (a.LeadDays > 0 && leadDays > 0 && a.LeadDays < leadDays)
+}
+
+// Escalated reports whether the alert has escalated since the admins were last sent it
+func (a Alert) Escalated() bool {
+	return a.NotifiedAt != nil && a.escalatedFrom(a.NotifiedSeverity, a.NotifiedLeadDays)
+}
+
+// NeedsNotice reports whether the admins should be sent the alert: it is open and new to them or escalated
+func (a Alert) NeedsNotice() bool {
+	return a.Status == AlertOpen && (a.NotifiedAt == nil || a.Escalated())
+}
+
+// Observe updates a stored alert with its condition as found again at now. A snooze that has
+// run out opens the alert to be sent again, and an escalation opens an acknowledged or snoozed one.
+func (a *Alert) Observe(current Alert, now time.Time) {
+	severity, leadDays := a.Severity, a.LeadDays
+	if a.NotifiedAt != nil {
+		severity, leadDays = a.NotifiedSeverity, a.NotifiedLeadDays
+	}
+	escalated := current.escalatedFrom(severity, leadDays)
+
+	a.Severity, a.Message = current.Severity, current.Message
+	a.ChemicalName, a.CAS, a.Room = current.ChemicalName, current.CAS, current.Room
+	a.ExpirationDate, a.LeadDays, a.SDSDate, a.Conflict = current.ExpirationDate, current.LeadDays, current.SDSDate, current.Conflict
+	a.Remaining, a.ReorderThreshold = current.Remaining, current.ReorderThreshold
+	a.LastSeen = now
+
+	switch {
+	case a.Status == AlertSnoozed && a.SnoozedUntil != nil && !now.Before(*a.SnoozedUntil):
+		a.Status, a.NotifiedAt = AlertOpen, nil
+	case a.Status != AlertOpen && escalated:
+		a.Status = AlertOpen
+	}
+}
+
+// MarkNotified records that the admins were sent the alert as it stands
+func (a *Alert) MarkNotified(at time.Time) {
+	a.NotifiedAt, a.NotifiedSeverity, a.NotifiedLeadDays = &at, a.Severity, a.LeadDays
+}
+
+// Acknowledge records that an admin has seen the alert, ending any snooze
+func (a *Alert) Acknowledge(by string, at time.Time) error {
+	if !a.Active() {
+		return ErrAlertResolved
+	}
+	a.Status, a.AcknowledgedBy, a.AcknowledgedAt = AlertAcknowledged, by, &at
+	a.SnoozedBy, a.SnoozedUntil = "", nil
+	return nil
+}
+
+// Snooze puts the alert off until the given time, when the monitor opens and sends it again
+func (a *Alert) Snooze(by string, until, at time.Time) error {
+	if !a.Active() {
+		return ErrAlertResolved
+	}
+	if !until.After(at) {
+		return ErrInvalidSnooze
+	}
+	a.Status, a.SnoozedBy, a.SnoozedUntil = AlertSnoozed, by, &until
+	return nil
+}
+
+// Resolve closes the alert. Should its condition still hold, the monitor raises it again as a new alert.
+func (a *Alert) Resolve(by string, at time.Time) error {
+	if !a.Active() {
+		return ErrAlertResolved
+	}
+	a.Status, a.ResolvedBy, a.ResolvedAt = AlertResolved, by, &at
+	a.SnoozedUntil = nil
+	return nil
+}
+
+// Storage conflicts a school is alerted about
+const (
+	ConflictsNone    = ""        // no storage alerts
+	ConflictsAll     = "warning" // every conflict, from warnings up
+	ConflictsBlocked = "blocked" // only the conflicts that must never share a shelf
+)
+
+// AlertRules are the conditions a school is alerted about. Schools without stored rules use
+// DefaultAlertRules.
+type AlertRules struct {
+	School           string     `json:"school"`
+	Expired          bool       `json:"expired"`            // chemicals past their expiration date
+	ExpiringLeadDays []int      `json:"expiring_lead_days"` // warn this many days before a chemical expires, such as [90, 30, 7]
+	LowStock         bool       `json:"low_stock"`          // chemicals at or below their reorder threshold
+	LowStockPercent  float64    `json:"low_stock_percent"`  // chemicals with at most this percentage of their container left, 0 for none
+	SDSMissing       bool       `json:"sds_missing"`        // chemicals without a safety data sheet
+	SDSMaxAgeYears   int        `json:"sds_max_age_years"`  // safety data sheets revised longer ago than this, 0 for none
+	StorageConflicts string     `json:"storage_conflicts"`  // "warning" for every conflict, "blocked" for blocking ones only, "" for none
+	UpdatedBy        string     `json:"updated_by,omitempty"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"` // unset for the default rules
+}
+
+// DefaultAlertRules are the rules of a school that has not set its own: expired chemicals,
+// those expiring within six months, and low stock
+func DefaultAlertRules(school string) AlertRules {
+	return AlertRules{School: school, Expired: true, ExpiringLeadDays: []int{180}, LowStock: true}
+}
+
+// Normalize checks the rules and sorts the lead times from longest to shortest without duplicates
+func (r *AlertRules) Normalize() error {
+	seen := map[int]bool{}
+	var leads []int
+	for _, days := range r.ExpiringLeadDays {
+		if days < 1 || days > 3650 {
+			return fmt.Errorf("%w: expiring lead times must be from 1 to 3650 days", ErrInvalidAlertRules)
+		}
+		if !seen[days] {
+			seen[days] = true
+			leads = append(leads, days)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(leads)))
+	r.ExpiringLeadDays = leads
+	if r.LowStockPercent < 0 || r.LowStockPercent > 100 {
+		return fmt.Errorf("%w: low_stock_percent must be from 0 to 100", ErrInvalidAlertRules)
+	}
+	if r.SDSMaxAgeYears < 0 || r.SDSMaxAgeYears > 50 {
+		return fmt.Errorf("%w: sds_max_age_years must be from 0 to 50", ErrInvalidAlertRules)
+	}
+	switch r.StorageConflicts {
+	case ConflictsNone, ConflictsAll, ConflictsBlocked:
+	default:
+		return fmt.Errorf("%w: storage_conflicts must be %q, %q or empty", ErrInvalidAlertRules, ConflictsAll, ConflictsBlocked)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// AuditAction is the kind of change an audit entry records
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"  // moved to the trash
+	AuditRestore AuditAction = "restore" // taken out of the trash
+	AuditPurge   AuditAction = "purge"   // removed for good
+
+	AuditTransferOut AuditAction = "transfer_out" // sent to another school, in whole or in part
+	AuditTransferIn  AuditAction = "transfer_in"  // received from another school
+)
+
+// SystemActor is the actor of changes made by background jobs rather than a user
+const SystemActor = "system"
+
+// Types of records the audit trail covers
+const (
+	AuditChemical = "chemical"
+	AuditUser     = "user"
+)
+
+// Redacted replaces the values of secret fields, such as the password hash, in a change
+const Redacted = "[redacted]"
+
+// FieldChange is the value of one field before and after a change. Before is null for
+// created records and After is null for deleted ones.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry is an immutable record of a change to a chemical or user
+type AuditEntry struct {
+	ID         string        `json:"id"`
+	EntityType string        `json:"entity_type"` // chemical or user
+	EntityID   string        `json:"entity_id"`
+	School     string        `json:"school"` // school of the record after the change, or before a delete
+	Action     AuditAction   `json:"action"`
+	Actor      string        `json:"actor"`                // ID of the user who made the change, or SystemActor
+	RequestID  string        `json:"request_id,omitempty"` // X-Request-ID of the API request
+	Changes    []FieldChange `json:"changes"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// derivedFields are left out of diffs: the ID never changes, quantity and low_stock are
+// computed from other fields of a chemical, and location_id moves with its room, cabinet and shelf
+var derivedFields = map[string]bool{"id": true, "quantity": true, "low_stock": true, "location_id": true}
+
+// Diff compares two records field by field through their JSON form and returns the changed
+// fields in name order. Either record may be nil, for creates and deletes. Fields hidden
+// from JSON, such as password hashes, are not compared.
+func Diff(before, after interface{}) ([]FieldChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for field := range union(b, a) {
+		if derivedFields[field] || reflect.DeepEqual(b[field], a[field]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: b[field], After: a[field]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// jsonFields returns the top level JSON fields of a record, or none for nil
+func jsonFields(record interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v := reflect.ValueOf(record); !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func union(a, b map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCAS is returned for CAS Registry Numbers that are not 5 to 10 digits in the NNNNNNN-NN-N form
+var ErrInvalidCAS = errors.New("invalid CAS number, expected 5 to 10 digits such as 7647-14-5")
+
+// ErrCASCheckDigit is returned for CAS Registry Numbers whose check digit does not match
+var ErrCASCheckDigit = errors.New("invalid CAS number, the check digit does not match")
+
+// ParseCAS validates a CAS Registry Number given with or without hyphens, such as 7647-14-5 or 7647145,
+// and returns it in the canonical hyphenated form. An empty string is returned unchanged.
+func ParseCAS(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	digits := strings.ReplaceAll(s, "-", "")
+	if len(digits) < 5 || len(digits) > 10 || strings.Trim(digits, "0123456789") != "" {
+		return "", ErrInvalidCAS
+	}
+	// Hyphens, when present, must sit where the canonical form puts them
+	if strings.Contains(s, "-") && s != FormatCAS(digits) {
+		return "", ErrInvalidCAS
+	}
+	if digits[0] == '0' {
+		return "", ErrInvalidCAS
+	}
+	if casCheckDigit(digits[:len(digits)-1]) != digits[len(digits)-1] {
+		return "", ErrCASCheckDigit
+	}
+	return FormatCAS(digits), nil
+}
+
+// FormatCAS hyphenates a string of CAS digits without validating it
+func FormatCAS(digits string) string {
+	n := len(digits)
+	if n < 4 {
+		return digits
+	}
+	return digits[:n-3] + "-" + digits[n-3:n-1] + "-" + digits[n-1:]
+}
+
+// casCheckDigit computes the check digit: the last digit is weighted 1, the one before it 2,
+// and so on, and the check digit is the weighted sum modulo 10
+func casCheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		weight := len(digits) - i
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte('0' + sum%10)
+}
+
+// CASInput is a CAS number in a request body, sent either as a JSON number (7647145)
+// or as a string with or without hyphens ("7647-14-5"). It is validated with ParseCAS.
+type CASInput string
+
+func (c *CASInput) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*c = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = CASInput(s)
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return ErrInvalidCAS
+	}
+	if n == 0 {
+		*c = ""
+		return nil
+	}
+	*c = CASInput(strconv.FormatInt(n, 10))
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Chemical is a chemical container in a school's inventory
+type Chemical struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Synonyms         []string  `json:"synonyms"`                // other names the chemical goes by, such as propan-2-one for acetone
+	CAS              string    `json:"CAS" example:"7647-14-5"` // canonical hyphenated CAS Registry Number
+	School           string    `json:"school"`
+	PurchaseDate     Date      `json:"purchase_date" swaggertype:"string" format:"date"`
+	ExpirationDate   Date      `json:"expiration_date" swaggertype:"string" format:"date"`
+	Status           string    `json:"status"`                // condition of the container, such as Good or Off-site
+	ContainerSize    Quantity  `json:"container_size"`        // capacity of a full container
+	Remaining        Quantity  `json:"remaining"`             // amount left in the container
+	ReorderThreshold Quantity  `json:"reorder_threshold"`     // stock counts as low at or below this amount
+	LocationID       string    `json:"location_id,omitempty"` // room, cabinet or shelf the chemical is kept in
+	Room             string    `json:"room"`
+	Cabinet          int       `json:"cabinet"`
+	Shelf            int       `json:"shelf"`
+	SDSURL           string    `json:"sdsURL,omitempty"`                            // URL of the uploaded safety data sheet
+	SDSDate          Date      `json:"sds_date" swaggertype:"string" format:"date"` // revision date of the safety data sheet
+	CheckedOut       *Checkout `json:"checked_out,omitempty"`                       // set while the container is checked out
+	Deleted          *Deletion `json:"deleted,omitempty"`                           // set while the chemical is in the trash
+	Disposal         *Disposal `json:"disposal,omitempty"`                          // set once the chemical is flagged for disposal
+	Hazards          Hazards   `json:"hazards"`                                     // GHS classification
+	StorageGroups    []string  `json:"storage_groups"`                              // storage groups on top of those implied by the hazards, such as acid
+	Notes            string    `json:"notes"`
+}
+
+// LowStock reports whether the remaining amount has reached the reorder threshold.
+// Without a threshold, or with units that cannot be compared, stock is never low.
+func (c Chemical) LowStock() bool {
+	if c.Remaining.IsZero() || c.ReorderThreshold.IsZero() {
+		return false
+	}
+	cmp, err := c.Remaining.Compare(c.ReorderThreshold)
+	return err == nil && cmp <= 0
+}
+
+// NormalizeSynonyms trims the synonyms, collapsing runs of whitespace, and drops empty ones and
+// those repeating an earlier one in another case
+func NormalizeSynonyms(synonyms []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, synonym := range synonyms {
+		synonym = strings.Join(strings.Fields(synonym), " ")
+		if key := strings.ToLower(synonym); synonym != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, synonym)
+		}
+	}
+	return out
+}
+
+// MarshalJSON adds the computed low_stock flag, and the remaining amount as the
+// "500 mL" quantity string older clients display
+func (c Chemical) MarshalJSON() ([]byte, error) {
+	type chemical Chemical
+	return json.Marshal(struct {
+		chemical
+		Quantity string `json:"quantity"`
+		LowStock bool   `json:"low_stock"`
+	}{chemical(c), c.Remaining.String(), c.LowStock()})
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Deletion records who moved a record to the trash and when. Trashed records are hidden
+// until they are restored, and removed for good once the retention period has passed.
+type Deletion struct {
+	By string    `json:"by"` // ID of the user who deleted the record
+	At time.Time `json:"at"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DisposalState is the step a chemical has reached on its way to hazardous waste disposal
+type DisposalState string
+
+const (
+	DisposalFlagged       DisposalState = "flagged"        // marked for disposal, waiting for an admin to approve it
+	DisposalPendingPickup DisposalState = "pending_pickup" // approved, waiting for the waste vendor to collect it
+	DisposalDisposed      DisposalState = "disposed"       // collected by the vendor under a waste manifest
+)
+
+// ErrDisposalState is returned when a disposal step does not follow from the current one
+var ErrDisposalState = errors.New("the chemical is not at the right disposal step for this action")
+
+// ErrDisposed is returned when using a chemical that has been disposed of
+var ErrDisposed = errors.New("the chemical has been disposed of")
+
+// Disposal records the disposal of a chemical, from flagging it to the vendor collecting it.
+// The fields of later steps are empty until the chemical reaches them.
+type Disposal struct {
+	State          DisposalState `json:"state"`
+	Reason         string        `json:"reason,omitempty"` // why the chemical is disposed of, such as expired or no longer needed
+	FlaggedBy      string        `json:"flagged_by"`
+	FlaggedAt      time.Time     `json:"flagged_at"`
+	Method         string        `json:"method,omitempty"`          // how it is disposed of, such as lab pack or incineration
+	Vendor         string        `json:"vendor,omitempty"`          // waste vendor collecting it
+	ApprovedBy     string        `json:"approved_by,omitempty"`     // ID of the admin who approved the disposal
+	ApprovedAt     time.Time     `json:"approved_at"`               // zero until approved
+	ManifestNumber string        `json:"manifest_number,omitempty"` // hazardous waste manifest the vendor collected it under
+	DisposedAt     time.Time     `json:"disposed_at"`               // zero until disposed of
+}
+
+// Since returns when the chemical reached its current disposal step
+func (d Disposal) Since() time.Time {
+	switch d.State {
+	case DisposalPendingPickup:
+		return d.ApprovedAt
+	case DisposalDisposed:
+		return d.DisposedAt
+	default:
+		return d.FlaggedAt
+	}
+}
+
+// IsDisposed reports whether the chemical has left the school as waste
+func (c Chemical) IsDisposed() bool {
+	return c.Disposal != nil && c.Disposal.State == DisposalDisposed
+}
+
+// FlagForDisposal marks a chemical for disposal
+func (c *Chemical) FlagForDisposal(by, reason string, at time.Time) error {
+	if c.Disposal != nil {
+		return ErrDisposalState
+	}
+	c.Disposal = &Disposal{State: DisposalFlagged, Reason: reason, FlaggedBy: by, FlaggedAt: at}
+	return nil
+}
+
+// ApproveDisposal approves a flagged chemical for pickup by a waste vendor
+func (c *Chemical) ApproveDisposal(by, method, vendor string, at time.Time) error {
+	if c.Disposal == nil || c.Disposal.State != DisposalFlagged {
+		return ErrDisposalState
+	}
+	d := *c.Disposal
+	d.State, d.Method, d.Vendor, d.ApprovedBy, d.ApprovedAt = DisposalPendingPickup, method, vendor, by, at
+	c.Disposal = &d
+	return nil
+}
+
+// CompleteDisposal records the vendor collecting a chemical approved for pickup. Nothing
+// remains of a disposed chemical.
+func (c *Chemical) CompleteDisposal(manifestNumber string, at time.Time) error {
+	if c.Disposal == nil || c.Disposal.State != DisposalPendingPickup {
+		return ErrDisposalState
+	}
+	d := *c.Disposal
+	d.State, d.ManifestNumber, d.DisposedAt = DisposalDisposed, manifestNumber, at
+	c.Disposal = &d
+	c.Remaining.Amount = 0
+	return nil
+}
+
+// CancelDisposal keeps a chemical that was flagged or approved for disposal
+func (c *Chemical) CancelDisposal() error {
+	if c.Disposal == nil || c.IsDisposed() {
+		return ErrDisposalState
+	}
+	c.Disposal = nil
+	return nil
+}
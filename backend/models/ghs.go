@@ -0,0 +1,378 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidHazard is returned when hazard data uses a code missing from the GHS reference table
+var ErrInvalidHazard = errors.New("invalid GHS hazard data")
+
+// GHS signal words, Danger for the more severe hazard categories
+const (
+	SignalDanger  = "Danger"
+	SignalWarning = "Warning"
+)
+
+// Hazards is the GHS classification of a chemical, as printed on its label and safety data sheet.
+// Codes are validated against the built-in reference table, see GHSTable.
+type Hazards struct {
+	Classes                 []string `json:"classes,omitempty"`                  // hazard classes, such as flammable_liquid
+	HazardStatements        []string `json:"hazard_statements,omitempty"`        // H-statements, such as H225
+	PrecautionaryStatements []string `json:"precautionary_statements,omitempty"` // P-statements, combined ones as P303+P361+P353
+	SignalWord              string   `json:"signal_word,omitempty"`              // Danger or Warning
+	Pictograms              []string `json:"pictograms,omitempty"`               // GHS01 to GHS09
+}
+
+// IsZero reports whether the chemical has no hazard data
+func (h Hazards) IsZero() bool {
+	return len(h.Classes) == 0 && len(h.HazardStatements) == 0 && len(h.PrecautionaryStatements) == 0 &&
+		h.SignalWord == "" && len(h.Pictograms) == 0
+}
+
+// Normalize checks every code against the reference table and returns the hazards in canonical
+// form: codes upper case (classes lower case), sorted and without duplicates. Combined
+// H-statements such as H300+H310 are split, since they mean the same as their parts.
+func (h Hazards) Normalize() (Hazards, error) {
+	var out Hazards
+	var err error
+	if out.Classes, err = normalizeCodes(h.Classes, strings.ToLower, ghsClassIndex, "hazard class", false); err != nil {
+		return Hazards{}, err
+	}
+	if out.HazardStatements, err = normalizeCodes(h.HazardStatements, strings.ToUpper, ghsHazardIndex, "hazard statement", true); err != nil {
+		return Hazards{}, err
+	}
+	if out.PrecautionaryStatements, err = normalizeCodes(h.PrecautionaryStatements, strings.ToUpper, ghsPrecautionaryIndex, "precautionary statement", false); err != nil {
+		return Hazards{}, err
+	}
+	if out.Pictograms, err = normalizeCodes(h.Pictograms, strings.ToUpper, ghsPictogramIndex, "pictogram", false); err != nil {
+		return Hazards{}, err
+	}
+	switch strings.ToLower(strings.TrimSpace(h.SignalWord)) {
+	case "":
+	case "danger":
+		out.SignalWord = SignalDanger
+	case "warning":
+		out.SignalWord = SignalWarning
+	default:
+		return Hazards{}, fmt.Errorf("%w: signal word must be Danger or Warning, not %q", ErrInvalidHazard, h.SignalWord)
+	}
+	return out, nil
+}
+
+// normalizeCodes canonicalizes a list of codes. Codes joined with + must each be known; they are
+// split into separate codes when split is set and kept together otherwise.
+func normalizeCodes(codes []string, canonical func(string) string, known map[string]string, kind string, split bool) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, code := range codes {
+		parts := strings.Split(canonical(strings.ReplaceAll(code, " ", "")), "+")
+		for _, part := range parts {
+			if _, ok := known[part]; !ok {
+				return nil, fmt.Errorf("%w: unknown %s %q", ErrInvalidHazard, kind, code)
+			}
+		}
+		if !split {
+			parts = []string{strings.Join(parts, "+")}
+		}
+		for _, part := range parts {
+			if !seen[part] {
+				seen[part] = true
+				out = append(out, part)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// GHSEntry is a code of the GHS reference table with its English text
+type GHSEntry struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// GHSReference is the built-in GHS reference table that hazard data is validated against
+type GHSReference struct {
+	Classes                 []GHSEntry `json:"classes"`
+	HazardStatements        []GHSEntry `json:"hazard_statements"`
+	PrecautionaryStatements []GHSEntry `json:"precautionary_statements"`
+	Pictograms              []GHSEntry `json:"pictograms"`
+	SignalWords             []string   `json:"signal_words"`
+}
+
+// GHSTable returns the reference table of GHS hazard classes, statements and pictograms
+func GHSTable() GHSReference {
+	return GHSReference{
+		Classes:                 ghsClasses,
+		HazardStatements:        ghsHazardStatements,
+		PrecautionaryStatements: ghsPrecautionaryStatements,
+		Pictograms:              ghsPictograms,
+		SignalWords:             []string{SignalDanger, SignalWarning},
+	}
+}
+
+var (
+	ghsClassIndex         = indexEntries(ghsClasses)
+	ghsHazardIndex        = indexEntries(ghsHazardStatements)
+	ghsPrecautionaryIndex = indexEntries(ghsPrecautionaryStatements)
+	ghsPictogramIndex     = indexEntries(ghsPictograms)
+)
+
+func indexEntries(entries []GHSEntry) map[string]string {
+	index := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		index[entry.Code] = entry.Description
+	}
+	return index
+}
+
+// Hazard classes of the GHS, physical hazards first, then health and environmental hazards
+var ghsClasses = []GHSEntry{
+	{"explosive", "Explosives"},
+	{"flammable_gas", "Flammable gases"},
+	{"aerosol", "Aerosols"},
+	{"oxidizing_gas", "Oxidizing gases"},
+	{"gas_under_pressure", "Gases under pressure"},
+	{"flammable_liquid", "Flammable liquids"},
+	{"flammable_solid", "Flammable solids"},
+	{"self_reactive", "Self-reactive substances and mixtures"},
+	{"pyrophoric_liquid", "Pyrophoric liquids"},
+	{"pyrophoric_solid", "Pyrophoric solids"},
+	{"self_heating", "Self-heating substances and mixtures"},
+	{"water_reactive", "Substances and mixtures which, in contact with water, emit flammable gases"},
+	{"oxidizing_liquid", "Oxidizing liquids"},
+	{"oxidizing_solid", "Oxidizing solids"},
+	{"organic_peroxide", "Organic peroxides"},
+	{"corrosive_to_metals", "Corrosive to metals"},
+	{"desensitized_explosive", "Desensitized explosives"},
+	{"acute_toxicity", "Acute toxicity"},
+	{"skin_corrosion", "Skin corrosion/irritation"},
+	{"eye_damage", "Serious eye damage/eye irritation"},
+	{"respiratory_sensitizer", "Respiratory sensitization"},
+	{"skin_sensitizer", "Skin sensitization"},
+	{"germ_cell_mutagenicity", "Germ cell mutagenicity"},
+	{"carcinogenicity", "Carcinogenicity"},
+	{"reproductive_toxicity", "Reproductive toxicity"},
+	{"stot_single", "Specific target organ toxicity, single exposure"},
+	{"stot_repeated", "Specific target organ toxicity, repeated exposure"},
+	{"aspiration_hazard", "Aspiration hazard"},
+	{"aquatic_hazard", "Hazardous to the aquatic environment"},
+	{"ozone_hazard", "Hazardous to the ozone layer"},
+}
+
+// GHS pictograms
+var ghsPictograms = []GHSEntry{
+	{"GHS01", "Exploding bomb"},
+	{"GHS02", "Flame"},
+	{"GHS03", "Flame over circle"},
+	{"GHS04", "Gas cylinder"},
+	{"GHS05", "Corrosion"},
+	{"GHS06", "Skull and crossbones"},
+	{"GHS07", "Exclamation mark"},
+	{"GHS08", "Health hazard"},
+	{"GHS09", "Environment"},
+}
+
+// GHS hazard statements
+var ghsHazardStatements = []GHSEntry{
+	{"H200", "Unstable explosive"},
+	{"H201", "Explosive; mass explosion hazard"},
+	{"H202", "Explosive; severe projection hazard"},
+	{"H203", "Explosive; fire, blast or projection hazard"},
+	{"H204", "Fire or projection hazard"},
+	{"H205", "May mass explode in fire"},
+	{"H206", "Fire, blast or projection hazard; increased risk of explosion if desensitizing agent is reduced"},
+	{"H207", "Fire or projection hazard; increased risk of explosion if desensitizing agent is reduced"},
+	{"H208", "Fire hazard; increased risk of explosion if desensitizing agent is reduced"},
+	{"H209", "Explosive"},
+	{"H210", "Very sensitive"},
+	{"H211", "May be sensitive"},
+	{"H220", "Extremely flammable gas"},
+	{"H221", "Flammable gas"},
+	{"H222", "Extremely flammable aerosol"},
+	{"H223", "Flammable aerosol"},
+	{"H224", "Extremely flammable liquid and vapour"},
+	{"H225", "Highly flammable liquid and vapour"},
+	{"H226", "Flammable liquid and vapour"},
+	{"H227", "Combustible liquid"},
+	{"H228", "Flammable solid"},
+	{"H229", "Pressurized container: may burst if heated"},
+	{"H230", "May react explosively even in the absence of air"},
+	{"H231", "May react explosively even in the absence of air at elevated pressure and/or temperature"},
+	{"H232", "May ignite spontaneously if exposed to air"},
+	{"H240", "Heating may cause an explosion"},
+	{"H241", "Heating may cause a fire or explosion"},
+	{"H242", "Heating may cause a fire"},
+	{"H250", "Catches fire spontaneously if exposed to air"},
+	{"H251", "Self-heating; may catch fire"},
+	{"H252", "Self-heating in large quantities; may catch fire"},
+	{"H260", "In contact with water releases flammable gases which may ignite spontaneously"},
+	{"H261", "In contact with water releases flammable gas"},
+	{"H270", "May cause or intensify fire; oxidizer"},
+	{"H271", "May cause fire or explosion; strong oxidizer"},
+	{"H272", "May intensify fire; oxidizer"},
+	{"H280", "Contains gas under pressure; may explode if heated"},
+	{"H281", "Contains refrigerated gas; may cause cryogenic burns or injury"},
+	{"H282", "Extremely flammable chemical under pressure: may explode if heated"},
+	{"H283", "Flammable chemical under pressure: may explode if heated"},
+	{"H284", "Chemical under pressure: may explode if heated"},
+	{"H290", "May be corrosive to metals"},
+	{"H300", "Fatal if swallowed"},
+	{"H301", "Toxic if swallowed"},
+	{"H302", "Harmful if swallowed"},
+	{"H303", "May be harmful if swallowed"},
+	{"H304", "May be fatal if swallowed and enters airways"},
+	{"H305", "May be harmful if swallowed and enters airways"},
+	{"H310", "Fatal in contact with skin"},
+	{"H311", "Toxic in contact with skin"},
+	{"H312", "Harmful in contact with skin"},
+	{"H313", "May be harmful in contact with skin"},
+	{"H314", "Causes severe skin burns and eye damage"},
+	{"H315", "Causes skin irritation"},
+	{"H316", "Causes mild skin irritation"},
+	{"H317", "May cause an allergic skin reaction"},
+	{"H318", "Causes serious eye damage"},
+	{"H319", "Causes serious eye irritation"},
+	{"H320", "Causes eye irritation"},
+	{"H330", "Fatal if inhaled"},
+	{"H331", "Toxic if inhaled"},
+	{"H332", "Harmful if inhaled"},
+	{"H333", "May be harmful if inhaled"},
+	{"H334", "May cause allergy or asthma symptoms or breathing difficulties if inhaled"},
+	{"H335", "May cause respiratory irritation"},
+	{"H336", "May cause drowsiness or dizziness"},
+	{"H340", "May cause genetic defects"},
+	{"H341", "Suspected of causing genetic defects"},
+	{"H350", "May cause cancer"},
+	{"H351", "Suspected of causing cancer"},
+	{"H360", "May damage fertility or the unborn child"},
+	{"H361", "Suspected of damaging fertility or the unborn child"},
+	{"H362", "May cause harm to breast-fed children"},
+	{"H370", "Causes damage to organs"},
+	{"H371", "May cause damage to organs"},
+	{"H372", "Causes damage to organs through prolonged or repeated exposure"},
+	{"H373", "May cause damage to organs through prolonged or repeated exposure"},
+	{"H400", "Very toxic to aquatic life"},
+	{"H401", "Toxic to aquatic life"},
+	{"H402", "Harmful to aquatic life"},
+	{"H410", "Very toxic to aquatic life with long lasting effects"},
+	{"H411", "Toxic to aquatic life with long lasting effects"},
+	{"H412", "Harmful to aquatic life with long lasting effects"},
+	{"H413", "May cause long lasting harmful effects to aquatic life"},
+	{"H420", "Harms public health and the environment by destroying ozone in the upper atmosphere"},
+}
+
+// GHS precautionary statements. Combined statements such as P301+P310 are built from these.
+var ghsPrecautionaryStatements = []GHSEntry{
+	{"P101", "If medical advice is needed, have product container or label at hand."},
+	{"P102", "Keep out of reach of children."},
+	{"P103", "Read label before use."},
+	{"P201", "Obtain special instructions before use."},
+	{"P202", "Do not handle until all safety precautions have been read and understood."},
+	{"P203", "Obtain, read and follow all safety instructions before use."},
+	{"P210", "Keep away from heat, hot surfaces, sparks, open flames and other ignition sources. No smoking."},
+	{"P211", "Do not spray on an open flame or other ignition source."},
+	{"P212", "Avoid heating under confinement or reduction of the desensitizing agent."},
+	{"P220", "Keep away from clothing and other combustible materials."},
+	{"P221", "Take any precaution to avoid mixing with combustibles."},
+	{"P222", "Do not allow contact with air."},
+	{"P223", "Do not allow contact with water."},
+	{"P230", "Keep wetted with..."},
+	{"P231", "Handle and store contents under inert gas."},
+	{"P232", "Protect from moisture."},
+	{"P233", "Keep container tightly closed."},
+	{"P234", "Keep only in original packaging."},
+	{"P235", "Keep cool."},
+	{"P236", "Keep only in original packaging in the transport configuration."},
+	{"P240", "Ground and bond container and receiving equipment."},
+	{"P241", "Use explosion-proof electrical, ventilating and lighting equipment."},
+	{"P242", "Use non-sparking tools."},
+	{"P243", "Take action to prevent static discharges."},
+	{"P244", "Keep valves and fittings free from oil and grease."},
+	{"P250", "Do not subject to grinding/shock/friction."},
+	{"P251", "Do not pierce or burn, even after use."},
+	{"P260", "Do not breathe dust/fume/gas/mist/vapours/spray."},
+	{"P261", "Avoid breathing dust/fume/gas/mist/vapours/spray."},
+	{"P262", "Do not get in eyes, on skin, or on clothing."},
+	{"P263", "Avoid contact during pregnancy and while nursing."},
+	{"P264", "Wash hands thoroughly after handling."},
+	{"P265", "Do not touch eyes."},
+	{"P270", "Do not eat, drink or smoke when using this product."},
+	{"P271", "Use only outdoors or in a well-ventilated area."},
+	{"P272", "Contaminated work clothing should not be allowed out of the workplace."},
+	{"P273", "Avoid release to the environment."},
+	{"P280", "Wear protective gloves/protective clothing/eye protection/face protection/hearing protection."},
+	{"P281", "Use personal protective equipment as required."},
+	{"P282", "Wear cold insulating gloves and either face shield or eye protection."},
+	{"P283", "Wear fire resistant or flame retardant clothing."},
+	{"P284", "In case of inadequate ventilation wear respiratory protection."},
+	{"P301", "IF SWALLOWED:"},
+	{"P302", "IF ON SKIN:"},
+	{"P303", "IF ON SKIN (or hair):"},
+	{"P304", "IF INHALED:"},
+	{"P305", "IF IN EYES:"},
+	{"P306", "IF ON CLOTHING:"},
+	{"P308", "IF exposed or concerned:"},
+	{"P310", "Immediately call a POISON CENTER/doctor."},
+	{"P311", "Call a POISON CENTER/doctor."},
+	{"P312", "Call a POISON CENTER/doctor if you feel unwell."},
+	{"P313", "Get medical advice/attention."},
+	{"P314", "Get medical advice/attention if you feel unwell."},
+	{"P315", "Get immediate medical advice/attention."},
+	{"P316", "Get emergency medical help immediately."},
+	{"P317", "Get medical help."},
+	{"P318", "If exposed or concerned, get medical advice."},
+	{"P319", "Get medical help if you feel unwell."},
+	{"P320", "Specific treatment is urgent (see ... on this label)."},
+	{"P321", "Specific treatment (see ... on this label)."},
+	{"P330", "Rinse mouth."},
+	{"P331", "Do NOT induce vomiting."},
+	{"P332", "If skin irritation occurs:"},
+	{"P333", "If skin irritation or rash occurs:"},
+	{"P334", "Immerse in cool water or wrap in wet bandages."},
+	{"P335", "Brush off loose particles from skin."},
+	{"P336", "Thaw frosted parts with lukewarm water. Do not rub affected area."},
+	{"P337", "If eye irritation persists:"},
+	{"P338", "Remove contact lenses, if present and easy to do. Continue rinsing."},
+	{"P340", "Remove person to fresh air and keep comfortable for breathing."},
+	{"P342", "If experiencing respiratory symptoms:"},
+	{"P351", "Rinse cautiously with water for several minutes."},
+	{"P352", "Wash with plenty of water."},
+	{"P353", "Rinse skin with water or shower."},
+	{"P354", "Immediately rinse with water for several minutes."},
+	{"P360", "Rinse immediately contaminated clothing and skin with plenty of water before removing clothes."},
+	{"P361", "Take off immediately all contaminated clothing."},
+	{"P362", "Take off contaminated clothing."},
+	{"P363", "Wash contaminated clothing before reuse."},
+	{"P364", "And wash it before reuse."},
+	{"P370", "In case of fire:"},
+	{"P371", "In case of major fire and large quantities:"},
+	{"P372", "Explosion risk."},
+	{"P373", "DO NOT fight fire when fire reaches explosives."},
+	{"P375", "Fight fire remotely due to the risk of explosion."},
+	{"P376", "Stop leak if safe to do so."},
+	{"P377", "Leaking gas fire: Do not extinguish, unless leak can be stopped safely."},
+	{"P378", "Use ... to extinguish."},
+	{"P380", "Evacuate area."},
+	{"P381", "In case of leakage, eliminate all ignition sources."},
+	{"P390", "Absorb spillage to prevent material damage."},
+	{"P391", "Collect spillage."},
+	{"P401", "Store in accordance with..."},
+	{"P402", "Store in a dry place."},
+	{"P403", "Store in a well-ventilated place."},
+	{"P404", "Store in a closed container."},
+	{"P405", "Store locked up."},
+	{"P406", "Store in a corrosion resistant container with a resistant inner liner."},
+	{"P407", "Maintain air gap between stacks or pallets."},
+	{"P410", "Protect from sunlight."},
+	{"P411", "Store at temperatures not exceeding ..."},
+	{"P412", "Do not expose to temperatures exceeding 50 °C/122 °F."},
+	{"P413", "Store bulk masses greater than ... at temperatures not exceeding ..."},
+	{"P420", "Store separately."},
+	{"P501", "Dispose of contents/container to..."},
+	{"P502", "Refer to manufacturer or supplier for information on recovery or recycling."},
+	{"P503", "Refer to manufacturer/supplier/... for information on disposal/recovery/recycling."},
+}
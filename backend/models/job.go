@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// JobStatus is the outcome of a run of a scheduled job
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// JobTrigger tells what started a run of a job
+type JobTrigger string
+
+const (
+	JobScheduled JobTrigger = "schedule" // the job's cron schedule came due
+	JobManual    JobTrigger = "manual"   // an administrator asked for it
+)
+
+// JobRun is one run of a background job, kept as its history
+type JobRun struct {
+	ID          string     `json:"id"`
+	Job         string     `json:"job"`
+	Trigger     JobTrigger `json:"trigger"`
+	TriggeredBy string     `json:"triggered_by,omitempty"` // user who started a manual run
+	Instance    string     `json:"instance"`               // API replica the job ran on
+	ScheduledAt time.Time  `json:"scheduled_at"`           // the time the run was due, or was asked for
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"` // unset while running
+	DurationMS  int64      `json:"duration_ms"`
+	Status      JobStatus  `json:"status"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Finish records the end of the run, failed when err is not nil
+func (r *JobRun) Finish(at time.Time, err error) {
+	r.FinishedAt = &at
+	r.DurationMS = at.Sub(r.StartedAt).Milliseconds()
+	r.Status = JobSucceeded
+	r.Error = ""
+	if err != nil {
+		r.Status = JobFailed
+		r.Error = err.Error()
+	}
+}
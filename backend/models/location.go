@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LocationKind is the level of a location in the hierarchy school → building → room → cabinet → shelf
+type LocationKind string
+
+const (
+	LocationSchool   LocationKind = "school"
+	LocationBuilding LocationKind = "building"
+	LocationRoom     LocationKind = "room"
+	LocationCabinet  LocationKind = "cabinet"
+	LocationShelf    LocationKind = "shelf"
+)
+
+// ErrLocationKind is returned when a location is put under a parent that cannot hold it
+var ErrLocationKind = errors.New("a location of this kind cannot be placed there")
+
+// Location is a place chemicals are kept, from a whole school down to a single shelf
+type Location struct {
+	ID         string       `json:"id"`
+	ParentID   string       `json:"parent_id,omitempty"` // empty for schools
+	Kind       LocationKind `json:"kind"`
+	Name       string       `json:"name"`
+	School     string       `json:"school"`     // name of the school the location belongs to
+	Capacity   int          `json:"capacity"`   // most chemical containers it holds, 0 for no limit
+	Attributes []string     `json:"attributes"` // such as ventilated or flammables_cabinet
+}
+
+// locationParents lists the kinds of location each kind can be placed under. Rooms can sit
+// directly in a school that has no separate buildings.
+var locationParents = map[LocationKind][]LocationKind{
+	LocationSchool:   nil,
+	LocationBuilding: {LocationSchool},
+	LocationRoom:     {LocationSchool, LocationBuilding},
+	LocationCabinet:  {LocationRoom},
+	LocationShelf:    {LocationCabinet},
+}
+
+// Valid reports whether the kind is part of the hierarchy
+func (k LocationKind) Valid() bool {
+	_, ok := locationParents[k]
+	return ok
+}
+
+// CanHold reports whether a location of this kind can be placed under one of the parent kind
+func (k LocationKind) CanHold(child LocationKind) bool {
+	for _, parent := range locationParents[child] {
+		if parent == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Stores reports whether chemicals can be placed directly in a location of this kind
+func (k LocationKind) Stores() bool {
+	return k == LocationRoom || k == LocationCabinet || k == LocationShelf
+}
+
+var (
+	locationPrefix = regexp.MustCompile(`^(room|rm|cabinet|cab|shelf|building|bldg)\b\.?\s*#?\s*`)
+	locationSpaces = regexp.MustCompile(`[\s_-]+`)
+	attributeChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// LocationKey reduces a location name to the form used to tell whether two names are the
+// same place, so that "Room 101", "rm. 101" and "101" all give "101"
+func LocationKey(name string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = locationPrefix.ReplaceAllString(key, "")
+	return strings.TrimSpace(locationSpaces.ReplaceAllString(key, " "))
+}
+
+// NormalizeAttributes returns location attributes lower case with underscores, sorted and
+// without duplicates, so "Flammables Cabinet" becomes flammables_cabinet
+func NormalizeAttributes(attributes []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, attribute := range attributes {
+		attribute = strings.Trim(attributeChars.ReplaceAllString(strings.ToLower(attribute), "_"), "_")
+		if attribute != "" && !seen[attribute] {
+			seen[attribute] = true
+			out = append(out, attribute)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Unit is a unit a chemical amount is measured in
+type Unit string
+
+const (
+	Gram       Unit = "g"
+	Kilogram   Unit = "kg"
+	Milliliter Unit = "mL"
+	Liter      Unit = "L"
+	Units      Unit = "units" // countable items such as ampoules or test strips
+)
+
+// ErrInvalidUnit is returned for units other than g, kg, mL, L and units
+var ErrInvalidUnit = errors.New("invalid unit, expected g, kg, mL, L or units")
+
+// ErrInvalidQuantity is returned for amounts that are negative or cannot be read
+var ErrInvalidQuantity = errors.New("invalid quantity, expected a non-negative amount and a unit such as \"500 mL\"")
+
+// ErrIncompatibleUnits is returned when converting between mass, volume and counts
+var ErrIncompatibleUnits = errors.New("incompatible units")
+
+// unitAliases maps the accepted spellings, in lower case, onto the canonical units
+var unitAliases = map[string]Unit{
+	"g": Gram, "gram": Gram, "grams": Gram,
+	"kg": Kilogram, "kilogram": Kilogram, "kilograms": Kilogram,
+	"ml": Milliliter, "milliliter": Milliliter, "milliliters": Milliliter, "millilitre": Milliliter, "millilitres": Milliliter,
+	"l": Liter, "liter": Liter, "liters": Liter, "litre": Liter, "litres": Liter,
+	"unit": Units, "units": Units, "pcs": Units, "count": Units,
+}
+
+// unitScale gives each unit's dimension and its size in the base unit of that dimension (g, mL or units)
+var unitScale = map[Unit]struct {
+	dimension string
+	factor    float64
+}{
+	Gram:       {"mass", 1},
+	Kilogram:   {"mass", 1000},
+	Milliliter: {"volume", 1},
+	Liter:      {"volume", 1000},
+	Units:      {"count", 1},
+}
+
+// ParseUnit returns the canonical unit for any accepted spelling, ignoring case
+func ParseUnit(s string) (Unit, error) {
+	if u, ok := unitAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return u, nil
+	}
+	return "", ErrInvalidUnit
+}
+
+// Quantity is an amount of a chemical. The zero Quantity, without a unit, means it is not known.
+type Quantity struct {
+	Amount float64 `json:"amount"`
+	Unit   Unit    `json:"unit"`
+}
+
+// IsZero reports whether the quantity is unknown
+func (q Quantity) IsZero() bool {
+	return q.Unit == ""
+}
+
+// ParseQuantity reads a quantity written as "500 mL", "2.5kg" or a bare count such as "12".
+// An empty string gives the zero Quantity.
+func ParseQuantity(s string) (Quantity, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Quantity{}, nil
+	}
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.')
+	})
+	number, unit := s, "units"
+	if i >= 0 {
+		number, unit = s[:i], s[i:]
+	}
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return Quantity{}, ErrInvalidQuantity
+	}
+	u, err := ParseUnit(unit)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Amount: amount, Unit: u}, nil
+}
+
+// Validate checks that a known quantity has a supported unit and a non-negative amount
+func (q Quantity) Validate() error {
+	if q.IsZero() {
+		return nil
+	}
+	if _, ok := unitScale[q.Unit]; !ok {
+		return ErrInvalidUnit
+	}
+	if q.Amount < 0 {
+		return ErrInvalidQuantity
+	}
+	return nil
+}
+
+// Convert expresses the quantity in another unit of the same dimension
+func (q Quantity) Convert(to Unit) (Quantity, error) {
+	from, ok := unitScale[q.Unit]
+	if !ok {
+		return Quantity{}, ErrInvalidUnit
+	}
+	target, ok := unitScale[to]
+	if !ok {
+		return Quantity{}, ErrInvalidUnit
+	}
+	if from.dimension != target.dimension {
+		return Quantity{}, ErrIncompatibleUnits
+	}
+	return Quantity{Amount: q.Amount * from.factor / target.factor, Unit: to}, nil
+}
+
+// Compare returns -1, 0 or 1 as q is less than, equal to or greater than other,
+// or ErrIncompatibleUnits when they measure different things
+func (q Quantity) Compare(other Quantity) (int, error) {
+	converted, err := other.Convert(q.Unit)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case q.Amount < converted.Amount:
+		return -1, nil
+	case q.Amount > converted.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// String formats the quantity as "500 mL", or "" when unknown
+func (q Quantity) String() string {
+	if q.IsZero() {
+		return ""
+	}
+	return strconv.FormatFloat(q.Amount, 'f', -1, 64) + " " + string(q.Unit)
+}
+
+// UnmarshalJSON accepts {"amount": 500, "unit": "mL"} as well as the string form "500 mL"
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*q = Quantity{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := ParseQuantity(s)
+		if err != nil {
+			return err
+		}
+		*q = parsed
+		return nil
+	}
+
+	var raw struct {
+		Amount float64 `json:"amount"`
+		Unit   string  `json:"unit"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ErrInvalidQuantity
+	}
+	if raw.Unit == "" {
+		*q = Quantity{}
+		return nil
+	}
+	u, err := ParseUnit(raw.Unit)
+	if err != nil {
+		return err
+	}
+	*q = Quantity{Amount: raw.Amount, Unit: u}
+	return q.Validate()
+}
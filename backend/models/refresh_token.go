@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token is kept.
+// Tokens rotated from the same login share a FamilyID, so a whole session can be revoked at once.
+type RefreshToken struct {
+	Hash      string
+	UserID    string
+	FamilyID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Revoked   bool
+	RevokedAt time.Time
+}
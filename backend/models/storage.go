@@ -0,0 +1,228 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidStorageGroup is returned for a storage group missing from the compatibility table
+var ErrInvalidStorageGroup = errors.New("invalid storage group")
+
+// Storage groups sort chemicals by what they may be stored next to
+const (
+	StorageAcid          = "acid"
+	StorageBase          = "base"
+	StorageOxidizer      = "oxidizer"
+	StorageFlammable     = "flammable"
+	StorageWaterReactive = "water_reactive" // releases flammable gas in contact with water
+	StoragePyrophoric    = "pyrophoric"     // ignites in air
+	StorageExplosive     = "explosive"
+	StorageToxic         = "toxic"
+)
+
+// Severity of a storage conflict
+const (
+	StorageWarning = "warning" // the chemicals should be kept apart
+	StorageBlocked = "blocked" // the chemicals must not share a shelf
+)
+
+// StorageConflict is a pair of chemicals sharing a shelf despite incompatible storage groups
+type StorageConflict struct {
+	ChemicalID   string `json:"chemical_id"`
+	ChemicalName string `json:"chemical_name"`
+	Group        string `json:"group"` // storage group of the chemical
+	OtherID      string `json:"other_id"`
+	OtherName    string `json:"other_name"`
+	OtherGroup   string `json:"other_group"` // storage group of the other chemical
+	Severity     string `json:"severity"`    // warning or blocked
+	Reason       string `json:"reason"`
+}
+
+type incompatibility struct {
+	severity string
+	reason   string
+}
+
+// storageIncompatibilities lists the pairs of storage groups that must be kept apart, in both orders
+var storageIncompatibilities = map[[2]string]incompatibility{}
+
+func init() {
+	for _, rule := range []struct {
+		a, b     string
+		severity string
+		reason   string
+	}{
+		{StorageOxidizer, StorageFlammable, StorageBlocked, "oxidizers intensify fires and can ignite flammables"},
+		{StorageOxidizer, StoragePyrophoric, StorageBlocked, "oxidizers intensify fires and can ignite pyrophorics"},
+		{StorageOxidizer, StorageExplosive, StorageBlocked, "oxidizers can set off explosives"},
+		{StorageOxidizer, StorageAcid, StorageWarning, "some acids react violently with oxidizers"},
+		{StorageAcid, StorageBase, StorageBlocked, "acids and bases neutralize violently, releasing heat"},
+		{StorageAcid, StorageWaterReactive, StorageBlocked, "water-reactive chemicals release flammable gas with aqueous acids"},
+		{StorageAcid, StorageFlammable, StorageWarning, "acids can ignite or degrade flammable solvents"},
+		{StorageBase, StorageWaterReactive, StorageWarning, "water-reactive chemicals react with aqueous bases"},
+		{StorageFlammable, StorageExplosive, StorageBlocked, "a fire can set off explosives"},
+		{StorageFlammable, StoragePyrophoric, StorageBlocked, "pyrophorics ignite in air and can set flammables alight"},
+		{StorageFlammable, StorageWaterReactive, StorageWarning, "a fire cannot be fought with water next to water-reactive chemicals"},
+		{StorageWaterReactive, StoragePyrophoric, StorageWarning, "both need to be kept dry and away from ignition sources"},
+		{StorageExplosive, StorageAcid, StorageBlocked, "acids can set off explosives"},
+		{StorageExplosive, StorageBase, StorageBlocked, "bases can set off explosives"},
+		{StorageExplosive, StoragePyrophoric, StorageBlocked, "pyrophorics can set off explosives"},
+		{StorageExplosive, StorageWaterReactive, StorageBlocked, "water-reactive chemicals can set off explosives"},
+		{StorageToxic, StorageAcid, StorageWarning, "acids can release toxic gas from some toxic chemicals"},
+	} {
+		storageIncompatibilities[[2]string{rule.a, rule.b}] = incompatibility{rule.severity, rule.reason}
+		storageIncompatibilities[[2]string{rule.b, rule.a}] = incompatibility{rule.severity, rule.reason}
+	}
+}
+
+// storageGroups is every known storage group
+var storageGroups = map[string]bool{
+	StorageAcid: true, StorageBase: true, StorageOxidizer: true, StorageFlammable: true,
+	StorageWaterReactive: true, StoragePyrophoric: true, StorageExplosive: true, StorageToxic: true,
+}
+
+// hazardStorageGroups gives the storage group implied by a GHS hazard class. Corrosives are
+// missing since GHS does not tell acids from bases.
+var hazardStorageGroups = map[string]string{
+	"explosive":              StorageExplosive,
+	"desensitized_explosive": StorageExplosive,
+	"self_reactive":          StorageExplosive,
+	"flammable_gas":          StorageFlammable,
+	"aerosol":                StorageFlammable,
+	"flammable_liquid":       StorageFlammable,
+	"flammable_solid":        StorageFlammable,
+	"self_heating":           StorageFlammable,
+	"pyrophoric_liquid":      StoragePyrophoric,
+	"pyrophoric_solid":       StoragePyrophoric,
+	"water_reactive":         StorageWaterReactive,
+	"oxidizing_gas":          StorageOxidizer,
+	"oxidizing_liquid":       StorageOxidizer,
+	"oxidizing_solid":        StorageOxidizer,
+	"organic_peroxide":       StorageOxidizer,
+	"acute_toxicity":         StorageToxic,
+}
+
+// NormalizeStorageGroups checks storage groups against the compatibility table and returns
+// them lower case, sorted and without duplicates
+func NormalizeStorageGroups(groups []string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, group := range groups {
+		group = strings.ToLower(strings.TrimSpace(group))
+		if !storageGroups[group] {
+			return nil, fmt.Errorf("%w %q", ErrInvalidStorageGroup, group)
+		}
+		if !seen[group] {
+			seen[group] = true
+			out = append(out, group)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// EffectiveStorageGroups returns the storage groups set on the chemical together with those
+// implied by its GHS hazard classes
+func (c Chemical) EffectiveStorageGroups() []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(group string) {
+		if group != "" && !seen[group] {
+			seen[group] = true
+			out = append(out, group)
+		}
+	}
+	for _, group := range c.StorageGroups {
+		add(group)
+	}
+	for _, class := range c.Hazards.Classes {
+		add(hazardStorageGroups[class])
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SameShelf reports whether two chemicals of the same school are stored on the same shelf.
+// Chemicals without a room have no known place.
+func (c Chemical) SameShelf(other Chemical) bool {
+	key, ok := c.shelfKey()
+	otherKey, otherOK := other.shelfKey()
+	return ok && otherOK && key == otherKey
+}
+
+// StorageConflicts lists the chemicals that cannot share a shelf with the chemical, with the
+// most severe conflict for each of them
+func (c Chemical) StorageConflicts(neighbours []Chemical) []StorageConflict {
+	groups := c.EffectiveStorageGroups()
+	var conflicts []StorageConflict
+	for _, other := range neighbours {
+		if other.ID == c.ID || !c.SameShelf(other) {
+			continue
+		}
+		var worst *StorageConflict
+		for _, group := range groups {
+			for _, otherGroup := range other.EffectiveStorageGroups() {
+				rule, ok := storageIncompatibilities[[2]string{group, otherGroup}]
+				if !ok || worst != nil && (worst.Severity == StorageBlocked || rule.severity != StorageBlocked) {
+					continue
+				}
+				worst = &StorageConflict{ChemicalID: c.ID, ChemicalName: c.Name, Group: group,
+					OtherID: other.ID, OtherName: other.Name, OtherGroup: otherGroup,
+					Severity: rule.severity, Reason: rule.reason}
+			}
+		}
+		if worst != nil {
+			conflicts = append(conflicts, *worst)
+		}
+	}
+	return conflicts
+}
+
+// HasBlockingConflict reports whether any of the conflicts forbids the placement
+func HasBlockingConflict(conflicts []StorageConflict) bool {
+	for _, conflict := range conflicts {
+		if conflict.Severity == StorageBlocked {
+			return true
+		}
+	}
+	return false
+}
+
+// shelfKey identifies a shelf by its school, room, cabinet and shelf. Chemicals filed under a
+// location carry the room, cabinet and shelf of that location, so they share a key with the
+// chemicals placed on the same shelf before the location hierarchy.
+type shelfKey struct {
+	school, room   string
+	cabinet, shelf int
+}
+
+// shelfKey returns the shelf the chemical is kept on, or false when it has no room
+func (c Chemical) shelfKey() (shelfKey, bool) {
+	if c.Room == "" {
+		return shelfKey{}, false
+	}
+	return shelfKey{school: c.School, room: strings.ToLower(c.Room), cabinet: c.Cabinet, shelf: c.Shelf}, true
+}
+
+// ShelfGroups groups the chemicals kept on the same shelf, leaving out those that have no
+// room or have been disposed of, so that each pair on a shelf can be checked once
+func ShelfGroups(chemicals []Chemical) [][]Chemical {
+	index := map[shelfKey]int{}
+	var groups [][]Chemical
+	for _, chemical := range chemicals {
+		key, ok := chemical.shelfKey()
+		if !ok || chemical.IsDisposed() {
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], chemical)
+	}
+	return groups
+}
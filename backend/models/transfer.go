@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// TransferStatus is the state of a request to move a chemical to another school
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"   // proposed by the sending school, waiting for the receiving school
+	TransferAccepted  TransferStatus = "accepted"  // the chemical has moved
+	TransferRejected  TransferStatus = "rejected"  // turned down by the receiving school
+	TransferCancelled TransferStatus = "cancelled" // withdrawn by the sending school
+)
+
+// ErrTransferDecided is returned when accepting, rejecting or cancelling a transfer that is no longer pending
+var ErrTransferDecided = errors.New("the transfer has already been accepted, rejected or cancelled")
+
+// ErrTransferPending is returned when proposing a transfer of a chemical that already has one pending
+var ErrTransferPending = errors.New("the chemical already has a pending transfer")
+
+// ErrTransferMoved is returned when accepting a transfer of a chemical that has left the sending school
+var ErrTransferMoved = errors.New("the chemical is no longer at the sending school")
+
+// ErrTransferCheckedOut is returned when transferring a container that is checked out
+var ErrTransferCheckedOut = errors.New("the container is checked out, check it in before transferring it")
+
+// ErrTransferDisposal is returned when transferring a chemical that is flagged or approved for disposal
+var ErrTransferDisposal = errors.New("the chemical is flagged for disposal, keep it before transferring it")
+
+// Transfer is a request to move a chemical, or part of its remaining amount, to another school.
+// The sending school proposes it and the receiving school accepts or rejects it.
+type Transfer struct {
+	ID           string         `json:"id"`
+	ChemicalID   string         `json:"chemical_id"`
+	ChemicalName string         `json:"chemical_name"`
+	FromSchool   string         `json:"from_school"`
+	ToSchool     string         `json:"to_school"`
+	Amount       Quantity       `json:"amount"` // part of the remaining amount to send, zero for the whole container
+	Notes        string         `json:"notes,omitempty"`
+	Status       TransferStatus `json:"status"`
+	RequestedBy  string         `json:"requested_by"`
+	RequestedAt  time.Time      `json:"requested_at"`
+	DecidedBy    string         `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time     `json:"decided_at,omitempty"`
+	Reason       string         `json:"reason,omitempty"` // why the transfer was rejected or cancelled
+
+	// Set when the transfer is accepted: where the receiving school keeps the chemical, and its
+	// ID there, which is a new chemical when only part of the remaining amount was sent
+	LocationID string `json:"location_id,omitempty"`
+	Room       string `json:"room,omitempty"`
+	Cabinet    int    `json:"cabinet,omitempty"`
+	Shelf      int    `json:"shelf,omitempty"`
+	ReceivedID string `json:"received_id,omitempty"`
+}
+
+// Whole reports whether the transfer sends the whole container rather than part of it
+func (t Transfer) Whole() bool {
+	return t.Amount.IsZero()
+}
+
+// CheckTransfer reports whether the chemical can be sent in the transfer as it stands
+func (c Chemical) CheckTransfer(t Transfer) error {
+	switch {
+	case c.IsDisposed():
+		return ErrDisposed
+	case c.Disposal != nil:
+		return ErrTransferDisposal
+	case c.School != t.FromSchool:
+		return ErrTransferMoved
+	case c.CheckedOut != nil:
+		return ErrTransferCheckedOut
+	}
+	if t.Whole() {
+		return nil
+	}
+	if err := t.Amount.Validate(); err != nil {
+		return err
+	}
+	if t.Amount.Amount <= 0 {
+		return ErrInvalidQuantity
+	}
+	if c.Remaining.IsZero() {
+		return ErrUnknownRemaining
+	}
+	sent, err := t.Amount.Convert(c.Remaining.Unit)
+	if err != nil {
+		return err
+	}
+	if sent.Amount > c.Remaining.Amount {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+// ApplyTransfer carries out an accepted transfer. A whole container moves to the receiving
+// school; otherwise the amount is taken from the chemical and returned as a new chemical at the
+// receiving school, with the ID left empty for the caller to store. Either way the received
+// chemical is placed where the transfer says.
+func (c *Chemical) ApplyTransfer(t *Transfer) (Chemical, error) {
+	if err := c.CheckTransfer(*t); err != nil {
+		return Chemical{}, err
+	}
+
+	var received Chemical
+	if t.Whole() {
+		received = *c
+	} else {
+		sent, _ := t.Amount.Convert(c.Remaining.Unit)
+		// Round away the float noise of unit conversions, such as 0.30000000000000004
+		c.Remaining.Amount = math.Round((c.Remaining.Amount-sent.Amount)*1e6) / 1e6
+		received = *c
+		received.ID = ""
+		received.Remaining = t.Amount
+		received.SDSURL, received.SDSDate = "", Date{}
+		received.StorageGroups = append([]string(nil), c.StorageGroups...)
+		received.Synonyms = append([]string(nil), c.Synonyms...)
+	}
+	received.School = t.ToSchool
+	received.LocationID, received.Room, received.Cabinet, received.Shelf = t.LocationID, t.Room, t.Cabinet, t.Shelf
+	if t.Whole() {
+		*c = received
+		t.ReceivedID = c.ID
+	}
+	return received, nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// UsageKind is the kind of event recorded in a chemical's usage ledger
+type UsageKind string
+
+const (
+	UsageWithdrawal UsageKind = "withdrawal" // an amount was taken from the container
+	UsageCheckOut   UsageKind = "checkout"   // the container was taken to a user and room
+	UsageCheckIn    UsageKind = "checkin"    // the container was returned, possibly with some of it used
+)
+
+// ErrInsufficientStock is returned when a usage event takes more than the container holds
+var ErrInsufficientStock = errors.New("the amount used is more than the remaining amount")
+
+// ErrUnknownRemaining is returned when an amount is used from a chemical whose remaining amount is not known
+var ErrUnknownRemaining = errors.New("the remaining amount of this chemical is not known, set it before logging usage")
+
+// ErrAlreadyCheckedOut is returned when checking out a container that is already checked out
+var ErrAlreadyCheckedOut = errors.New("the container is already checked out")
+
+// ErrNotCheckedOut is returned when checking in a container that is not checked out
+var ErrNotCheckedOut = errors.New("the container is not checked out")
+
+// UsageEvent is an entry in a chemical's usage ledger. Events are never changed once recorded.
+type UsageEvent struct {
+	ID         string    `json:"id"`
+	ChemicalID string    `json:"chemical_id"`
+	School     string    `json:"school"`
+	Kind       UsageKind `json:"kind"`
+	UserID     string    `json:"user_id"`     // who used the chemical, or holds the container when checked out
+	RecordedBy string    `json:"recorded_by"` // who logged the event
+	Amount     Quantity  `json:"amount"`      // amount used, subtracted from the remaining amount
+	Remaining  Quantity  `json:"remaining"`   // remaining amount after the event
+	Room       string    `json:"room,omitempty"`
+	Notes      string    `json:"notes,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Checkout describes who has a container out of its storage location
+type Checkout struct {
+	UserID string    `json:"user_id"`
+	Room   string    `json:"room"`
+	Since  time.Time `json:"since"`
+}
+
+// ApplyUsage updates the chemical for a usage event: the event's amount is subtracted from the
+// remaining amount and check-outs and check-ins move the container. The event is completed with
+// the chemical's ID and school and the amount left afterwards.
+func (c *Chemical) ApplyUsage(e *UsageEvent) error {
+	if c.IsDisposed() {
+		return ErrDisposed
+	}
+	switch e.Kind {
+	case UsageWithdrawal:
+		if e.Amount.IsZero() || e.Amount.Amount <= 0 {
+			return ErrInvalidQuantity
+		}
+	case UsageCheckOut:
+		if c.CheckedOut != nil {
+			return ErrAlreadyCheckedOut
+		}
+	case UsageCheckIn:
+		if c.CheckedOut == nil {
+			return ErrNotCheckedOut
+		}
+	default:
+		return errors.New("invalid usage kind")
+	}
+	if err := e.Amount.Validate(); err != nil {
+		return err
+	}
+
+	if !e.Amount.IsZero() && e.Amount.Amount > 0 {
+		if c.Remaining.IsZero() {
+			return ErrUnknownRemaining
+		}
+		used, err := e.Amount.Convert(c.Remaining.Unit)
+		if err != nil {
+			return err
+		}
+		// Round away the float noise of unit conversions, such as 0.30000000000000004
+		left := math.Round((c.Remaining.Amount-used.Amount)*1e6) / 1e6
+		if left < 0 {
+			return ErrInsufficientStock
+		}
+		c.Remaining.Amount = left
+	}
+
+	switch e.Kind {
+	case UsageCheckOut:
+		c.CheckedOut = &Checkout{UserID: e.UserID, Room: e.Room, Since: e.CreatedAt}
+	case UsageCheckIn:
+		c.CheckedOut = nil
+	}
+	e.ChemicalID = c.ID
+	e.School = c.School
+	e.Remaining = c.Remaining
+	return nil
+}
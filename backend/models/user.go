@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// User is an account of a teacher, school admin or master user
+type User struct {
+	ID                string    `json:"id"`
+	First             string    `json:"first"`
+	Last              string    `json:"last"`
+	Email             string    `json:"email"`
+	Password          string    `json:"-"` // bcrypt hash, never returned by the API
+	School            string    `json:"school"`
+	ExpoPushToken     string    `json:"expo_push_token"`
+	IsAdmin           bool      `json:"is_admin"`
+	IsMaster          bool      `json:"is_master"`
+	AllowEmail        bool      `json:"allow_email"`
+	AllowPush         bool      `json:"allow_push"`
+	ProfilePictureURL string    `json:"profilePictureURL,omitempty"`
+	Locale            string    `json:"locale,omitempty"` // language of the notifications the user gets, the deployment's when empty
+	ResetToken        string    `json:"-"`                // SHA-256 hash of a pending password reset token
+	ResetExpiry       time.Time `json:"-"`
+	Deleted           *Deletion `json:"deleted,omitempty"` // set while the user is in the trash
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// AlertsData is the data of the alerts template: the new and escalated alerts of a school
+type AlertsData struct {
+	School string
+	Alerts []models.Alert // most severe first
+	Now    time.Time      // when the alerts were found, to count the days until expiration from
+}
+
+func (AlertsData) Template() string { return TemplateAlerts }
+
+// PasswordResetData is the data of the password reset template
+type PasswordResetData struct {
+	Token     string        // the reset token the user enters in the app
+	URL       string        // link opening the app on its reset screen
+	ExpiresIn time.Duration // how long the token is valid
+}
+
+func (PasswordResetData) Template() string { return TemplatePasswordReset }
+
+// Minutes is how long the token is valid in whole minutes
+func (d PasswordResetData) Minutes() int {
+	return int(d.ExpiresIn / time.Minute)
+}
+
+// InvitationData is the data of the invitation template, sent to a user an admin or master added
+type InvitationData struct {
+	Name      string // first name of the new user
+	Email     string // the address they sign in with
+	School    string
+	InvitedBy string // name of who added them, empty when unknown
+	Admin     bool
+	Master    bool
+}
+
+func (InvitationData) Template() string { return TemplateInvitation }
+
+// DigestData is the data of the digest template, summing up the alerts of a school over a period
+type DigestData struct {
+	School   string
+	Since    time.Time
+	Until    time.Time
+	Alerts   []models.Alert // the active alerts, most severe first
+	Resolved int            // alerts resolved during the period
+}
+
+func (DigestData) Template() string { return TemplateDigest }
+
+// Count returns how many of the active alerts have the given severity
+func (d DigestData) Count(severity models.AlertSeverity) int {
+	n := 0
+	for _, a := range d.Alerts {
+		if a.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// TransferData is the data of the transfer template, sent to the admins of the school a transfer
+// was offered to, or of the schools it was decided for. The status of the transfer picks the message.
+type TransferData struct {
+	Transfer models.Transfer
+}
+
+func (TransferData) Template() string { return TemplateTransfer }
+
+// Sample returns made up data for a template, to preview it with
+func Sample(name string, now time.Time) (Data, bool) {
+	school := "Encina High School"
+	day := func(days int) *models.Date {
+		d := models.NewDate(now.AddDate(0, 0, days))
+		return &d
+	}
+	remaining, _ := models.ParseQuantity("40 mL")
+	threshold, _ := models.ParseQuantity("100 mL")
+	snoozed := now.AddDate(0, 0, 5)
+	alerts := []models.Alert{
+		{ID: "sample-1", Kind: models.AlertExpired, Severity: models.SeverityCritical, School: school, ChemicalID: "sample-ether",
+			ChemicalName: "Diethyl ether", CAS: "60-29-7", Room: "Chem Lab 2", ExpirationDate: day(-3), Status: models.AlertOpen,
+			Message: "Diethyl ether expired"},
+		{ID: "sample-2", Kind: models.AlertStorageConflict, Severity: models.SeverityCritical, School: school, ChemicalID: "sample-acid",
+			ChemicalName: "Nitric acid", CAS: "7697-37-2", Room: "Chem Lab 2", Status: models.AlertAcknowledged,
+			Conflict: &models.StorageConflict{ChemicalName: "Nitric acid", OtherName: "Acetic acid", Reason: "oxidizers must be kept away from organic acids"},
+			Message:  "Nitric acid is stored next to Acetic acid"},
+		{ID: "sample-3", Kind: models.AlertExpiring, Severity: models.SeverityWarning, School: school, ChemicalID: "sample-acetone",
+			ChemicalName: "Acetone", CAS: "67-64-1", Room: "Prep Room", ExpirationDate: day(12), LeadDays: 30, Status: models.AlertOpen,
+			Message: "Acetone expires soon"},
+		{ID: "sample-4", Kind: models.AlertLowStock, Severity: models.SeverityWarning, School: school, ChemicalID: "sample-ethanol",
+			ChemicalName: "Ethanol", CAS: "64-17-5", Room: "Prep Room", Remaining: &remaining, ReorderThreshold: &threshold,
+			Status: models.AlertSnoozed, SnoozedUntil: &snoozed, Message: "Ethanol is low"},
+		{ID: "sample-5", Kind: models.AlertSDSMissing, Severity: models.SeverityInfo, School: school, ChemicalID: "sample-toluene",
+			ChemicalName: "Toluene", CAS: "108-88-3", Status: models.AlertOpen, Message: "Toluene has no safety data sheet"},
+	}
+
+	switch name {
+	case TemplateAlerts:
+		return AlertsData{School: school, Alerts: []models.Alert{alerts[0], alerts[2], alerts[4]}, Now: now}, true
+	case TemplatePasswordReset:
+		token := "3f9a0c7e5b2d41a8c6e0f7b9d2a4c8e1"
+		return PasswordResetData{Token: token, URL: "chemtrack://resetPassword?token=" + token, ExpiresIn: 15 * time.Minute}, true
+	case TemplateInvitation:
+		return InvitationData{Name: "Alex", Email: "alex@example.com", School: school, InvitedBy: "Jordan Lee"}, true
+	case TemplateDigest:
+		return DigestData{School: school, Since: now.AddDate(0, 0, -7), Until: now, Alerts: alerts, Resolved: 3}, true
+	case TemplateTransfer:
+		amount, _ := models.ParseQuantity("250 mL")
+		return TransferData{Transfer: models.Transfer{ID: "sample-transfer", ChemicalID: "sample-ethanol", ChemicalName: "Ethanol",
+			FromSchool: school, ToSchool: "Davis Senior High School", Amount: amount, Status: models.TransferPending,
+			Notes: "Left over from the spring labs", RequestedAt: now}}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// catalog maps the keys of the strings in the templates to their translation, a format for
+// fmt.Sprintf. Plurals use the key followed by ".one" or ".other".
+type catalog map[string]string
+
+// loadCatalogs reads locales/<locale>.json for every language
+func loadCatalogs() (map[string]catalog, error) {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	catalogs := map[string]catalog{}
+	for _, entry := range entries {
+		locale := strings.TrimSuffix(entry.Name(), ".json")
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", entry.Name(), err)
+		}
+		catalogs[locale] = c
+	}
+	if _, ok := catalogs[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("%w: no catalog for %q", ErrUnknownLocale, DefaultLocale)
+	}
+	return catalogs, nil
+}
+
+// localizer translates the strings of a template into one language
+type localizer struct {
+	locale   string
+	catalog  catalog
+	fallback catalog
+}
+
+// funcs are the functions templates call
+func (l localizer) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"t":        l.t,
+		"tn":       l.tn,
+		"date":     formatDate,
+		"describe": l.describe,
+		"title":    func(kind models.AlertKind) string { return l.t("alert.title." + string(kind)) },
+		"severity": func(s models.AlertSeverity) string { return l.t("severity." + string(s)) },
+		"locale":   func() string { return l.locale },
+		"sent":     l.sent,
+	}
+}
+
+// t translates the string under key, formatted with args. Keys missing from the language are
+// taken from DefaultLocale, and keys missing there are returned as they are.
+func (l localizer) t(key string, args ...interface{}) string {
+	format, ok := l.catalog[key]
+	if !ok {
+		if format, ok = l.fallback[key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// tn translates the singular or plural of the string under key for a count of n, which is
+// formatted first, followed by args
+func (l localizer) tn(key string, n int, args ...interface{}) string {
+	form := ".other"
+	if n == 1 {
+		form = ".one"
+	}
+	return l.t(key+form, append([]interface{}{n}, args...)...)
+}
+
+// describe is the one line summary of an alert in the language, as of now. Alerts without the
+// details of their kind keep the summary they were raised with.
+func (l localizer) describe(a models.Alert, now time.Time) string {
+	switch a.Kind {
+	case models.AlertExpired:
+		if a.ExpirationDate != nil {
+			return l.t("alert.expired", a.ChemicalName, formatDate(a.ExpirationDate))
+		}
+	case models.AlertExpiring:
+		if a.ExpirationDate != nil {
+			switch days := int(a.ExpirationDate.Sub(models.NewDate(now).Time).Hours() / 24); {
+			case days <= 0:
+				return l.t("alert.expiring.today", a.ChemicalName)
+			case days == 1:
+				return l.t("alert.expiring.tomorrow", a.ChemicalName)
+			default:
+				return l.t("alert.expiring", a.ChemicalName, days, formatDate(a.ExpirationDate))
+			}
+		}
+	case models.AlertLowStock:
+		if a.Remaining != nil {
+			if a.ReorderThreshold != nil && !a.ReorderThreshold.IsZero() {
+				return l.t("alert.low_stock.threshold", a.ChemicalName, a.Remaining.String(), a.ReorderThreshold.String())
+			}
+			return l.t("alert.low_stock", a.ChemicalName, a.Remaining.String())
+		}
+	case models.AlertSDSMissing:
+		return l.t("alert.sds_missing", a.ChemicalName)
+	case models.AlertSDSOutdated:
+		if a.SDSDate != nil {
+			return l.t("alert.sds_outdated", a.ChemicalName, formatDate(a.SDSDate))
+		}
+	case models.AlertStorageConflict:
+		if a.Conflict != nil {
+			return l.t("alert.storage_conflict", a.Conflict.ChemicalName, a.Conflict.OtherName, a.Conflict.Reason)
+		}
+	}
+	return a.Message
+}
+
+// sent names what a transfer sends, such as "250 mL of Ethanol", or the chemical for a whole container
+func (l localizer) sent(t models.Transfer) string {
+	if t.Whole() {
+		return t.ChemicalName
+	}
+	return l.t("transfer.part", t.Amount.String(), t.ChemicalName)
+}
+
+// formatDate formats a date or time as 2006-01-02, or "" when unknown
+func formatDate(value interface{}) string {
+	switch v := value.(type) {
+	case models.Date:
+		return v.String()
+	case *models.Date:
+		if v != nil {
+			return v.String()
+		}
+	case time.Time:
+		if !v.IsZero() {
+			return v.Format("2006-01-02")
+		}
+	case *time.Time:
+		if v != nil && !v.IsZero() {
+			return v.Format("2006-01-02")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,243 @@
+// Package notify renders the messages ChemTrack sends from named templates: an HTML and a plain
+// text email, and a push notification, in the language of the recipient where there is one.
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// DefaultLocale is the language of the source strings, used for keys other languages lack
+const DefaultLocale = "en"
+
+// Names of the templates
+const (
+	TemplateAlerts        = "alerts"         // new and escalated alerts of a school, for its admins
+	TemplatePasswordReset = "password_reset" // the token to reset a forgotten password
+	TemplateInvitation    = "invitation"     // welcome to a user added by an admin
+	TemplateDigest        = "digest"         // weekly summary of a school's alerts
+	TemplateTransfer      = "transfer"       // a transfer offered to, or decided by, another school
+)
+
+// ErrUnknownLocale is returned for a language without a catalog
+var ErrUnknownLocale = errors.New("unknown locale")
+
+//go:embed templates locales
+var files embed.FS
+
+// Message is a notification rendered for every channel it can be sent on
+type Message struct {
+	Template  string `json:"template"`
+	Locale    string `json:"locale"`
+	Subject   string `json:"subject"`
+	HTML      string `json:"html"`
+	Text      string `json:"text"`
+	PushTitle string `json:"push_title"`
+	PushBody  string `json:"push_body"`
+}
+
+// Data is the typed data of a template, which it names
+type Data interface {
+	Template() string
+}
+
+// Renderer renders the templates in the languages of its catalogs
+type Renderer struct {
+	locale   string
+	catalogs map[string]catalog
+	html     map[string]*htmltemplate.Template
+	text     map[string]*texttemplate.Template
+}
+
+// NewRenderer parses the templates and catalogs, rendering in locale when a message asks for no
+// language or one without a catalog
+func NewRenderer(locale string) (*Renderer, error) {
+	catalogs, err := loadCatalogs()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := catalogs[locale]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLocale, locale)
+	}
+	r := &Renderer{locale: locale, catalogs: catalogs, html: map[string]*htmltemplate.Template{}, text: map[string]*texttemplate.Template{}}
+	// Functions are bound to a language when rendering, these only declare them for parsing
+	funcs := localizer{}.funcs()
+	for _, name := range Templates() {
+		html, err := htmltemplate.New("layout.html").Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+		text, err := texttemplate.New(name+".txt").Funcs(funcs).ParseFS(files, "templates/"+name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		r.html[name], r.text[name] = html, text
+	}
+	return r, nil
+}
+
+var (
+	defaultRenderer     *Renderer
+	defaultRendererOnce sync.Once
+)
+
+// Default returns a renderer in DefaultLocale
+func Default() *Renderer {
+	defaultRendererOnce.Do(func() {
+		r, err := NewRenderer(DefaultLocale)
+		if err != nil {
+			// The templates are built in, so this only fails on a broken build
+			panic(fmt.Sprintf("notify: %v", err))
+		}
+		defaultRenderer = r
+	})
+	return defaultRenderer
+}
+
+// Templates returns the names of the templates
+func Templates() []string {
+	return []string{TemplateAlerts, TemplateDigest, TemplateInvitation, TemplatePasswordReset, TemplateTransfer}
+}
+
+// Locales returns the languages the renderer has catalogs for
+func (r *Renderer) Locales() []string {
+	locales := make([]string, 0, len(r.catalogs))
+	for locale := range r.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Locale returns the language a message asking for locale is rendered in
+func (r *Renderer) Locale(locale string) string {
+	if matched, ok := r.match(locale); ok {
+		return matched
+	}
+	return r.locale
+}
+
+// Supports reports whether the renderer has a catalog for a language tag such as "es" or "es-MX"
+func (r *Renderer) Supports(tag string) bool {
+	_, ok := r.match(tag)
+	return ok
+}
+
+// match returns the catalog for a language tag such as "es" or "es-MX", a regional variant
+// falling back to its language
+func (r *Renderer) match(tag string) (string, bool) {
+	tag = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+	if _, ok := r.catalogs[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	_, ok := r.catalogs[base]
+	return base, ok
+}
+
+// Negotiate picks the language of an Accept-Language header, such as "es-MX,es;q=0.9,en;q=0.8",
+// from those the renderer has. Preferences are taken in the order given.
+func (r *Renderer) Negotiate(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if locale, ok := r.match(tag); ok {
+			return locale
+		}
+	}
+	return r.locale
+}
+
+// Render renders the template data names in locale, or the renderer's language when locale is
+// empty or has no catalog
+func (r *Renderer) Render(locale string, data Data) (Message, error) {
+	name := data.Template()
+	html, ok := r.html[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown template %q", name)
+	}
+	locale = r.Locale(locale)
+	funcs := localizer{locale: locale, catalog: r.catalogs[locale], fallback: r.catalogs[DefaultLocale]}.funcs()
+
+	msg := Message{Template: name, Locale: locale}
+	var buf bytes.Buffer
+	page, err := html.Clone()
+	if err != nil {
+		return Message{}, err
+	}
+	if err := page.Funcs(funcs).ExecuteTemplate(&buf, "layout.html", data); err != nil {
+		return Message{}, fmt.Errorf("rendering %s: %w", name, err)
+	}
+	msg.HTML = buf.String()
+
+	text, err := r.text[name].Clone()
+	if err != nil {
+		return Message{}, err
+	}
+	text.Funcs(funcs)
+	for _, part := range []struct {
+		block string
+		out   *string
+		line  bool // collapsed to a single line
+	}{
+		{"subject", &msg.Subject, true},
+		{"text", &msg.Text, false},
+		{"push_title", &msg.PushTitle, true},
+		{"push_body", &msg.PushBody, true},
+	} {
+		buf.Reset()
+		if err := text.ExecuteTemplate(&buf, part.block, data); err != nil {
+			return Message{}, fmt.Errorf("rendering the %s of %s: %w", part.block, name, err)
+		}
+		if part.line {
+			*part.out = strings.Join(strings.Fields(buf.String()), " ")
+		} else {
+			*part.out = tidyText(buf.String())
+		}
+	}
+	return msg, nil
+}
+
+// Localized renders the data of one notification in the language of each recipient, once per language
+type Localized struct {
+	renderer *Renderer
+	data     Data
+	messages map[string]Message
+}
+
+// Localize prepares data to be rendered for recipients who may read different languages
+func (r *Renderer) Localize(data Data) *Localized {
+	return &Localized{renderer: r, data: data, messages: map[string]Message{}}
+}
+
+// In returns the message in locale, or the renderer's language when locale is empty or has no catalog
+func (l *Localized) In(locale string) (Message, error) {
+	locale = l.renderer.Locale(locale)
+	if msg, ok := l.messages[locale]; ok {
+		return msg, nil
+	}
+	msg, err := l.renderer.Render(locale, l.data)
+	if err != nil {
+		return Message{}, err
+	}
+	l.messages[locale] = msg
+	return msg, nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidyText drops trailing spaces and runs of blank lines from rendered plain text
+func tidyText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	hiddenElements = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	lineBreaks     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|tr|table|ol|ul)>`)
+	listItems      = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	tags           = regexp.MustCompile(`<[^>]*>`)
+)
+
+// PlainText turns the HTML body of an email into the plain text shown by clients without HTML,
+// for bodies that were not rendered from a template
+func PlainText(body string) string {
+	s := hiddenElements.ReplaceAllString(body, "")
+	s = lineBreaks.ReplaceAllString(s, "\n")
+	s = listItems.ReplaceAllString(s, "\n- ")
+	s = html.UnescapeString(tags.ReplaceAllString(s, ""))
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return tidyText(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"errors"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+)
+
+// Access rules:
+//   - masters can see and manage everything in every school
+//   - admins manage the chemicals and users of their own school
+//   - regular users have read-only access to their own school and can log chemical usage there
+
+// ErrForbidden is returned when the caller is not allowed to perform an action
+var ErrForbidden = errors.New("forbidden")
+
+// Subject describes the user a caller wants to view or change
+type Subject struct {
+	ID       string
+	School   string
+	IsAdmin  bool
+	IsMaster bool
+}
+
+func isMaster(p auth.Principal) bool {
+	return p.Role == auth.RoleMaster
+}
+
+func isAdmin(p auth.Principal) bool {
+	return p.Role == auth.RoleAdmin
+}
+
+func ownSchool(p auth.Principal, school string) bool {
+	return p.School != "" && p.School == school
+}
+
+// CanViewSchool reports whether the caller can read the inventory and users of a school
+func CanViewSchool(p auth.Principal, school string) bool {
+	return isMaster(p) || ownSchool(p, school)
+}
+
+// CanManageChemicals reports whether the caller can create, change or delete chemicals of a school
+func CanManageChemicals(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanLogUsage reports whether the caller can record usage of a school's chemicals
+func CanLogUsage(p auth.Principal, school string) bool {
+	return CanViewSchool(p, school)
+}
+
+// CanViewAudit reports whether the caller can browse the recent activity of a school
+func CanViewAudit(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanManageTrash reports whether the caller can list, restore and purge the deleted chemicals
+// and users of a school
+func CanManageTrash(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanManageLocations reports whether the caller can create, change or delete the buildings,
+// rooms, cabinets and shelves of a school
+func CanManageLocations(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanManageAlertRules reports whether the caller can change what a school is alerted about
+func CanManageAlertRules(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanManageAlerts reports whether the caller can acknowledge, snooze or resolve a school's alerts
+func CanManageAlerts(p auth.Principal, school string) bool {
+	return isMaster(p) || (isAdmin(p) && ownSchool(p, school))
+}
+
+// CanViewUser reports whether the caller can read a user's profile
+func CanViewUser(p auth.Principal, target Subject) bool {
+	return p.UserID == target.ID || CanViewSchool(p, target.School)
+}
+
+// CanManageUser reports whether the caller can change or delete another user.
+// Admins cannot touch masters, and nobody but a master can manage users outside their school.
+func CanManageUser(p auth.Principal, target Subject) bool {
+	if isMaster(p) {
+		return true
+	}
+	return isAdmin(p) && ownSchool(p, target.School) && !target.IsMaster
+}
+
+// CanEditProfile reports whether the caller can change the profile fields (name, email,
+// password, notification settings) of a user. Everyone can edit their own profile.
+func CanEditProfile(p auth.Principal, target Subject) bool {
+	return p.UserID == target.ID || CanManageUser(p, target)
+}
+
+// CanAssignUser reports whether the caller can place a user in a school with the given roles.
+// It applies both when creating users and when changing the school or role flags of one.
+// Anonymous callers (sign up) can only create regular users.
+func CanAssignUser(p auth.Principal, school string, makeAdmin, makeMaster bool) bool {
+	switch {
+	case isMaster(p):
+		return true
+	case makeMaster:
+		return false
+	case isAdmin(p):
+		return ownSchool(p, school)
+	default:
+		return !makeAdmin && p.UserID == ""
+	}
+}
+
+// CanSendEmail reports whether the caller can send arbitrary emails through the API
+func CanSendEmail(p auth.Principal) bool {
+	return isMaster(p) || isAdmin(p)
+}
+
+// CanPreviewNotifications reports whether the caller can preview the emails and push notifications ChemTrack sends
+func CanPreviewNotifications(p auth.Principal) bool {
+	return isMaster(p) || isAdmin(p)
+}
+
+// CanManageJobs reports whether the caller can see and start the district-wide background jobs
+func CanManageJobs(p auth.Principal) bool {
+	return isMaster(p)
+}
+
+// ListSchool returns the school a list request is limited to. Masters may ask for any school,
+// or "" for every school; everyone else is limited to their own school.
+func ListSchool(p auth.Principal, requested string) (string, error) {
+	if isMaster(p) {
+		return requested, nil
+	}
+	if requested != "" && requested != p.School {
+		return "", ErrForbidden
+	}
+	return p.School, nil
+}
@@ -0,0 +1,1639 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// NewFirestore returns repositories backed by the chemicals, users, refresh_tokens, usage, audit_log,
+// locations, transfers, job_locks, job_runs, alert_rules and alerts collections
+func NewFirestore(client *firestore.Client) Repositories {
+	return Repositories{
+		Chemicals:     &firestoreChemicals{client: client, collection: client.Collection("chemicals")},
+		Users:         &firestoreUsers{collection: client.Collection("users")},
+		RefreshTokens: &firestoreRefreshTokens{client: client, collection: client.Collection("refresh_tokens")},
+		Usage:         &firestoreUsage{client: client, chemicals: client.Collection("chemicals"), collection: client.Collection("usage")},
+		Audit:         &firestoreAudit{collection: client.Collection("audit_log")},
+		Locations:     &firestoreLocations{collection: client.Collection("locations")},
+		Transfers:     &firestoreTransfers{client: client, chemicals: client.Collection("chemicals"), audit: client.Collection("audit_log"), collection: client.Collection("transfers")},
+		Jobs:          &firestoreJobs{client: client, locks: client.Collection("job_locks"), runs: client.Collection("job_runs")},
+		AlertRules:    &firestoreAlertRules{collection: client.Collection("alert_rules")},
+		Alerts:        &firestoreAlerts{collection: client.Collection("alerts")},
+	}
+}
+
+// translateError maps Firestore status codes onto the repository errors
+func translateError(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// Documents written before the repository layer were untyped, so fields are read leniently:
+// a missing or mistyped field decodes to its zero value instead of failing the whole document.
+
+func stringField(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
+
+func intField(data map[string]interface{}, key string) int {
+	switch v := data[key].(type) {
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func boolField(data map[string]interface{}, key string) bool {
+	b, _ := data[key].(bool)
+	return b
+}
+
+func timeField(data map[string]interface{}, key string) time.Time {
+	t, _ := data[key].(time.Time)
+	return t
+}
+
+// dateField reads a date stored as a timestamp, or as an ISO 8601 string by documents not yet migrated
+func dateField(data map[string]interface{}, key string) models.Date {
+	if t, ok := data[key].(time.Time); ok {
+		return models.NewDate(t)
+	}
+	d, _ := models.ParseDate(stringField(data, key))
+	return d
+}
+
+// dateValue stores an unknown date as null
+func dateValue(d models.Date) interface{} {
+	if d.IsZero() {
+		return nil
+	}
+	return d.Time
+}
+
+// casField reads a CAS number, which older documents stored as an integer without hyphens
+func casField(data map[string]interface{}, key string) string {
+	if _, ok := data[key].(string); !ok {
+		if n := intField(data, key); n > 0 {
+			return models.FormatCAS(strconv.Itoa(n))
+		}
+		return ""
+	}
+	if cas, err := models.ParseCAS(stringField(data, key)); err == nil {
+		return cas
+	}
+	return stringField(data, key)
+}
+
+// quantityField reads a quantity stored as an {amount, unit} map
+func quantityField(data map[string]interface{}, key string) models.Quantity {
+	m, ok := data[key].(map[string]interface{})
+	if !ok {
+		return models.Quantity{}
+	}
+	unit, err := models.ParseUnit(stringField(m, "unit"))
+	if err != nil {
+		return models.Quantity{}
+	}
+	var amount float64
+	switch v := m["amount"].(type) {
+	case float64:
+		amount = v
+	case int64:
+		amount = float64(v)
+	}
+	return models.Quantity{Amount: amount, Unit: unit}
+}
+
+// quantityValue stores an unknown quantity as null
+func quantityValue(q models.Quantity) interface{} {
+	if q.IsZero() {
+		return nil
+	}
+	return map[string]interface{}{"amount": q.Amount, "unit": string(q.Unit)}
+}
+
+// checkoutField reads a check-out stored as a {user_id, room, since} map
+func checkoutField(data map[string]interface{}, key string) *models.Checkout {
+	m, ok := data[key].(map[string]interface{})
+	if !ok || stringField(m, "user_id") == "" {
+		return nil
+	}
+	return &models.Checkout{UserID: stringField(m, "user_id"), Room: stringField(m, "room"), Since: timeField(m, "since")}
+}
+
+// checkoutValue stores a container that is not checked out as null
+func checkoutValue(checkout *models.Checkout) interface{} {
+	if checkout == nil {
+		return nil
+	}
+	return map[string]interface{}{"user_id": checkout.UserID, "room": checkout.Room, "since": checkout.Since}
+}
+
+// stringsField reads an array of strings
+func stringsField(data map[string]interface{}, key string) []string {
+	values, _ := data[key].([]interface{})
+	var out []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// hazardsField reads GHS hazard data stored as a map of code arrays
+func hazardsField(data map[string]interface{}, key string) models.Hazards {
+	m, _ := data[key].(map[string]interface{})
+	return models.Hazards{
+		Classes:                 stringsField(m, "classes"),
+		HazardStatements:        stringsField(m, "hazard_statements"),
+		PrecautionaryStatements: stringsField(m, "precautionary_statements"),
+		SignalWord:              stringField(m, "signal_word"),
+		Pictograms:              stringsField(m, "pictograms"),
+	}
+}
+
+func hazardsValue(h models.Hazards) interface{} {
+	return map[string]interface{}{
+		"classes":                  h.Classes,
+		"hazard_statements":        h.HazardStatements,
+		"precautionary_statements": h.PrecautionaryStatements,
+		"signal_word":              h.SignalWord,
+		"pictograms":               h.Pictograms,
+	}
+}
+
+// disposalField reads the disposal of a chemical stored as a map of its steps
+func disposalField(data map[string]interface{}, key string) *models.Disposal {
+	m, ok := data[key].(map[string]interface{})
+	if !ok || stringField(m, "state") == "" {
+		return nil
+	}
+	return &models.Disposal{
+		State:          models.DisposalState(stringField(m, "state")),
+		Reason:         stringField(m, "reason"),
+		FlaggedBy:      stringField(m, "flagged_by"),
+		FlaggedAt:      timeField(m, "flagged_at"),
+		Method:         stringField(m, "method"),
+		Vendor:         stringField(m, "vendor"),
+		ApprovedBy:     stringField(m, "approved_by"),
+		ApprovedAt:     timeField(m, "approved_at"),
+		ManifestNumber: stringField(m, "manifest_number"),
+		DisposedAt:     timeField(m, "disposed_at"),
+	}
+}
+
+// disposalValue stores a chemical that is not being disposed of as null
+func disposalValue(d *models.Disposal) interface{} {
+	if d == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"state":           string(d.State),
+		"reason":          d.Reason,
+		"flagged_by":      d.FlaggedBy,
+		"flagged_at":      d.FlaggedAt,
+		"method":          d.Method,
+		"vendor":          d.Vendor,
+		"approved_by":     d.ApprovedBy,
+		"approved_at":     d.ApprovedAt,
+		"manifest_number": d.ManifestNumber,
+		"disposed_at":     d.DisposedAt,
+	}
+}
+
+// deletionField reads the deletion of a trashed record stored as a {by, at} map
+func deletionField(data map[string]interface{}, key string) *models.Deletion {
+	m, ok := data[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return &models.Deletion{By: stringField(m, "by"), At: timeField(m, "at")}
+}
+
+func deletionValue(d models.Deletion) interface{} {
+	return map[string]interface{}{"by": d.By, "at": d.At}
+}
+
+// isTrashed reports whether a document has been moved to the trash
+func isTrashed(doc *firestore.DocumentSnapshot) bool {
+	return deletionField(doc.Data(), "deleted") != nil
+}
+
+// getDoc reads a document that is live, or in the trash when trashed is set
+func getDoc(ctx context.Context, collection *firestore.CollectionRef, id string, trashed bool) (*firestore.DocumentSnapshot, error) {
+	doc, err := collection.Doc(id).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if isTrashed(doc) != trashed {
+		return nil, ErrNotFound
+	}
+	return doc, nil
+}
+
+// setDeletion moves a live document to the trash, or a trashed one back when value is firestore.Delete.
+// The update is conditional on the document not having changed since it was read.
+func setDeletion(ctx context.Context, collection *firestore.CollectionRef, id string, value interface{}) error {
+	doc, err := getDoc(ctx, collection, id, value == firestore.Delete)
+	if err != nil {
+		return err
+	}
+	_, err = doc.Ref.Update(ctx, []firestore.Update{{Path: "deleted", Value: value}}, firestore.LastUpdateTime(doc.UpdateTime))
+	return translateError(err)
+}
+
+// optional stores empty strings as a removed field
+func optional(value string) interface{} {
+	if value == "" {
+		return firestore.Delete
+	}
+	return value
+}
+
+// createDoc adds a document under id, or under a generated ID when id is empty, and returns the ID used
+func createDoc(ctx context.Context, collection *firestore.CollectionRef, id string, data map[string]interface{}) (string, error) {
+	ref := collection.NewDoc()
+	if id != "" {
+		ref = collection.Doc(id)
+	}
+	if _, err := ref.Create(ctx, data); err != nil {
+		return "", translateError(err)
+	}
+	return ref.ID, nil
+}
+
+// updateDoc overwrites the given fields of an existing document
+func updateDoc(ctx context.Context, collection *firestore.CollectionRef, id string, data map[string]interface{}) error {
+	updates := make([]firestore.Update, 0, len(data))
+	for path, value := range data {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+	_, err := collection.Doc(id).Update(ctx, updates)
+	return translateError(err)
+}
+
+// deleteDoc removes an existing document
+func deleteDoc(ctx context.Context, collection *firestore.CollectionRef, id string) error {
+	_, err := collection.Doc(id).Delete(ctx, firestore.Exists)
+	return translateError(err)
+}
+
+// each calls fn for every document returned by the query
+func each(ctx context.Context, query firestore.Query, fn func(*firestore.DocumentSnapshot) error) error {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(doc); errors.Is(err, errStopEach) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// errStopEach is returned by the function passed to each to stop reading documents
+var errStopEach = errors.New("stop reading documents")
+
+// chemicalSortFields are the fields chemicals are ordered by in each sort order, before the
+// document ID, in the order of chemicalKey.values
+var chemicalSortFields = map[ChemicalSort][]string{
+	SortByName:       {"name_key"},
+	SortByCAS:        {"CAS"},
+	SortByExpiration: {"expiration_key"},
+	SortByLocation:   {"school", "room_key", "cabinet", "shelf"},
+}
+
+type firestoreChemicals struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func chemicalFromDoc(doc *firestore.DocumentSnapshot) models.Chemical {
+	data := doc.Data()
+	c := models.Chemical{
+		ID:               doc.Ref.ID,
+		Name:             stringField(data, "name"),
+		CAS:              casField(data, "CAS"),
+		School:           stringField(data, "school"),
+		PurchaseDate:     dateField(data, "purchase_date"),
+		ExpirationDate:   dateField(data, "expiration_date"),
+		Status:           stringField(data, "status"),
+		ContainerSize:    quantityField(data, "container_size"),
+		Remaining:        quantityField(data, "remaining"),
+		ReorderThreshold: quantityField(data, "reorder_threshold"),
+		LocationID:       stringField(data, "location_id"),
+		Room:             stringField(data, "room"),
+		Cabinet:          intField(data, "cabinet"),
+		Shelf:            intField(data, "shelf"),
+		SDSURL:           stringField(data, "sdsURL"),
+		SDSDate:          dateField(data, "sds_date"),
+		CheckedOut:       checkoutField(data, "checked_out"),
+		Deleted:          deletionField(data, "deleted"),
+		Disposal:         disposalField(data, "disposal"),
+		Hazards:          hazardsField(data, "hazards"),
+		StorageGroups:    stringsField(data, "storage_groups"),
+		Synonyms:         stringsField(data, "synonyms"),
+		Notes:            stringField(data, "notes"),
+	}
+	// Older documents only have a "500 mL" quantity string, which described a full container
+	if c.ContainerSize.IsZero() && c.Remaining.IsZero() {
+		if q, err := models.ParseQuantity(stringField(data, "quantity")); err == nil {
+			c.ContainerSize, c.Remaining = q, q
+		}
+	}
+	return c
+}
+
+func chemicalData(c models.Chemical) map[string]interface{} {
+	return map[string]interface{}{
+		"name":              c.Name,
+		"CAS":               c.CAS,
+		"school":            c.School,
+		"purchase_date":     dateValue(c.PurchaseDate),
+		"expiration_date":   dateValue(c.ExpirationDate),
+		"status":            c.Status,
+		"container_size":    quantityValue(c.ContainerSize),
+		"remaining":         quantityValue(c.Remaining),
+		"reorder_threshold": quantityValue(c.ReorderThreshold),
+		"location_id":       c.LocationID,
+		"room":              c.Room,
+		"cabinet":           c.Cabinet,
+		"shelf":             c.Shelf,
+		"checked_out":       checkoutValue(c.CheckedOut),
+		"disposal":          disposalValue(c.Disposal),
+		"hazards":           hazardsValue(c.Hazards),
+		"storage_groups":    c.StorageGroups,
+		"synonyms":          c.Synonyms,
+		"notes":             c.Notes,
+		"sds_date":          dateValue(c.SDSDate),
+		"name_key":          nameKey(c),
+		"room_key":          roomKey(c),
+		"expiration_key":    expirationKey(c),
+	}
+}
+
+func (r *firestoreChemicals) Create(ctx context.Context, c *models.Chemical) error {
+	data := chemicalData(*c)
+	if c.SDSURL != "" {
+		data["sdsURL"] = c.SDSURL
+	}
+	if c.Deleted != nil {
+		data["deleted"] = deletionValue(*c.Deleted)
+	}
+	id, err := createDoc(ctx, r.collection, c.ID, data)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+// CreateMany writes the chemicals in one transaction, which holds at most 500 writes
+func (r *firestoreChemicals) CreateMany(ctx context.Context, chemicals []*models.Chemical) error {
+	refs := make([]*firestore.DocumentRef, len(chemicals))
+	for i, c := range chemicals {
+		refs[i] = r.collection.NewDoc()
+		if c.ID != "" {
+			refs[i] = r.collection.Doc(c.ID)
+		}
+	}
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		for i, c := range chemicals {
+			data := chemicalData(*c)
+			if c.SDSURL != "" {
+				data["sdsURL"] = c.SDSURL
+			}
+			if c.Deleted != nil {
+				data["deleted"] = deletionValue(*c.Deleted)
+			}
+			if err := tx.Create(refs[i], data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	for i, c := range chemicals {
+		c.ID = refs[i].ID
+	}
+	return nil
+}
+
+func (r *firestoreChemicals) Get(ctx context.Context, id string) (models.Chemical, error) {
+	doc, err := getDoc(ctx, r.collection, id, false)
+	if err != nil {
+		return models.Chemical{}, err
+	}
+	return chemicalFromDoc(doc), nil
+}
+
+func (r *firestoreChemicals) GetTrashed(ctx context.Context, id string) (models.Chemical, error) {
+	doc, err := getDoc(ctx, r.collection, id, true)
+	if err != nil {
+		return models.Chemical{}, err
+	}
+	return chemicalFromDoc(doc), nil
+}
+
+func (r *firestoreChemicals) List(ctx context.Context, filter ChemicalFilter) ([]models.Chemical, error) {
+	query := r.collection.Query
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if filter.LocationID != "" {
+		query = query.Where("location_id", "==", filter.LocationID)
+	}
+	if filter.Disposal != "" {
+		query = query.Where("disposal.state", "==", string(filter.Disposal))
+	}
+	after, err := filter.after()
+	if err != nil {
+		return nil, err
+	}
+	direction := firestore.Asc
+	if filter.Descending {
+		direction = firestore.Desc
+	}
+	for _, field := range chemicalSortFields[filter.Sort] {
+		query = query.OrderBy(field, direction)
+	}
+	query = query.OrderBy(firestore.DocumentID, direction)
+	if after != nil {
+		query = query.StartAfter(after.values()...)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	// Documents written before the trash existed have no deleted field, so it is filtered here
+	// rather than in the query, along with the hazards since a query can only test one array,
+	// and the case-insensitive status and room. Pages are read on from the last document until
+	// enough chemicals match.
+	var chemicals []models.Chemical
+	for {
+		read := 0
+		var last *firestore.DocumentSnapshot
+		err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+			read, last = read+1, doc
+			chemical := chemicalFromDoc(doc)
+			if isTrashed(doc) == filter.Trashed && filter.matchesHazards(chemical) && filter.matchesInventory(chemical) {
+				chemicals = append(chemicals, chemical)
+			}
+			if filter.full(chemicals) {
+				return errStopEach
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if filter.Limit <= 0 || read < filter.Limit || filter.full(chemicals) {
+			return chemicals, nil
+		}
+		query = query.StartAfter(last)
+	}
+}
+
+func (r *firestoreChemicals) Update(ctx context.Context, c models.Chemical) error {
+	data := chemicalData(c)
+	data["sdsURL"] = optional(c.SDSURL)
+	return updateDoc(ctx, r.collection, c.ID, data)
+}
+
+func (r *firestoreChemicals) Trash(ctx context.Context, id string, d models.Deletion) error {
+	return setDeletion(ctx, r.collection, id, deletionValue(d))
+}
+
+func (r *firestoreChemicals) Restore(ctx context.Context, id string) error {
+	return setDeletion(ctx, r.collection, id, firestore.Delete)
+}
+
+func (r *firestoreChemicals) Delete(ctx context.Context, id string) error {
+	return deleteDoc(ctx, r.collection, id)
+}
+
+// MigrateFirestoreDates rewrites the purchase and expiration dates that chemicals still keep as strings
+// into timestamps and returns how many documents changed. Strings that do not parse are left untouched
+// and reported, so they can be corrected by hand and the migration run again.
+func MigrateFirestoreDates(ctx context.Context, client *firestore.Client) (int, []ConversionProblem, error) {
+	converted := 0
+	var problems []ConversionProblem
+	err := each(ctx, client.Collection("chemicals").Query, func(doc *firestore.DocumentSnapshot) error {
+		var updates []firestore.Update
+		for _, field := range []string{"purchase_date", "expiration_date"} {
+			value, ok := doc.Data()[field].(string)
+			if !ok {
+				continue
+			}
+			d, err := models.ParseDate(value)
+			if err != nil {
+				problems = append(problems, ConversionProblem{ChemicalID: doc.Ref.ID, Field: field, Value: value})
+				continue
+			}
+			updates = append(updates, firestore.Update{Path: field, Value: dateValue(d)})
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if _, err := doc.Ref.Update(ctx, updates); err != nil {
+			return err
+		}
+		converted++
+		return nil
+	})
+	return converted, problems, err
+}
+
+// MigrateFirestoreCAS rewrites CAS numbers stored as integers into the canonical hyphenated form and
+// returns how many documents changed. Numbers whose check digit does not match are converted as well,
+// so no data is lost, and reported so they can be corrected.
+func MigrateFirestoreCAS(ctx context.Context, client *firestore.Client) (int, []ConversionProblem, error) {
+	converted := 0
+	var problems []ConversionProblem
+	err := each(ctx, client.Collection("chemicals").Query, func(doc *firestore.DocumentSnapshot) error {
+		data := doc.Data()
+		if _, ok := data["CAS"].(string); ok || data["CAS"] == nil {
+			return nil
+		}
+		digits := strconv.Itoa(intField(data, "CAS"))
+		if _, err := models.ParseCAS(digits); err != nil {
+			problems = append(problems, ConversionProblem{ChemicalID: doc.Ref.ID, Field: "CAS", Value: digits})
+		}
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "CAS", Value: models.FormatCAS(digits)}}); err != nil {
+			return err
+		}
+		converted++
+		return nil
+	})
+	return converted, problems, err
+}
+
+// MigrateFirestoreSortKeys stores the lower-cased name and room and the expiration date that
+// chemicals are sorted by on the documents written without them, or since their dates were
+// converted, and returns how many documents changed. Queries ordered by a field skip the
+// documents that lack it, so run it after the other conversions.
+func MigrateFirestoreSortKeys(ctx context.Context, client *firestore.Client) (int, error) {
+	converted := 0
+	err := each(ctx, client.Collection("chemicals").Query, func(doc *firestore.DocumentSnapshot) error {
+		data, c := doc.Data(), chemicalFromDoc(doc)
+		key, _ := data["expiration_key"].(time.Time)
+		if stringField(data, "name_key") == nameKey(c) && stringField(data, "room_key") == roomKey(c) &&
+			key.Equal(expirationKey(c)) {
+			return nil
+		}
+		_, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "name_key", Value: nameKey(c)},
+			{Path: "room_key", Value: roomKey(c)},
+			{Path: "expiration_key", Value: expirationKey(c)},
+		})
+		if err != nil {
+			return err
+		}
+		converted++
+		return nil
+	})
+	return converted, err
+}
+
+type firestoreUsers struct {
+	collection *firestore.CollectionRef
+}
+
+func userFromDoc(doc *firestore.DocumentSnapshot) models.User {
+	data := doc.Data()
+	return models.User{
+		ID:                doc.Ref.ID,
+		First:             stringField(data, "first"),
+		Last:              stringField(data, "last"),
+		Email:             stringField(data, "email"),
+		Password:          stringField(data, "password"),
+		School:            stringField(data, "school"),
+		ExpoPushToken:     stringField(data, "expo_push_token"),
+		IsAdmin:           boolField(data, "is_admin"),
+		IsMaster:          boolField(data, "is_master"),
+		AllowEmail:        boolField(data, "allow_email"),
+		AllowPush:         boolField(data, "allow_push"),
+		ProfilePictureURL: stringField(data, "profilePictureURL"),
+		Locale:            stringField(data, "locale"),
+		ResetToken:        stringField(data, "reset_token"),
+		ResetExpiry:       timeField(data, "reset_expiry"),
+		Deleted:           deletionField(data, "deleted"),
+	}
+}
+
+func userData(u models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"first":           u.First,
+		"last":            u.Last,
+		"email":           u.Email,
+		"password":        u.Password,
+		"school":          u.School,
+		"expo_push_token": u.ExpoPushToken,
+		"is_admin":        u.IsAdmin,
+		"is_master":       u.IsMaster,
+		"allow_email":     u.AllowEmail,
+		"allow_push":      u.AllowPush,
+		"locale":          u.Locale,
+	}
+}
+
+func (r *firestoreUsers) Create(ctx context.Context, u *models.User) error {
+	data := userData(*u)
+	if u.ProfilePictureURL != "" {
+		data["profilePictureURL"] = u.ProfilePictureURL
+	}
+	if u.ResetToken != "" {
+		data["reset_token"] = u.ResetToken
+		data["reset_expiry"] = u.ResetExpiry
+	}
+	if u.Deleted != nil {
+		data["deleted"] = deletionValue(*u.Deleted)
+	}
+	id, err := createDoc(ctx, r.collection, u.ID, data)
+	if err != nil {
+		return err
+	}
+	u.ID = id
+	return nil
+}
+
+func (r *firestoreUsers) Get(ctx context.Context, id string) (models.User, error) {
+	doc, err := getDoc(ctx, r.collection, id, false)
+	if err != nil {
+		return models.User{}, err
+	}
+	return userFromDoc(doc), nil
+}
+
+func (r *firestoreUsers) GetTrashed(ctx context.Context, id string) (models.User, error) {
+	doc, err := getDoc(ctx, r.collection, id, true)
+	if err != nil {
+		return models.User{}, err
+	}
+	return userFromDoc(doc), nil
+}
+
+// first returns the first live user matched by the query
+func (r *firestoreUsers) first(ctx context.Context, query firestore.Query) (models.User, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return models.User{}, ErrNotFound
+		}
+		if err != nil {
+			return models.User{}, err
+		}
+		if !isTrashed(doc) {
+			return userFromDoc(doc), nil
+		}
+	}
+}
+
+func (r *firestoreUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.first(ctx, r.collection.Where("email", "==", email))
+}
+
+func (r *firestoreUsers) GetByResetToken(ctx context.Context, tokenHash string) (models.User, error) {
+	if tokenHash == "" {
+		return models.User{}, ErrNotFound
+	}
+	return r.first(ctx, r.collection.Where("reset_token", "==", tokenHash))
+}
+
+func (r *firestoreUsers) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	after, err := afterUser(filter.After)
+	if err != nil {
+		return nil, err
+	}
+	query := r.collection.OrderBy(firestore.DocumentID, firestore.Asc)
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if after != "" {
+		query = query.StartAfter(after)
+	}
+	// The trash is filtered here, so the limit is too
+	var users []models.User
+	err = each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		if isTrashed(doc) == filter.Trashed {
+			users = append(users, userFromDoc(doc))
+		}
+		if filter.Limit > 0 && len(users) >= filter.Limit {
+			return errStopEach
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (r *firestoreUsers) Update(ctx context.Context, u models.User) error {
+	data := userData(u)
+	data["profilePictureURL"] = optional(u.ProfilePictureURL)
+	if u.ResetToken != "" {
+		data["reset_token"] = u.ResetToken
+		data["reset_expiry"] = u.ResetExpiry
+	} else {
+		data["reset_token"] = firestore.Delete
+		data["reset_expiry"] = firestore.Delete
+	}
+	return updateDoc(ctx, r.collection, u.ID, data)
+}
+
+func (r *firestoreUsers) Trash(ctx context.Context, id string, d models.Deletion) error {
+	return setDeletion(ctx, r.collection, id, deletionValue(d))
+}
+
+func (r *firestoreUsers) Restore(ctx context.Context, id string) error {
+	return setDeletion(ctx, r.collection, id, firestore.Delete)
+}
+
+func (r *firestoreUsers) Delete(ctx context.Context, id string) error {
+	return deleteDoc(ctx, r.collection, id)
+}
+
+type firestoreRefreshTokens struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func refreshTokenFromDoc(doc *firestore.DocumentSnapshot) models.RefreshToken {
+	data := doc.Data()
+	return models.RefreshToken{
+		Hash:      doc.Ref.ID,
+		UserID:    stringField(data, "user_id"),
+		FamilyID:  stringField(data, "family_id"),
+		CreatedAt: timeField(data, "created_at"),
+		ExpiresAt: timeField(data, "expires_at"),
+		Revoked:   boolField(data, "revoked"),
+		RevokedAt: timeField(data, "revoked_at"),
+	}
+}
+
+func (r *firestoreRefreshTokens) Create(ctx context.Context, t models.RefreshToken) error {
+	_, err := createDoc(ctx, r.collection, t.Hash, map[string]interface{}{
+		"user_id":    t.UserID,
+		"family_id":  t.FamilyID,
+		"created_at": t.CreatedAt,
+		"expires_at": t.ExpiresAt,
+		"revoked":    t.Revoked,
+	})
+	return err
+}
+
+func (r *firestoreRefreshTokens) Get(ctx context.Context, hash string) (models.RefreshToken, error) {
+	doc, err := r.collection.Doc(hash).Get(ctx)
+	if err != nil {
+		return models.RefreshToken{}, translateError(err)
+	}
+	return refreshTokenFromDoc(doc), nil
+}
+
+func (r *firestoreRefreshTokens) Consume(ctx context.Context, hash string) (models.RefreshToken, error) {
+	ref := r.collection.Doc(hash)
+	var token models.RefreshToken
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		token = refreshTokenFromDoc(doc)
+		if token.Revoked {
+			return nil
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: time.Now()},
+		})
+	})
+	if err != nil {
+		return models.RefreshToken{}, translateError(err)
+	}
+	return token, nil
+}
+
+// revoke marks every live token matched by the query as revoked
+func (r *firestoreRefreshTokens) revoke(ctx context.Context, query firestore.Query) error {
+	now := time.Now()
+	return each(ctx, query.Where("revoked", "==", false), func(doc *firestore.DocumentSnapshot) error {
+		_, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: now},
+		})
+		return err
+	})
+}
+
+func (r *firestoreRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.revoke(ctx, r.collection.Where("family_id", "==", familyID))
+}
+
+func (r *firestoreRefreshTokens) RevokeUser(ctx context.Context, userID string) error {
+	return r.revoke(ctx, r.collection.Where("user_id", "==", userID))
+}
+
+// firestoreUsage keeps the usage ledger in its own collection. Listing with a filter and a date range
+// needs composite indexes on the filtered field and created_at, which Firestore offers to create
+// the first time such a query fails.
+type firestoreUsage struct {
+	client     *firestore.Client
+	chemicals  *firestore.CollectionRef
+	collection *firestore.CollectionRef
+}
+
+func usageEventFromDoc(doc *firestore.DocumentSnapshot) models.UsageEvent {
+	data := doc.Data()
+	return models.UsageEvent{
+		ID:         doc.Ref.ID,
+		ChemicalID: stringField(data, "chemical_id"),
+		School:     stringField(data, "school"),
+		Kind:       models.UsageKind(stringField(data, "kind")),
+		UserID:     stringField(data, "user_id"),
+		RecordedBy: stringField(data, "recorded_by"),
+		Amount:     quantityField(data, "amount"),
+		Remaining:  quantityField(data, "remaining"),
+		Room:       stringField(data, "room"),
+		Notes:      stringField(data, "notes"),
+		CreatedAt:  timeField(data, "created_at"),
+	}
+}
+
+func (r *firestoreUsage) Record(ctx context.Context, e *models.UsageEvent) (models.Chemical, error) {
+	ref := r.collection.NewDoc()
+	var chemical models.Chemical
+	var recorded models.UsageEvent
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(r.chemicals.Doc(e.ChemicalID))
+		if err != nil {
+			return err
+		}
+		if isTrashed(doc) {
+			return ErrNotFound
+		}
+		// The transaction may be retried, so work on copies of the event
+		chemical = chemicalFromDoc(doc)
+		recorded = *e
+		if err := chemical.ApplyUsage(&recorded); err != nil {
+			return err
+		}
+		recorded.ID = ref.ID
+
+		err = tx.Update(doc.Ref, []firestore.Update{
+			{Path: "remaining", Value: quantityValue(chemical.Remaining)},
+			{Path: "checked_out", Value: checkoutValue(chemical.CheckedOut)},
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Create(ref, map[string]interface{}{
+			"chemical_id": recorded.ChemicalID,
+			"school":      recorded.School,
+			"kind":        string(recorded.Kind),
+			"user_id":     recorded.UserID,
+			"recorded_by": recorded.RecordedBy,
+			"amount":      quantityValue(recorded.Amount),
+			"remaining":   quantityValue(recorded.Remaining),
+			"room":        recorded.Room,
+			"notes":       recorded.Notes,
+			"created_at":  recorded.CreatedAt,
+		})
+	})
+	if err != nil {
+		return models.Chemical{}, translateError(err)
+	}
+	*e = recorded
+	return chemical, nil
+}
+
+func (r *firestoreUsage) List(ctx context.Context, filter UsageFilter) ([]models.UsageEvent, error) {
+	query := r.collection.Query
+	if filter.ChemicalID != "" {
+		query = query.Where("chemical_id", "==", filter.ChemicalID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id", "==", filter.UserID)
+	}
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at", "<", filter.To)
+	}
+	var events []models.UsageEvent
+	err := each(ctx, query.OrderBy("created_at", firestore.Asc), func(doc *firestore.DocumentSnapshot) error {
+		events = append(events, usageEventFromDoc(doc))
+		return nil
+	})
+	return events, err
+}
+
+type firestoreAudit struct {
+	collection *firestore.CollectionRef
+}
+
+func auditEntryFromDoc(doc *firestore.DocumentSnapshot) models.AuditEntry {
+	data := doc.Data()
+	e := models.AuditEntry{
+		ID:         doc.Ref.ID,
+		EntityType: stringField(data, "entity_type"),
+		EntityID:   stringField(data, "entity_id"),
+		School:     stringField(data, "school"),
+		Action:     models.AuditAction(stringField(data, "action")),
+		Actor:      stringField(data, "actor"),
+		RequestID:  stringField(data, "request_id"),
+		CreatedAt:  timeField(data, "created_at"),
+	}
+	changes, _ := data["changes"].([]interface{})
+	for _, change := range changes {
+		m, ok := change.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		e.Changes = append(e.Changes, models.FieldChange{Field: stringField(m, "field"), Before: m["before"], After: m["after"]})
+	}
+	return e
+}
+
+func (r *firestoreAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	id, err := createDoc(ctx, r.collection, "", auditEntryData(*e))
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// auditEntryData is the document of an audit entry
+func auditEntryData(e models.AuditEntry) map[string]interface{} {
+	changes := make([]interface{}, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		changes = append(changes, map[string]interface{}{"field": change.Field, "before": change.Before, "after": change.After})
+	}
+	return map[string]interface{}{
+		"entity_type": e.EntityType,
+		"entity_id":   e.EntityID,
+		"school":      e.School,
+		"action":      string(e.Action),
+		"actor":       e.Actor,
+		"request_id":  e.RequestID,
+		"changes":     changes,
+		"created_at":  e.CreatedAt,
+	}
+}
+
+func (r *firestoreAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	query := r.collection.Query
+	if filter.EntityType != "" {
+		query = query.Where("entity_type", "==", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id", "==", filter.EntityID)
+	}
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if !filter.Before.IsZero() {
+		query = query.Where("created_at", "<", filter.Before)
+	}
+	query = query.OrderBy("created_at", firestore.Desc)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var entries []models.AuditEntry
+	err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		entries = append(entries, auditEntryFromDoc(doc))
+		return nil
+	})
+	return entries, err
+}
+
+type firestoreLocations struct {
+	collection *firestore.CollectionRef
+}
+
+func locationFromDoc(doc *firestore.DocumentSnapshot) models.Location {
+	data := doc.Data()
+	return models.Location{
+		ID:         doc.Ref.ID,
+		ParentID:   stringField(data, "parent_id"),
+		Kind:       models.LocationKind(stringField(data, "kind")),
+		Name:       stringField(data, "name"),
+		School:     stringField(data, "school"),
+		Capacity:   intField(data, "capacity"),
+		Attributes: stringsField(data, "attributes"),
+	}
+}
+
+func locationData(l models.Location) map[string]interface{} {
+	return map[string]interface{}{
+		"parent_id":  l.ParentID,
+		"kind":       string(l.Kind),
+		"name":       l.Name,
+		"school":     l.School,
+		"capacity":   l.Capacity,
+		"attributes": l.Attributes,
+	}
+}
+
+func (r *firestoreLocations) Create(ctx context.Context, l *models.Location) error {
+	id, err := createDoc(ctx, r.collection, l.ID, locationData(*l))
+	if err != nil {
+		return err
+	}
+	l.ID = id
+	return nil
+}
+
+func (r *firestoreLocations) Get(ctx context.Context, id string) (models.Location, error) {
+	doc, err := r.collection.Doc(id).Get(ctx)
+	if err != nil {
+		return models.Location{}, translateError(err)
+	}
+	return locationFromDoc(doc), nil
+}
+
+func (r *firestoreLocations) List(ctx context.Context, filter LocationFilter) ([]models.Location, error) {
+	query := r.collection.Query
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if filter.ParentID != "" {
+		query = query.Where("parent_id", "==", filter.ParentID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind", "==", string(filter.Kind))
+	}
+	var locations []models.Location
+	err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		locations = append(locations, locationFromDoc(doc))
+		return nil
+	})
+	return locations, err
+}
+
+func (r *firestoreLocations) Update(ctx context.Context, l models.Location) error {
+	return updateDoc(ctx, r.collection, l.ID, locationData(l))
+}
+
+func (r *firestoreLocations) Delete(ctx context.Context, id string) error {
+	return deleteDoc(ctx, r.collection, id)
+}
+
+type firestoreTransfers struct {
+	client     *firestore.Client
+	chemicals  *firestore.CollectionRef
+	audit      *firestore.CollectionRef
+	collection *firestore.CollectionRef
+}
+
+func transferFromDoc(doc *firestore.DocumentSnapshot) models.Transfer {
+	data := doc.Data()
+	t := models.Transfer{
+		ID:           doc.Ref.ID,
+		ChemicalID:   stringField(data, "chemical_id"),
+		ChemicalName: stringField(data, "chemical_name"),
+		FromSchool:   stringField(data, "from_school"),
+		ToSchool:     stringField(data, "to_school"),
+		Amount:       quantityField(data, "amount"),
+		Notes:        stringField(data, "notes"),
+		Status:       models.TransferStatus(stringField(data, "status")),
+		RequestedBy:  stringField(data, "requested_by"),
+		RequestedAt:  timeField(data, "requested_at"),
+		DecidedBy:    stringField(data, "decided_by"),
+		Reason:       stringField(data, "reason"),
+		LocationID:   stringField(data, "location_id"),
+		Room:         stringField(data, "room"),
+		Cabinet:      intField(data, "cabinet"),
+		Shelf:        intField(data, "shelf"),
+		ReceivedID:   stringField(data, "received_id"),
+	}
+	if at := timeField(data, "decided_at"); !at.IsZero() {
+		t.DecidedAt = &at
+	}
+	return t
+}
+
+func transferData(t models.Transfer) map[string]interface{} {
+	var decidedAt interface{}
+	if t.DecidedAt != nil {
+		decidedAt = *t.DecidedAt
+	}
+	return map[string]interface{}{
+		"chemical_id":   t.ChemicalID,
+		"chemical_name": t.ChemicalName,
+		"from_school":   t.FromSchool,
+		"to_school":     t.ToSchool,
+		"amount":        quantityValue(t.Amount),
+		"notes":         t.Notes,
+		"status":        string(t.Status),
+		"requested_by":  t.RequestedBy,
+		"requested_at":  t.RequestedAt,
+		"decided_by":    t.DecidedBy,
+		"decided_at":    decidedAt,
+		"reason":        t.Reason,
+		"location_id":   t.LocationID,
+		"room":          t.Room,
+		"cabinet":       t.Cabinet,
+		"shelf":         t.Shelf,
+		"received_id":   t.ReceivedID,
+	}
+}
+
+func (r *firestoreTransfers) Create(ctx context.Context, t *models.Transfer) error {
+	ref := r.collection.NewDoc()
+	// Checking for a pending transfer and creating this one in a transaction keeps a single one per chemical
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		pending, err := tx.Documents(r.collection.Where("chemical_id", "==", t.ChemicalID).
+			Where("status", "==", string(models.TransferPending)).Limit(1)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return models.ErrTransferPending
+		}
+		return tx.Create(ref, transferData(*t))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	t.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreTransfers) Get(ctx context.Context, id string) (models.Transfer, error) {
+	doc, err := r.collection.Doc(id).Get(ctx)
+	if err != nil {
+		return models.Transfer{}, translateError(err)
+	}
+	return transferFromDoc(doc), nil
+}
+
+func (r *firestoreTransfers) List(ctx context.Context, filter TransferFilter) ([]models.Transfer, error) {
+	query := r.collection.Query
+	if filter.ChemicalID != "" {
+		query = query.Where("chemical_id", "==", filter.ChemicalID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status", "==", string(filter.Status))
+	}
+	// A school can be on either side, which a single query cannot test, so it is filtered here
+	// and the transfers sorted here too rather than needing a composite index
+	var transfers []models.Transfer
+	err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		t := transferFromDoc(doc)
+		if filter.School == "" || t.FromSchool == filter.School || t.ToSchool == filter.School {
+			transfers = append(transfers, t)
+		}
+		return nil
+	})
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].RequestedAt.After(transfers[j].RequestedAt) })
+	return transfers, err
+}
+
+func (r *firestoreTransfers) Decide(ctx context.Context, t models.Transfer) error {
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(r.collection.Doc(t.ID))
+		if err != nil {
+			return err
+		}
+		if transferFromDoc(doc).Status != models.TransferPending {
+			return models.ErrTransferDecided
+		}
+		return tx.Set(doc.Ref, transferData(t))
+	})
+	return translateError(err)
+}
+
+func (r *firestoreTransfers) Accept(ctx context.Context, t *models.Transfer, audit func(TransferOutcome) []models.AuditEntry) (TransferOutcome, error) {
+	received := r.chemicals.NewDoc()
+	var outcome TransferOutcome
+	var accepted models.Transfer
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		transferDoc, err := tx.Get(r.collection.Doc(t.ID))
+		if err != nil {
+			return err
+		}
+		if transferFromDoc(transferDoc).Status != models.TransferPending {
+			return models.ErrTransferDecided
+		}
+		chemicalDoc, err := tx.Get(r.chemicals.Doc(t.ChemicalID))
+		if err != nil {
+			return err
+		}
+		if isTrashed(chemicalDoc) {
+			return ErrNotFound
+		}
+
+		// The transaction may be retried, so work on copies of the transfer
+		accepted = *t
+		chemical := chemicalFromDoc(chemicalDoc)
+		outcome = TransferOutcome{Before: chemical}
+		outcome.Received, err = chemical.ApplyTransfer(&accepted)
+		if err != nil {
+			return err
+		}
+		outcome.Sent = chemical
+
+		data := chemicalData(chemical)
+		updates := make([]firestore.Update, 0, len(data))
+		for path, value := range data {
+			updates = append(updates, firestore.Update{Path: path, Value: value})
+		}
+		if err := tx.Update(chemicalDoc.Ref, updates); err != nil {
+			return err
+		}
+		if !accepted.Whole() {
+			outcome.Received.ID = received.ID
+			if err := tx.Create(received, chemicalData(outcome.Received)); err != nil {
+				return err
+			}
+		}
+		accepted.ReceivedID = outcome.Received.ID
+		if audit != nil {
+			for _, e := range audit(outcome) {
+				if err := tx.Create(r.audit.NewDoc(), auditEntryData(e)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Set(transferDoc.Ref, transferData(accepted))
+	})
+	if err != nil {
+		return TransferOutcome{}, translateError(err)
+	}
+	*t = accepted
+	return outcome, nil
+}
+
+// firestoreJobs keeps a lock document per job, named after it, and a document per run
+type firestoreJobs struct {
+	client *firestore.Client
+	locks  *firestore.CollectionRef
+	runs   *firestore.CollectionRef
+}
+
+func (r *firestoreJobs) Claim(ctx context.Context, job, holder string, slot time.Time, lease time.Duration) (bool, error) {
+	ref := r.locks.Doc(job)
+	claimed := false
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		var lock jobLock
+		doc, err := tx.Get(ref)
+		switch {
+		case err == nil:
+			data := doc.Data()
+			lock = jobLock{holder: stringField(data, "holder"), until: timeField(data, "locked_until"), lastSlot: timeField(data, "last_slot")}
+		case status.Code(err) != codes.NotFound:
+			return err
+		}
+		now := time.Now()
+		if !lock.free(slot, now) {
+			return nil
+		}
+		claimed = true
+		return tx.Set(ref, map[string]interface{}{"holder": holder, "locked_until": now.Add(lease), "last_slot": slot})
+	})
+	return claimed, err
+}
+
+func (r *firestoreJobs) Release(ctx context.Context, job, holder string) error {
+	ref := r.locks.Doc(job)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if stringField(doc.Data(), "holder") != holder {
+			return nil
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "holder", Value: ""}, {Path: "locked_until", Value: time.Time{}}})
+	})
+}
+
+func jobRunFromDoc(doc *firestore.DocumentSnapshot) models.JobRun {
+	data := doc.Data()
+	r := models.JobRun{
+		ID:          doc.Ref.ID,
+		Job:         stringField(data, "job"),
+		Trigger:     models.JobTrigger(stringField(data, "trigger")),
+		TriggeredBy: stringField(data, "triggered_by"),
+		Instance:    stringField(data, "instance"),
+		ScheduledAt: timeField(data, "scheduled_at"),
+		StartedAt:   timeField(data, "started_at"),
+		DurationMS:  int64(intField(data, "duration_ms")),
+		Status:      models.JobStatus(stringField(data, "status")),
+		Error:       stringField(data, "error"),
+	}
+	if at := timeField(data, "finished_at"); !at.IsZero() {
+		r.FinishedAt = &at
+	}
+	return r
+}
+
+func jobRunData(r models.JobRun) map[string]interface{} {
+	var finishedAt interface{}
+	if r.FinishedAt != nil {
+		finishedAt = *r.FinishedAt
+	}
+	return map[string]interface{}{
+		"job":          r.Job,
+		"trigger":      string(r.Trigger),
+		"triggered_by": r.TriggeredBy,
+		"instance":     r.Instance,
+		"scheduled_at": r.ScheduledAt,
+		"started_at":   r.StartedAt,
+		"finished_at":  finishedAt,
+		"duration_ms":  r.DurationMS,
+		"status":       string(r.Status),
+		"error":        r.Error,
+	}
+}
+
+func (r *firestoreJobs) CreateRun(ctx context.Context, run *models.JobRun) error {
+	ref := r.runs.NewDoc()
+	if _, err := ref.Create(ctx, jobRunData(*run)); err != nil {
+		return translateError(err)
+	}
+	run.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreJobs) UpdateRun(ctx context.Context, run models.JobRun) error {
+	data := jobRunData(run)
+	updates := make([]firestore.Update, 0, len(data))
+	for path, value := range data {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+	_, err := r.runs.Doc(run.ID).Update(ctx, updates)
+	return translateError(err)
+}
+
+func (r *firestoreJobs) ListRuns(ctx context.Context, filter JobRunFilter) ([]models.JobRun, error) {
+	query := r.runs.Query
+	if filter.Job != "" {
+		query = query.Where("job", "==", filter.Job)
+	}
+	// Sorted here rather than needing a composite index of job and start time
+	var runs []models.JobRun
+	err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		runs = append(runs, jobRunFromDoc(doc))
+		return nil
+	})
+	sortRuns(runs)
+	if filter.Limit > 0 && len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+	return runs, err
+}
+
+// firestoreAlertRules keeps a document per school, named after it
+type firestoreAlertRules struct {
+	collection *firestore.CollectionRef
+}
+
+// doc returns the document of a school's rules. School names may hold slashes, which document IDs cannot.
+func (r *firestoreAlertRules) doc(school string) *firestore.DocumentRef {
+	return r.collection.Doc(url.PathEscape(school))
+}
+
+func (r *firestoreAlertRules) Get(ctx context.Context, school string) (models.AlertRules, error) {
+	doc, err := r.doc(school).Get(ctx)
+	if err != nil {
+		return models.AlertRules{}, translateError(err)
+	}
+	data := doc.Data()
+	rules := models.AlertRules{
+		School:           stringField(data, "school"),
+		Expired:          boolField(data, "expired"),
+		LowStock:         boolField(data, "low_stock"),
+		SDSMissing:       boolField(data, "sds_missing"),
+		SDSMaxAgeYears:   intField(data, "sds_max_age_years"),
+		StorageConflicts: stringField(data, "storage_conflicts"),
+		UpdatedBy:        stringField(data, "updated_by"),
+	}
+	leads, _ := data["expiring_lead_days"].([]interface{})
+	for _, days := range leads {
+		if n, ok := days.(int64); ok {
+			rules.ExpiringLeadDays = append(rules.ExpiringLeadDays, int(n))
+		}
+	}
+	switch v := data["low_stock_percent"].(type) {
+	case float64:
+		rules.LowStockPercent = v
+	case int64:
+		rules.LowStockPercent = float64(v)
+	}
+	if at := timeField(data, "updated_at"); !at.IsZero() {
+		rules.UpdatedAt = &at
+	}
+	return rules, nil
+}
+
+func (r *firestoreAlertRules) Put(ctx context.Context, rules models.AlertRules) error {
+	var updatedAt interface{}
+	if rules.UpdatedAt != nil {
+		updatedAt = *rules.UpdatedAt
+	}
+	_, err := r.doc(rules.School).Set(ctx, map[string]interface{}{
+		"school":             rules.School,
+		"expired":            rules.Expired,
+		"expiring_lead_days": rules.ExpiringLeadDays,
+		"low_stock":          rules.LowStock,
+		"low_stock_percent":  rules.LowStockPercent,
+		"sds_missing":        rules.SDSMissing,
+		"sds_max_age_years":  rules.SDSMaxAgeYears,
+		"storage_conflicts":  rules.StorageConflicts,
+		"updated_by":         rules.UpdatedBy,
+		"updated_at":         updatedAt,
+	})
+	return translateError(err)
+}
+
+func (r *firestoreAlertRules) Delete(ctx context.Context, school string) error {
+	_, err := r.doc(school).Delete(ctx, firestore.Exists)
+	return translateError(err)
+}
+
+type firestoreAlerts struct {
+	collection *firestore.CollectionRef
+}
+
+// optionalTimeValue stores a missing time as null
+func optionalTimeValue(at *time.Time) interface{} {
+	if at == nil {
+		return nil
+	}
+	return *at
+}
+
+// optionalTimeField reads a time stored by optionalTimeValue
+func optionalTimeField(data map[string]interface{}, key string) *time.Time {
+	at := timeField(data, key)
+	if at.IsZero() {
+		return nil
+	}
+	return &at
+}
+
+func alertData(a models.Alert) (map[string]interface{}, error) {
+	details, err := json.Marshal(alertDetails{ExpirationDate: a.ExpirationDate, LeadDays: a.LeadDays, Remaining: a.Remaining,
+		ReorderThreshold: a.ReorderThreshold, SDSDate: a.SDSDate, Conflict: a.Conflict})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"school":             a.School,
+		"kind":               string(a.Kind),
+		"severity":           string(a.Severity),
+		"chemical_id":        a.ChemicalID,
+		"chemical_name":      a.ChemicalName,
+		"cas":                a.CAS,
+		"room":               a.Room,
+		"message":            a.Message,
+		"details":            string(details),
+		"status":             string(a.Status),
+		"first_seen":         a.FirstSeen,
+		"last_seen":          a.LastSeen,
+		"notified_at":        optionalTimeValue(a.NotifiedAt),
+		"notified_severity":  string(a.NotifiedSeverity),
+		"notified_lead_days": a.NotifiedLeadDays,
+		"acknowledged_by":    a.AcknowledgedBy,
+		"acknowledged_at":    optionalTimeValue(a.AcknowledgedAt),
+		"snoozed_by":         a.SnoozedBy,
+		"snoozed_until":      optionalTimeValue(a.SnoozedUntil),
+		"resolved_by":        a.ResolvedBy,
+		"resolved_at":        optionalTimeValue(a.ResolvedAt),
+	}, nil
+}
+
+func alertFromDoc(doc *firestore.DocumentSnapshot) (models.Alert, error) {
+	data := doc.Data()
+	a := models.Alert{
+		ID:               doc.Ref.ID,
+		Kind:             models.AlertKind(stringField(data, "kind")),
+		Severity:         models.AlertSeverity(stringField(data, "severity")),
+		School:           stringField(data, "school"),
+		ChemicalID:       stringField(data, "chemical_id"),
+		ChemicalName:     stringField(data, "chemical_name"),
+		CAS:              stringField(data, "cas"),
+		Room:             stringField(data, "room"),
+		Message:          stringField(data, "message"),
+		Status:           models.AlertStatus(stringField(data, "status")),
+		FirstSeen:        timeField(data, "first_seen"),
+		LastSeen:         timeField(data, "last_seen"),
+		NotifiedAt:       optionalTimeField(data, "notified_at"),
+		NotifiedSeverity: models.AlertSeverity(stringField(data, "notified_severity")),
+		NotifiedLeadDays: intField(data, "notified_lead_days"),
+		AcknowledgedBy:   stringField(data, "acknowledged_by"),
+		AcknowledgedAt:   optionalTimeField(data, "acknowledged_at"),
+		SnoozedBy:        stringField(data, "snoozed_by"),
+		SnoozedUntil:     optionalTimeField(data, "snoozed_until"),
+		ResolvedBy:       stringField(data, "resolved_by"),
+		ResolvedAt:       optionalTimeField(data, "resolved_at"),
+	}
+	var d alertDetails
+	if details := stringField(data, "details"); details != "" {
+		if err := json.Unmarshal([]byte(details), &d); err != nil {
+			return models.Alert{}, fmt.Errorf("alert %s: %w", a.ID, err)
+		}
+	}
+	a.ExpirationDate, a.LeadDays, a.Remaining, a.ReorderThreshold, a.SDSDate, a.Conflict =
+		d.ExpirationDate, d.LeadDays, d.Remaining, d.ReorderThreshold, d.SDSDate, d.Conflict
+	return a, nil
+}
+
+func (r *firestoreAlerts) Create(ctx context.Context, a *models.Alert) error {
+	data, err := alertData(*a)
+	if err != nil {
+		return err
+	}
+	id, err := createDoc(ctx, r.collection, "", data)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	return nil
+}
+
+func (r *firestoreAlerts) Get(ctx context.Context, id string) (models.Alert, error) {
+	doc, err := r.collection.Doc(id).Get(ctx)
+	if err != nil {
+		return models.Alert{}, translateError(err)
+	}
+	return alertFromDoc(doc)
+}
+
+func (r *firestoreAlerts) List(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	query := r.collection.Query
+	if filter.School != "" {
+		query = query.Where("school", "==", filter.School)
+	}
+	if filter.ChemicalID != "" {
+		query = query.Where("chemical_id", "==", filter.ChemicalID)
+	}
+	// The other conditions are checked here rather than needing a composite index for each combination
+	var alerts []models.Alert
+	err := each(ctx, query, func(doc *firestore.DocumentSnapshot) error {
+		a, err := alertFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if filter.matches(a) {
+			alerts = append(alerts, a)
+		}
+		return nil
+	})
+	sortAlerts(alerts)
+	if filter.Limit > 0 && len(alerts) > filter.Limit {
+		alerts = alerts[:filter.Limit]
+	}
+	return alerts, err
+}
+
+func (r *firestoreAlerts) Update(ctx context.Context, a models.Alert) error {
+	data, err := alertData(a)
+	if err != nil {
+		return err
+	}
+	return updateDoc(ctx, r.collection, a.ID, data)
+}
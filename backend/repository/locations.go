@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// FindLocation returns the location of the given kind under the parent whose name is the same
+// place as name, or ErrNotFound. Rooms are looked up across the whole school, whether they sit
+// in a building or directly in the school, and schools by their exact name.
+func FindLocation(ctx context.Context, locations LocationRepository, school, parentID string, kind models.LocationKind, name string) (models.Location, error) {
+	filter := LocationFilter{School: school, ParentID: parentID, Kind: kind}
+	if kind == models.LocationRoom {
+		filter.ParentID = ""
+	}
+	candidates, err := locations.List(ctx, filter)
+	if err != nil {
+		return models.Location{}, err
+	}
+	key := models.LocationKey(name)
+	for _, l := range candidates {
+		if kind == models.LocationSchool && l.Name == name || kind != models.LocationSchool && models.LocationKey(l.Name) == key {
+			return l, nil
+		}
+	}
+	return models.Location{}, ErrNotFound
+}
+
+// findOrCreateLocation returns the location named name under the parent, creating it when missing
+func findOrCreateLocation(ctx context.Context, locations LocationRepository, parent models.Location, kind models.LocationKind, name string) (models.Location, error) {
+	l, err := FindLocation(ctx, locations, parent.School, parent.ID, kind, name)
+	if !errors.Is(err, ErrNotFound) {
+		return l, err
+	}
+	l = models.Location{ParentID: parent.ID, Kind: kind, Name: name, School: parent.School, Attributes: []string{}}
+	if kind == models.LocationSchool {
+		l.ParentID, l.School = "", name
+	}
+	return l, locations.Create(ctx, &l)
+}
+
+// PlaceChemical files a chemical under the location named by its school, room, cabinet and shelf,
+// creating the levels of the hierarchy that do not exist yet. It returns the ID of the deepest
+// location, or an empty ID for a chemical without a school or room.
+func PlaceChemical(ctx context.Context, locations LocationRepository, c models.Chemical) (string, error) {
+	if c.School == "" || c.Room == "" {
+		return "", nil
+	}
+	l, err := findOrCreateLocation(ctx, locations, models.Location{}, models.LocationSchool, c.School)
+	if err == nil {
+		l, err = findOrCreateLocation(ctx, locations, l, models.LocationRoom, c.Room)
+	}
+	if err == nil && c.Cabinet != 0 {
+		l, err = findOrCreateLocation(ctx, locations, l, models.LocationCabinet, "Cabinet "+strconv.Itoa(c.Cabinet))
+		if err == nil && c.Shelf != 0 {
+			l, err = findOrCreateLocation(ctx, locations, l, models.LocationShelf, "Shelf "+strconv.Itoa(c.Shelf))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return l.ID, nil
+}
+
+// LocationPath returns the location with the given ID and its ancestors, school first
+func LocationPath(ctx context.Context, locations LocationRepository, id string) ([]models.Location, error) {
+	var path []models.Location
+	for id != "" {
+		if len(path) > 5 {
+			return nil, fmt.Errorf("location %s: the hierarchy loops", id)
+		}
+		l, err := locations.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		path = append([]models.Location{l}, path...)
+		id = l.ParentID
+	}
+	return path, nil
+}
+
+// BuildLocations builds the location hierarchy from the free-text schools of users and the
+// school, room, cabinet and shelf fields of chemicals, and files every chemical without a
+// location under it. Names that are the same place, such as "Room 101" and "rm 101", share one
+// location. It returns how many chemicals were filed and can safely be run more than once.
+func BuildLocations(ctx context.Context, repos Repositories) (int, error) {
+	users, err := repos.Users.List(ctx, UserFilter{})
+	if err != nil {
+		return 0, err
+	}
+	var chemicals []models.Chemical
+	for _, trashed := range []bool{false, true} {
+		list, err := repos.Chemicals.List(ctx, ChemicalFilter{Trashed: trashed})
+		if err != nil {
+			return 0, err
+		}
+		chemicals = append(chemicals, list...)
+	}
+
+	schools := map[string]bool{}
+	for _, u := range users {
+		schools[u.School] = true
+	}
+	for _, c := range chemicals {
+		schools[c.School] = true
+	}
+	for school := range schools {
+		if school == "" {
+			continue
+		}
+		if _, err := findOrCreateLocation(ctx, repos.Locations, models.Location{}, models.LocationSchool, school); err != nil {
+			return 0, err
+		}
+	}
+
+	placed := 0
+	for _, c := range chemicals {
+		if c.LocationID != "" {
+			continue
+		}
+		if c.LocationID, err = PlaceChemical(ctx, repos.Locations, c); err != nil {
+			return placed, err
+		}
+		if c.LocationID == "" {
+			continue
+		}
+		if err := repos.Chemicals.Update(ctx, c); err != nil {
+			return placed, err
+		}
+		placed++
+	}
+	return placed, nil
+}
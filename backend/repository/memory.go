@@ -0,0 +1,790 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// NewMemory returns repositories that keep everything in process memory.
+// They are safe for concurrent use and are meant for tests and local development.
+func NewMemory() Repositories {
+	chemicals := &memoryChemicals{items: map[string]models.Chemical{}}
+	audit := &memoryAudit{}
+	return Repositories{
+		Chemicals:     chemicals,
+		Users:         &memoryUsers{items: map[string]models.User{}},
+		RefreshTokens: &memoryRefreshTokens{items: map[string]models.RefreshToken{}},
+		Usage:         &memoryUsage{chemicals: chemicals},
+		Audit:         audit,
+		Locations:     &memoryLocations{items: map[string]models.Location{}},
+		Transfers:     &memoryTransfers{chemicals: chemicals, audit: audit, items: map[string]models.Transfer{}},
+		Jobs:          &memoryJobs{locks: map[string]jobLock{}, runs: map[string]models.JobRun{}},
+		AlertRules:    &memoryAlertRules{items: map[string]models.AlertRules{}},
+		Alerts:        &memoryAlerts{items: map[string]models.Alert{}},
+	}
+}
+
+// newID generates a random document ID, similar to the ones Firestore assigns
+func newID() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+type memoryChemicals struct {
+	mu    sync.RWMutex
+	items map[string]models.Chemical
+}
+
+func (m *memoryChemicals) Create(ctx context.Context, c *models.Chemical) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.ID == "" {
+		c.ID = newID()
+	}
+	if _, ok := m.items[c.ID]; ok {
+		return ErrAlreadyExists
+	}
+	m.items[c.ID] = *c
+	return nil
+}
+
+func (m *memoryChemicals) CreateMany(ctx context.Context, chemicals []*models.Chemical) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := map[string]bool{}
+	for _, c := range chemicals {
+		if c.ID == "" {
+			c.ID = newID()
+		}
+		if _, ok := m.items[c.ID]; ok || ids[c.ID] {
+			return ErrAlreadyExists
+		}
+		ids[c.ID] = true
+	}
+	for _, c := range chemicals {
+		m.items[c.ID] = *c
+	}
+	return nil
+}
+
+func (m *memoryChemicals) Get(ctx context.Context, id string) (models.Chemical, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.items[id]
+	if !ok || c.Deleted != nil {
+		return models.Chemical{}, ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *memoryChemicals) GetTrashed(ctx context.Context, id string) (models.Chemical, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.items[id]
+	if !ok || c.Deleted == nil {
+		return models.Chemical{}, ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *memoryChemicals) List(ctx context.Context, filter ChemicalFilter) ([]models.Chemical, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var chemicals []models.Chemical
+	for _, c := range m.items {
+		if filter.School != "" && c.School != filter.School || (c.Deleted != nil) != filter.Trashed {
+			continue
+		}
+		if filter.LocationID != "" && c.LocationID != filter.LocationID {
+			continue
+		}
+		if filter.Disposal != "" && (c.Disposal == nil || c.Disposal.State != filter.Disposal) || !filter.matchesHazards(c) {
+			continue
+		}
+		if filter.matchesInventory(c) {
+			chemicals = append(chemicals, c)
+		}
+	}
+	return filter.page(chemicals)
+}
+
+func (m *memoryChemicals) Update(ctx context.Context, c models.Chemical) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.items[c.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	c.Deleted = stored.Deleted
+	m.items[c.ID] = c
+	return nil
+}
+
+func (m *memoryChemicals) Trash(ctx context.Context, id string, d models.Deletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.items[id]
+	if !ok || c.Deleted != nil {
+		return ErrNotFound
+	}
+	c.Deleted = &d
+	m.items[id] = c
+	return nil
+}
+
+func (m *memoryChemicals) Restore(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.items[id]
+	if !ok || c.Deleted == nil {
+		return ErrNotFound
+	}
+	c.Deleted = nil
+	m.items[id] = c
+	return nil
+}
+
+func (m *memoryChemicals) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.items, id)
+	return nil
+}
+
+type memoryUsers struct {
+	mu    sync.RWMutex
+	items map[string]models.User
+}
+
+func (m *memoryUsers) Create(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u.ID == "" {
+		u.ID = newID()
+	}
+	if _, ok := m.items[u.ID]; ok {
+		return ErrAlreadyExists
+	}
+	m.items[u.ID] = *u
+	return nil
+}
+
+func (m *memoryUsers) Get(ctx context.Context, id string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.items[id]
+	if !ok || u.Deleted != nil {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *memoryUsers) GetTrashed(ctx context.Context, id string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.items[id]
+	if !ok || u.Deleted == nil {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// find returns the first user outside the trash, in ID order, that matches
+func (m *memoryUsers) find(match func(models.User) bool) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found *models.User
+	for _, u := range m.items {
+		if u.Deleted == nil && match(u) && (found == nil || u.ID < found.ID) {
+			u := u
+			found = &u
+		}
+	}
+	if found == nil {
+		return models.User{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+func (m *memoryUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return m.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (m *memoryUsers) GetByResetToken(ctx context.Context, tokenHash string) (models.User, error) {
+	return m.find(func(u models.User) bool { return tokenHash != "" && u.ResetToken == tokenHash })
+}
+
+func (m *memoryUsers) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	after, err := afterUser(filter.After)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var users []models.User
+	for _, u := range m.items {
+		if filter.School != "" && u.School != filter.School || (u.Deleted != nil) != filter.Trashed || u.ID <= after {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (m *memoryUsers) Update(ctx context.Context, u models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.items[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Deleted = stored.Deleted
+	m.items[u.ID] = u
+	return nil
+}
+
+func (m *memoryUsers) Trash(ctx context.Context, id string, d models.Deletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.items[id]
+	if !ok || u.Deleted != nil {
+		return ErrNotFound
+	}
+	u.Deleted = &d
+	m.items[id] = u
+	return nil
+}
+
+func (m *memoryUsers) Restore(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.items[id]
+	if !ok || u.Deleted == nil {
+		return ErrNotFound
+	}
+	u.Deleted = nil
+	m.items[id] = u
+	return nil
+}
+
+func (m *memoryUsers) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.items, id)
+	return nil
+}
+
+type memoryRefreshTokens struct {
+	mu    sync.Mutex
+	items map[string]models.RefreshToken
+}
+
+func (m *memoryRefreshTokens) Create(ctx context.Context, t models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[t.Hash]; ok {
+		return ErrAlreadyExists
+	}
+	m.items[t.Hash] = t
+	return nil
+}
+
+func (m *memoryRefreshTokens) Get(ctx context.Context, hash string) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.items[hash]
+	if !ok {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *memoryRefreshTokens) Consume(ctx context.Context, hash string) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.items[hash]
+	if !ok {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	if !t.Revoked {
+		revoked := t
+		revoked.Revoked = true
+		revoked.RevokedAt = time.Now()
+		m.items[hash] = revoked
+	}
+	return t, nil
+}
+
+// revokeWhere revokes every live token that matches
+func (m *memoryRefreshTokens) revokeWhere(match func(models.RefreshToken) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for hash, t := range m.items {
+		if !t.Revoked && match(t) {
+			t.Revoked = true
+			t.RevokedAt = now
+			m.items[hash] = t
+		}
+	}
+}
+
+func (m *memoryRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	m.revokeWhere(func(t models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (m *memoryRefreshTokens) RevokeUser(ctx context.Context, userID string) error {
+	m.revokeWhere(func(t models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+type memoryUsage struct {
+	chemicals *memoryChemicals
+	mu        sync.RWMutex
+	events    []models.UsageEvent
+}
+
+func (m *memoryUsage) Record(ctx context.Context, e *models.UsageEvent) (models.Chemical, error) {
+	// Holding the chemicals lock makes reading, changing and storing the chemical one step
+	m.chemicals.mu.Lock()
+	defer m.chemicals.mu.Unlock()
+	c, ok := m.chemicals.items[e.ChemicalID]
+	if !ok || c.Deleted != nil {
+		return models.Chemical{}, ErrNotFound
+	}
+	if err := c.ApplyUsage(e); err != nil {
+		return models.Chemical{}, err
+	}
+	e.ID = newID()
+	m.chemicals.items[c.ID] = c
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, *e)
+	return c, nil
+}
+
+func (m *memoryUsage) List(ctx context.Context, filter UsageFilter) ([]models.UsageEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []models.UsageEvent
+	for _, e := range m.events {
+		switch {
+		case filter.ChemicalID != "" && e.ChemicalID != filter.ChemicalID,
+			filter.UserID != "" && e.UserID != filter.UserID,
+			filter.School != "" && e.School != filter.School,
+			!filter.From.IsZero() && e.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !e.CreatedAt.Before(filter.To):
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+type memoryAudit struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+}
+
+func (m *memoryAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = newID()
+	m.entries = append(m.entries, *e)
+	return nil
+}
+
+func (m *memoryAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// Walk backwards so entries with the same time stay newest first
+	var entries []models.AuditEntry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		e := m.entries[i]
+		switch {
+		case filter.EntityType != "" && e.EntityType != filter.EntityType,
+			filter.EntityID != "" && e.EntityID != filter.EntityID,
+			filter.School != "" && e.School != filter.School,
+			!filter.Before.IsZero() && !e.CreatedAt.Before(filter.Before):
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+type memoryLocations struct {
+	mu    sync.RWMutex
+	items map[string]models.Location
+}
+
+func (m *memoryLocations) Create(ctx context.Context, l *models.Location) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l.ID == "" {
+		l.ID = newID()
+	}
+	if _, ok := m.items[l.ID]; ok {
+		return ErrAlreadyExists
+	}
+	m.items[l.ID] = *l
+	return nil
+}
+
+func (m *memoryLocations) Get(ctx context.Context, id string) (models.Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.items[id]
+	if !ok {
+		return models.Location{}, ErrNotFound
+	}
+	return l, nil
+}
+
+func (m *memoryLocations) List(ctx context.Context, filter LocationFilter) ([]models.Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var locations []models.Location
+	for _, l := range m.items {
+		switch {
+		case filter.School != "" && l.School != filter.School,
+			filter.ParentID != "" && l.ParentID != filter.ParentID,
+			filter.Kind != "" && l.Kind != filter.Kind:
+			continue
+		}
+		locations = append(locations, l)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].ID < locations[j].ID })
+	return locations, nil
+}
+
+func (m *memoryLocations) Update(ctx context.Context, l models.Location) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[l.ID]; !ok {
+		return ErrNotFound
+	}
+	m.items[l.ID] = l
+	return nil
+}
+
+func (m *memoryLocations) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.items, id)
+	return nil
+}
+
+type memoryTransfers struct {
+	chemicals *memoryChemicals
+	audit     *memoryAudit
+	mu        sync.RWMutex
+	items     map[string]models.Transfer
+}
+
+func (m *memoryTransfers) Create(ctx context.Context, t *models.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.items {
+		if other.ChemicalID == t.ChemicalID && other.Status == models.TransferPending {
+			return models.ErrTransferPending
+		}
+	}
+	t.ID = newID()
+	m.items[t.ID] = *t
+	return nil
+}
+
+func (m *memoryTransfers) Get(ctx context.Context, id string) (models.Transfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.items[id]
+	if !ok {
+		return models.Transfer{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *memoryTransfers) List(ctx context.Context, filter TransferFilter) ([]models.Transfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var transfers []models.Transfer
+	for _, t := range m.items {
+		switch {
+		case filter.School != "" && t.FromSchool != filter.School && t.ToSchool != filter.School,
+			filter.ChemicalID != "" && t.ChemicalID != filter.ChemicalID,
+			filter.Status != "" && t.Status != filter.Status:
+			continue
+		}
+		transfers = append(transfers, t)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].RequestedAt.Equal(transfers[j].RequestedAt) {
+			return transfers[i].RequestedAt.After(transfers[j].RequestedAt)
+		}
+		return transfers[i].ID < transfers[j].ID
+	})
+	return transfers, nil
+}
+
+func (m *memoryTransfers) Decide(ctx context.Context, t models.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.items[t.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Status != models.TransferPending {
+		return models.ErrTransferDecided
+	}
+	m.items[t.ID] = t
+	return nil
+}
+
+func (m *memoryTransfers) Accept(ctx context.Context, t *models.Transfer, audit func(TransferOutcome) []models.AuditEntry) (TransferOutcome, error) {
+	// Holding every lock makes checking the transfer, moving the chemical and recording it one step
+	m.chemicals.mu.Lock()
+	defer m.chemicals.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit.mu.Lock()
+	defer m.audit.mu.Unlock()
+
+	stored, ok := m.items[t.ID]
+	if !ok {
+		return TransferOutcome{}, ErrNotFound
+	}
+	if stored.Status != models.TransferPending {
+		return TransferOutcome{}, models.ErrTransferDecided
+	}
+	c, ok := m.chemicals.items[t.ChemicalID]
+	if !ok || c.Deleted != nil {
+		return TransferOutcome{}, ErrNotFound
+	}
+	outcome := TransferOutcome{Before: c}
+	received, err := c.ApplyTransfer(t)
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+	if !t.Whole() {
+		received.ID = newID()
+		m.chemicals.items[received.ID] = received
+	}
+	m.chemicals.items[c.ID] = c
+	t.ReceivedID = received.ID
+	m.items[t.ID] = *t
+
+	outcome.Sent, outcome.Received = c, received
+	if audit != nil {
+		for _, e := range audit(outcome) {
+			e.ID = newID()
+			m.audit.entries = append(m.audit.entries, e)
+		}
+	}
+	return outcome, nil
+}
+
+// jobLock is the lock of a job and the slot of its last claimed run
+type jobLock struct {
+	holder   string
+	until    time.Time
+	lastSlot time.Time
+}
+
+// free reports whether the lock can be claimed for the run due at slot
+func (l jobLock) free(slot, now time.Time) bool {
+	return l.lastSlot.Before(slot) && (l.holder == "" || l.until.Before(now))
+}
+
+type memoryJobs struct {
+	mu    sync.Mutex
+	locks map[string]jobLock
+	runs  map[string]models.JobRun
+}
+
+func (m *memoryJobs) Claim(ctx context.Context, job, holder string, slot time.Time, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if !m.locks[job].free(slot, now) {
+		return false, nil
+	}
+	m.locks[job] = jobLock{holder: holder, until: now.Add(lease), lastSlot: slot}
+	return true, nil
+}
+
+func (m *memoryJobs) Release(ctx context.Context, job, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.locks[job]; ok && l.holder == holder {
+		l.holder, l.until = "", time.Time{}
+		m.locks[job] = l
+	}
+	return nil
+}
+
+func (m *memoryJobs) CreateRun(ctx context.Context, r *models.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID = newID()
+	m.runs[r.ID] = *r
+	return nil
+}
+
+func (m *memoryJobs) UpdateRun(ctx context.Context, r models.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.runs[r.ID]; !ok {
+		return ErrNotFound
+	}
+	m.runs[r.ID] = r
+	return nil
+}
+
+func (m *memoryJobs) ListRuns(ctx context.Context, filter JobRunFilter) ([]models.JobRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []models.JobRun
+	for _, r := range m.runs {
+		if filter.Job == "" || r.Job == filter.Job {
+			runs = append(runs, r)
+		}
+	}
+	sortRuns(runs)
+	if filter.Limit > 0 && len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+	return runs, nil
+}
+
+// sortRuns orders job runs newest first
+func sortRuns(runs []models.JobRun) {
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+}
+
+type memoryAlertRules struct {
+	mu    sync.RWMutex
+	items map[string]models.AlertRules
+}
+
+func (m *memoryAlertRules) Get(ctx context.Context, school string) (models.AlertRules, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rules, ok := m.items[school]
+	if !ok {
+		return models.AlertRules{}, ErrNotFound
+	}
+	rules.ExpiringLeadDays = append([]int(nil), rules.ExpiringLeadDays...)
+	return rules, nil
+}
+
+func (m *memoryAlertRules) Put(ctx context.Context, rules models.AlertRules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rules.ExpiringLeadDays = append([]int(nil), rules.ExpiringLeadDays...)
+	m.items[rules.School] = rules
+	return nil
+}
+
+func (m *memoryAlertRules) Delete(ctx context.Context, school string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[school]; !ok {
+		return ErrNotFound
+	}
+	delete(m.items, school)
+	return nil
+}
+
+type memoryAlerts struct {
+	mu    sync.RWMutex
+	items map[string]models.Alert
+}
+
+func (m *memoryAlerts) Create(ctx context.Context, a *models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a.ID = newID()
+	m.items[a.ID] = *a
+	return nil
+}
+
+func (m *memoryAlerts) Get(ctx context.Context, id string) (models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.items[id]
+	if !ok {
+		return models.Alert{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *memoryAlerts) List(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []models.Alert
+	for _, a := range m.items {
+		if filter.matches(a) {
+			out = append(out, a)
+		}
+	}
+	sortAlerts(out)
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
+
+func (m *memoryAlerts) Update(ctx context.Context, a models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[a.ID]; !ok {
+		return ErrNotFound
+	}
+	m.items[a.ID] = a
+	return nil
+}
+
+// sortAlerts orders alerts most recently seen first
+func sortAlerts(alerts []models.Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].LastSeen.Equal(alerts[j].LastSeen) {
+			return alerts[i].LastSeen.After(alerts[j].LastSeen)
+		}
+		return alerts[i].ID > alerts[j].ID
+	})
+}
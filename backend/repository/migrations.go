@@ -0,0 +1,603 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// migration is one versioned step of the SQL schema. Applied versions are recorded in
+// schema_migrations, so every step runs exactly once per database, in order.
+// Statements must work on both SQLite and PostgreSQL. Data that cannot be converted in SQL alone
+// is handled by run, which is called in the same transaction after up.
+type migration struct {
+	version int
+	name    string
+	up      string
+	run     func(ctx context.Context, tx *sql.Tx, dialect Dialect) error
+}
+
+// migrations lists the schema history. Never edit an applied step, append a new one instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create chemicals, users and refresh tokens",
+		up: `
+CREATE TABLE chemicals (
+	id              TEXT PRIMARY KEY,
+	name            TEXT NOT NULL DEFAULT '',
+	cas             INTEGER NOT NULL DEFAULT 0,
+	school          TEXT NOT NULL DEFAULT '',
+	purchase_date   TEXT NOT NULL DEFAULT '',
+	expiration_date TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL DEFAULT '',
+	quantity        TEXT NOT NULL DEFAULT '',
+	room            TEXT NOT NULL DEFAULT '',
+	cabinet         INTEGER NOT NULL DEFAULT 0,
+	shelf           INTEGER NOT NULL DEFAULT 0,
+	sds_url         TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX chemicals_school ON chemicals (school);
+
+CREATE TABLE users (
+	id                  TEXT PRIMARY KEY,
+	first               TEXT NOT NULL DEFAULT '',
+	last                TEXT NOT NULL DEFAULT '',
+	email               TEXT NOT NULL DEFAULT '',
+	password            TEXT NOT NULL DEFAULT '',
+	school              TEXT NOT NULL DEFAULT '',
+	expo_push_token     TEXT NOT NULL DEFAULT '',
+	is_admin            BOOLEAN NOT NULL DEFAULT FALSE,
+	is_master           BOOLEAN NOT NULL DEFAULT FALSE,
+	allow_email         BOOLEAN NOT NULL DEFAULT FALSE,
+	allow_push          BOOLEAN NOT NULL DEFAULT FALSE,
+	profile_picture_url TEXT NOT NULL DEFAULT '',
+	reset_token         TEXT NOT NULL DEFAULT '',
+	reset_expiry        TIMESTAMP NULL
+);
+CREATE INDEX users_email ON users (email);
+CREATE INDEX users_school ON users (school);
+CREATE INDEX users_reset_token ON users (reset_token);
+
+CREATE TABLE refresh_tokens (
+	hash       TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	family_id  TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked    BOOLEAN NOT NULL DEFAULT FALSE,
+	revoked_at TIMESTAMP NULL
+);
+CREATE INDEX refresh_tokens_user ON refresh_tokens (user_id);
+CREATE INDEX refresh_tokens_family ON refresh_tokens (family_id);
+`,
+	},
+	{
+		version: 2,
+		name:    "store chemical dates as timestamps",
+		up: `
+ALTER TABLE chemicals RENAME COLUMN purchase_date TO legacy_purchase_date;
+ALTER TABLE chemicals RENAME COLUMN expiration_date TO legacy_expiration_date;
+ALTER TABLE chemicals ADD COLUMN purchase_date TIMESTAMP NULL;
+ALTER TABLE chemicals ADD COLUMN expiration_date TIMESTAMP NULL;
+`,
+		run: func(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+			_, problems, err := convertLegacyDates(ctx, tx, dialect)
+			for _, p := range problems {
+				log.Printf("Chemical %s keeps unparseable %s %q, correct it and run migrate-legacy", p.ChemicalID, p.Field, p.Value)
+			}
+			return err
+		},
+	},
+	{
+		version: 3,
+		name:    "store chemical quantities with units",
+		up: `
+ALTER TABLE chemicals RENAME COLUMN quantity TO legacy_quantity;
+ALTER TABLE chemicals ADD COLUMN container_amount DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE chemicals ADD COLUMN container_unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN remaining_amount DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE chemicals ADD COLUMN remaining_unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN reorder_amount DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE chemicals ADD COLUMN reorder_unit TEXT NOT NULL DEFAULT '';
+`,
+		run: convertLegacyQuantities,
+	},
+	{
+		version: 4,
+		name:    "store CAS numbers in canonical hyphenated form",
+		up: `
+ALTER TABLE chemicals RENAME COLUMN cas TO legacy_cas;
+ALTER TABLE chemicals ADD COLUMN cas TEXT NOT NULL DEFAULT '';
+`,
+		run: convertLegacyCAS,
+	},
+	{
+		version: 5,
+		name:    "record chemical usage and check-outs",
+		up: `
+ALTER TABLE chemicals ADD COLUMN checked_out_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN checked_out_room TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN checked_out_at TIMESTAMP NULL;
+
+CREATE TABLE usage_events (
+	id               TEXT PRIMARY KEY,
+	chemical_id      TEXT NOT NULL,
+	school           TEXT NOT NULL DEFAULT '',
+	kind             TEXT NOT NULL,
+	user_id          TEXT NOT NULL DEFAULT '',
+	recorded_by      TEXT NOT NULL DEFAULT '',
+	amount           DOUBLE PRECISION NOT NULL DEFAULT 0,
+	amount_unit      TEXT NOT NULL DEFAULT '',
+	remaining_amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+	remaining_unit   TEXT NOT NULL DEFAULT '',
+	room             TEXT NOT NULL DEFAULT '',
+	notes            TEXT NOT NULL DEFAULT '',
+	created_at       TIMESTAMP NOT NULL
+);
+CREATE INDEX usage_events_chemical ON usage_events (chemical_id, created_at);
+CREATE INDEX usage_events_user ON usage_events (user_id, created_at);
+CREATE INDEX usage_events_school ON usage_events (school, created_at);
+`,
+	},
+	{
+		version: 6,
+		name:    "create the audit log",
+		up: `
+CREATE TABLE audit_log (
+	id          TEXT PRIMARY KEY,
+	entity_type TEXT NOT NULL,
+	entity_id   TEXT NOT NULL,
+	school      TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL,
+	actor       TEXT NOT NULL DEFAULT '',
+	request_id  TEXT NOT NULL DEFAULT '',
+	changes     TEXT NOT NULL DEFAULT '[]',
+	created_at  TIMESTAMP NOT NULL
+);
+CREATE INDEX audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX audit_log_school ON audit_log (school, created_at);
+`,
+	},
+	{
+		version: 7,
+		name:    "keep deleted chemicals and users in a trash",
+		up: `
+ALTER TABLE chemicals ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE chemicals ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		version: 8,
+		name:    "record the disposal of chemicals",
+		up: `
+ALTER TABLE chemicals ADD COLUMN disposal_state TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_flagged_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_flagged_at TIMESTAMP NULL;
+ALTER TABLE chemicals ADD COLUMN disposal_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_vendor TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_approved_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposal_approved_at TIMESTAMP NULL;
+ALTER TABLE chemicals ADD COLUMN disposal_manifest TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN disposed_at TIMESTAMP NULL;
+CREATE INDEX chemicals_disposal ON chemicals (school, disposal_state, disposed_at);
+`,
+	},
+	{
+		version: 9,
+		name:    "add GHS hazard data to chemicals",
+		up: `
+ALTER TABLE chemicals ADD COLUMN hazard_classes TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN hazard_statements TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN precautionary_statements TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN signal_word TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN pictograms TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		version: 10,
+		name:    "add storage groups to chemicals",
+		up: `
+ALTER TABLE chemicals ADD COLUMN storage_groups TEXT NOT NULL DEFAULT '';
+CREATE INDEX chemicals_location ON chemicals (school, room, cabinet, shelf);
+`,
+	},
+	{
+		version: 11,
+		name:    "create the location hierarchy",
+		up: `
+CREATE TABLE locations (
+	id         TEXT PRIMARY KEY,
+	parent_id  TEXT NOT NULL DEFAULT '',
+	kind       TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	school     TEXT NOT NULL,
+	capacity   INTEGER NOT NULL DEFAULT 0,
+	attributes TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX locations_parent ON locations (school, parent_id);
+ALTER TABLE chemicals ADD COLUMN location_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX chemicals_location_id ON chemicals (location_id);
+`,
+	},
+	{
+		version: 12,
+		name:    "record transfers of chemicals between schools",
+		up: `
+CREATE TABLE transfers (
+	id            TEXT PRIMARY KEY,
+	chemical_id   TEXT NOT NULL,
+	chemical_name TEXT NOT NULL DEFAULT '',
+	from_school   TEXT NOT NULL,
+	to_school     TEXT NOT NULL,
+	amount        DOUBLE PRECISION NOT NULL DEFAULT 0,
+	amount_unit   TEXT NOT NULL DEFAULT '',
+	notes         TEXT NOT NULL DEFAULT '',
+	status        TEXT NOT NULL,
+	requested_by  TEXT NOT NULL DEFAULT '',
+	requested_at  TIMESTAMP NOT NULL,
+	decided_by    TEXT NOT NULL DEFAULT '',
+	decided_at    TIMESTAMP,
+	reason        TEXT NOT NULL DEFAULT '',
+	location_id   TEXT NOT NULL DEFAULT '',
+	room          TEXT NOT NULL DEFAULT '',
+	cabinet       INTEGER NOT NULL DEFAULT 0,
+	shelf         INTEGER NOT NULL DEFAULT 0,
+	received_id   TEXT NOT NULL DEFAULT ''
+);
+CREATE UNIQUE INDEX transfers_pending ON transfers (chemical_id) WHERE status = 'pending';
+CREATE INDEX transfers_from ON transfers (from_school, requested_at);
+CREATE INDEX transfers_to ON transfers (to_school, requested_at);
+`,
+	},
+	{
+		version: 13,
+		name:    "add synonyms and notes to chemicals",
+		up: `
+ALTER TABLE chemicals ADD COLUMN synonyms TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		version: 14,
+		name:    "record background job locks and runs",
+		up: `
+CREATE TABLE job_locks (
+	job          TEXT PRIMARY KEY,
+	holder       TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMP NOT NULL,
+	last_slot    TIMESTAMP NOT NULL
+);
+CREATE TABLE job_runs (
+	id           TEXT PRIMARY KEY,
+	job          TEXT NOT NULL,
+	trigger_type TEXT NOT NULL,
+	triggered_by TEXT NOT NULL DEFAULT '',
+	instance     TEXT NOT NULL DEFAULT '',
+	scheduled_at TIMESTAMP NOT NULL,
+	started_at   TIMESTAMP NOT NULL,
+	finished_at  TIMESTAMP,
+	duration_ms  BIGINT NOT NULL DEFAULT 0,
+	status       TEXT NOT NULL,
+	error        TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX job_runs_job ON job_runs (job, started_at);
+`,
+	},
+	{
+		version: 15,
+		name:    "add alert rules and the revision date of safety data sheets",
+		up: `
+ALTER TABLE chemicals ADD COLUMN sds_date TIMESTAMP NULL;
+CREATE TABLE alert_rules (
+	school             TEXT PRIMARY KEY,
+	expired            BOOLEAN NOT NULL DEFAULT FALSE,
+	expiring_lead_days TEXT NOT NULL DEFAULT '',
+	low_stock          BOOLEAN NOT NULL DEFAULT FALSE,
+	low_stock_percent  DOUBLE PRECISION NOT NULL DEFAULT 0,
+	sds_missing        BOOLEAN NOT NULL DEFAULT FALSE,
+	sds_max_age_years  INTEGER NOT NULL DEFAULT 0,
+	storage_conflicts  TEXT NOT NULL DEFAULT '',
+	updated_by         TEXT NOT NULL DEFAULT '',
+	updated_at         TIMESTAMP NULL
+);
+`,
+	},
+	{
+		version: 16,
+		name:    "keep the alerts raised by the chemical monitor",
+		up: `
+CREATE TABLE alerts (
+	id                 TEXT PRIMARY KEY,
+	school             TEXT NOT NULL,
+	kind               TEXT NOT NULL,
+	severity           TEXT NOT NULL,
+	chemical_id        TEXT NOT NULL,
+	chemical_name      TEXT NOT NULL DEFAULT '',
+	cas                TEXT NOT NULL DEFAULT '',
+	room               TEXT NOT NULL DEFAULT '',
+	message            TEXT NOT NULL DEFAULT '',
+	details            TEXT NOT NULL DEFAULT '{}',
+	status             TEXT NOT NULL,
+	first_seen         TIMESTAMP NOT NULL,
+	last_seen          TIMESTAMP NOT NULL,
+	notified_at        TIMESTAMP NULL,
+	notified_severity  TEXT NOT NULL DEFAULT '',
+	notified_lead_days INTEGER NOT NULL DEFAULT 0,
+	acknowledged_by    TEXT NOT NULL DEFAULT '',
+	acknowledged_at    TIMESTAMP NULL,
+	snoozed_by         TEXT NOT NULL DEFAULT '',
+	snoozed_until      TIMESTAMP NULL,
+	resolved_by        TEXT NOT NULL DEFAULT '',
+	resolved_at        TIMESTAMP NULL
+);
+CREATE INDEX alerts_school ON alerts (school, status);
+CREATE INDEX alerts_chemical ON alerts (chemical_id);
+`,
+	},
+	{
+		version: 17,
+		name:    "sort chemicals by their lower-cased name and room in the database",
+		up: `
+ALTER TABLE chemicals ADD COLUMN name_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE chemicals ADD COLUMN room_key TEXT NOT NULL DEFAULT '';
+CREATE INDEX chemicals_name_key ON chemicals (school, name_key, id);
+CREATE INDEX chemicals_room_key ON chemicals (school, room_key, cabinet, shelf, id);
+`,
+		run: fillChemicalSortKeys,
+	},
+	{
+		version: 18,
+		name:    "add the language users get notifications in",
+		up: `
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+`,
+	},
+}
+
+// Migrate brings the schema up to date, applying every pending migration in its own transaction
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, dialect, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Applied database migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, dialect Dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.up); err != nil {
+		return err
+	}
+	if m.run != nil {
+		if err := m.run(ctx, tx, dialect); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.version, m.name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateSQLDates converts the chemical dates still held in the legacy text columns, returning how many
+// chemicals changed and the values that did not parse. Those stay in the legacy columns until corrected.
+func MigrateSQLDates(ctx context.Context, db *sql.DB, dialect Dialect) (int, []ConversionProblem, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	converted, problems, err := convertLegacyDates(ctx, tx, dialect)
+	if err != nil {
+		return 0, nil, err
+	}
+	return converted, problems, tx.Commit()
+}
+
+// convertLegacyDates moves every parseable legacy text date into its timestamp column
+func convertLegacyDates(ctx context.Context, tx *sql.Tx, dialect Dialect) (int, []ConversionProblem, error) {
+	type legacyDates struct {
+		id, purchase, expiration string
+	}
+
+	// Read everything first, PostgreSQL does not allow statements while rows are still open
+	rows, err := tx.QueryContext(ctx, `SELECT id, legacy_purchase_date, legacy_expiration_date FROM chemicals
+		WHERE legacy_purchase_date <> '' OR legacy_expiration_date <> ''`)
+	if err != nil {
+		return 0, nil, err
+	}
+	var pending []legacyDates
+	for rows.Next() {
+		var l legacyDates
+		if err := rows.Scan(&l.id, &l.purchase, &l.expiration); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		pending = append(pending, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	converted := 0
+	var problems []ConversionProblem
+	for _, l := range pending {
+		changed := false
+		for _, field := range []struct{ name, value string }{
+			{"purchase_date", l.purchase},
+			{"expiration_date", l.expiration},
+		} {
+			if field.value == "" {
+				continue
+			}
+			d, err := models.ParseDate(field.value)
+			if err != nil {
+				problems = append(problems, ConversionProblem{ChemicalID: l.id, Field: field.name, Value: field.value})
+				continue
+			}
+			query := `UPDATE chemicals SET ` + field.name + ` = ?, legacy_` + field.name + ` = '' WHERE id = ?`
+			if _, err := tx.ExecContext(ctx, dialect.rebind(query), nullTime(d.Time), l.id); err != nil {
+				return 0, nil, err
+			}
+			changed = true
+		}
+		if changed {
+			converted++
+		}
+	}
+	return converted, problems, nil
+}
+
+// convertLegacyQuantities reads the old "500 mL" quantity strings as full containers.
+// Strings that do not parse are logged and stay in legacy_quantity.
+func convertLegacyQuantities(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, legacy_quantity FROM chemicals WHERE legacy_quantity <> ''`)
+	if err != nil {
+		return err
+	}
+	legacy := map[string]string{}
+	for rows.Next() {
+		var id, quantity string
+		if err := rows.Scan(&id, &quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[id] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, quantity := range legacy {
+		q, err := models.ParseQuantity(quantity)
+		if err != nil {
+			log.Printf("Chemical %s keeps unparseable quantity %q in legacy_quantity", id, quantity)
+			continue
+		}
+		_, err = tx.ExecContext(ctx, dialect.rebind(`UPDATE chemicals SET container_amount = ?, container_unit = ?,
+			remaining_amount = ?, remaining_unit = ?, legacy_quantity = '' WHERE id = ?`),
+			q.Amount, q.Unit, q.Amount, q.Unit, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillChemicalSortKeys lower-cases the names and rooms in Go, which unlike SQLite's LOWER also
+// handles letters outside ASCII, so they sort the way the cursors of ChemicalCursor expect
+func fillChemicalSortKeys(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, room FROM chemicals`)
+	if err != nil {
+		return err
+	}
+	var chemicals []models.Chemical
+	for rows.Next() {
+		var c models.Chemical
+		if err := rows.Scan(&c.ID, &c.Name, &c.Room); err != nil {
+			rows.Close()
+			return err
+		}
+		chemicals = append(chemicals, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range chemicals {
+		_, err := tx.ExecContext(ctx, dialect.rebind(`UPDATE chemicals SET name_key = ?, room_key = ? WHERE id = ?`),
+			nameKey(c), roomKey(c), c.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertLegacyCAS hyphenates the integer CAS numbers. Numbers with a wrong check digit are
+// converted too, so nothing is lost, and logged so they can be corrected.
+func convertLegacyCAS(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, legacy_cas FROM chemicals WHERE legacy_cas <> 0`)
+	if err != nil {
+		return err
+	}
+	legacy := map[string]int64{}
+	for rows.Next() {
+		var id string
+		var cas int64
+		if err := rows.Scan(&id, &cas); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[id] = cas
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, cas := range legacy {
+		digits := strconv.FormatInt(cas, 10)
+		if _, err := models.ParseCAS(digits); err != nil {
+			log.Printf("Chemical %s has an invalid CAS number %s: %v", id, digits, err)
+		}
+		_, err := tx.ExecContext(ctx, dialect.rebind(`UPDATE chemicals SET cas = ?, legacy_cas = 0 WHERE id = ?`),
+			models.FormatCAS(digits), id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
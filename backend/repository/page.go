@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// ChemicalSort is the order chemicals are listed in. Ties are broken by ID.
+type ChemicalSort string
+
+const (
+	SortByID         ChemicalSort = ""
+	SortByName       ChemicalSort = "name"            // case-insensitive
+	SortByExpiration ChemicalSort = "expiration_date" // chemicals without an expiration date last
+	SortByCAS        ChemicalSort = "CAS"
+	SortByLocation   ChemicalSort = "location" // school, room, cabinet and shelf
+)
+
+// Valid reports whether the sort order is one chemicals can be listed in
+func (s ChemicalSort) Valid() bool {
+	switch s {
+	case SortByID, SortByName, SortByExpiration, SortByCAS, SortByLocation:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidCursor is returned for a page cursor that is malformed or was issued for another sort order
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// noExpiration stands in for an unknown expiration date, so those chemicals sort last
+var noExpiration = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// chemicalKey is the position of a chemical in a sort order, and what a page cursor holds
+type chemicalKey struct {
+	Sort    ChemicalSort `json:"s,omitempty"`
+	Desc    bool         `json:"d,omitempty"`
+	Text    string       `json:"t,omitempty"` // lower-cased name, CAS number or school
+	Room    string       `json:"r,omitempty"` // lower-cased
+	Cabinet int          `json:"c,omitempty"`
+	Shelf   int          `json:"h,omitempty"`
+	Time    time.Time    `json:"e,omitempty"`
+	ID      string       `json:"i"`
+}
+
+// sortKey returns the position of the chemical in the filter's sort order
+func (f ChemicalFilter) sortKey(c models.Chemical) chemicalKey {
+	key := chemicalKey{Sort: f.Sort, Desc: f.Descending, ID: c.ID}
+	switch f.Sort {
+	case SortByName:
+		key.Text = nameKey(c)
+	case SortByCAS:
+		key.Text = c.CAS
+	case SortByExpiration:
+		key.Time = expirationKey(c)
+	case SortByLocation:
+		key.Text, key.Room, key.Cabinet, key.Shelf = c.School, roomKey(c), c.Cabinet, c.Shelf
+	}
+	return key
+}
+
+// values lists what the key holds for its sort order, in the order chemicals are sorted by and
+// with the ID last, as the databases compare them
+func (k chemicalKey) values() []interface{} {
+	switch k.Sort {
+	case SortByName, SortByCAS:
+		return []interface{}{k.Text, k.ID}
+	case SortByExpiration:
+		return []interface{}{k.Time, k.ID}
+	case SortByLocation:
+		return []interface{}{k.Text, k.Room, k.Cabinet, k.Shelf, k.ID}
+	}
+	return []interface{}{k.ID}
+}
+
+// nameKey and roomKey are the lower-cased name and room the databases sort chemicals by
+func nameKey(c models.Chemical) string { return strings.ToLower(c.Name) }
+func roomKey(c models.Chemical) string { return strings.ToLower(c.Room) }
+
+// expirationKey is the expiration date the databases sort a chemical by
+func expirationKey(c models.Chemical) time.Time {
+	if c.ExpirationDate.IsZero() {
+		return noExpiration
+	}
+	return c.ExpirationDate.UTC()
+}
+
+// compare orders two keys of the same sort order, ascending
+func (k chemicalKey) compare(other chemicalKey) int {
+	for _, cmp := range []int{
+		strings.Compare(k.Text, other.Text),
+		k.Time.Compare(other.Time),
+		strings.Compare(k.Room, other.Room),
+		k.Cabinet - other.Cabinet,
+		k.Shelf - other.Shelf,
+		strings.Compare(k.ID, other.ID),
+	} {
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// ChemicalCursor returns the cursor of a chemical listed with the filter, which the next page
+// is asked for with as filter.After
+func ChemicalCursor(filter ChemicalFilter, c models.Chemical) string {
+	return encodeCursor(filter.sortKey(c))
+}
+
+// after decodes the filter's cursor, checking it was issued for the same sort order
+func (f ChemicalFilter) after() (*chemicalKey, error) {
+	if f.After == "" {
+		return nil, nil
+	}
+	var key chemicalKey
+	if err := decodeCursor(f.After, &key); err != nil || key.Sort != f.Sort || key.Desc != f.Descending || key.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &key, nil
+}
+
+// matchesInventory reports whether a chemical has the status, room, expiration and stock the filter asks for
+func (f ChemicalFilter) matchesInventory(c models.Chemical) bool {
+	return (f.Status == "" || strings.EqualFold(c.Status, f.Status)) &&
+		(f.Room == "" || strings.EqualFold(c.Room, f.Room)) &&
+		(f.ExpiringBefore.IsZero() || !c.ExpirationDate.IsZero() && c.ExpirationDate.Before(f.ExpiringBefore)) &&
+		(!f.LowStock || c.LowStock())
+}
+
+// full reports whether a page holds as many chemicals as the filter asks for
+func (f ChemicalFilter) full(chemicals []models.Chemical) bool {
+	return f.Limit > 0 && len(chemicals) >= f.Limit
+}
+
+// page sorts chemicals in the filter's order and returns those after its cursor, at most Limit of them
+func (f ChemicalFilter) page(chemicals []models.Chemical) ([]models.Chemical, error) {
+	after, err := f.after()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]chemicalKey, len(chemicals))
+	for _, c := range chemicals {
+		keys[c.ID] = f.sortKey(c)
+	}
+	less := func(a, b chemicalKey) bool {
+		if f.Descending {
+			return a.compare(b) > 0
+		}
+		return a.compare(b) < 0
+	}
+	sort.Slice(chemicals, func(i, j int) bool { return less(keys[chemicals[i].ID], keys[chemicals[j].ID]) })
+
+	if after != nil {
+		start := sort.Search(len(chemicals), func(i int) bool { return less(*after, keys[chemicals[i].ID]) })
+		chemicals = chemicals[start:]
+	}
+	if f.Limit > 0 && len(chemicals) > f.Limit {
+		chemicals = chemicals[:f.Limit]
+	}
+	return chemicals, nil
+}
+
+// userKey is the position of a user in a listing, always ordered by ID, and what a user page cursor holds
+type userKey struct {
+	ID string `json:"u"`
+}
+
+// UserCursor returns the cursor of a user, which the next page is asked for with as UserFilter.After
+func UserCursor(u models.User) string {
+	return encodeCursor(userKey{ID: u.ID})
+}
+
+// afterUser decodes a user page cursor into the ID of the last user of the previous page
+func afterUser(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	var key userKey
+	if err := decodeCursor(cursor, &key); err != nil || key.ID == "" {
+		return "", ErrInvalidCursor
+	}
+	return key.ID, nil
+}
+
+// encodeCursor turns a position in a listing into an opaque URL-safe string
+func encodeCursor(key interface{}) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string, key interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, key)
+}
@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrAlreadyExists is returned when creating a record whose ID is already taken
+var ErrAlreadyExists = errors.New("record already exists")
+
+// ConversionProblem is a stored chemical value that a legacy data conversion could not handle
+type ConversionProblem struct {
+	ChemicalID string
+	Field      string
+	Value      string
+}
+
+// ChemicalFilter narrows a chemical listing. Empty fields match everything.
+type ChemicalFilter struct {
+	School     string
+	LocationID string               // only chemicals kept directly in this location
+	Trashed    bool                 // list the chemicals in the trash instead of the live ones
+	Disposal   models.DisposalState // only chemicals at this disposal step
+
+	// GHS hazard codes the chemicals must carry, in the canonical form of models.Hazards
+	HazardClass     string
+	HazardStatement string
+	Pictogram       string
+	SignalWord      string
+
+	Status         string    // condition of the container, case-insensitive
+	Room           string    // case-insensitive
+	ExpiringBefore time.Time // only chemicals with an expiration date before this
+	LowStock       bool      // only chemicals at or below their reorder threshold
+
+	Sort       ChemicalSort
+	Descending bool
+	After      string // cursor of the last chemical of the previous page, from ChemicalCursor
+	Limit      int    // the most chemicals to return, zero for all
+}
+
+// matchesHazards reports whether a chemical carries the hazards the filter asks for
+func (f ChemicalFilter) matchesHazards(c models.Chemical) bool {
+	return (f.HazardClass == "" || contains(c.Hazards.Classes, f.HazardClass)) &&
+		(f.HazardStatement == "" || contains(c.Hazards.HazardStatements, f.HazardStatement)) &&
+		(f.Pictogram == "" || contains(c.Hazards.Pictograms, f.Pictogram)) &&
+		(f.SignalWord == "" || c.Hazards.SignalWord == f.SignalWord)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ChemicalRepository stores the chemical inventory
+type ChemicalRepository interface {
+	// Create stores a new chemical. When c.ID is empty a new ID is generated and set on c.
+	Create(ctx context.Context, c *models.Chemical) error
+	// CreateMany stores new chemicals in one transaction, all of them or none. Empty IDs are
+	// generated and set. It returns ErrAlreadyExists when one of the IDs is taken.
+	CreateMany(ctx context.Context, chemicals []*models.Chemical) error
+	// Get returns the chemical with the given ID, or ErrNotFound. Chemicals in the trash are not found.
+	Get(ctx context.Context, id string) (models.Chemical, error)
+	// GetTrashed returns the chemical with the given ID if it is in the trash, or ErrNotFound
+	GetTrashed(ctx context.Context, id string) (models.Chemical, error)
+	// List returns the chemicals matching the filter in its sort order, starting after its
+	// cursor. It returns ErrInvalidCursor for a cursor issued for another sort order.
+	List(ctx context.Context, filter ChemicalFilter) ([]models.Chemical, error)
+	// Update replaces a stored chemical, returning ErrNotFound if it does not exist.
+	// It leaves the chemical in or out of the trash.
+	Update(ctx context.Context, c models.Chemical) error
+	// Trash moves a chemical to the trash, returning ErrNotFound if it does not exist or is already there
+	Trash(ctx context.Context, id string, d models.Deletion) error
+	// Restore takes a chemical out of the trash, returning ErrNotFound if it is not there
+	Restore(ctx context.Context, id string) error
+	// Delete removes a chemical for good, returning ErrNotFound if it does not exist
+	Delete(ctx context.Context, id string) error
+}
+
+// LocationFilter narrows a location listing. Empty fields match everything.
+type LocationFilter struct {
+	School   string
+	ParentID string
+	Kind     models.LocationKind
+}
+
+// LocationRepository stores the location hierarchy of the schools
+type LocationRepository interface {
+	// Create stores a new location. When l.ID is empty a new ID is generated and set on l.
+	Create(ctx context.Context, l *models.Location) error
+	// Get returns the location with the given ID, or ErrNotFound
+	Get(ctx context.Context, id string) (models.Location, error)
+	// List returns the locations matching the filter, ordered by ID
+	List(ctx context.Context, filter LocationFilter) ([]models.Location, error)
+	// Update replaces a stored location, returning ErrNotFound if it does not exist
+	Update(ctx context.Context, l models.Location) error
+	// Delete removes a location, returning ErrNotFound if it does not exist
+	Delete(ctx context.Context, id string) error
+}
+
+// TransferFilter narrows a transfer listing. Empty fields match everything.
+type TransferFilter struct {
+	School     string // transfers sent or received by the school
+	ChemicalID string
+	Status     models.TransferStatus
+}
+
+// TransferOutcome is what accepting a transfer changed
+type TransferOutcome struct {
+	Before   models.Chemical // the chemical at the sending school before the transfer
+	Sent     models.Chemical // the same chemical afterwards: moved for a whole container, with less left otherwise
+	Received models.Chemical // the chemical at the receiving school, the same record as Sent for a whole container
+}
+
+// TransferRepository stores the transfers of chemicals between schools
+type TransferRepository interface {
+	// Create stores a new pending transfer and sets t.ID. It returns models.ErrTransferPending when
+	// the chemical already has a pending transfer.
+	Create(ctx context.Context, t *models.Transfer) error
+	// Get returns the transfer with the given ID, or ErrNotFound
+	Get(ctx context.Context, id string) (models.Transfer, error)
+	// List returns the transfers matching the filter, newest first
+	List(ctx context.Context, filter TransferFilter) ([]models.Transfer, error)
+	// Decide stores a rejected or cancelled transfer. It returns models.ErrTransferDecided when the
+	// stored transfer is no longer pending.
+	Decide(ctx context.Context, t models.Transfer) error
+	// Accept applies a pending transfer to its chemical with models.Chemical.ApplyTransfer and
+	// stores the sending chemical, the received chemical, the accepted transfer and the audit
+	// entries audit lists for the outcome in one transaction. audit may be nil. Accept sets
+	// t.ReceivedID and returns ErrNotFound when the chemical is gone, models.ErrTransferDecided
+	// when the stored transfer is no longer pending, or the error returned by ApplyTransfer.
+	Accept(ctx context.Context, t *models.Transfer, audit func(TransferOutcome) []models.AuditEntry) (TransferOutcome, error)
+}
+
+// UserFilter narrows a user listing. Empty fields match everything.
+type UserFilter struct {
+	School  string
+	Trashed bool   // list the users in the trash instead of the live ones
+	After   string // cursor of the last user of the previous page, from UserCursor
+	Limit   int    // the most users to return, zero for all
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	// Create stores a new user. When u.ID is empty a new ID is generated and set on u.
+	Create(ctx context.Context, u *models.User) error
+	// Get returns the user with the given ID, or ErrNotFound. Users in the trash are not found
+	// by Get, GetByEmail or GetByResetToken.
+	Get(ctx context.Context, id string) (models.User, error)
+	// GetTrashed returns the user with the given ID if it is in the trash, or ErrNotFound
+	GetTrashed(ctx context.Context, id string) (models.User, error)
+	// GetByEmail returns the user with the given email address, or ErrNotFound
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// GetByResetToken returns the user holding the given password reset token hash, or ErrNotFound
+	GetByResetToken(ctx context.Context, tokenHash string) (models.User, error)
+	// List returns the users matching the filter, ordered by ID and starting after its cursor.
+	// It returns ErrInvalidCursor for a malformed cursor.
+	List(ctx context.Context, filter UserFilter) ([]models.User, error)
+	// Update replaces a stored user, returning ErrNotFound if it does not exist.
+	// It leaves the user in or out of the trash.
+	Update(ctx context.Context, u models.User) error
+	// Trash moves a user to the trash, returning ErrNotFound if it does not exist or is already there
+	Trash(ctx context.Context, id string, d models.Deletion) error
+	// Restore takes a user out of the trash, returning ErrNotFound if it is not there
+	Restore(ctx context.Context, id string) error
+	// Delete removes a user for good, returning ErrNotFound if it does not exist
+	Delete(ctx context.Context, id string) error
+}
+
+// RefreshTokenRepository stores refresh tokens by their hash
+type RefreshTokenRepository interface {
+	// Create stores a new refresh token, returning ErrAlreadyExists if the hash is taken
+	Create(ctx context.Context, t models.RefreshToken) error
+	// Get returns the token with the given hash, or ErrNotFound
+	Get(ctx context.Context, hash string) (models.RefreshToken, error)
+	// Consume atomically revokes the token and returns it as it was before the call,
+	// so the caller can tell a first use from a replay of an already rotated token
+	Consume(ctx context.Context, hash string) (models.RefreshToken, error)
+	// RevokeFamily revokes every token rotated from the same login
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every token issued to a user
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// UsageFilter narrows a usage ledger listing. Empty fields match everything.
+type UsageFilter struct {
+	ChemicalID string
+	UserID     string
+	School     string
+	From       time.Time // events at or after this time
+	To         time.Time // events before this time
+}
+
+// UsageRepository stores the usage ledger of the chemicals
+type UsageRepository interface {
+	// Record applies the event to its chemical with models.Chemical.ApplyUsage and stores both in one
+	// transaction, so concurrent withdrawals cannot both spend the same amount. It sets e.ID and
+	// returns the updated chemical, ErrNotFound, or the error returned by ApplyUsage.
+	Record(ctx context.Context, e *models.UsageEvent) (models.Chemical, error)
+	// List returns the events matching the filter, oldest first
+	List(ctx context.Context, filter UsageFilter) ([]models.UsageEvent, error)
+}
+
+// AuditFilter narrows an audit trail listing. Empty fields match everything.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	School     string
+	Before     time.Time // entries older than this, to page back through the trail
+	Limit      int       // at most this many entries, 0 for all
+}
+
+// AuditRepository stores the audit trail. It is append only: entries cannot be changed or removed.
+type AuditRepository interface {
+	// Append stores a new entry and sets e.ID
+	Append(ctx context.Context, e *models.AuditEntry) error
+	// List returns the entries matching the filter, newest first
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+}
+
+// JobRunFilter narrows a listing of job runs. Empty fields match everything.
+type JobRunFilter struct {
+	Job   string
+	Limit int // at most this many runs, 0 for all
+}
+
+// JobRepository keeps the locks and run history of the background jobs, shared by every replica of the API
+type JobRepository interface {
+	// Claim locks a job for its run due at slot until the lease runs out or the lock is released.
+	// It returns false when another holder has the job locked, or when a run due at or after slot
+	// has already been claimed, so that each run happens once however many replicas are due to start it.
+	Claim(ctx context.Context, job, holder string, slot time.Time, lease time.Duration) (bool, error)
+	// Release unlocks a job if the holder still has it locked
+	Release(ctx context.Context, job, holder string) error
+	// CreateRun stores a new run and sets r.ID
+	CreateRun(ctx context.Context, r *models.JobRun) error
+	// UpdateRun replaces a stored run, returning ErrNotFound if it does not exist
+	UpdateRun(ctx context.Context, r models.JobRun) error
+	// ListRuns returns the runs matching the filter, newest first
+	ListRuns(ctx context.Context, filter JobRunFilter) ([]models.JobRun, error)
+}
+
+// AlertRuleRepository stores the alert rules each school has set
+type AlertRuleRepository interface {
+	// Get returns the rules of a school, or ErrNotFound when it has not set any
+	Get(ctx context.Context, school string) (models.AlertRules, error)
+	// Put stores the rules of a school, replacing those it had
+	Put(ctx context.Context, rules models.AlertRules) error
+	// Delete removes the rules of a school, returning ErrNotFound if it has none
+	Delete(ctx context.Context, school string) error
+}
+
+// AlertFilter narrows an alert listing. Empty fields match everything.
+type AlertFilter struct {
+	School     string
+	ChemicalID string
+	Statuses   []models.AlertStatus // alerts in any of these statuses
+	Kind       models.AlertKind
+	Severity   models.AlertSeverity
+	Limit      int // at most this many alerts, 0 for all
+}
+
+// matches reports whether an alert passes the filter
+func (f AlertFilter) matches(a models.Alert) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			found = found || a.Status == status
+		}
+		if !found {
+			return false
+		}
+	}
+	return (f.School == "" || a.School == f.School) && (f.ChemicalID == "" || a.ChemicalID == f.ChemicalID) &&
+		(f.Kind == "" || a.Kind == f.Kind) && (f.Severity == "" || a.Severity == f.Severity)
+}
+
+// AlertRepository stores the alerts raised by the chemical monitor and where they stand
+type AlertRepository interface {
+	// Create stores a new alert and sets a.ID
+	Create(ctx context.Context, a *models.Alert) error
+	// Get returns the alert with the given ID, or ErrNotFound
+	Get(ctx context.Context, id string) (models.Alert, error)
+	// List returns the alerts matching the filter, most recently seen first
+	List(ctx context.Context, filter AlertFilter) ([]models.Alert, error)
+	// Update replaces a stored alert, returning ErrNotFound if it does not exist
+	Update(ctx context.Context, a models.Alert) error
+}
+
+// Repositories groups the stores the API depends on
+type Repositories struct {
+	Chemicals     ChemicalRepository
+	Users         UserRepository
+	RefreshTokens RefreshTokenRepository
+	Usage         UsageRepository
+	Audit         AuditRepository
+	Locations     LocationRepository
+	Transfers     TransferRepository
+	Jobs          JobRepository
+	AlertRules    AlertRuleRepository
+	Alerts        AlertRepository
+}
@@ -0,0 +1,1316 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"           // registers the "postgres" driver
+	_ "github.com/mattn/go-sqlite3" // registers the "sqlite3" driver
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// Dialect names a supported SQL database
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// driver returns the database/sql driver name of the dialect
+func (d Dialect) driver() string {
+	if d == SQLite {
+		return "sqlite3"
+	}
+	return "postgres"
+}
+
+// rebind rewrites the ? placeholders used in this package into $1, $2, ... for PostgreSQL
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// OpenSQL connects to an SQLite file or a PostgreSQL server and applies pending migrations.
+// For SQLite the source is a file path, for PostgreSQL a connection URL or keyword/value string.
+func OpenSQL(ctx context.Context, dialect Dialect, source string) (*sql.DB, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("unsupported SQL dialect %q", dialect)
+	}
+	db, err := sql.Open(dialect.driver(), source)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so serialize access instead of failing with "database is locked"
+	if dialect == SQLite {
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := Migrate(ctx, db, dialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQL returns repositories backed by a migrated SQL database
+func NewSQL(db *sql.DB, dialect Dialect) Repositories {
+	s := sqlStore{db: db, dialect: dialect}
+	return Repositories{
+		Chemicals:     &sqlChemicals{s},
+		Users:         &sqlUsers{s},
+		RefreshTokens: &sqlRefreshTokens{s},
+		Usage:         &sqlUsage{s},
+		Audit:         &sqlAudit{s},
+		Locations:     &sqlLocations{s},
+		Transfers:     &sqlTransfers{s},
+		Jobs:          &sqlJobs{s},
+		AlertRules:    &sqlAlertRules{s},
+		Alerts:        &sqlAlerts{s},
+	}
+}
+
+type sqlStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func (s sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s sqlStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s sqlStore) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+// affected turns a statement that touched no rows into the given error
+func affected(result sql.Result, err error, none error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return none
+	}
+	return nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// deletion reads the deleted_by and deleted_at columns of a trashed record
+func deletion(by string, at sql.NullTime) *models.Deletion {
+	if !at.Valid {
+		return nil
+	}
+	return &models.Deletion{By: by, At: at.Time}
+}
+
+// trashCondition selects the records in the trash or the live ones
+func trashCondition(trashed bool) string {
+	if trashed {
+		return `deleted_at IS NOT NULL`
+	}
+	return `deleted_at IS NULL`
+}
+
+// trash marks a live record of the table as deleted
+func (s sqlStore) trash(ctx context.Context, table, id string, d models.Deletion) error {
+	result, err := s.exec(ctx, `UPDATE `+table+` SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`,
+		d.At.UTC(), d.By, id)
+	return affected(result, err, ErrNotFound)
+}
+
+// restore clears the deletion of a trashed record of the table
+func (s sqlStore) restore(ctx context.Context, table, id string) error {
+	result, err := s.exec(ctx, `UPDATE `+table+` SET deleted_at = NULL, deleted_by = '' WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return affected(result, err, ErrNotFound)
+}
+
+// sqlExecer is the part of *sql.DB and *sql.Tx used to write records
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// scanner is the part of *sql.Row and *sql.Rows used by the scan functions
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type sqlChemicals struct{ sqlStore }
+
+const chemicalColumns = `id, name, cas, school, purchase_date, expiration_date, status, room, cabinet, shelf, sds_url,
+	container_amount, container_unit, remaining_amount, remaining_unit, reorder_amount, reorder_unit,
+	checked_out_by, checked_out_room, checked_out_at, deleted_by, deleted_at,
+	disposal_state, disposal_reason, disposal_flagged_by, disposal_flagged_at, disposal_method, disposal_vendor,
+	disposal_approved_by, disposal_approved_at, disposal_manifest, disposed_at,
+	hazard_classes, hazard_statements, precautionary_statements, signal_word, pictograms, storage_groups, location_id,
+	synonyms, notes, sds_date`
+
+func scanChemical(row scanner) (models.Chemical, error) {
+	var c models.Chemical
+	var purchaseDate, expirationDate, sdsDate, checkedOutAt, deletedAt sql.NullTime
+	var checkedOutBy, checkedOutRoom, deletedBy string
+	var disposal models.Disposal
+	var flaggedAt, approvedAt, disposedAt sql.NullTime
+	var hazardClasses, hazardStatements, precautionaryStatements, pictograms, storageGroups, synonyms string
+	err := row.Scan(&c.ID, &c.Name, &c.CAS, &c.School, &purchaseDate, &expirationDate,
+		&c.Status, &c.Room, &c.Cabinet, &c.Shelf, &c.SDSURL,
+		&c.ContainerSize.Amount, &c.ContainerSize.Unit, &c.Remaining.Amount, &c.Remaining.Unit,
+		&c.ReorderThreshold.Amount, &c.ReorderThreshold.Unit,
+		&checkedOutBy, &checkedOutRoom, &checkedOutAt, &deletedBy, &deletedAt,
+		&disposal.State, &disposal.Reason, &disposal.FlaggedBy, &flaggedAt, &disposal.Method, &disposal.Vendor,
+		&disposal.ApprovedBy, &approvedAt, &disposal.ManifestNumber, &disposedAt,
+		&hazardClasses, &hazardStatements, &precautionaryStatements, &c.Hazards.SignalWord, &pictograms,
+		&storageGroups, &c.LocationID, &synonyms, &c.Notes, &sdsDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Chemical{}, ErrNotFound
+	}
+	if purchaseDate.Valid {
+		c.PurchaseDate = models.NewDate(purchaseDate.Time)
+	}
+	if expirationDate.Valid {
+		c.ExpirationDate = models.NewDate(expirationDate.Time)
+	}
+	if sdsDate.Valid {
+		c.SDSDate = models.NewDate(sdsDate.Time)
+	}
+	if checkedOutBy != "" {
+		c.CheckedOut = &models.Checkout{UserID: checkedOutBy, Room: checkedOutRoom, Since: checkedOutAt.Time}
+	}
+	c.Deleted = deletion(deletedBy, deletedAt)
+	if disposal.State != "" {
+		disposal.FlaggedAt, disposal.ApprovedAt, disposal.DisposedAt = flaggedAt.Time, approvedAt.Time, disposedAt.Time
+		c.Disposal = &disposal
+	}
+	c.Hazards.Classes = splitCodes(hazardClasses)
+	c.Hazards.HazardStatements = splitCodes(hazardStatements)
+	c.Hazards.PrecautionaryStatements = splitCodes(precautionaryStatements)
+	c.Hazards.Pictograms = splitCodes(pictograms)
+	c.StorageGroups = splitCodes(storageGroups)
+	c.Synonyms = splitLines(synonyms)
+	return c, err
+}
+
+// hazardColumns splits hazard data into the values of the hazard columns, in their order in chemicalColumns.
+// Lists of codes are stored as ",H225,H319," so a single code can be found with LIKE.
+func hazardColumns(h models.Hazards) []interface{} {
+	return []interface{}{joinCodes(h.Classes), joinCodes(h.HazardStatements), joinCodes(h.PrecautionaryStatements),
+		h.SignalWord, joinCodes(h.Pictograms)}
+}
+
+func joinCodes(codes []string) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	return "," + strings.Join(codes, ",") + ","
+}
+
+func splitCodes(value string) []string {
+	value = strings.Trim(value, ",")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// joinLines stores a list of names, which may hold commas, one per line
+func joinLines(names []string) string {
+	return strings.Join(names, "\n")
+}
+
+func splitLines(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+// codePattern is the LIKE pattern matching a list of codes that contains the code
+func codePattern(code string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(code)
+	return "%," + escaped + ",%"
+}
+
+// disposalColumns splits a disposal into the values of the disposal columns, in their order in chemicalColumns
+func disposalColumns(d *models.Disposal) []interface{} {
+	if d == nil {
+		d = &models.Disposal{}
+	}
+	return []interface{}{string(d.State), d.Reason, d.FlaggedBy, nullTime(d.FlaggedAt), d.Method, d.Vendor,
+		d.ApprovedBy, nullTime(d.ApprovedAt), d.ManifestNumber, nullTime(d.DisposedAt)}
+}
+
+// deletionColumns splits a deletion into the deleted_by and deleted_at values
+func deletionColumns(d *models.Deletion) (string, sql.NullTime) {
+	if d == nil {
+		return "", sql.NullTime{}
+	}
+	return d.By, nullTime(d.At)
+}
+
+// checkoutColumns splits a check-out into the checked_out_by, checked_out_room and checked_out_at values
+func checkoutColumns(checkout *models.Checkout) (string, string, sql.NullTime) {
+	if checkout == nil {
+		return "", "", sql.NullTime{}
+	}
+	return checkout.UserID, checkout.Room, nullTime(checkout.Since)
+}
+
+func (r *sqlChemicals) Create(ctx context.Context, c *models.Chemical) error {
+	return insertChemical(ctx, r.db, r.dialect, c)
+}
+
+// insertChemical stores a new chemical through the database or a transaction
+func insertChemical(ctx context.Context, db sqlExecer, dialect Dialect, c *models.Chemical) error {
+	if c.ID == "" {
+		c.ID = newID()
+	}
+	checkedOutBy, checkedOutRoom, checkedOutAt := checkoutColumns(c.CheckedOut)
+	deletedBy, deletedAt := deletionColumns(c.Deleted)
+	args := append([]interface{}{c.ID, c.Name, c.CAS, c.School, nullTime(c.PurchaseDate.Time), nullTime(c.ExpirationDate.Time),
+		c.Status, c.Room, c.Cabinet, c.Shelf, c.SDSURL,
+		c.ContainerSize.Amount, c.ContainerSize.Unit, c.Remaining.Amount, c.Remaining.Unit,
+		c.ReorderThreshold.Amount, c.ReorderThreshold.Unit, checkedOutBy, checkedOutRoom, checkedOutAt,
+		deletedBy, deletedAt}, disposalColumns(c.Disposal)...)
+	args = append(append(args, hazardColumns(c.Hazards)...), joinCodes(c.StorageGroups), c.LocationID,
+		joinLines(c.Synonyms), c.Notes, nullTime(c.SDSDate.Time), nameKey(*c), roomKey(*c))
+	result, err := db.ExecContext(ctx, dialect.rebind(`INSERT INTO chemicals (`+chemicalColumns+`, name_key, room_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+		?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`), args...)
+	return affected(result, err, ErrAlreadyExists)
+}
+
+func (r *sqlChemicals) CreateMany(ctx context.Context, chemicals []*models.Chemical) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range chemicals {
+		if err := insertChemical(ctx, tx, r.dialect, c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqlChemicals) Get(ctx context.Context, id string) (models.Chemical, error) {
+	return scanChemical(r.queryRow(ctx, `SELECT `+chemicalColumns+` FROM chemicals WHERE id = ? AND deleted_at IS NULL`, id))
+}
+
+func (r *sqlChemicals) GetTrashed(ctx context.Context, id string) (models.Chemical, error) {
+	return scanChemical(r.queryRow(ctx, `SELECT `+chemicalColumns+` FROM chemicals WHERE id = ? AND deleted_at IS NOT NULL`, id))
+}
+
+func (r *sqlChemicals) List(ctx context.Context, filter ChemicalFilter) ([]models.Chemical, error) {
+	query := `SELECT ` + chemicalColumns + ` FROM chemicals WHERE ` + trashCondition(filter.Trashed)
+	var args []interface{}
+	if filter.School != "" {
+		query += ` AND school = ?`
+		args = append(args, filter.School)
+	}
+	if filter.LocationID != "" {
+		query += ` AND location_id = ?`
+		args = append(args, filter.LocationID)
+	}
+	if filter.Disposal != "" {
+		query += ` AND disposal_state = ?`
+		args = append(args, string(filter.Disposal))
+	}
+	for _, f := range []struct{ column, code string }{
+		{"hazard_classes", filter.HazardClass},
+		{"hazard_statements", filter.HazardStatement},
+		{"pictograms", filter.Pictogram},
+	} {
+		if f.code != "" {
+			query += ` AND ` + f.column + ` LIKE ? ESCAPE '\'`
+			args = append(args, codePattern(f.code))
+		}
+	}
+	if filter.SignalWord != "" {
+		query += ` AND signal_word = ?`
+		args = append(args, filter.SignalWord)
+	}
+	if filter.Status != "" {
+		query += ` AND LOWER(status) = LOWER(?)`
+		args = append(args, filter.Status)
+	}
+	if filter.Room != "" {
+		query += ` AND LOWER(room) = LOWER(?)`
+		args = append(args, filter.Room)
+	}
+	if !filter.ExpiringBefore.IsZero() {
+		query += ` AND expiration_date < ?`
+		args = append(args, filter.ExpiringBefore.UTC())
+	}
+	after, err := filter.after()
+	if err != nil {
+		return nil, err
+	}
+
+	// The page is read in the filter's order from its cursor on. Low stock depends on the units, so
+	// it is matched here, reading on from the last chemical until the page is full.
+	columns, columnArgs := r.dialect.chemicalOrder(filter.Sort)
+	direction, comparison := ` ASC`, `>`
+	if filter.Descending {
+		direction, comparison = ` DESC`, `<`
+	}
+	var chemicals []models.Chemical
+	for {
+		pageQuery, pageArgs := query, append([]interface{}(nil), args...)
+		if after != nil {
+			pageQuery += ` AND (` + strings.Join(columns, `, `) + `) ` + comparison + ` (?` + strings.Repeat(`, ?`, len(columns)-1) + `)`
+			pageArgs = append(append(pageArgs, columnArgs...), after.values()...)
+		}
+		pageQuery += ` ORDER BY ` + strings.Join(columns, direction+`, `) + direction
+		pageArgs = append(pageArgs, columnArgs...)
+		if filter.Limit > 0 {
+			pageQuery += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+		}
+
+		read, last, err := r.listPage(ctx, pageQuery, pageArgs, func(c models.Chemical) {
+			if filter.matchesInventory(c) && !filter.full(chemicals) {
+				chemicals = append(chemicals, c)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		if filter.Limit <= 0 || read < filter.Limit || filter.full(chemicals) {
+			return chemicals, nil
+		}
+		key := filter.sortKey(last)
+		after = &key
+	}
+}
+
+// listPage reads the chemicals of a query, returning how many there were and the last of them
+func (r *sqlChemicals) listPage(ctx context.Context, query string, args []interface{}, fn func(models.Chemical)) (int, models.Chemical, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return 0, models.Chemical{}, err
+	}
+	defer rows.Close()
+
+	read, last := 0, models.Chemical{}
+	for rows.Next() {
+		c, err := scanChemical(rows)
+		if err != nil {
+			return 0, models.Chemical{}, err
+		}
+		read, last = read+1, c
+		fn(c)
+	}
+	return read, last, rows.Err()
+}
+
+// chemicalOrder returns the expressions chemicals are sorted by in a sort order, in the order of
+// chemicalKey.values, and the arguments they take. PostgreSQL compares text byte by byte like Go
+// and SQLite, so every backend lists chemicals in the same order.
+func (d Dialect) chemicalOrder(sort ChemicalSort) ([]string, []interface{}) {
+	text := func(column string) string {
+		if d == Postgres {
+			return column + ` COLLATE "C"`
+		}
+		return column
+	}
+	var columns []string
+	var args []interface{}
+	switch sort {
+	case SortByName:
+		columns = []string{text("name_key")}
+	case SortByCAS:
+		columns = []string{text("cas")}
+	case SortByExpiration:
+		columns, args = []string{"COALESCE(expiration_date, ?)"}, []interface{}{noExpiration}
+	case SortByLocation:
+		columns = []string{text("school"), text("room_key"), "cabinet", "shelf"}
+	}
+	return append(columns, text("id")), args
+}
+
+func (r *sqlChemicals) Update(ctx context.Context, c models.Chemical) error {
+	return updateChemical(ctx, r.db, r.dialect, c)
+}
+
+// updateChemical replaces a stored chemical through the database or a transaction
+func updateChemical(ctx context.Context, db sqlExecer, dialect Dialect, c models.Chemical) error {
+	checkedOutBy, checkedOutRoom, checkedOutAt := checkoutColumns(c.CheckedOut)
+	args := append([]interface{}{c.Name, c.CAS, c.School, nullTime(c.PurchaseDate.Time), nullTime(c.ExpirationDate.Time),
+		c.Status, c.Room, c.Cabinet, c.Shelf, c.SDSURL,
+		c.ContainerSize.Amount, c.ContainerSize.Unit, c.Remaining.Amount, c.Remaining.Unit,
+		c.ReorderThreshold.Amount, c.ReorderThreshold.Unit, checkedOutBy, checkedOutRoom, checkedOutAt},
+		disposalColumns(c.Disposal)...)
+	args = append(append(args, hazardColumns(c.Hazards)...), joinCodes(c.StorageGroups), c.LocationID,
+		joinLines(c.Synonyms), c.Notes, nullTime(c.SDSDate.Time), nameKey(c), roomKey(c))
+	result, err := db.ExecContext(ctx, dialect.rebind(`UPDATE chemicals SET name = ?, cas = ?, school = ?, purchase_date = ?,
+		expiration_date = ?, status = ?, room = ?, cabinet = ?, shelf = ?, sds_url = ?,
+		container_amount = ?, container_unit = ?, remaining_amount = ?, remaining_unit = ?,
+		reorder_amount = ?, reorder_unit = ?, checked_out_by = ?, checked_out_room = ?, checked_out_at = ?,
+		disposal_state = ?, disposal_reason = ?, disposal_flagged_by = ?, disposal_flagged_at = ?, disposal_method = ?,
+		disposal_vendor = ?, disposal_approved_by = ?, disposal_approved_at = ?, disposal_manifest = ?, disposed_at = ?,
+		hazard_classes = ?, hazard_statements = ?, precautionary_statements = ?, signal_word = ?, pictograms = ?,
+		storage_groups = ?, location_id = ?, synonyms = ?, notes = ?, sds_date = ?, name_key = ?, room_key = ?
+		WHERE id = ?`), append(args, c.ID)...)
+	return affected(result, err, ErrNotFound)
+}
+
+func (r *sqlChemicals) Trash(ctx context.Context, id string, d models.Deletion) error {
+	return r.trash(ctx, "chemicals", id, d)
+}
+
+func (r *sqlChemicals) Restore(ctx context.Context, id string) error {
+	return r.restore(ctx, "chemicals", id)
+}
+
+func (r *sqlChemicals) Delete(ctx context.Context, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM chemicals WHERE id = ?`, id)
+	return affected(result, err, ErrNotFound)
+}
+
+type sqlUsers struct{ sqlStore }
+
+const userColumns = `id, first, last, email, password, school, expo_push_token, is_admin, is_master,
+	allow_email, allow_push, profile_picture_url, reset_token, reset_expiry, deleted_by, deleted_at, locale`
+
+func scanUser(row scanner) (models.User, error) {
+	var u models.User
+	var resetExpiry, deletedAt sql.NullTime
+	var deletedBy string
+	err := row.Scan(&u.ID, &u.First, &u.Last, &u.Email, &u.Password, &u.School, &u.ExpoPushToken,
+		&u.IsAdmin, &u.IsMaster, &u.AllowEmail, &u.AllowPush, &u.ProfilePictureURL, &u.ResetToken, &resetExpiry,
+		&deletedBy, &deletedAt, &u.Locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	u.ResetExpiry = resetExpiry.Time
+	u.Deleted = deletion(deletedBy, deletedAt)
+	return u, err
+}
+
+func (r *sqlUsers) Create(ctx context.Context, u *models.User) error {
+	if u.ID == "" {
+		u.ID = newID()
+	}
+	deletedBy, deletedAt := deletionColumns(u.Deleted)
+	result, err := r.exec(ctx, `INSERT INTO users (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		u.ID, u.First, u.Last, u.Email, u.Password, u.School, u.ExpoPushToken,
+		u.IsAdmin, u.IsMaster, u.AllowEmail, u.AllowPush, u.ProfilePictureURL, u.ResetToken, nullTime(u.ResetExpiry),
+		deletedBy, deletedAt, u.Locale)
+	return affected(result, err, ErrAlreadyExists)
+}
+
+func (r *sqlUsers) Get(ctx context.Context, id string) (models.User, error) {
+	return scanUser(r.queryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`, id))
+}
+
+func (r *sqlUsers) GetTrashed(ctx context.Context, id string) (models.User, error) {
+	return scanUser(r.queryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NOT NULL`, id))
+}
+
+func (r *sqlUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(r.queryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = ? AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`, email))
+}
+
+func (r *sqlUsers) GetByResetToken(ctx context.Context, tokenHash string) (models.User, error) {
+	if tokenHash == "" {
+		return models.User{}, ErrNotFound
+	}
+	return scanUser(r.queryRow(ctx, `SELECT `+userColumns+` FROM users WHERE reset_token = ? AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`, tokenHash))
+}
+
+func (r *sqlUsers) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	after, err := afterUser(filter.After)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + trashCondition(filter.Trashed)
+	var args []interface{}
+	if filter.School != "" {
+		query += ` AND school = ?`
+		args = append(args, filter.School)
+	}
+	if after != "" {
+		query += ` AND id > ?`
+		args = append(args, after)
+	}
+	query += ` ORDER BY id`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+	}
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *sqlUsers) Update(ctx context.Context, u models.User) error {
+	result, err := r.exec(ctx, `UPDATE users SET first = ?, last = ?, email = ?, password = ?, school = ?,
+		expo_push_token = ?, is_admin = ?, is_master = ?, allow_email = ?, allow_push = ?,
+		profile_picture_url = ?, reset_token = ?, reset_expiry = ?, locale = ? WHERE id = ?`,
+		u.First, u.Last, u.Email, u.Password, u.School, u.ExpoPushToken,
+		u.IsAdmin, u.IsMaster, u.AllowEmail, u.AllowPush, u.ProfilePictureURL, u.ResetToken, nullTime(u.ResetExpiry),
+		u.Locale, u.ID)
+	return affected(result, err, ErrNotFound)
+}
+
+func (r *sqlUsers) Trash(ctx context.Context, id string, d models.Deletion) error {
+	return r.trash(ctx, "users", id, d)
+}
+
+func (r *sqlUsers) Restore(ctx context.Context, id string) error {
+	return r.restore(ctx, "users", id)
+}
+
+func (r *sqlUsers) Delete(ctx context.Context, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM users WHERE id = ?`, id)
+	return affected(result, err, ErrNotFound)
+}
+
+type sqlRefreshTokens struct{ sqlStore }
+
+const refreshTokenColumns = `hash, user_id, family_id, created_at, expires_at, revoked, revoked_at`
+
+func scanRefreshToken(row scanner) (models.RefreshToken, error) {
+	var t models.RefreshToken
+	var revokedAt sql.NullTime
+	err := row.Scan(&t.Hash, &t.UserID, &t.FamilyID, &t.CreatedAt, &t.ExpiresAt, &t.Revoked, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	t.RevokedAt = revokedAt.Time
+	return t, err
+}
+
+func (r *sqlRefreshTokens) Create(ctx context.Context, t models.RefreshToken) error {
+	result, err := r.exec(ctx, `INSERT INTO refresh_tokens (`+refreshTokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (hash) DO NOTHING`,
+		t.Hash, t.UserID, t.FamilyID, t.CreatedAt.UTC(), t.ExpiresAt.UTC(), t.Revoked, nullTime(t.RevokedAt))
+	return affected(result, err, ErrAlreadyExists)
+}
+
+func (r *sqlRefreshTokens) Get(ctx context.Context, hash string) (models.RefreshToken, error) {
+	return scanRefreshToken(r.queryRow(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE hash = ?`, hash))
+}
+
+func (r *sqlRefreshTokens) Consume(ctx context.Context, hash string) (models.RefreshToken, error) {
+	// The conditional update succeeds for exactly one caller, which is the one that saw the token live
+	result, err := r.exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE, revoked_at = ? WHERE hash = ? AND revoked = FALSE`,
+		time.Now().UTC(), hash)
+	consumed := affected(result, err, ErrNotFound)
+	if consumed != nil && consumed != ErrNotFound {
+		return models.RefreshToken{}, consumed
+	}
+
+	t, err := r.Get(ctx, hash)
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+	if consumed == nil {
+		t.Revoked = false
+		t.RevokedAt = time.Time{}
+	}
+	return t, nil
+}
+
+func (r *sqlRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE, revoked_at = ? WHERE family_id = ? AND revoked = FALSE`,
+		time.Now().UTC(), familyID)
+	return err
+}
+
+func (r *sqlRefreshTokens) RevokeUser(ctx context.Context, userID string) error {
+	_, err := r.exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE, revoked_at = ? WHERE user_id = ? AND revoked = FALSE`,
+		time.Now().UTC(), userID)
+	return err
+}
+
+type sqlUsage struct{ sqlStore }
+
+const usageColumns = `id, chemical_id, school, kind, user_id, recorded_by, amount, amount_unit,
+	remaining_amount, remaining_unit, room, notes, created_at`
+
+func scanUsageEvent(row scanner) (models.UsageEvent, error) {
+	var e models.UsageEvent
+	err := row.Scan(&e.ID, &e.ChemicalID, &e.School, &e.Kind, &e.UserID, &e.RecordedBy,
+		&e.Amount.Amount, &e.Amount.Unit, &e.Remaining.Amount, &e.Remaining.Unit, &e.Room, &e.Notes, &e.CreatedAt)
+	return e, err
+}
+
+func (r *sqlUsage) Record(ctx context.Context, e *models.UsageEvent) (models.Chemical, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Chemical{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the row on PostgreSQL. SQLite runs on a single connection, so the transaction is already exclusive.
+	query := `SELECT ` + chemicalColumns + ` FROM chemicals WHERE id = ? AND deleted_at IS NULL`
+	if r.dialect == Postgres {
+		query += ` FOR UPDATE`
+	}
+	c, err := scanChemical(tx.QueryRowContext(ctx, r.dialect.rebind(query), e.ChemicalID))
+	if err != nil {
+		return models.Chemical{}, err
+	}
+	if err := c.ApplyUsage(e); err != nil {
+		return models.Chemical{}, err
+	}
+
+	checkedOutBy, checkedOutRoom, checkedOutAt := checkoutColumns(c.CheckedOut)
+	_, err = tx.ExecContext(ctx, r.dialect.rebind(`UPDATE chemicals SET remaining_amount = ?, remaining_unit = ?,
+		checked_out_by = ?, checked_out_room = ?, checked_out_at = ? WHERE id = ?`),
+		c.Remaining.Amount, c.Remaining.Unit, checkedOutBy, checkedOutRoom, checkedOutAt, c.ID)
+	if err != nil {
+		return models.Chemical{}, err
+	}
+
+	e.ID = newID()
+	_, err = tx.ExecContext(ctx, r.dialect.rebind(`INSERT INTO usage_events (`+usageColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		e.ID, e.ChemicalID, e.School, e.Kind, e.UserID, e.RecordedBy, e.Amount.Amount, e.Amount.Unit,
+		e.Remaining.Amount, e.Remaining.Unit, e.Room, e.Notes, e.CreatedAt.UTC())
+	if err != nil {
+		return models.Chemical{}, err
+	}
+	return c, tx.Commit()
+}
+
+func (r *sqlUsage) List(ctx context.Context, filter UsageFilter) ([]models.UsageEvent, error) {
+	var conditions []string
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"chemical_id", filter.ChemicalID},
+		{"user_id", filter.UserID},
+		{"school", filter.School},
+	} {
+		if f.value != "" {
+			conditions = append(conditions, f.column+` = ?`)
+			args = append(args, f.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `SELECT ` + usageColumns + ` FROM usage_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	rows, err := r.query(ctx, query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.UsageEvent
+	for rows.Next() {
+		e, err := scanUsageEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+type sqlAudit struct{ sqlStore }
+
+const auditColumns = `id, entity_type, entity_id, school, action, actor, request_id, changes, created_at`
+
+func scanAuditEntry(row scanner) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var changes string
+	err := row.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.School, &e.Action, &e.Actor, &e.RequestID, &changes, &e.CreatedAt)
+	if err != nil {
+		return e, err
+	}
+	return e, json.Unmarshal([]byte(changes), &e.Changes)
+}
+
+func (r *sqlAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	return insertAuditEntry(ctx, r.db, r.dialect, e)
+}
+
+// insertAuditEntry inserts an audit entry and sets its ID
+func insertAuditEntry(ctx context.Context, db sqlExecer, dialect Dialect, e *models.AuditEntry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	e.ID = newID()
+	_, err = db.ExecContext(ctx, dialect.rebind(`INSERT INTO audit_log (`+auditColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		e.ID, e.EntityType, e.EntityID, e.School, e.Action, e.Actor, e.RequestID, string(changes), e.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqlAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"entity_type", filter.EntityType},
+		{"entity_id", filter.EntityID},
+		{"school", filter.School},
+	} {
+		if f.value != "" {
+			conditions = append(conditions, f.column+` = ?`)
+			args = append(args, f.value)
+		}
+	}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, filter.Before.UTC())
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(filter.Limit)
+	}
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+type sqlLocations struct{ sqlStore }
+
+const locationColumns = `id, parent_id, kind, name, school, capacity, attributes`
+
+func scanLocation(row scanner) (models.Location, error) {
+	var l models.Location
+	var attributes string
+	err := row.Scan(&l.ID, &l.ParentID, &l.Kind, &l.Name, &l.School, &l.Capacity, &attributes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Location{}, ErrNotFound
+	}
+	l.Attributes = splitCodes(attributes)
+	return l, err
+}
+
+func (r *sqlLocations) Create(ctx context.Context, l *models.Location) error {
+	if l.ID == "" {
+		l.ID = newID()
+	}
+	result, err := r.exec(ctx, `INSERT INTO locations (`+locationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`, l.ID, l.ParentID, string(l.Kind), l.Name, l.School, l.Capacity, joinCodes(l.Attributes))
+	return affected(result, err, ErrAlreadyExists)
+}
+
+func (r *sqlLocations) Get(ctx context.Context, id string) (models.Location, error) {
+	return scanLocation(r.queryRow(ctx, `SELECT `+locationColumns+` FROM locations WHERE id = ?`, id))
+}
+
+func (r *sqlLocations) List(ctx context.Context, filter LocationFilter) ([]models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE 1 = 1`
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"school", filter.School},
+		{"parent_id", filter.ParentID},
+		{"kind", string(filter.Kind)},
+	} {
+		if f.value != "" {
+			query += ` AND ` + f.column + ` = ?`
+			args = append(args, f.value)
+		}
+	}
+	rows, err := r.query(ctx, query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		l, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+func (r *sqlLocations) Update(ctx context.Context, l models.Location) error {
+	result, err := r.exec(ctx, `UPDATE locations SET parent_id = ?, kind = ?, name = ?, school = ?, capacity = ?,
+		attributes = ? WHERE id = ?`, l.ParentID, string(l.Kind), l.Name, l.School, l.Capacity, joinCodes(l.Attributes), l.ID)
+	return affected(result, err, ErrNotFound)
+}
+
+func (r *sqlLocations) Delete(ctx context.Context, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM locations WHERE id = ?`, id)
+	return affected(result, err, ErrNotFound)
+}
+
+type sqlTransfers struct{ sqlStore }
+
+const transferColumns = `id, chemical_id, chemical_name, from_school, to_school, amount, amount_unit, notes,
+	status, requested_by, requested_at, decided_by, decided_at, reason, location_id, room, cabinet, shelf, received_id`
+
+func scanTransfer(row scanner) (models.Transfer, error) {
+	var t models.Transfer
+	var decidedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.ChemicalID, &t.ChemicalName, &t.FromSchool, &t.ToSchool, &t.Amount.Amount, &t.Amount.Unit,
+		&t.Notes, &t.Status, &t.RequestedBy, &t.RequestedAt, &t.DecidedBy, &decidedAt, &t.Reason,
+		&t.LocationID, &t.Room, &t.Cabinet, &t.Shelf, &t.ReceivedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Transfer{}, ErrNotFound
+	}
+	if decidedAt.Valid {
+		t.DecidedAt = &decidedAt.Time
+	}
+	return t, err
+}
+
+func (r *sqlTransfers) Create(ctx context.Context, t *models.Transfer) error {
+	t.ID = newID()
+	// The transfers_pending index allows a single pending transfer per chemical
+	result, err := r.exec(ctx, `INSERT INTO transfers (`+transferColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		t.ID, t.ChemicalID, t.ChemicalName, t.FromSchool, t.ToSchool, t.Amount.Amount, t.Amount.Unit, t.Notes,
+		t.Status, t.RequestedBy, t.RequestedAt.UTC(), t.DecidedBy, optionalTime(t.DecidedAt), t.Reason,
+		t.LocationID, t.Room, t.Cabinet, t.Shelf, t.ReceivedID)
+	return affected(result, err, models.ErrTransferPending)
+}
+
+// optionalTime stores a missing time, such as that of an undecided transfer, as NULL
+func optionalTime(at *time.Time) sql.NullTime {
+	if at == nil {
+		return sql.NullTime{}
+	}
+	return nullTime(*at)
+}
+
+func (r *sqlTransfers) Get(ctx context.Context, id string) (models.Transfer, error) {
+	return scanTransfer(r.queryRow(ctx, `SELECT `+transferColumns+` FROM transfers WHERE id = ?`, id))
+}
+
+func (r *sqlTransfers) List(ctx context.Context, filter TransferFilter) ([]models.Transfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM transfers WHERE 1 = 1`
+	var args []interface{}
+	if filter.School != "" {
+		query += ` AND (from_school = ? OR to_school = ?)`
+		args = append(args, filter.School, filter.School)
+	}
+	if filter.ChemicalID != "" {
+		query += ` AND chemical_id = ?`
+		args = append(args, filter.ChemicalID)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, string(filter.Status))
+	}
+	rows, err := r.query(ctx, query+` ORDER BY requested_at DESC, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+func (r *sqlTransfers) Decide(ctx context.Context, t models.Transfer) error {
+	_, err := r.Get(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	result, err := r.exec(ctx, `UPDATE transfers SET status = ?, decided_by = ?, decided_at = ?, reason = ?
+		WHERE id = ? AND status = ?`, t.Status, t.DecidedBy, optionalTime(t.DecidedAt), t.Reason, t.ID, models.TransferPending)
+	return affected(result, err, models.ErrTransferDecided)
+}
+
+func (r *sqlTransfers) Accept(ctx context.Context, t *models.Transfer, audit func(TransferOutcome) []models.AuditEntry) (TransferOutcome, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the rows on PostgreSQL. SQLite runs on a single connection, so the transaction is already exclusive.
+	lock := ""
+	if r.dialect == Postgres {
+		lock = ` FOR UPDATE`
+	}
+	stored, err := scanTransfer(tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT `+transferColumns+` FROM transfers WHERE id = ?`+lock), t.ID))
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+	if stored.Status != models.TransferPending {
+		return TransferOutcome{}, models.ErrTransferDecided
+	}
+	c, err := scanChemical(tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT `+chemicalColumns+` FROM chemicals
+		WHERE id = ? AND deleted_at IS NULL`+lock), t.ChemicalID))
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+
+	outcome := TransferOutcome{Before: c}
+	received, err := c.ApplyTransfer(t)
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+	if err := updateChemical(ctx, tx, r.dialect, c); err != nil {
+		return TransferOutcome{}, err
+	}
+	if !t.Whole() {
+		if err := insertChemical(ctx, tx, r.dialect, &received); err != nil {
+			return TransferOutcome{}, err
+		}
+	}
+	t.ReceivedID = received.ID
+	_, err = tx.ExecContext(ctx, r.dialect.rebind(`UPDATE transfers SET status = ?, decided_by = ?, decided_at = ?,
+		location_id = ?, room = ?, cabinet = ?, shelf = ?, received_id = ? WHERE id = ?`),
+		t.Status, t.DecidedBy, optionalTime(t.DecidedAt), t.LocationID, t.Room, t.Cabinet, t.Shelf, t.ReceivedID, t.ID)
+	if err != nil {
+		return TransferOutcome{}, err
+	}
+
+	outcome.Sent, outcome.Received = c, received
+	if audit != nil {
+		for _, e := range audit(outcome) {
+			if err := insertAuditEntry(ctx, tx, r.dialect, &e); err != nil {
+				return TransferOutcome{}, err
+			}
+		}
+	}
+	return outcome, tx.Commit()
+}
+
+type sqlJobs struct{ sqlStore }
+
+func (r *sqlJobs) Claim(ctx context.Context, job, holder string, slot time.Time, lease time.Duration) (bool, error) {
+	never := time.Unix(0, 0).UTC()
+	if _, err := r.exec(ctx, `INSERT INTO job_locks (job, holder, locked_until, last_slot) VALUES (?, '', ?, ?)
+		ON CONFLICT DO NOTHING`, job, never, never); err != nil {
+		return false, err
+	}
+	// A single conditional update claims the lock, so only one of several replicas can succeed
+	now := time.Now().UTC()
+	result, err := r.exec(ctx, `UPDATE job_locks SET holder = ?, locked_until = ?, last_slot = ?
+		WHERE job = ? AND last_slot < ? AND (holder = '' OR locked_until < ?)`,
+		holder, now.Add(lease), slot.UTC(), job, slot.UTC(), now)
+	if err := affected(result, err, ErrNotFound); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *sqlJobs) Release(ctx context.Context, job, holder string) error {
+	_, err := r.exec(ctx, `UPDATE job_locks SET holder = '', locked_until = ? WHERE job = ? AND holder = ?`,
+		time.Unix(0, 0).UTC(), job, holder)
+	return err
+}
+
+const jobRunColumns = `id, job, trigger_type, triggered_by, instance, scheduled_at, started_at, finished_at, duration_ms, status, error`
+
+func scanJobRun(row scanner) (models.JobRun, error) {
+	var run models.JobRun
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.ID, &run.Job, &run.Trigger, &run.TriggeredBy, &run.Instance, &run.ScheduledAt, &run.StartedAt,
+		&finishedAt, &run.DurationMS, &run.Status, &run.Error)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, err
+}
+
+func (r *sqlJobs) CreateRun(ctx context.Context, run *models.JobRun) error {
+	run.ID = newID()
+	_, err := r.exec(ctx, `INSERT INTO job_runs (`+jobRunColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Job, run.Trigger, run.TriggeredBy, run.Instance, run.ScheduledAt.UTC(), run.StartedAt.UTC(),
+		optionalTime(run.FinishedAt), run.DurationMS, run.Status, run.Error)
+	return err
+}
+
+func (r *sqlJobs) UpdateRun(ctx context.Context, run models.JobRun) error {
+	result, err := r.exec(ctx, `UPDATE job_runs SET job = ?, trigger_type = ?, triggered_by = ?, instance = ?, scheduled_at = ?,
+		started_at = ?, finished_at = ?, duration_ms = ?, status = ?, error = ? WHERE id = ?`,
+		run.Job, run.Trigger, run.TriggeredBy, run.Instance, run.ScheduledAt.UTC(), run.StartedAt.UTC(),
+		optionalTime(run.FinishedAt), run.DurationMS, run.Status, run.Error, run.ID)
+	return affected(result, err, ErrNotFound)
+}
+
+func (r *sqlJobs) ListRuns(ctx context.Context, filter JobRunFilter) ([]models.JobRun, error) {
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs WHERE 1 = 1`
+	var args []interface{}
+	if filter.Job != "" {
+		query += ` AND job = ?`
+		args = append(args, filter.Job)
+	}
+	query += ` ORDER BY started_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+type sqlAlertRules struct{ sqlStore }
+
+func (r *sqlAlertRules) Get(ctx context.Context, school string) (models.AlertRules, error) {
+	var rules models.AlertRules
+	var leadDays string
+	var updatedAt sql.NullTime
+	err := r.queryRow(ctx, `SELECT school, expired, expiring_lead_days, low_stock,
+		low_stock_percent, sds_missing, sds_max_age_years, storage_conflicts, updated_by, updated_at
+		FROM alert_rules WHERE school = ?`, school).Scan(&rules.School, &rules.Expired, &leadDays, &rules.LowStock,
+		&rules.LowStockPercent, &rules.SDSMissing, &rules.SDSMaxAgeYears, &rules.StorageConflicts, &rules.UpdatedBy, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.AlertRules{}, ErrNotFound
+	}
+	if err != nil {
+		return models.AlertRules{}, err
+	}
+	for _, days := range splitCodes(leadDays) {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return models.AlertRules{}, fmt.Errorf("alert rules of %s: bad lead time %q", school, days)
+		}
+		rules.ExpiringLeadDays = append(rules.ExpiringLeadDays, n)
+	}
+	if updatedAt.Valid {
+		rules.UpdatedAt = &updatedAt.Time
+	}
+	return rules, nil
+}
+
+func (r *sqlAlertRules) Put(ctx context.Context, rules models.AlertRules) error {
+	leadDays := make([]string, len(rules.ExpiringLeadDays))
+	for i, days := range rules.ExpiringLeadDays {
+		leadDays[i] = strconv.Itoa(days)
+	}
+	_, err := r.exec(ctx, `INSERT INTO alert_rules (school, expired, expiring_lead_days, low_stock, low_stock_percent,
+		sds_missing, sds_max_age_years, storage_conflicts, updated_by, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (school) DO UPDATE SET expired = excluded.expired, expiring_lead_days = excluded.expiring_lead_days,
+		low_stock = excluded.low_stock, low_stock_percent = excluded.low_stock_percent, sds_missing = excluded.sds_missing,
+		sds_max_age_years = excluded.sds_max_age_years, storage_conflicts = excluded.storage_conflicts,
+		updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		rules.School, rules.Expired, joinCodes(leadDays), rules.LowStock, rules.LowStockPercent,
+		rules.SDSMissing, rules.SDSMaxAgeYears, rules.StorageConflicts, rules.UpdatedBy, optionalTime(rules.UpdatedAt))
+	return err
+}
+
+func (r *sqlAlertRules) Delete(ctx context.Context, school string) error {
+	result, err := r.exec(ctx, `DELETE FROM alert_rules WHERE school = ?`, school)
+	return affected(result, err, ErrNotFound)
+}
+
+type sqlAlerts struct{ sqlStore }
+
+const alertColumns = `id, school, kind, severity, chemical_id, chemical_name, cas, room, message, details, status,
+	first_seen, last_seen, notified_at, notified_severity, notified_lead_days, acknowledged_by, acknowledged_at,
+	snoozed_by, snoozed_until, resolved_by, resolved_at`
+
+// alertDetails are the details of an alert's condition, stored as JSON since they depend on its kind
+type alertDetails struct {
+	ExpirationDate   *models.Date            `json:"expiration_date,omitempty"`
+	LeadDays         int                     `json:"lead_days,omitempty"`
+	Remaining        *models.Quantity        `json:"remaining,omitempty"`
+	ReorderThreshold *models.Quantity        `json:"reorder_threshold,omitempty"`
+	SDSDate          *models.Date            `json:"sds_date,omitempty"`
+	Conflict         *models.StorageConflict `json:"conflict,omitempty"`
+}
+
+func scanAlert(row scanner) (models.Alert, error) {
+	var a models.Alert
+	var details string
+	var notifiedAt, acknowledgedAt, snoozedUntil, resolvedAt sql.NullTime
+	err := row.Scan(&a.ID, &a.School, &a.Kind, &a.Severity, &a.ChemicalID, &a.ChemicalName, &a.CAS, &a.Room, &a.Message,
+		&details, &a.Status, &a.FirstSeen, &a.LastSeen, &notifiedAt, &a.NotifiedSeverity, &a.NotifiedLeadDays,
+		&a.AcknowledgedBy, &acknowledgedAt, &a.SnoozedBy, &snoozedUntil, &a.ResolvedBy, &resolvedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Alert{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Alert{}, err
+	}
+	var d alertDetails
+	if err := json.Unmarshal([]byte(details), &d); err != nil {
+		return models.Alert{}, fmt.Errorf("alert %s: %w", a.ID, err)
+	}
+	a.ExpirationDate, a.LeadDays, a.Remaining, a.ReorderThreshold, a.SDSDate, a.Conflict =
+		d.ExpirationDate, d.LeadDays, d.Remaining, d.ReorderThreshold, d.SDSDate, d.Conflict
+	for _, t := range []struct {
+		column sql.NullTime
+		field  **time.Time
+	}{{notifiedAt, &a.NotifiedAt}, {acknowledgedAt, &a.AcknowledgedAt}, {snoozedUntil, &a.SnoozedUntil}, {resolvedAt, &a.ResolvedAt}} {
+		if t.column.Valid {
+			at := t.column.Time
+			*t.field = &at
+		}
+	}
+	return a, nil
+}
+
+// alertValues returns the values of an alert's columns, in their order in alertColumns
+func alertValues(a models.Alert) ([]interface{}, error) {
+	details, err := json.Marshal(alertDetails{ExpirationDate: a.ExpirationDate, LeadDays: a.LeadDays, Remaining: a.Remaining,
+		ReorderThreshold: a.ReorderThreshold, SDSDate: a.SDSDate, Conflict: a.Conflict})
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{a.ID, a.School, a.Kind, a.Severity, a.ChemicalID, a.ChemicalName, a.CAS, a.Room, a.Message,
+		string(details), a.Status, a.FirstSeen.UTC(), a.LastSeen.UTC(), optionalTime(a.NotifiedAt), a.NotifiedSeverity,
+		a.NotifiedLeadDays, a.AcknowledgedBy, optionalTime(a.AcknowledgedAt), a.SnoozedBy, optionalTime(a.SnoozedUntil),
+		a.ResolvedBy, optionalTime(a.ResolvedAt)}, nil
+}
+
+func (r *sqlAlerts) Create(ctx context.Context, a *models.Alert) error {
+	a.ID = newID()
+	values, err := alertValues(*a)
+	if err != nil {
+		return err
+	}
+	_, err = r.exec(ctx, `INSERT INTO alerts (`+alertColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, values...)
+	return err
+}
+
+func (r *sqlAlerts) Get(ctx context.Context, id string) (models.Alert, error) {
+	return scanAlert(r.queryRow(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = ?`, id))
+}
+
+func (r *sqlAlerts) List(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE 1 = 1`
+	var args []interface{}
+	for _, f := range []struct {
+		column string
+		value  string
+	}{{"school", filter.School}, {"chemical_id", filter.ChemicalID}, {"kind", string(filter.Kind)}, {"severity", string(filter.Severity)}} {
+		if f.value != "" {
+			query += ` AND ` + f.column + ` = ?`
+			args = append(args, f.value)
+		}
+	}
+	if len(filter.Statuses) > 0 {
+		query += ` AND status IN (?` + strings.Repeat(`, ?`, len(filter.Statuses)-1) + `)`
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	query += ` ORDER BY last_seen DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *sqlAlerts) Update(ctx context.Context, a models.Alert) error {
+	values, err := alertValues(a)
+	if err != nil {
+		return err
+	}
+	result, err := r.exec(ctx, `UPDATE alerts SET school = ?, kind = ?, severity = ?, chemical_id = ?, chemical_name = ?,
+		cas = ?, room = ?, message = ?, details = ?, status = ?, first_seen = ?, last_seen = ?, notified_at = ?,
+		notified_severity = ?, notified_lead_days = ?, acknowledged_by = ?, acknowledged_at = ?, snoozed_by = ?,
+		snoozed_until = ?, resolved_by = ?, resolved_at = ? WHERE id = ?`, append(values[1:], a.ID)...)
+	return affected(result, err, ErrNotFound)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+)
+
+// RegisterRoutesAlerts registers the routes of the chemical alerts and the rules raising them
+func RegisterRoutesAlerts(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
+
+	r.GET("/alerts", h.GetAlerts)                         // List the stored alerts of a school
+	r.GET("/alerts/rules", h.GetAlertRules)               // Get the alert rules of a school
+	r.PUT("/alerts/rules", h.UpdateAlertRules)            // Set the alert rules of a school
+	r.DELETE("/alerts/rules", h.ResetAlertRules)          // Go back to the default alert rules
+	r.GET("/alerts/:id", h.GetAlert)                      // Get an alert
+	r.POST("/alerts/:id/acknowledge", h.AcknowledgeAlert) // Mark an alert as seen
+	r.POST("/alerts/:id/snooze", h.SnoozeAlert)           // Put an alert off until a date
+	r.POST("/alerts/:id/resolve", h.ResolveAlert)         // Close an alert
+}
@@ -3,17 +3,46 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
 )
 
 // RegisterRoutes defines and registers all routes
-func RegisterRoutesChemical(router *gin.Engine) {
-	r := router.Group("/api/v1")
+func RegisterRoutesChemical(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
 
 	// Chemical routes
-	r.POST("/chemicals", controllers.AddChemical)        // Create a new chemical
-	r.GET("/chemicals", controllers.GetChemicals)        // Get all chemicals
-	r.GET("/chemicals/:id", controllers.GetChemical)     // Get a specific chemical by ID
-	r.PUT("/chemicals/:id", controllers.UpdateChemical)  // Update a chemical by ID
-	r.DELETE("/chemicals/:id", controllers.DeleteChemical) // Delete a chemical by ID
+	r.POST("/chemicals", h.AddChemical)        // Create a new chemical
+	r.GET("/chemicals", h.GetChemicals)        // Get all chemicals
+	r.GET("/chemicals/search", h.SearchChemicals) // Search chemicals by name, synonyms, CAS, location and notes
+	r.POST("/chemicals/import", h.ImportChemicals) // Import chemicals from a CSV or XLSX file
+	r.GET("/chemicals/export", h.ExportChemicals)  // Export chemicals as CSV, XLSX or JSON
+	r.GET("/chemicals/:id", h.GetChemical)     // Get a specific chemical by ID
+	r.PUT("/chemicals/:id", h.UpdateChemical)  // Update a chemical by ID
+	r.DELETE("/chemicals/:id", h.DeleteChemical) // Delete a chemical by ID
+	r.GET("/ghs", h.GetGHSReference)             // Get the GHS hazard reference table
+	r.GET("/storage/conflicts", h.GetStorageConflicts) // Get the shelves holding incompatible chemicals
+
+	// Usage routes
+	r.POST("/chemicals/:id/usage", h.LogUsage)           // Log an amount used
+	r.GET("/chemicals/:id/usage", h.GetChemicalUsage)    // Get the usage ledger of a chemical
+	r.POST("/chemicals/:id/checkout", h.CheckOutChemical) // Check a container out to a user and room
+	r.POST("/chemicals/:id/checkin", h.CheckInChemical)   // Check a container back in
+	r.GET("/usage", h.GetUsage)                          // Get usage events by school, chemical, user or date range
+
+	// Audit routes
+	r.GET("/chemicals/:id/history", h.GetChemicalHistory) // Get the change history of a chemical
+	r.GET("/audit", h.GetAuditLog)                       // Get the recent activity of a school
+
+	// Trash routes
+	r.GET("/trash/chemicals", h.GetTrashedChemicals)    // Get the deleted chemicals of a school
+	r.POST("/chemicals/:id/restore", h.RestoreChemical) // Restore a deleted chemical
+	r.DELETE("/chemicals/:id/purge", h.PurgeChemical)   // Remove a deleted chemical for good
+
+	// Disposal routes
+	r.POST("/chemicals/:id/disposal/flag", h.FlagChemicalForDisposal)      // Flag a chemical for disposal
+	r.POST("/chemicals/:id/disposal/approve", h.ApproveChemicalDisposal)   // Approve a flagged chemical for pickup
+	r.POST("/chemicals/:id/disposal/complete", h.CompleteChemicalDisposal) // Record the pickup with its manifest number
+	r.DELETE("/chemicals/:id/disposal", h.CancelChemicalDisposal)          // Keep a chemical flagged or approved for disposal
+	r.GET("/disposals", h.GetDisposalReport)                               // Get the disposal report of a school
 
 }
\ No newline at end of file
@@ -1,14 +1,64 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ekjyotshinh/ChemTrack/backend/config"
 	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
-
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
 )
 
-func RegisterRoutesEmail(router *gin.Engine) {
-	r := router.Group("/api/v1")
+// InitTemplates loads the templates of the emails and push notifications in the configured language
+func InitTemplates(cfg config.Config) *notify.Renderer {
+	templates, err := notify.NewRenderer(cfg.NotificationLocale)
+	if err != nil {
+		log.Fatalf("Invalid NOTIFICATION_LOCALE %q: %v", cfg.NotificationLocale, err)
+	}
+	return templates
+}
+
+// captureSender signs captured emails when no sender is configured, on a domain that never delivers
+const captureSender = "ChemTrack <noreply@chemtrack.invalid>"
+
+// InitMailer sets up the configured email transport
+func InitMailer(cfg config.Config) mailer.Mailer {
+	from := cfg.MailFrom
+	if from == "" {
+		if cfg.MailTransport != "capture" {
+			log.Fatalf("MAIL_FROM is required to send emails with MAIL_TRANSPORT %q", cfg.MailTransport)
+		}
+		from = captureSender
+	}
+	sender, err := mailer.ParseSender(from, cfg.MailReplyTo)
+	if err != nil {
+		log.Fatalf("Invalid MAIL_FROM or MAIL_REPLY_TO: %v", err)
+	}
+	switch cfg.MailTransport {
+	case "sendgrid":
+		if cfg.SendGridAPIKey == "" {
+			log.Println("SENDGRID_API_KEY is not set, emails will fail to send")
+		}
+		return mailer.NewSendGrid(cfg.SendGridAPIKey, sender)
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword,
+			Timeout: cfg.SMTPTimeout}, sender)
+	case "capture":
+		log.Println("MAIL_TRANSPORT is capture, emails are logged and not sent")
+		return mailer.NewCapture(sender)
+	default:
+		log.Fatalf("Unknown MAIL_TRANSPORT %q, expected sendgrid, smtp or capture", cfg.MailTransport)
+		return nil
+	}
+}
+
+func RegisterRoutesEmail(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
 	{
-		r.POST("/email/send", controllers.SendEmail)
+		r.POST("/email/send", h.SendEmail)
+		r.GET("/notifications/templates", h.GetNotificationTemplates)                   // List the notification templates and languages
+		r.GET("/notifications/templates/:name/preview", h.PreviewNotificationTemplate) // Render a template with sample data
 	}
 }
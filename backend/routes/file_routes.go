@@ -1,31 +1,62 @@
 package routes
 
 import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/ekjyotshinh/ChemTrack/backend/config"
 	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
 	"github.com/gin-gonic/gin"
 )
 
+// InitStorage creates the configured file storage backend
+func InitStorage(cfg config.Config) blobstore.BlobStore {
+	var store blobstore.BlobStore
+	var err error
+
+	switch cfg.StorageBackend {
+	case "local":
+		store, err = blobstore.NewLocalStore(cfg.LocalStorageDir, cfg.PublicBaseURL)
+	case "gcs":
+		store, err = blobstore.NewGCSStore(context.Background(), cfg.GCSBucket, os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q, expected \"gcs\" or \"local\"", cfg.StorageBackend)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+	log.Printf("Using %s file storage", cfg.StorageBackend)
+
+	return store
+}
+
 // RegisterRoutes defines and registers all routes
-func RegisterRoutesFiles(router *gin.Engine) {
-	r := router.Group("/api/v1")
+func RegisterRoutesFiles(router *gin.Engine, h *controllers.Handler) {
+	// Stored files, the URLs handed out by the local storage backend point here
+	router.GET("/blobs/*key", h.ServeBlob)
+
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
 
 	// sds routes
-	r.POST("/files/sds/:chemicalIdNumber", controllers.AddSDS)      // Create a new chemical
-	r.GET("/files/sds/:chemicalIdNumber", controllers.GetSDS)       // Retrieve SDS URL
-	r.DELETE("/files/sds/:chemicalIdNumber", controllers.DeleteSDS) // Delete SDS
+	r.POST("/files/sds/:chemicalIdNumber", h.AddSDS)      // Create a new chemical
+	r.GET("/files/sds/:chemicalIdNumber", h.GetSDS)       // Retrieve SDS URL
+	r.DELETE("/files/sds/:chemicalIdNumber", h.DeleteSDS) // Delete SDS
 
 	//profile picture routes
-	r.POST("/files/profile/:userId", controllers.AddProfilePicture)      // Add a new profile picture
-	r.GET("/files/profile/:userId", controllers.GetProfilePicture)       // Get the profile picture URL
-	r.DELETE("/files/profile/:userId", controllers.DeleteProfilePicture) // Delete profile picture
-	r.PUT("/files/profile/:userId", controllers.UpdateProfilePicture)    // Update existing profile picture
+	r.POST("/files/profile/:userId", h.AddProfilePicture)      // Add a new profile picture
+	r.GET("/files/profile/:userId", h.GetProfilePicture)       // Get the profile picture URL
+	r.DELETE("/files/profile/:userId", h.DeleteProfilePicture) // Delete profile picture
+	r.PUT("/files/profile/:userId", h.UpdateProfilePicture)    // Update existing profile picture
 
 	// label routes
-	r.POST("/files/label/:chemicalIdNumber", controllers.AddLabel) // Create a new label
-	r.GET("/files/label/:chemicalIdNumber", controllers.GetLabel) // Retrieve label URL
-	r.DELETE("/files/label/:chemicalIdNumber", controllers.DeleteLabel) // Delete label
+	r.POST("/files/label/:chemicalIdNumber", h.AddLabel) // Create a new label
+	r.GET("/files/label/:chemicalIdNumber", h.GetLabel) // Retrieve label URL
+	r.DELETE("/files/label/:chemicalIdNumber", h.DeleteLabel) // Delete label
 
 	// QR code routes
-	r.GET("/files/qrcode/:chemicalIdNumber", controllers.GetQRCode)        // Generate a QR code for a chemical
-	r.GET("/files/qrcode/url/:chemicalIdNumber", controllers.GetQRCodeURL) // Get the QR code URL for a chemical
+	r.GET("/files/qrcode/:chemicalIdNumber", h.GetQRCode)        // Generate a QR code for a chemical
+	r.GET("/files/qrcode/url/:chemicalIdNumber", h.GetQRCodeURL) // Get the QR code URL for a chemical
 }
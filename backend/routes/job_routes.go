@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	_ "time/tzdata" // Time zones for SCHEDULER_TIMEZONE, as the container image has none
+
+	"github.com/gin-gonic/gin"
+	"github.com/ekjyotshinh/ChemTrack/backend/config"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/scheduler"
+	"github.com/ekjyotshinh/ChemTrack/backend/services"
+)
+
+// Names of the background jobs, as used by the job routes
+const (
+	JobChemicalMonitor = "chemical_monitor"
+	JobAlertDigest     = "alert_digest"
+	JobTrashPurge      = "trash_purge"
+)
+
+// InitJobs creates the scheduler of the background jobs. Replicas share the job locks through the repositories.
+func InitJobs(cfg config.Config, repos repository.Repositories) *scheduler.Scheduler {
+	location, err := time.LoadLocation(cfg.SchedulerTimezone)
+	if err != nil {
+		log.Fatalf("Invalid SCHEDULER_TIMEZONE %q: %v", cfg.SchedulerTimezone, err)
+	}
+	host, _ := os.Hostname()
+	instance := fmt.Sprintf("%s-%d", host, os.Getpid())
+	return scheduler.New(repos.Jobs, scheduler.Options{Instance: instance, Lease: cfg.JobLockLease, Location: location})
+}
+
+// StartJobs adds the background jobs to the scheduler and starts it
+func StartJobs(cfg config.Config, jobs *scheduler.Scheduler, repos repository.Repositories, templates *notify.Renderer, mail mailer.Mailer, h *controllers.Handler) {
+	monitor := services.NewChemicalMonitor(repos, templates, mail)
+	for _, job := range []scheduler.Job{
+		{Name: JobChemicalMonitor, Schedule: cfg.ChemicalMonitorSchedule, Run: monitor.CheckCriticalChemicalStatus},
+		{Name: JobAlertDigest, Schedule: cfg.AlertDigestSchedule, Run: monitor.SendAlertDigest},
+		{Name: JobTrashPurge, Schedule: cfg.TrashPurgeSchedule, Run: func(ctx context.Context) error {
+			// Purge the trash of records deleted longer ago than the retention period
+			purged, err := h.PurgeExpiredTrash(ctx, time.Now().Add(-cfg.TrashRetention))
+			log.Printf("Purged %d records deleted more than %s ago", purged, cfg.TrashRetention)
+			return err
+		}},
+	} {
+		if err := jobs.Add(job); err != nil {
+			log.Fatalf("Failed to schedule background jobs: %v", err)
+		}
+	}
+	jobs.Start()
+	for _, job := range jobs.Jobs() {
+		log.Printf("Scheduled job %s on %q", job.Name, job.Schedule)
+	}
+}
+
+// RegisterRoutesJobs registers the routes masters use to watch and start the background jobs
+func RegisterRoutesJobs(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
+
+	r.GET("/jobs", h.GetJobs)                // Get the background jobs and their schedules
+	r.GET("/jobs/:name/runs", h.GetJobRuns)  // Get the run history of a job
+	r.POST("/jobs/:name/run", h.RunJob)      // Run a job now
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+)
+
+// RegisterRoutesLocation registers the routes managing schools, rooms, cabinets and shelves
+func RegisterRoutesLocation(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
+
+	r.POST("/locations", h.CreateLocation)       // Create a location
+	r.GET("/locations", h.GetLocations)          // Get the locations of a school
+	r.GET("/locations/:id", h.GetLocation)       // Get a location with its path and children
+	r.PUT("/locations/:id", h.UpdateLocation)    // Rename a location or change its capacity or attributes
+	r.DELETE("/locations/:id", h.DeleteLocation) // Delete an empty location
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+)
+
+// RegisterRoutesTransfer registers the routes moving chemicals between schools
+func RegisterRoutesTransfer(router *gin.Engine, h *controllers.Handler) {
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
+
+	r.POST("/transfers", h.ProposeTransfer)           // Offer a chemical to another school
+	r.GET("/transfers", h.GetTransfers)               // Get the transfers sent or received by a school
+	r.GET("/transfers/:id", h.GetTransfer)            // Get a specific transfer by ID
+	r.POST("/transfers/:id/accept", h.AcceptTransfer) // Accept a transfer and receive the chemical
+	r.POST("/transfers/:id/reject", h.RejectTransfer) // Turn down a transfer
+	r.POST("/transfers/:id/cancel", h.CancelTransfer) // Withdraw a transfer offer
+}
@@ -2,54 +2,111 @@ package routes
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
+	"os"
+	"path/filepath"
 
 	"cloud.google.com/go/firestore"
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/config"
 	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
-	"github.com/ekjyotshinh/ChemTrack/backend/services"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
 	"github.com/gin-gonic/gin"
 	"google.golang.org/api/option"
 )
 
 var client *firestore.Client
+var tokens *auth.TokenManager
 
-// InitFirestore initializes the Firestore client and sets it in the controllers
-func InitFirestore() {
+// InitDatabase connects to the configured database and returns the repositories backed by it
+func InitDatabase(cfg config.Config) repository.Repositories {
 	ctx := context.Background()
-	sa := option.WithCredentialsFile("/tmp/key.json")
 
-	var err error
-	client, err = firestore.NewClient(ctx, "chemtrack-encina", sa)
-	if err != nil {
-		log.Fatalf("Failed to create Firestore client: %v", err)
+	switch cfg.DatabaseBackend {
+	case "firestore":
+		sa := option.WithCredentialsFile(cfg.FirestoreCredentialsFile)
+
+		var err error
+		client, err = firestore.NewClient(ctx, cfg.FirestoreProject, sa)
+		if err != nil {
+			log.Fatalf("Failed to create Firestore client: %v", err)
+		}
+		log.Printf("Using Firestore project %s", cfg.FirestoreProject)
+		return repository.NewFirestore(client)
+	case "sqlite", "postgres":
+		dialect := repository.Dialect(cfg.DatabaseBackend)
+		if dialect == repository.SQLite {
+			if err := os.MkdirAll(filepath.Dir(cfg.DatabaseURL), 0o755); err != nil {
+				log.Fatalf("Failed to create database directory: %v", err)
+			}
+		}
+		db, err := repository.OpenSQL(ctx, dialect, cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("Failed to open %s database: %v", cfg.DatabaseBackend, err)
+		}
+		log.Printf("Using %s database", cfg.DatabaseBackend)
+		return repository.NewSQL(db, dialect)
 	}
 
-	// Pass the initialized Firestore client to the controllers package
-	controllers.SetClient(client)
-	// Pass the initialized Firestore client to the services package
-	services.SetClient(client)
+	log.Fatalf("Unknown DATABASE_BACKEND %q, expected \"firestore\", \"sqlite\" or \"postgres\"", cfg.DatabaseBackend)
+	return repository.Repositories{}
 }
 
-// RegisterRoutes defines and registers all routes
-func RegisterRoutesUser(router *gin.Engine) {
-	r := router.Group("/api/v1")
+// InitAuth creates the token manager used to sign access tokens and to protect the routes
+func InitAuth(cfg config.Config) *auth.TokenManager {
+	secret := cfg.JWTSecret
+	if secret == "" {
+		// A generated secret ends every session on restart and is not shared between replicas
+		if !cfg.JWTEphemeral {
+			log.Fatalf("JWT_SECRET is required, or JWT_EPHEMERAL_SECRET=true to sign with a temporary secret in development")
+		}
+		log.Println("JWT_EPHEMERAL_SECRET is set, signing tokens with a temporary secret")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+	tokens = auth.NewTokenManager(secret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	return tokens
+}
 
-	// User routes
-	r.POST("/users", controllers.AddUser)               // Create a new user
-	r.GET("/users", controllers.GetUsers)               // Get all users
-	r.GET("/users/:id", controllers.GetUser)            // Get a specific user by ID
-	r.GET("/users/schools", controllers.GetUserSchools) // Get list of schools
-	r.PUT("/users/:id", controllers.UpdateUser)         // Update a user by ID
-	r.DELETE("/users/:id", controllers.DeleteUser)      // Delete a user by ID
+// RegisterRoutes defines and registers all routes
+func RegisterRoutesUser(router *gin.Engine, h *controllers.Handler) {
+	// Public routes, used before the caller has a token
+	public := router.Group("/api/v1", middleware.OptionalAuth(tokens))
+	public.POST("/users", h.AddUser)               // Create a new user (sign up)
+	public.GET("/users/schools", h.GetUserSchools) // Get list of schools
 
 	// Authentication route
-	r.POST("/login", controllers.Login) // User login
-	
+	public.POST("/login", h.Login)               // User login
+	public.POST("/auth/refresh", h.RefreshToken) // Rotate a refresh token
+
 	// Password reset routes
-	r.POST("/auth/forgot-password", controllers.ForgotPassword) // Request password reset
-	r.POST("/auth/reset-password", controllers.ResetPassword)   // Reset password with token
-	
+	public.POST("/auth/forgot-password", h.ForgotPassword) // Request password reset
+	public.POST("/auth/reset-password", h.ResetPassword)   // Reset password with token
+
 	// Verify reset token route
-	r.POST("/auth/verify-token", controllers.VerifyResetToken) // Verify reset token
+	public.POST("/auth/verify-token", h.VerifyResetToken) // Verify reset token
+
+	r := router.Group("/api/v1", middleware.RequireAuth(tokens))
+
+	// User routes
+	r.GET("/users", h.GetUsers)          // Get all users
+	r.GET("/users/:id", h.GetUser)       // Get a specific user by ID
+	r.PUT("/users/:id", h.UpdateUser)    // Update a user by ID
+	r.DELETE("/users/:id", h.DeleteUser) // Delete a user by ID
+
+	// Trash routes
+	r.GET("/trash/users", h.GetTrashedUsers)    // Get the deleted users of a school
+	r.POST("/users/:id/restore", h.RestoreUser) // Restore a deleted user
+	r.DELETE("/users/:id/purge", h.PurgeUser)   // Remove a deleted user for good
+
+	// Session routes
+	r.POST("/auth/logout", h.Logout)        // Revoke a refresh token
+	r.POST("/auth/logout-all", h.LogoutAll) // Revoke every session of the caller
 }
 
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned for a schedule that is not a valid cron expression
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// descriptors are the shorthands accepted in place of the five cron fields
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes one of the five fields of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // Sunday is 0 or 7
+}
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month and day of week
+type Schedule struct {
+	spec    string
+	fields  [5]uint64 // bit n is set when value n matches
+	anyDay  bool      // the day of month field is *
+	anyWeek bool      // the day of week field is *
+}
+
+// ParseSchedule parses a standard five-field cron expression such as "0 7 * * 1-5", or one of
+// @hourly, @daily, @weekly, @monthly and @yearly. Fields take *, numbers, ranges, lists and
+// steps such as */15 or 1-5/2.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	expression := spec
+	if full, ok := descriptors[strings.ToLower(spec)]; ok {
+		expression = full
+	}
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return Schedule{}, fmt.Errorf("%w %q: expected 5 fields", ErrInvalidSchedule, spec)
+	}
+	s := Schedule{spec: spec, anyDay: parts[2] == "*", anyWeek: parts[4] == "*"}
+	for i, part := range parts {
+		bits, err := parseField(part, cronFields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%w %q: %v", ErrInvalidSchedule, spec, err)
+		}
+		s.fields[i] = bits
+	}
+	// Sunday may be written as 7
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step %q in the %s field", stepPart, f.name)
+			}
+			step = n
+		}
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("bad value %q in the %s field", from, f.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("bad value %q in the %s field", to, f.name)
+				}
+			} else if hasStep {
+				// 5/15 means every 15 starting at 5
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, fmt.Errorf("%q is out of range for the %s field, %d to %d", rangePart, f.name, f.min, f.max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.spec
+}
+
+func (s Schedule) matches(field int, value int) bool {
+	return s.fields[field]&(1<<uint(value)) != 0
+}
+
+// matchesDay follows cron in matching either day field when both are restricted
+func (s Schedule) matchesDay(t time.Time) bool {
+	day, weekday := s.matches(2, t.Day()), s.matches(4, int(t.Weekday()))
+	switch {
+	case s.anyDay && s.anyWeek:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeek:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first time after t the schedule comes due, in t's location, or the zero
+// time when it never does, as for February 30th
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every match recurs within a few years; leap days within eight
+	limit := t.AddDate(8, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.matches(3, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.matches(1, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.matches(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
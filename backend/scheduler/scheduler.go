@@ -0,0 +1,303 @@
+// Package scheduler runs the API's background jobs on cron schedules. Runs are claimed through
+// a shared repository, so a job due at the same time on several replicas runs on only one, and
+// every run is kept with its status and duration.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+var (
+	// ErrUnknownJob is returned when triggering a job that was never added
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned when triggering a job that is already running, here or on another replica
+	ErrJobRunning = errors.New("the job is already running")
+	// ErrStopped is returned when triggering a job after the scheduler was stopped
+	ErrStopped = errors.New("the scheduler has stopped")
+)
+
+// Job is a background task
+type Job struct {
+	Name     string
+	Schedule string // cron expression; empty or "off" for a job that only runs when triggered
+	Run      func(ctx context.Context) error
+}
+
+// JobInfo describes a job and when it next runs
+type JobInfo struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	Running  bool       `json:"running"` // on this replica
+}
+
+// Options configure a scheduler
+type Options struct {
+	Instance string         // name of this replica in the run history
+	Lease    time.Duration  // how long a run holds its job's lock before other replicas may take it over
+	Location *time.Location // time zone schedules are read in, UTC when nil
+}
+
+type entry struct {
+	job      Job
+	schedule *Schedule
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs jobs on their schedules until it is stopped
+type Scheduler struct {
+	repo    repository.JobRepository
+	options Options
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	started bool
+	stopped bool
+
+	loops  sync.WaitGroup  // one per scheduled job
+	runs   sync.WaitGroup  // one per run in progress
+	stop   chan struct{}   // closed to stop the schedule loops
+	ctx    context.Context // passed to runs, cancelled when a stop runs out of time
+	cancel context.CancelFunc
+}
+
+// New returns a scheduler claiming and recording runs in the repository
+func New(repo repository.JobRepository, options Options) *Scheduler {
+	if options.Lease <= 0 {
+		options.Lease = time.Hour
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{repo: repo, options: options, entries: map[string]*entry{}, stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+// Add registers a job. Jobs added after Start are scheduled at once.
+func (s *Scheduler) Add(job Job) error {
+	e := &entry{job: job}
+	if job.Schedule != "" && job.Schedule != "off" {
+		schedule, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		e.schedule = &schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already added", job.Name)
+	}
+	s.entries[job.Name] = e
+	if s.started && !s.stopped {
+		s.schedule(e)
+	}
+	return nil
+}
+
+// Start begins running the jobs on their schedules
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, e := range s.entries {
+		s.schedule(e)
+	}
+}
+
+// schedule starts the loop running a job each time its schedule comes due. s.mu must be held.
+func (s *Scheduler) schedule(e *entry) {
+	if e.schedule == nil {
+		return
+	}
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		for {
+			now := time.Now().In(s.options.Location)
+			next := e.schedule.Next(now)
+			if next.IsZero() {
+				log.Printf("Job %s never comes due on schedule %q", e.job.Name, e.schedule)
+				return
+			}
+			s.mu.Lock()
+			e.next = next
+			s.mu.Unlock()
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			// Runs are kept in this loop, so a run outlasting its interval skips the runs it overlaps
+			if _, done, err := s.begin(e, next, models.JobScheduled, ""); err == nil {
+				<-done
+			} else if !errors.Is(err, ErrJobRunning) && !errors.Is(err, ErrStopped) {
+				log.Printf("Failed to start job %s: %v", e.job.Name, err)
+			}
+		}
+	}()
+}
+
+// Trigger runs a job now, outside its schedule, and returns the run as it starts. The run
+// carries on in the background and its outcome is kept in the history.
+func (s *Scheduler) Trigger(ctx context.Context, name, by string) (models.JobRun, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return models.JobRun{}, ErrUnknownJob
+	}
+	run, _, err := s.begin(e, time.Now(), models.JobManual, by)
+	return run, err
+}
+
+// begin claims a run of the job due at slot and starts it, returning the run and a channel
+// closed once it has finished
+func (s *Scheduler) begin(e *entry, slot time.Time, trigger models.JobTrigger, by string) (models.JobRun, <-chan struct{}, error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return models.JobRun{}, nil, ErrStopped
+	}
+	if e.running {
+		s.mu.Unlock()
+		return models.JobRun{}, nil, ErrJobRunning
+	}
+	e.running = true
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	finish := func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+		s.runs.Done()
+	}
+
+	ctx := context.Background()
+	claimed, err := s.repo.Claim(ctx, e.job.Name, s.options.Instance, slot, s.options.Lease)
+	if err == nil && !claimed {
+		err = ErrJobRunning
+	}
+	if err != nil {
+		finish()
+		return models.JobRun{}, nil, err
+	}
+
+	run := models.JobRun{Job: e.job.Name, Trigger: trigger, TriggeredBy: by, Instance: s.options.Instance,
+		ScheduledAt: slot, StartedAt: time.Now(), Status: models.JobRunning}
+	if err := s.repo.CreateRun(ctx, &run); err != nil {
+		// The job still runs; only its history is missing
+		log.Printf("Failed to record the start of job %s: %v", e.job.Name, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer finish()
+		log.Printf("Running job %s (%s)", e.job.Name, trigger)
+		err := s.execute(e.job)
+		final := run
+		final.Finish(time.Now(), err)
+		if err != nil {
+			log.Printf("Job %s failed after %dms: %v", e.job.Name, final.DurationMS, err)
+		} else {
+			log.Printf("Job %s finished in %dms", e.job.Name, final.DurationMS)
+		}
+		if final.ID != "" {
+			if err := s.repo.UpdateRun(ctx, final); err != nil {
+				log.Printf("Failed to record the end of job %s: %v", e.job.Name, err)
+			}
+		}
+		if err := s.repo.Release(ctx, e.job.Name, s.options.Instance); err != nil {
+			log.Printf("Failed to unlock job %s: %v", e.job.Name, err)
+		}
+	}()
+	return run, done, nil
+}
+
+// execute runs a job, turning a panic into a failed run
+func (s *Scheduler) execute(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(s.ctx)
+}
+
+// Jobs returns the jobs by name
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]JobInfo, 0, len(s.entries))
+	for _, e := range s.entries {
+		info := JobInfo{Name: e.job.Name, Running: e.running}
+		if e.schedule != nil {
+			info.Schedule = e.schedule.String()
+			if !e.next.IsZero() && !s.stopped {
+				next := e.next
+				info.NextRun = &next
+			}
+		}
+		jobs = append(jobs, info)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Has reports whether a job was added
+func (s *Scheduler) Has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[name]
+	return ok
+}
+
+// Runs returns the run history, newest first
+func (s *Scheduler) Runs(ctx context.Context, filter repository.JobRunFilter) ([]models.JobRun, error) {
+	return s.repo.ListRuns(ctx, filter)
+}
+
+// Stop stops scheduling runs and waits for those in progress to finish. When ctx is done first,
+// the runs are cancelled and Stop returns ctx's error without waiting further.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.loops.Wait()
+		s.runs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
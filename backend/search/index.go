@@ -0,0 +1,322 @@
+// Package search finds chemicals by name, synonym, CAS number, location and notes, tolerating
+// typos and partly typed words and ranking the best matches first.
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// Fields a query can match, reported with every hit
+const (
+	FieldName     = "name"
+	FieldSynonyms = "synonyms"
+	FieldCAS      = "CAS"
+	FieldLocation = "location"
+	FieldNotes    = "notes"
+)
+
+// weights rank a match in the name or CAS number above one in the synonyms, and those above
+// matches in the location or notes
+var weights = map[string]float64{
+	FieldName:     3,
+	FieldSynonyms: 2,
+	FieldCAS:      3,
+	FieldLocation: 1,
+	FieldNotes:    1,
+}
+
+// How well a query word matches a word of a chemical
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.8 // the query word starts the chemical's word, as while typing
+	typoMatch   = 0.6 // one or two letters off, less for each one
+)
+
+// Hit is a chemical matching a query
+type Hit struct {
+	ID     string   `json:"id"`
+	Score  float64  `json:"score"`  // higher is better
+	Fields []string `json:"fields"` // the fields the query matched in
+}
+
+// Query is a search of the index. Every word of the text has to match somewhere in a chemical.
+type Query struct {
+	Text   string
+	School string // only chemicals of this school, empty for every school
+	Limit  int    // the most hits to return, zero for all
+}
+
+// document is what the index keeps of a chemical
+type document struct {
+	school string
+	name   string // lower-cased, to break ties between equal scores
+	cas    string // digits of the CAS number, to match partly typed or differently formatted numbers
+	terms  map[string]string
+}
+
+// Index is an index of the chemicals one search covers, built from them as they are stored so
+// that every replica of the API finds the same chemicals. It does not change once built.
+type Index struct {
+	docs     map[string]*document
+	postings map[string]map[string]string // word to the chemicals holding it, with its best field
+}
+
+// Build indexes the given chemicals
+func Build(chemicals []models.Chemical) *Index {
+	index := &Index{docs: map[string]*document{}, postings: map[string]map[string]string{}}
+	for _, c := range chemicals {
+		index.put(c)
+	}
+	return index
+}
+
+// put adds a chemical to the index
+func (x *Index) put(c models.Chemical) {
+	doc := &document{school: c.School, name: strings.ToLower(c.Name), cas: digits(c.CAS), terms: map[string]string{}}
+	add := func(field, text string) {
+		for _, term := range tokenize(text) {
+			if current, ok := doc.terms[term]; !ok || weights[field] > weights[current] {
+				doc.terms[term] = field
+			}
+		}
+	}
+	add(FieldName, c.Name)
+	add(FieldSynonyms, strings.Join(c.Synonyms, " "))
+	add(FieldCAS, c.CAS)
+	location := c.Room
+	if c.Cabinet != 0 {
+		location += fmt.Sprintf(" cabinet %d", c.Cabinet)
+	}
+	if c.Shelf != 0 {
+		location += fmt.Sprintf(" shelf %d", c.Shelf)
+	}
+	add(FieldLocation, location)
+	add(FieldNotes, c.Notes)
+
+	x.docs[c.ID] = doc
+	for term, field := range doc.terms {
+		if x.postings[term] == nil {
+			x.postings[term] = map[string]string{}
+		}
+		x.postings[term][c.ID] = field
+	}
+}
+
+// Search returns the chemicals matching every word of the query, best first
+func (x *Index) Search(q Query) []Hit {
+	words := tokenize(q.Text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	type match struct {
+		score  float64
+		fields map[string]bool
+	}
+	var matches map[string]*match
+	for i, word := range words {
+		scores := x.matchWord(word, q.School)
+		next := map[string]*match{}
+		for id, s := range scores {
+			m := &match{fields: map[string]bool{}}
+			if i > 0 {
+				// Chemicals have to match every word
+				if matches[id] == nil {
+					continue
+				}
+				m = matches[id]
+			}
+			m.score += s.score
+			m.fields[s.field] = true
+			next[id] = m
+		}
+		matches = next
+	}
+
+	hits := make([]Hit, 0, len(matches))
+	for id, m := range matches {
+		hit := Hit{ID: id, Score: m.score}
+		for field := range m.fields {
+			hit.Fields = append(hit.Fields, field)
+		}
+		sort.Strings(hit.Fields)
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if x.docs[a.ID].name != x.docs[b.ID].name {
+			return x.docs[a.ID].name < x.docs[b.ID].name
+		}
+		return a.ID < b.ID
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits
+}
+
+type wordScore struct {
+	score float64
+	field string
+}
+
+// matchWord scores the chemicals of a school against one word of a query, keeping each
+// chemical's best match
+func (x *Index) matchWord(word, school string) map[string]wordScore {
+	scores := map[string]wordScore{}
+	keep := func(id, field string, quality float64) {
+		if school != "" && x.docs[id].school != school {
+			return
+		}
+		score := weights[field] * quality
+		if score > scores[id].score {
+			scores[id] = wordScore{score: score, field: field}
+		}
+	}
+
+	// A number is matched against the CAS numbers whatever their hyphens, so 64175, 64-17-5
+	// and the start of either find ethanol
+	if number := digits(word); len(number) >= 2 && len(number) == len(strings.ReplaceAll(word, "-", "")) {
+		for id, doc := range x.docs {
+			switch {
+			case doc.cas == "":
+			case doc.cas == number:
+				keep(id, FieldCAS, exactMatch)
+			case strings.HasPrefix(doc.cas, number):
+				keep(id, FieldCAS, prefixMatch)
+			case len(number) >= 3 && strings.Contains(doc.cas, number):
+				keep(id, FieldCAS, typoMatch)
+			}
+		}
+	}
+
+	for term, holders := range x.postings {
+		quality := wordQuality(word, term)
+		if quality == 0 {
+			continue
+		}
+		for id, field := range holders {
+			keep(id, field, quality)
+		}
+	}
+	return scores
+}
+
+// wordQuality rates how well a query word matches a word of a chemical, zero for no match
+func wordQuality(word, term string) float64 {
+	if word == term {
+		return exactMatch
+	}
+	if len(word) >= 2 && strings.HasPrefix(term, word) {
+		return prefixMatch
+	}
+	allowed := maxTypos(word)
+	if allowed == 0 {
+		return 0
+	}
+	w, t := []rune(word), []rune(term)
+	best := allowed + 1
+	if abs(len(w)-len(t)) <= allowed {
+		best = distance(w, t)
+	}
+	// A typo in a partly typed word, such as acetn for acetone
+	if len(t) > len(w) {
+		if d := distance(w, t[:len(w)]); d < best {
+			best = d
+		}
+	}
+	if best > allowed {
+		return 0
+	}
+	return typoMatch - 0.15*float64(best-1)
+}
+
+// maxTypos is how many letters a word can be off by: none for short words, where a typo is as
+// likely to be another word, or for numbers, where one digit off is another number
+func maxTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4 || strings.ContainsAny(word, "0123456789"):
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance is the number of letters inserted, removed, changed or swapped with a neighbour to
+// turn one word into the other
+func distance(s, t []rune) int {
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
+
+// tokenize splits text into lower-case words of letters and digits. Hyphens between digits are
+// kept, so a CAS number stays one word.
+func tokenize(text string) []string {
+	runes := []rune(strings.ToLower(text))
+	var words []string
+	var word []rune
+	for i, r := range runes {
+		keep := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if r == '-' && i > 0 && i+1 < len(runes) && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+			keep = true
+		}
+		if keep {
+			word = append(word, r)
+			continue
+		}
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// digits returns the digits of s
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -4,188 +4,194 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
-	"cloud.google.com/go/firestore"
-	"google.golang.org/api/iterator"
+	"github.com/ekjyotshinh/ChemTrack/backend/alerts"
 	"github.com/ekjyotshinh/ChemTrack/backend/helpers"
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
 )
 
-// Global Firestore client variable
-var client *firestore.Client
+// ChemicalMonitor reports the alerts raised by each school's alert rules to its admins. Alerts are
+// stored, so a condition is only sent when it is new or has escalated.
+type ChemicalMonitor struct {
+	chemicals repository.ChemicalRepository
+	users     repository.UserRepository
+	rules     repository.AlertRuleRepository
+	alerts    repository.AlertRepository
+	templates *notify.Renderer
+	mailer    mailer.Mailer
+}
 
-// SetClient initializes Firestore client
-func SetClient(c *firestore.Client) {
-	client = c
+// NewChemicalMonitor creates a monitor reading from the given repositories, rendering its reports
+// with templates and emailing them through mail
+func NewChemicalMonitor(repos repository.Repositories, templates *notify.Renderer, mail mailer.Mailer) *ChemicalMonitor {
+	return &ChemicalMonitor{chemicals: repos.Chemicals, users: repos.Users, rules: repos.AlertRules, alerts: repos.Alerts, templates: templates, mailer: mail}
 }
 
-// CheckCriticalChemicalStatus checks for chemicals with low stock or near expiration
-func CheckCriticalChemicalStatus() {
-	ctx := context.Background()
+// CheckCriticalChemicalStatus evaluates the alert rules of each school against its chemicals, stores
+// the alerts raised, resolves those whose condition cleared, and sends the report of each school's
+// new and escalated alerts to its admins and the masters
+func (m *ChemicalMonitor) CheckCriticalChemicalStatus(ctx context.Context) error {
+	now := time.Now()
 
-	if client == nil {
-		log.Println("Firestore client is not initialized")
-		return
+	notices, err := alerts.Refresh(ctx, m.chemicals, m.rules, m.alerts, "", now)
+	if err != nil {
+		return err
 	}
 
-	now := time.Now()
-	sixMonthsLater := now.AddDate(0, 6, 0)
+	failed := 0
+	for school, found := range notices {
+		sent, failures, err := m.send(ctx, school, notify.AlertsData{School: school, Alerts: found, Now: now})
+		if err != nil {
+			return err
+		}
+		failed += failures
+		// Alerts nobody could be sent stay unnotified, to be tried again on the next run
+		if !sent {
+			continue
+		}
+		for _, alert := range found {
+			alert.MarkNotified(now)
+			if err := m.alerts.Update(ctx, alert); err != nil {
+				return fmt.Errorf("recording the notice of alert %s: %w", alert.ID, err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d alert notifications could not be sent", failed)
+	}
+	return nil
+}
 
-	iter := client.Collection("chemicals").Documents(ctx)
+// SendAlertDigest sends the admins of each school with active alerts, or alerts resolved in the
+// past week, a summary of them
+func (m *ChemicalMonitor) SendAlertDigest(ctx context.Context) error {
+	until := time.Now()
+	since := until.AddDate(0, 0, -7)
 
-	schoolMap := make(map[string][]map[string]interface{})
+	stored, err := m.alerts.List(ctx, repository.AlertFilter{})
+	if err != nil {
+		return fmt.Errorf("fetching alerts: %w", err)
+	}
+	digests := map[string]*notify.DigestData{}
+	for _, alert := range stored {
+		active := alert.Active()
+		if !active && (alert.ResolvedAt == nil || alert.ResolvedAt.Before(since)) {
+			continue
+		}
+		digest, ok := digests[alert.School]
+		if !ok {
+			digest = &notify.DigestData{School: alert.School, Since: since, Until: until}
+			digests[alert.School] = digest
+		}
+		if active {
+			digest.Alerts = append(digest.Alerts, alert)
+		} else {
+			digest.Resolved++
+		}
+	}
 
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
+	failed := 0
+	for school, digest := range digests {
+		// Stop between schools when the scheduler is shutting down
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		sort.SliceStable(digest.Alerts, func(i, j int) bool {
+			return digest.Alerts[i].Severity.Rank() > digest.Alerts[j].Severity.Rank()
+		})
+		_, failures, err := m.send(ctx, school, *digest)
 		if err != nil {
-			log.Printf("Error fetching chemicals: %v", err)
-			continue
+			return err
 		}
+		failed += failures
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d alert digests could not be sent", failed)
+	}
+	return nil
+}
 
-		data := doc.Data()
-
-		CAS, ok1 := data["CAS"]
-		school, ok2 := data["school"].(string)
-		expField, ok3 := data["expiration_date"]
-		status, ok4 := data["status"].(string)
+// send renders a message about a school and sends it to the school's admins and the masters by
+// email and push notification, as each of them allows, in the language each of them reads. It
+// reports whether anyone got it, or there was nobody to send it to, and how many sends failed.
+func (m *ChemicalMonitor) send(ctx context.Context, school string, data notify.Data) (bool, int, error) {
+	recipients, err := m.GetAdminMasterRecipients(ctx, school)
+	if err != nil {
+		return false, 0, fmt.Errorf("fetching admin and master emails: %w", err)
+	}
 
-		if !ok1 || !ok2 || !ok3 || !ok4 {
-			log.Printf("Skipping invalid document: %v", data)
-			continue
+	notice := m.templates.Localize(data)
+	sent, failed := 0, 0
+	for locale, to := range recipients {
+		msg, err := notice.In(locale)
+		if err != nil {
+			return false, 0, fmt.Errorf("rendering the %s of %s: %w", data.Template(), school, err)
 		}
-
-		var expirationDate time.Time
-		switch v := expField.(type) {
-		case time.Time:
-			expirationDate = v
-		case string:
-			var err error
-			expirationDate, err = time.Parse("2006-01-02", v)
-			if err != nil {
+		for _, email := range to.Emails {
+			if err := m.mailer.Send(ctx, mailer.Message{To: email, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text}); err != nil {
+				log.Printf("Failed to send the %s of %s to %s: %v", data.Template(), school, email, err)
+				failed++
 				continue
 			}
-		default:
-			continue
+			sent++
 		}
-
-		chemicalData := map[string]interface{}{
-			"CAS":             CAS,
-			"expiration_date": expirationDate,
-			"status":        status,
+		for _, token := range to.ExpoTokens {
+			if err := helpers.SendPushNotification(token, msg.PushTitle, msg.PushBody); err != nil {
+				log.Printf("Failed to push the %s of %s: %v", data.Template(), school, err)
+				failed++
+				continue
+			}
+			sent++
 		}
-		schoolMap[school] = append(schoolMap[school], chemicalData)
 	}
+	return sent > 0 || failed == 0, failed, nil
+}
 
-	for school, chemicals := range schoolMap {
-		var alertMessage string
+// Recipients are the emails and Expo push tokens a notice in one language goes to
+type Recipients struct {
+	Emails     []string
+	ExpoTokens []string
+}
 
-		for _, chemical := range chemicals {
-			expirationDate := chemical["expiration_date"].(time.Time)
+// GetAdminMasterRecipients fetches the admin and master emails along with their Expo push tokens,
+// by the language each of them gets notifications in
+func (m *ChemicalMonitor) GetAdminMasterRecipients(ctx context.Context, school string) (map[string]*Recipients, error) {
+	users, err := m.users.List(ctx, repository.UserFilter{})
+	if err != nil {
+		return nil, err
+	}
 
-			if chemical["status"] == "Low" {
-				alertMessage += fmt.Sprintf(
-					"🔴 LOW STOCK ALERT\n<br>"+
-						"- CAS Number: %v\n<br>"+
-						"- School: %s\n<br>"+
-						"- Status: Low Stock\n\n<br><br>",
-					chemical["CAS"], school,
-				)
-			}
-			if expirationDate.Before(now) {
-				alertMessage += fmt.Sprintf(
-					"⚠️ EXPIRED CHEMICAL ALERT\n<br>"+
-						"- CAS Number: %v\n<br>"+
-						"- School: %s\n<br>"+
-						"- Expiration Date: %s\n<br>"+
-						"- Status: Expired \n\n<br><br>",
-					chemical["CAS"], school, expirationDate.Format("2006-01-02"),
-				)
-			}
-			if expirationDate.Before(sixMonthsLater) {
-				alertMessage += fmt.Sprintf(
-					"🟡 EXPIRATION WARNING\n<br>"+
-						"- CAS Number: %v\n<br>"+
-						"- School: %s\n<br>"+
-						"- Expiration Date: %s\n<br>"+
-						"- Status: Will Expire Soon \n\n<br><br>",
-					chemical["CAS"], school, expirationDate.Format("2006-01-02"),
-				)
-			}
+	recipients := make(map[string]*Recipients)
+	seen := make(map[string]bool)
+	for _, user := range users {
+		// Users who are either admins for the school or masters, who get the notices of every school
+		if !isRecipient(user, school) {
+			continue
 		}
-
-		if alertMessage != "" {
-			emails, expoTokens, err := GetAdminMasterEmailsAndTokens(school)
-			if err != nil {
-			    fmt.Println("Error fetching admin and master emails:", err)
-			    return
-			}
-    		if len(emails) > 0 {
-    		    for _, email := range emails {
-    		        subject := fmt.Sprintf("Chemical Alert Report for %s", school)
-    		        helpers.SendEmailHelper(email, subject, alertMessage)
-    		    }
-    		}
-		
-    		if len(expoTokens) > 0 {
-    		    subject := fmt.Sprintf("Chemical Alert Report for %s", school)
-    		    for _, token := range expoTokens {
-    		        helpers.SendPushNotification(token, subject, alertMessage)
-    		    }
-    		}
+		locale := m.templates.Locale(user.Locale)
+		to, ok := recipients[locale]
+		if !ok {
+			to = &Recipients{}
+			recipients[locale] = to
+		}
+		if user.Email != "" && user.AllowEmail && !seen["email:"+user.Email] {
+			seen["email:"+user.Email] = true
+			to.Emails = append(to.Emails, user.Email)
+		}
+		if user.ExpoPushToken != "" && user.AllowPush && !seen["push:"+user.ExpoPushToken] {
+			seen["push:"+user.ExpoPushToken] = true
+			to.ExpoTokens = append(to.ExpoTokens, user.ExpoPushToken)
 		}
 	}
+	return recipients, nil
 }
 
-// Fetch admin and master emails along with their Expo push tokens
-func GetAdminMasterEmailsAndTokens(school string) ([]string, []string, error) {
-    ctx := context.Background()
-    emailSet := make(map[string]struct{})
-    expoTokenSet := make(map[string]struct{}) 
-
-    // Query for users who are either admins for the school or masters user(in that case we just send out the email irrespective of the school)
-    queries := []firestore.Query{
-        client.Collection("users").Where("is_admin", "==", true).Where("school", "==", school),
-        client.Collection("users").Where("is_master", "==", true),
-    }
-
-    for _, query := range queries {
-        iter := query.Documents(ctx)
-        for {
-            doc, err := iter.Next()
-            if err == iterator.Done {
-                break
-            }
-            if err != nil {
-                return nil, nil, err
-            }
-            user := doc.Data()
-            if email, ok := user["email"].(string); ok {
-                if allowEmail, exists := user["allow_email"].(bool); exists && allowEmail {
-                    emailSet[email] = struct{}{}
-                }
-            }
-            if expoToken, ok := user["expo_push_token"].(string); ok {
-				if allowPush, exists := user["allow_push"].(bool); exists && allowPush {
-                    expoTokenSet[expoToken] = struct{}{}
-                }
-                
-            }
-        }
-    }
-
-    // Convert map keys to a array
-    emails := make([]string, 0, len(emailSet))
-    for email := range emailSet {
-        emails = append(emails, email)
-    }
-
-    expoTokens := make([]string, 0, len(expoTokenSet))
-    for token := range expoTokenSet {
-        expoTokens = append(expoTokens, token)
-    }
-
-    return emails, expoTokens, nil
-}
\ No newline at end of file
+// isRecipient reports whether the user receives the alert report of a school
+func isRecipient(user models.User, school string) bool {
+	return user.IsMaster || (user.IsAdmin && user.School == school)
+}
@@ -0,0 +1,453 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/alerts"
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+)
+
+var alertAdmin = auth.Principal{UserID: "alert-admin", School: "Alert School", Role: auth.RoleAdmin}
+
+// quantity parses a quantity such as "500 mL" for a test chemical
+func quantity(t *testing.T, s string) models.Quantity {
+	t.Helper()
+	q, err := models.ParseQuantity(s)
+	if err != nil {
+		t.Fatalf("Bad test quantity %q: %v", s, err)
+	}
+	return q
+}
+
+// alertChemicals is a school's inventory raising one alert of each kind, relative to today
+func alertChemicals(t *testing.T, school string, today time.Time) []models.Chemical {
+	day := func(days int) models.Date { return models.NewDate(today.AddDate(0, 0, days)) }
+	sds := "https://example.com/sds.pdf"
+	return []models.Chemical{
+		{ID: school + "-expired", Name: "Ether", School: school, ExpirationDate: day(-3), SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-expiring", Name: "Acetone", School: school, ExpirationDate: day(20), SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-fresh", Name: "Glycerol", School: school, ExpirationDate: day(400), SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-low", Name: "Ethanol", School: school, Remaining: quantity(t, "50 mL"), ReorderThreshold: quantity(t, "100 mL"),
+			ContainerSize: quantity(t, "1 L"), SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-nearly-empty", Name: "Methanol", School: school, Remaining: quantity(t, "0.08 L"), ContainerSize: quantity(t, "1 L"),
+			SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-empty", Name: "Hexane", School: school, Remaining: quantity(t, "0 mL"), ReorderThreshold: quantity(t, "100 mL"),
+			SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-no-sds", Name: "Toluene", School: school},
+		{ID: school + "-old-sds", Name: "Xylene", School: school, SDSURL: sds, SDSDate: day(-6 * 365)},
+		{ID: school + "-acid", Name: "Acetic acid", School: school, Room: "Lab", Cabinet: 1, Shelf: 1, StorageGroups: []string{"acid"},
+			SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-base", Name: "Sodium hydroxide", School: school, Room: "lab", Cabinet: 1, Shelf: 1, StorageGroups: []string{"base"},
+			SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-solvent", Name: "Heptane", School: school, Room: "Lab", Cabinet: 1, Shelf: 1, StorageGroups: []string{"flammable"},
+			SDSURL: sds, SDSDate: day(-100)},
+		{ID: school + "-flagged", Name: "Old picric acid", School: school, ExpirationDate: day(-900),
+			Disposal: &models.Disposal{State: models.DisposalFlagged}},
+	}
+}
+
+// alertsByChemical indexes alerts by the ID of their chemical and their kind, followed by the ID
+// of the other chemical for storage conflicts
+func alertsByChemical(found []models.Alert) map[string]models.Alert {
+	out := map[string]models.Alert{}
+	for _, alert := range found {
+		key := alert.ChemicalID + " " + string(alert.Kind)
+		if alert.Conflict != nil {
+			key += " " + alert.Conflict.OtherID
+		}
+		out[key] = alert
+	}
+	return out
+}
+
+// refreshAlerts brings the stored alerts of a school up to date, as the chemical monitor does
+func refreshAlerts(t *testing.T, school string) {
+	t.Helper()
+	_, err := alerts.Refresh(context.Background(), repos.Chemicals, repos.AlertRules, repos.Alerts, school, time.Now())
+	assert.NoError(t, err)
+}
+
+// Test that the rules raise typed alerts, most severe first
+func TestEvaluateAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	chemicals := alertChemicals(t, "Alert School", now)
+	chemicals = append(chemicals, models.Chemical{ID: "elsewhere", Name: "Ether", School: "Other School", ExpirationDate: models.NewDate(now)})
+
+	// The defaults only cover expiration within six months and low stock, as the monitor always did,
+	// but an expired chemical is no longer also reported as expiring
+	found := alerts.Evaluate(models.DefaultAlertRules("Alert School"), chemicals, now)
+	byChemical := alertsByChemical(found)
+	assert.Len(t, found, 4)
+	assert.Equal(t, models.SeverityCritical, byChemical["Alert School-expired expired"].Severity)
+	assert.NotContains(t, byChemical, "Alert School-expired expiring")
+	assert.Equal(t, 180, byChemical["Alert School-expiring expiring"].LeadDays)
+	assert.Equal(t, models.SeverityWarning, byChemical["Alert School-low low_stock"].Severity)
+	assert.Equal(t, models.SeverityCritical, byChemical["Alert School-empty low_stock"].Severity)
+	assert.Equal(t, models.SeverityCritical, found[0].Severity)
+
+	rules := models.AlertRules{School: "Alert School", Expired: true, ExpiringLeadDays: []int{7, 90, 30, 90}, LowStock: true,
+		LowStockPercent: 10, SDSMissing: true, SDSMaxAgeYears: 5, StorageConflicts: models.ConflictsAll}
+	assert.NoError(t, rules.Normalize())
+	assert.Equal(t, []int{90, 30, 7}, rules.ExpiringLeadDays)
+	found = alerts.Evaluate(rules, chemicals, now)
+	byChemical = alertsByChemical(found)
+	expiring := byChemical["Alert School-expiring expiring"]
+	assert.Equal(t, 30, expiring.LeadDays)
+	assert.Equal(t, "Acetone expires in 20 days, on 2026-11-07", expiring.Message)
+	assert.Equal(t, "2026-11-07", expiring.ExpirationDate.String())
+	assert.Equal(t, "0.08 L", byChemical["Alert School-nearly-empty low_stock"].Remaining.String())
+	assert.Nil(t, byChemical["Alert School-nearly-empty low_stock"].ReorderThreshold)
+	assert.Equal(t, models.SeverityInfo, byChemical["Alert School-no-sds sds_missing"].Severity)
+	assert.Contains(t, byChemical, "Alert School-old-sds sds_outdated")
+	assert.NotContains(t, byChemical, "Alert School-expired sds_outdated")
+	assert.Equal(t, models.SeverityCritical, byChemical["Alert School-acid storage_conflict Alert School-base"].Severity)
+	assert.Equal(t, models.SeverityWarning, byChemical["Alert School-acid storage_conflict Alert School-solvent"].Severity)
+	for _, alert := range found {
+		assert.Equal(t, "Alert School", alert.School)
+		assert.NotEqual(t, "Alert School-flagged", alert.ChemicalID, "chemicals flagged for disposal are already dealt with")
+		assert.NotEqual(t, "Alert School-fresh", alert.ChemicalID)
+	}
+	for i := 1; i < len(found); i++ {
+		assert.GreaterOrEqual(t, found[i-1].Severity.Rank(), found[i].Severity.Rank())
+	}
+
+	// Only the blocking acid and base pair is reported when asked for
+	rules.StorageConflicts = models.ConflictsBlocked
+	conflicts := 0
+	for _, alert := range alerts.Evaluate(rules, chemicals, now) {
+		if alert.Kind == models.AlertStorageConflict {
+			conflicts++
+			assert.Equal(t, models.StorageBlocked, alert.Conflict.Severity)
+		}
+	}
+	assert.Equal(t, 1, conflicts)
+
+	for _, bad := range []models.AlertRules{
+		{ExpiringLeadDays: []int{0}},
+		{LowStockPercent: 120},
+		{SDSMaxAgeYears: -1},
+		{StorageConflicts: "all"},
+	} {
+		assert.ErrorIs(t, bad.Normalize(), models.ErrInvalidAlertRules)
+	}
+}
+
+// Test that every backend stores the alert rules of a school, and the revision date of safety data sheets
+func TestRepositoryAlertRules(t *testing.T) {
+	ctx := context.Background()
+	updated := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := backend.AlertRules.Get(ctx, "North/South High")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+
+			rules := models.AlertRules{School: "North/South High", Expired: true, ExpiringLeadDays: []int{60, 14}, LowStockPercent: 12.5,
+				SDSMaxAgeYears: 3, StorageConflicts: models.ConflictsBlocked, UpdatedBy: "admin-1", UpdatedAt: &updated}
+			assert.NoError(t, backend.AlertRules.Put(ctx, rules))
+			stored, err := backend.AlertRules.Get(ctx, "North/South High")
+			assert.NoError(t, err)
+			assert.Equal(t, []int{60, 14}, stored.ExpiringLeadDays)
+			assert.Equal(t, 12.5, stored.LowStockPercent)
+			assert.True(t, stored.UpdatedAt.Equal(updated))
+			stored.UpdatedAt = rules.UpdatedAt
+			assert.Equal(t, rules, stored)
+
+			rules.ExpiringLeadDays, rules.SDSMissing = nil, true
+			assert.NoError(t, backend.AlertRules.Put(ctx, rules))
+			stored, _ = backend.AlertRules.Get(ctx, "North/South High")
+			assert.Empty(t, stored.ExpiringLeadDays)
+			assert.True(t, stored.SDSMissing)
+
+			assert.NoError(t, backend.AlertRules.Delete(ctx, "North/South High"))
+			assert.ErrorIs(t, backend.AlertRules.Delete(ctx, "North/South High"), repository.ErrNotFound)
+			_, err = backend.AlertRules.Get(ctx, "North/South High")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+
+			revised, _ := models.ParseDate("2021-03-15")
+			chemical := models.Chemical{Name: "Acetone", School: "North/South High", SDSURL: "https://example.com/sds.pdf", SDSDate: revised}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &chemical))
+			read, _ := backend.Chemicals.Get(ctx, chemical.ID)
+			assert.Equal(t, "2021-03-15", read.SDSDate.String())
+		})
+	}
+}
+
+// Test the routes admins use to set what they are alerted about and see the current alerts
+func TestAlertRoutes(t *testing.T) {
+	for _, chemical := range alertChemicals(t, "Alert School", time.Now()) {
+		seedChemical(t, chemical)
+	}
+	repos.AlertRules.Delete(context.Background(), "Alert School")
+
+	// Without rules of its own a school gets the defaults
+	w := sendAs(http.MethodGet, "/api/v1/alerts/rules", alertAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rules models.AlertRules
+	json.Unmarshal(w.Body.Bytes(), &rules)
+	assert.Equal(t, models.DefaultAlertRules("Alert School"), rules)
+
+	// Listing the alerts does not evaluate the rules, the monitor does
+	w = sendAs(http.MethodGet, "/api/v1/alerts", alertAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	refreshAlerts(t, "Alert School")
+	w = sendAs(http.MethodGet, "/api/v1/alerts", alertAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found []models.Alert
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Len(t, found, 4)
+
+	body := map[string]interface{}{"expired": true, "expiring_lead_days": []int{7, 30}, "sds_missing": true, "storage_conflicts": "blocked"}
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules", admin, body) // an admin of another school
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules?school=Alert+School", admin, body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules", teacher, body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules", alertAdmin, map[string]interface{}{"expiring_lead_days": []int{-5}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "invalid alert rules: expiring lead times must be from 1 to 3650 days"}`, w.Body.String())
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules", alertAdmin, map[string]interface{}{"expiring_lead_days": "soon"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "Invalid input"}`, w.Body.String())
+	w = sendAs(http.MethodPut, "/api/v1/alerts/rules", alertAdmin, body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &rules)
+	assert.Equal(t, []int{30, 7}, rules.ExpiringLeadDays)
+	assert.Equal(t, "alert-admin", rules.UpdatedBy)
+	assert.NotNil(t, rules.UpdatedAt)
+
+	// Masters see the alerts of any school, filtered by kind or severity
+	refreshAlerts(t, "Alert School")
+	w = sendAs(http.MethodGet, "/api/v1/alerts?school=Alert+School", master, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	byChemical := alertsByChemical(found)
+	assert.Len(t, found, 4)
+	assert.Equal(t, 30, byChemical["Alert School-expiring expiring"].LeadDays)
+	assert.Contains(t, byChemical, "Alert School-no-sds sds_missing")
+	assert.Contains(t, byChemical, "Alert School-acid storage_conflict Alert School-base")
+	assert.NotContains(t, byChemical, "Alert School-low low_stock")
+	w = sendAs(http.MethodGet, "/api/v1/alerts?school=Alert+School&severity=critical", master, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Len(t, found, 2)
+	w = sendAs(http.MethodGet, "/api/v1/alerts?school=Alert+School&kind=sds_missing", master, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Len(t, found, 1)
+	w = sendAs(http.MethodGet, "/api/v1/alerts?school=Alert+School", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/alerts/rules?school=Alert+School", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/alerts/rules", auth.Principal{UserID: "district", Role: auth.RoleMaster}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAs(http.MethodDelete, "/api/v1/alerts/rules", alertAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/alerts/rules", alertAdmin, nil)
+	rules = models.AlertRules{}
+	json.Unmarshal(w.Body.Bytes(), &rules)
+	assert.Equal(t, models.DefaultAlertRules("Alert School"), rules)
+	sendAs(http.MethodDelete, "/api/v1/alerts/rules", admin, nil)
+}
+
+// Test that stored alerts are sent once, again when they escalate or a snooze runs out, and
+// resolve when their condition clears
+func TestSyncAlerts(t *testing.T) {
+	ctx := context.Background()
+	day0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	rules := models.DefaultAlertRules("Sync School")
+	rules.ExpiringLeadDays = []int{30, 7}
+
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemicals := alertChemicals(t, "Sync School", day0)
+			// sync checks the chemicals at now and records the alerts returned as sent
+			sync := func(now time.Time) map[string]models.Alert {
+				t.Helper()
+				notify, err := alerts.Sync(ctx, backend.Alerts, "Sync School", alerts.Evaluate(rules, chemicals, now), now)
+				assert.NoError(t, err)
+				for _, alert := range notify {
+					alert.MarkNotified(now)
+					assert.NoError(t, backend.Alerts.Update(ctx, alert))
+				}
+				return alertsByChemical(notify)
+			}
+
+			sent := sync(day0)
+			assert.Len(t, sent, 4)
+			expiring := sent["Sync School-expiring expiring"]
+			low := sent["Sync School-low low_stock"]
+			assert.Equal(t, models.AlertOpen, expiring.Status)
+			assert.Equal(t, 30, expiring.LeadDays)
+			assert.True(t, expiring.FirstSeen.Equal(day0))
+			assert.Empty(t, sync(day0.Add(time.Hour)), "alerts already sent")
+
+			assert.NoError(t, expiring.Acknowledge("admin-1", day0))
+			assert.NoError(t, backend.Alerts.Update(ctx, expiring))
+			assert.ErrorIs(t, low.Snooze("admin-1", day0.Add(-time.Hour), day0), models.ErrInvalidSnooze)
+			assert.NoError(t, low.Snooze("admin-1", day0.AddDate(0, 0, 20), day0))
+			assert.NoError(t, backend.Alerts.Update(ctx, low))
+
+			// Reaching the shorter lead time reopens the acknowledged alert, the snoozed one waits
+			sent = sync(day0.AddDate(0, 0, 14))
+			assert.Len(t, sent, 1)
+			escalated := sent["Sync School-expiring expiring"]
+			assert.Equal(t, expiring.ID, escalated.ID)
+			assert.Equal(t, 7, escalated.LeadDays)
+			assert.Equal(t, models.AlertOpen, escalated.Status)
+			stored, _ := backend.Alerts.Get(ctx, low.ID)
+			assert.Equal(t, models.AlertSnoozed, stored.Status)
+
+			// Once the snooze runs out the alert is sent again, and cleared conditions resolve
+			chemicals = chemicals[:5] // dropping the empty hexane, the last of them alerted about
+			sent = sync(day0.AddDate(0, 0, 21))
+			assert.Len(t, sent, 2)
+			assert.Equal(t, low.ID, sent["Sync School-low low_stock"].ID)
+			assert.Contains(t, sent, "Sync School-expiring expired")
+
+			stored, _ = backend.Alerts.Get(ctx, expiring.ID)
+			assert.Equal(t, models.AlertResolved, stored.Status)
+			assert.Equal(t, models.SystemActor, stored.ResolvedBy)
+			assert.ErrorIs(t, stored.Acknowledge("admin-1", day0), models.ErrAlertResolved)
+			active, err := backend.Alerts.List(ctx, repository.AlertFilter{School: "Sync School", Statuses: models.ActiveAlertStatuses})
+			assert.NoError(t, err)
+			assert.Len(t, active, 3)
+			resolved, _ := backend.Alerts.List(ctx, repository.AlertFilter{School: "Sync School", Statuses: []models.AlertStatus{models.AlertResolved}})
+			assert.Len(t, resolved, 2)
+		})
+	}
+}
+
+func TestRepositoryAlerts(t *testing.T) {
+	ctx := context.Background()
+	seen := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			remaining, threshold := quantity(t, "50 mL"), quantity(t, "100 mL")
+			low := models.Alert{Kind: models.AlertLowStock, Severity: models.SeverityWarning, School: "North High", ChemicalID: "chem-1",
+				ChemicalName: "Ethanol", CAS: "64-17-5", Message: "Ethanol is low", Remaining: &remaining, ReorderThreshold: &threshold,
+				Status: models.AlertOpen, FirstSeen: seen, LastSeen: seen}
+			assert.NoError(t, backend.Alerts.Create(ctx, &low))
+			assert.NotEmpty(t, low.ID)
+			conflict := models.Alert{Kind: models.AlertStorageConflict, Severity: models.SeverityCritical, School: "North High",
+				ChemicalID: "chem-2", Conflict: &models.StorageConflict{OtherID: "chem-3"}, Status: models.AlertOpen,
+				FirstSeen: seen, LastSeen: seen.Add(time.Minute)}
+			assert.NoError(t, backend.Alerts.Create(ctx, &conflict))
+			other := models.Alert{Kind: models.AlertLowStock, School: "South High", ChemicalID: "chem-4", Status: models.AlertResolved,
+				FirstSeen: seen, LastSeen: seen}
+			assert.NoError(t, backend.Alerts.Create(ctx, &other))
+
+			stored, err := backend.Alerts.Get(ctx, low.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "50 mL", stored.Remaining.String())
+			assert.Equal(t, "100 mL", stored.ReorderThreshold.String())
+			assert.Equal(t, "Ethanol is low", stored.Message)
+			assert.True(t, stored.LastSeen.Equal(seen))
+			assert.Nil(t, stored.NotifiedAt)
+			assert.Nil(t, stored.Conflict)
+			stored, _ = backend.Alerts.Get(ctx, conflict.ID)
+			assert.Equal(t, "storage_conflict:chem-2:chem-3", stored.Key())
+
+			listed, err := backend.Alerts.List(ctx, repository.AlertFilter{School: "North High"})
+			assert.NoError(t, err)
+			if assert.Len(t, listed, 2) {
+				assert.Equal(t, conflict.ID, listed[0].ID, "most recently seen first")
+			}
+			listed, _ = backend.Alerts.List(ctx, repository.AlertFilter{Statuses: models.ActiveAlertStatuses})
+			assert.Len(t, listed, 2)
+			listed, _ = backend.Alerts.List(ctx, repository.AlertFilter{Kind: models.AlertLowStock, Severity: models.SeverityWarning})
+			assert.Len(t, listed, 1)
+			listed, _ = backend.Alerts.List(ctx, repository.AlertFilter{ChemicalID: "chem-4", Limit: 1})
+			assert.Len(t, listed, 1)
+
+			low.MarkNotified(seen)
+			assert.NoError(t, low.Snooze("admin-1", seen.AddDate(0, 0, 7), seen))
+			assert.NoError(t, backend.Alerts.Update(ctx, low))
+			stored, _ = backend.Alerts.Get(ctx, low.ID)
+			assert.Equal(t, models.AlertSnoozed, stored.Status)
+			assert.Equal(t, "admin-1", stored.SnoozedBy)
+			assert.True(t, stored.SnoozedUntil.Equal(seen.AddDate(0, 0, 7)))
+			assert.True(t, stored.NotifiedAt.Equal(seen))
+			assert.Equal(t, models.SeverityWarning, stored.NotifiedSeverity)
+
+			assert.ErrorIs(t, backend.Alerts.Update(ctx, models.Alert{ID: "missing"}), repository.ErrNotFound)
+			_, err = backend.Alerts.Get(ctx, "missing")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test the routes admins use to acknowledge, snooze and resolve alerts
+func TestAlertActionRoutes(t *testing.T) {
+	actionAdmin := auth.Principal{UserID: "action-admin", School: "Action School", Role: auth.RoleAdmin}
+	for _, chemical := range alertChemicals(t, "Action School", time.Now()) {
+		seedChemical(t, chemical)
+	}
+	refreshAlerts(t, "Action School")
+
+	w := sendAs(http.MethodGet, "/api/v1/alerts?kind=expiring", actionAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found []models.Alert
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if !assert.Len(t, found, 1) {
+		return
+	}
+	alert := found[0]
+	assert.NotEmpty(t, alert.ID)
+	assert.Equal(t, models.AlertOpen, alert.Status)
+	path := "/api/v1/alerts/" + alert.ID
+
+	w = sendAs(http.MethodGet, path, teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/alerts/missing", actionAdmin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodPost, path+"/acknowledge", admin, nil) // an admin of another school
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = sendAs(http.MethodPost, path+"/acknowledge", actionAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &alert)
+	assert.Equal(t, models.AlertAcknowledged, alert.Status)
+	assert.Equal(t, "action-admin", alert.AcknowledgedBy)
+	w = sendAs(http.MethodGet, "/api/v1/alerts?status=acknowledged", actionAdmin, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Len(t, found, 1)
+
+	w = sendAs(http.MethodPost, path+"/snooze", actionAdmin, map[string]string{"until": "2020-01-01"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodPost, path+"/snooze", actionAdmin, map[string]string{"until": "next week"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	until := time.Now().AddDate(0, 0, 7).UTC().Format(time.RFC3339)
+	w = sendAs(http.MethodPost, path+"/snooze", actionAdmin, map[string]string{"until": until})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &alert)
+	assert.Equal(t, models.AlertSnoozed, alert.Status)
+
+	// A resolved alert whose condition still holds is raised again as a new one
+	w = sendAs(http.MethodPost, path+"/resolve", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodPost, path+"/resolve", actionAdmin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	refreshAlerts(t, "Action School")
+	w = sendAs(http.MethodGet, "/api/v1/alerts?kind=expiring", actionAdmin, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if assert.Len(t, found, 1) {
+		assert.NotEqual(t, alert.ID, found[0].ID)
+		assert.Equal(t, models.AlertOpen, found[0].Status)
+	}
+	w = sendAs(http.MethodGet, "/api/v1/alerts?status=resolved", actionAdmin, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, alert.ID, found[0].ID)
+		assert.Equal(t, "master-1", found[0].ResolvedBy)
+	}
+	w = sendAs(http.MethodGet, "/api/v1/alerts?status=closed", actionAdmin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
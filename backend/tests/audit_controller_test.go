@@ -0,0 +1,170 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendAs sends a JSON request as the given caller with a fixed request ID
+func sendAs(method, path string, p auth.Principal, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		jsonValue, _ := json.Marshal(body)
+		reader = bytes.NewReader(jsonValue)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("X-Request-ID", "req-"+strings.ToLower(method))
+	authorizeAs(req, p)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// historyOf fetches the change history of a chemical as the given caller
+func historyOf(t *testing.T, chemicalID string, p auth.Principal) []models.AuditEntry {
+	t.Helper()
+	w := sendAs(http.MethodGet, "/api/v1/chemicals/"+chemicalID+"/history", p, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []models.AuditEntry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	return entries
+}
+
+// Test that creating, moving and deleting a chemical leaves a history with the changed fields
+func TestChemicalHistory(t *testing.T) {
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{
+		"name": "Hydrochloric Acid", "CAS": "7647-01-0", "quantity": "1 L", "room": "B10", "cabinet": 1, "shelf": 2,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "req-post", w.Header().Get("X-Request-ID"))
+	var created struct {
+		Chemical struct {
+			ID string `json:"id"`
+		} `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	chemicalID := created.Chemical.ID
+
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{"cabinet": 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+	// An update that changes nothing is not recorded
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{"cabinet": 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	entries := historyOf(t, chemicalID, teacher)
+	if assert.Len(t, entries, 2) {
+		update := entries[0]
+		assert.Equal(t, models.AuditUpdate, update.Action)
+		assert.Equal(t, admin.UserID, update.Actor)
+		assert.Equal(t, "req-put", update.RequestID)
+		assert.Equal(t, "Test School", update.School)
+		assert.Equal(t, []models.FieldChange{{Field: "cabinet", Before: 1.0, After: 4.0}}, update.Changes)
+
+		assert.Equal(t, models.AuditCreate, entries[1].Action)
+		assert.Equal(t, "req-post", entries[1].RequestID)
+		assert.NotEmpty(t, entries[1].Changes)
+	}
+
+	// The history stays available once the chemical is in the trash
+	w = sendAs(http.MethodDelete, "/api/v1/chemicals/"+chemicalID, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	entries = historyOf(t, chemicalID, teacher)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, models.AuditDelete, entries[0].Action)
+		assert.Nil(t, fieldChange(entries[0], "deleted").Before)
+		assert.NotNil(t, fieldChange(entries[0], "deleted").After)
+	}
+
+	other := auth.Principal{UserID: "teacher-2", School: "Other School", Role: auth.RoleUser}
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/"+chemicalID+"/history", other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/never-existed/history", teacher, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// fieldChange returns the change of one field in an entry
+func fieldChange(entry models.AuditEntry, field string) models.FieldChange {
+	for _, change := range entry.Changes {
+		if change.Field == field {
+			return change
+		}
+	}
+	return models.FieldChange{}
+}
+
+// Test that password changes are recorded without the hashes
+func TestUserAudit_PasswordRedacted(t *testing.T) {
+	userID := seedUser(t, models.User{ID: "audit-user", First: "Ada", Email: "ada@example.com", School: "Test School", Password: "old-hash"})
+
+	w := sendAs(http.MethodPut, "/api/v1/users/"+userID, admin, map[string]interface{}{"first": "Ada L.", "password": "new-password"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/audit?entity_type=user", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "old-hash")
+	var entries []models.AuditEntry
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	if assert.NotEmpty(t, entries) {
+		entry := entries[0]
+		assert.Equal(t, userID, entry.EntityID)
+		assert.Equal(t, []models.FieldChange{
+			{Field: "first", Before: "Ada", After: "Ada L."},
+			{Field: "password", Before: models.Redacted, After: models.Redacted},
+		}, entry.Changes)
+	}
+}
+
+// Test who can browse a school's activity, and paging through it
+func TestGetAuditLog(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "audit-log")
+	for _, cabinet := range []int{2, 3, 4} {
+		sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{"cabinet": cabinet})
+	}
+
+	w := sendAs(http.MethodGet, "/api/v1/audit", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/audit?school=Other%20School", admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/audit?limit=0", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/audit?entity_type=chemical&limit=2", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []models.AuditEntry
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, 4.0, fieldChange(entries[0], "cabinet").After)
+		assert.False(t, entries[0].CreatedAt.Before(entries[1].CreatedAt))
+	}
+}
+
+// Test the field by field comparison used by the audit trail
+func TestAuditDiff(t *testing.T) {
+	before := models.Chemical{ID: "c1", Name: "Ethanol", Room: "B10", Remaining: models.Quantity{Amount: 1, Unit: models.Liter}}
+	after := before
+	after.Room = "B12"
+	after.Remaining.Amount = 0.5
+
+	changes, err := models.Diff(&before, &after)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "remaining", Before: map[string]interface{}{"amount": 1.0, "unit": "L"}, After: map[string]interface{}{"amount": 0.5, "unit": "L"}},
+		{Field: "room", Before: "B10", After: "B12"},
+	}, changes)
+
+	changes, err = models.Diff(nil, &before)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ethanol", fieldChange(models.AuditEntry{Changes: changes}, "name").After)
+	assert.Empty(t, fieldChange(models.AuditEntry{Changes: changes}, "id").Field)
+}
@@ -0,0 +1,145 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that an issued access token parses back to the same principal
+func TestAccessToken_RoundTrip(t *testing.T) {
+	principal := auth.Principal{UserID: "user-1", School: "Test School", Role: auth.RoleAdmin}
+
+	token, err := tokens.IssueAccessToken(principal)
+	assert.NoError(t, err)
+
+	parsed, err := tokens.ParseAccessToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, principal, parsed)
+}
+
+// Test that tokens signed with another secret or already expired are rejected
+func TestAccessToken_Rejected(t *testing.T) {
+	principal := auth.Principal{UserID: "user-1", School: "Test School", Role: auth.RoleUser}
+
+	other := auth.NewTokenManager("another-secret", time.Minute, time.Hour)
+	forged, _ := other.IssueAccessToken(principal)
+	_, err := tokens.ParseAccessToken(forged)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	expiredManager := auth.NewTokenManager("test-secret", -time.Minute, time.Hour)
+	expired, _ := expiredManager.IssueAccessToken(principal)
+	_, err = tokens.ParseAccessToken(expired)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, err = tokens.ParseAccessToken("not-a-token")
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestRoleFor(t *testing.T) {
+	assert.Equal(t, auth.RoleMaster, auth.RoleFor(true, true))
+	assert.Equal(t, auth.RoleAdmin, auth.RoleFor(true, false))
+	assert.Equal(t, auth.RoleUser, auth.RoleFor(false, false))
+}
+
+// set up a router with a single protected route that echoes the caller
+func setupProtectedRouter() *gin.Engine {
+	router := gin.New()
+	router.GET("/protected", middleware.RequireAuth(tokens), func(c *gin.Context) {
+		principal, _ := middleware.CurrentUser(c)
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": c.GetString(middleware.ContextUserID),
+			"school":  c.GetString(middleware.ContextSchool),
+			"role":    principal.Role,
+		})
+	})
+	return router
+}
+
+func TestRequireAuth_MissingToken(t *testing.T) {
+	router := setupProtectedRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Authentication required")
+}
+
+func TestRequireAuth_InvalidToken(t *testing.T) {
+	router := setupProtectedRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequireAuth_ValidToken(t *testing.T) {
+	router := setupProtectedRouter()
+	token, _ := tokens.IssueAccessToken(auth.Principal{UserID: "user-42", School: "Test School", Role: auth.RoleMaster})
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "user-42")
+	assert.Contains(t, w.Body.String(), "Test School")
+	assert.Contains(t, w.Body.String(), auth.RoleMaster)
+}
+
+// Test that a refresh token can be rotated once and that reusing it is rejected
+func TestRefreshToken_Rotation(t *testing.T) {
+	user := User{
+		First:    "Refresh",
+		Last:     "Test",
+		Email:    "refresh.test@example.com",
+		Password: "password123",
+		School:   "Test School",
+	}
+	userJSON, _ := json.Marshal(user)
+	reqAdd := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(userJSON))
+	wAdd := httptest.NewRecorder()
+	r.ServeHTTP(wAdd, reqAdd)
+	assert.Equal(t, http.StatusOK, wAdd.Code)
+
+	loginJSON, _ := json.Marshal(map[string]string{"email": user.Email, "password": user.Password})
+	reqLogin := httptest.NewRequest(http.MethodPost, "/api/v1/login", bytes.NewReader(loginJSON))
+	wLogin := httptest.NewRecorder()
+	r.ServeHTTP(wLogin, reqLogin)
+	assert.Equal(t, http.StatusOK, wLogin.Code)
+
+	var session struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := json.Unmarshal(wLogin.Body.Bytes(), &session)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, session.RefreshToken)
+
+	// First use rotates the token
+	refreshJSON, _ := json.Marshal(map[string]string{"refresh_token": session.RefreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", bytes.NewReader(refreshJSON))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "access_token")
+
+	// Second use of the same token is rejected
+	req2 := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", bytes.NewReader(refreshJSON))
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusUnauthorized, w2.Code)
+}
@@ -0,0 +1,67 @@
+package controllers_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore_PutGetStatDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:8080/")
+	assert.NoError(t, err)
+
+	err = store.Put(ctx, "sds/123.pdf", strings.NewReader("%PDF-test"), "application/pdf")
+	assert.NoError(t, err)
+
+	reader, err := store.Get(ctx, "sds/123.pdf")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "%PDF-test", string(data))
+
+	info, err := store.Stat(ctx, "sds/123.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), info.Size)
+	assert.Equal(t, "application/pdf", info.ContentType)
+
+	assert.Equal(t, "http://localhost:8080/blobs/sds/123.pdf", store.URL("sds/123.pdf"))
+
+	assert.NoError(t, store.Delete(ctx, "sds/123.pdf"))
+	_, err = store.Get(ctx, "sds/123.pdf")
+	assert.ErrorIs(t, err, blobstore.ErrNotExist)
+	assert.ErrorIs(t, store.Delete(ctx, "sds/123.pdf"), blobstore.ErrNotExist)
+}
+
+func TestLocalStore_List(t *testing.T) {
+	ctx := context.Background()
+	store, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:8080")
+	assert.NoError(t, err)
+
+	for _, key := range []string{"label/b.pdf", "label/a.pdf", "QRcodes/a.png"} {
+		assert.NoError(t, store.Put(ctx, key, strings.NewReader("x"), ""))
+	}
+
+	objects, err := store.List(ctx, "label/")
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, "label/a.pdf", objects[0].Key)
+		assert.Equal(t, "label/b.pdf", objects[1].Key)
+	}
+}
+
+func TestLocalStore_RejectsTraversal(t *testing.T) {
+	ctx := context.Background()
+	store, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:8080")
+	assert.NoError(t, err)
+
+	for _, key := range []string{"../secret", "sds/../../secret", "", "/abs"} {
+		assert.Error(t, store.Put(ctx, key, strings.NewReader("x"), ""), key)
+		_, err := store.Get(ctx, key)
+		assert.Error(t, err, key)
+	}
+}
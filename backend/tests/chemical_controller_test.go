@@ -9,6 +9,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +20,7 @@ type Chemical struct {
 	ID             string `json:"id"`
 	QRcode         string `json:"qrcode"`
 	Name           string `json:"name"`
-	CAS            int    `json:"CAS"`
+	CAS            string `json:"CAS"`
 	School         string `json:"school"`
 	PurchaseDate   string `json:"purchase_date"`
 	ExpirationDate string `json:"expiration_date"`
@@ -28,12 +31,32 @@ type Chemical struct {
 	Shelf          int    `json:"shelf"`
 }
 
+// record converts the test chemical into the stored model
+func (c Chemical) record() models.Chemical {
+	purchaseDate, _ := models.ParseDate(c.PurchaseDate)
+	expirationDate, _ := models.ParseDate(c.ExpirationDate)
+	quantity, _ := models.ParseQuantity(c.Quantity)
+	return models.Chemical{
+		Name:           c.Name,
+		CAS:            c.CAS,
+		School:         c.School,
+		PurchaseDate:   purchaseDate,
+		ExpirationDate: expirationDate,
+		Status:         c.Status,
+		ContainerSize:  quantity,
+		Remaining:      quantity,
+		Room:           c.Room,
+		Cabinet:        c.Cabinet,
+		Shelf:          c.Shelf,
+	}
+}
+
 // Test AddChemical
 func TestAddChemical(t *testing.T) {
 
 	chemical := Chemical{
 		Name:           "Test Chemical",
-		CAS:            123456,
+		CAS:            "7647-14-5",
 		School:         "Test School",
 		PurchaseDate:   "2023-03-01",
 		ExpirationDate: "2025-03-01",
@@ -46,6 +69,7 @@ func TestAddChemical(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(chemical)
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -61,7 +85,7 @@ func TestGetChemical(t *testing.T) {
 	// Adding a mock chemical for testing
 	chemical := Chemical{
 		Name:           "Test Chemical",
-		CAS:            123456,
+		CAS:            "7647-14-5",
 		School:         "Test School",
 		PurchaseDate:   "2023-03-01",
 		ExpirationDate: "2025-03-01",
@@ -72,33 +96,19 @@ func TestGetChemical(t *testing.T) {
 		Shelf:          1,
 	}
 
-	ctx := context.Background()
 	// add a chemical to test get
-	docRef, _, err := client.Collection("chemicals").Add(ctx, map[string]interface{}{
-		"name":            chemical.Name,
-		"CAS":             chemical.CAS,
-		"school":          chemical.School,
-		"purchase_date":   chemical.PurchaseDate,
-		"expiration_date": chemical.ExpirationDate,
-		"status":          chemical.Status,
-		"quantity":        chemical.Quantity,
-		"room":            chemical.Room,
-		"cabinet":         chemical.Cabinet,
-		"shelf":           chemical.Shelf,
-	})
-	if err != nil {
-		t.Fatalf("Failed to add mock chemical: %v", err)
-	}
+	chemicalID := seedChemical(t, chemical.record())
 
 	// Send a GET request to fetch the chemical
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+docRef.ID, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+chemicalID, nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
 
 	// Assert the response
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), docRef.ID)
+	assert.Contains(t, w.Body.String(), chemicalID)
 }
 
 // Test GetChemicals
@@ -107,7 +117,7 @@ func TestGetChemicals(t *testing.T) {
 	// Adding a mock chemical for testing
 	chemical := Chemical{
 		Name:           "Test Chemical",
-		CAS:            123456,
+		CAS:            "7647-14-5",
 		School:         "Test School",
 		PurchaseDate:   "2023-03-01",
 		ExpirationDate: "2025-03-01",
@@ -119,25 +129,11 @@ func TestGetChemicals(t *testing.T) {
 	}
 
 	// add a chemical to test get all
-	ctx := context.Background()
-	_, _, err := client.Collection("chemicals").Add(ctx, map[string]interface{}{
-		"name":            chemical.Name,
-		"CAS":             chemical.CAS,
-		"school":          chemical.School,
-		"purchase_date":   chemical.PurchaseDate,
-		"expiration_date": chemical.ExpirationDate,
-		"status":          chemical.Status,
-		"quantity":        chemical.Quantity,
-		"room":            chemical.Room,
-		"cabinet":         chemical.Cabinet,
-		"shelf":           chemical.Shelf,
-	})
-	if err != nil {
-		t.Fatalf("Failed to add mock chemical: %v", err)
-	}
+	seedChemical(t, chemical.record())
 
 	// Send a GET request to fetch all chemicals
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals",nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -147,13 +143,76 @@ func TestGetChemicals(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Test Chemical")
 }
 
+// Test paging, sorting and filtering the chemical and user lists
+func TestGetChemicals_Pages(t *testing.T) {
+	pageAdmin := auth.Principal{UserID: "page-admin", School: "Page School", Role: auth.RoleAdmin}
+	for _, chemical := range []Chemical{
+		{Name: "Toluene", CAS: "108-88-3", School: "Page School", ExpirationDate: "2026-05-01", Status: "Good", Room: "Lab 2"},
+		{Name: "acetone", CAS: "67-64-1", School: "Page School", ExpirationDate: "2027-01-01", Status: "Good", Room: "Lab 1"},
+		{Name: "Methanol", CAS: "67-56-1", School: "Page School", Status: "Damaged", Room: "Lab 1"},
+	} {
+		seedChemical(t, chemical.record())
+	}
+
+	// list fetches one page, returning the chemical names and the next cursor
+	list := func(query string) (int, []string, string) {
+		w := sendAs(http.MethodGet, "/api/v1/chemicals?"+query, pageAdmin, nil)
+		var chemicals []models.Chemical
+		json.Unmarshal(w.Body.Bytes(), &chemicals)
+		names := make([]string, len(chemicals))
+		for i, chemical := range chemicals {
+			names[i] = chemical.Name
+		}
+		return w.Code, names, w.Header().Get(controllers.HeaderNextCursor)
+	}
+
+	code, names, cursor := list("sort=name&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"acetone", "Methanol"}, names)
+	if assert.NotEmpty(t, cursor) {
+		_, names, next := list("sort=name&limit=2&cursor=" + cursor)
+		assert.Equal(t, []string{"Toluene"}, names)
+		assert.Empty(t, next)
+
+		code, _, _ = list("sort=-name&cursor=" + cursor)
+		assert.Equal(t, http.StatusBadRequest, code)
+	}
+
+	// Without a limit the whole list is returned as before
+	_, names, cursor = list("sort=-expiration_date")
+	assert.Equal(t, []string{"Methanol", "acetone", "Toluene"}, names)
+	assert.Empty(t, cursor)
+
+	_, names, _ = list("status=good&room=lab+1")
+	assert.Equal(t, []string{"acetone"}, names)
+	_, names, _ = list("expiring_before=2026-12-31")
+	assert.Equal(t, []string{"Toluene"}, names)
+
+	for _, query := range []string{"sort=quantity", "limit=0", "limit=501", "expiring_before=soon", "low_stock=maybe", "cursor=nope"} {
+		code, _, _ = list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+
+	for _, email := range []string{"page-one@example.com", "page-two@example.com"} {
+		seedUser(t, models.User{Email: email, School: "Page School"})
+	}
+	w := sendAs(http.MethodGet, "/api/v1/users?limit=1", pageAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	cursor = w.Header().Get(controllers.HeaderNextCursor)
+	if assert.NotEmpty(t, cursor) {
+		w = sendAs(http.MethodGet, "/api/v1/users?limit=1&cursor="+cursor, pageAdmin, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(controllers.HeaderNextCursor))
+	}
+}
+
 // Test UpdateChemical
 func TestUpdateChemical(t *testing.T) {
 
 	// Adding a mock chemical for testing
 	chemical := Chemical{
 		Name:           "Test Chemical",
-		CAS:            123456,
+		CAS:            "7647-14-5",
 		School:         "Test School",
 		PurchaseDate:   "2023-03-01",
 		ExpirationDate: "2025-03-01",
@@ -164,29 +223,14 @@ func TestUpdateChemical(t *testing.T) {
 		Shelf:          1,
 	}
 
-	ctx := context.Background()
 	// add a chemical to test update
-	docRef, _, err := client.Collection("chemicals").Add(ctx, map[string]interface{}{
-		"name":            chemical.Name,
-		"CAS":             chemical.CAS,
-		"school":          chemical.School,
-		"purchase_date":   chemical.PurchaseDate,
-		"expiration_date": chemical.ExpirationDate,
-		"status":          chemical.Status,
-		"quantity":        chemical.Quantity,
-		"room":            chemical.Room,
-		"cabinet":         chemical.Cabinet,
-		"shelf":           chemical.Shelf,
-	})
-	if err != nil {
-		t.Fatalf("Failed to add mock chemical: %v", err)
-	}
+	chemicalID := seedChemical(t, chemical.record())
 
 	// New data for updating the chemical
 	updatedChemical := Chemical{
 		Name:           "Updated Chemical",
-		CAS:            789012,
-		School:         "Updated School",
+		CAS:            "64-17-5",
+		School:         "Test School",
 		PurchaseDate:   "2024-01-01",
 		ExpirationDate: "2026-01-01",
 		Status:         "Inactive",
@@ -197,7 +241,8 @@ func TestUpdateChemical(t *testing.T) {
 	}
 
 	jsonValue, _ := json.Marshal(updatedChemical)
-	req := httptest.NewRequest(http.MethodPut, "/api/v1/chemicals/"+docRef.ID, bytes.NewReader(jsonValue))
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/chemicals/"+chemicalID, bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -213,7 +258,7 @@ func TestDeleteChemical(t *testing.T) {
 	// Adding a mock chemical for testing
 	chemical := Chemical{
 		Name:           "Test Chemical",
-		CAS:            123456,
+		CAS:            "7647-14-5",
 		School:         "Test School",
 		PurchaseDate:   "2023-03-01",
 		ExpirationDate: "2025-03-01",
@@ -224,25 +269,11 @@ func TestDeleteChemical(t *testing.T) {
 		Shelf:          1,
 	}
 
-	ctx := context.Background()
 	// add a chemical to test delete
-	docRef, _, err := client.Collection("chemicals").Add(ctx, map[string]interface{}{
-		"name":            chemical.Name,
-		"CAS":             chemical.CAS,
-		"school":          chemical.School,
-		"purchase_date":   chemical.PurchaseDate,
-		"expiration_date": chemical.ExpirationDate,
-		"status":          chemical.Status,
-		"quantity":        chemical.Quantity,
-		"room":            chemical.Room,
-		"cabinet":         chemical.Cabinet,
-		"shelf":           chemical.Shelf,
-	})
-	if err != nil {
-		t.Fatalf("Failed to add mock chemical: %v", err)
-	}
+	chemicalID := seedChemical(t, chemical.record())
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/v1/chemicals/"+docRef.ID, nil)
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/chemicals/"+chemicalID, nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -251,3 +282,283 @@ func TestDeleteChemical(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Chemical deleted successfully")
 }
+
+// Test that ISO 8601 dates and date-times are accepted and stored as calendar dates
+func TestParseDate(t *testing.T) {
+	for _, input := range []string{"2024-03-01", "2024-03-01T10:30:00Z", "2024-03-01T23:30:00-08:00", "2024-03-01T10:30:00"} {
+		d, err := models.ParseDate(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, "2024-03-01", d.String(), input)
+	}
+
+	for _, input := range []string{"03/01/2024", "2024-13-01", "tomorrow"} {
+		_, err := models.ParseDate(input)
+		assert.ErrorIs(t, err, models.ErrInvalidDate, input)
+	}
+}
+
+// Test that AddChemical rejects dates that are not ISO 8601
+func TestAddChemical_InvalidDate(t *testing.T) {
+	chemical := Chemical{
+		Name:           "Test Chemical",
+		CAS:            "7647-14-5",
+		School:         "Test School",
+		PurchaseDate:   "03/01/2023",
+		ExpirationDate: "2025-03-01",
+	}
+
+	jsonValue, _ := json.Marshal(chemical)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(jsonValue))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid purchase date")
+}
+
+// Test that AddChemical rejects a chemical that expires before it was purchased
+func TestAddChemical_ExpirationBeforePurchase(t *testing.T) {
+	chemical := Chemical{
+		Name:           "Test Chemical",
+		CAS:            "7647-14-5",
+		School:         "Test School",
+		PurchaseDate:   "2025-03-01",
+		ExpirationDate: "2023-03-01",
+	}
+
+	jsonValue, _ := json.Marshal(chemical)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(jsonValue))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Expiration date cannot be before the purchase date")
+}
+
+// Test that a date-time is stored and returned as its calendar date
+func TestAddChemical_DateTimeNormalized(t *testing.T) {
+	chemical := Chemical{
+		Name:           "Test Chemical",
+		CAS:            "7647-14-5",
+		School:         "Test School",
+		PurchaseDate:   "2023-03-01T09:15:00Z",
+		ExpirationDate: "2025-03-01",
+	}
+
+	jsonValue, _ := json.Marshal(chemical)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(jsonValue))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Chemical Chemical `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	stored, err := repos.Chemicals.Get(context.Background(), response.Chemical.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "2023-03-01", stored.PurchaseDate.String())
+	assert.Equal(t, "2023-03-01", response.Chemical.PurchaseDate)
+}
+
+// Test that an update cannot move the expiration date before the stored purchase date
+func TestUpdateChemical_ExpirationBeforePurchase(t *testing.T) {
+	chemical := Chemical{
+		Name:           "Test Chemical",
+		CAS:            "7647-14-5",
+		School:         "Test School",
+		PurchaseDate:   "2023-03-01",
+		ExpirationDate: "2025-03-01",
+	}
+	chemicalID := seedChemical(t, chemical.record())
+
+	jsonValue, _ := json.Marshal(Chemical{ExpirationDate: "2022-01-01"})
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/chemicals/"+chemicalID, bytes.NewReader(jsonValue))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Equal(t, "2025-03-01", stored.ExpirationDate.String())
+}
+
+// Test parsing quantities and converting between units
+func TestQuantity_ParseAndConvert(t *testing.T) {
+	q, err := models.ParseQuantity("2.5kg")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Quantity{Amount: 2.5, Unit: models.Kilogram}, q)
+
+	grams, err := q.Convert(models.Gram)
+	assert.NoError(t, err)
+	assert.Equal(t, 2500.0, grams.Amount)
+
+	q, err = models.ParseQuantity("500 ml")
+	assert.NoError(t, err)
+	assert.Equal(t, "500 mL", q.String())
+
+	q, err = models.ParseQuantity("12")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Units, q.Unit)
+
+	_, err = q.Convert(models.Liter)
+	assert.ErrorIs(t, err, models.ErrIncompatibleUnits)
+	_, err = models.ParseQuantity("5 gallons")
+	assert.ErrorIs(t, err, models.ErrInvalidUnit)
+	_, err = models.ParseQuantity("-5 g")
+	assert.ErrorIs(t, err, models.ErrInvalidQuantity)
+}
+
+// Test that low stock is computed from the reorder threshold across units
+func TestChemical_LowStock(t *testing.T) {
+	chemical := models.Chemical{
+		Remaining:        models.Quantity{Amount: 400, Unit: models.Milliliter},
+		ReorderThreshold: models.Quantity{Amount: 0.5, Unit: models.Liter},
+	}
+	assert.True(t, chemical.LowStock())
+
+	chemical.Remaining = models.Quantity{Amount: 0.75, Unit: models.Liter}
+	assert.False(t, chemical.LowStock())
+
+	chemical.ReorderThreshold = models.Quantity{}
+	assert.False(t, chemical.LowStock())
+}
+
+// Test adding a chemical with structured quantities
+func TestAddChemical_StructuredQuantities(t *testing.T) {
+	body := []byte(`{"name": "Ethanol", "CAS": 64175, "school": "Test School",
+		"container_size": {"amount": 1, "unit": "L"},
+		"remaining": "200 mL",
+		"reorder_threshold": {"amount": 250, "unit": "mL"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(body))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Chemical Chemical `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+response.Chemical.ID, nil)
+	authorize(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"low_stock":true`)
+	assert.Contains(t, w.Body.String(), `"quantity":"200 mL"`)
+}
+
+// Test that adding a chemical answers with the stored chemical, quantities filled in from quantity
+func TestAddChemical_ReturnsStored(t *testing.T) {
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{
+		"name": "Methanol", "CAS": "67-56-1", "quantity": "2 L", "reorder_threshold": "500 mL",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Chemical map[string]interface{} `json:"chemical"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	quantity := map[string]interface{}{"amount": 2.0, "unit": "L"}
+	assert.Equal(t, quantity, response.Chemical["container_size"])
+	assert.Equal(t, quantity, response.Chemical["remaining"])
+	assert.Equal(t, "2 L", response.Chemical["quantity"])
+	assert.Equal(t, false, response.Chemical["low_stock"])
+	assert.Equal(t, "Test School", response.Chemical["school"])
+	assert.NotEmpty(t, response.Chemical["id"])
+}
+
+// Test that inconsistent quantities are rejected
+func TestAddChemical_InvalidQuantities(t *testing.T) {
+	cases := map[string]string{
+		`{"name": "Ethanol", "CAS": 64175, "quantity": "5 gallons"}`:                                       "invalid unit",
+		`{"name": "Ethanol", "CAS": 64175, "container_size": "500 mL", "remaining": "2 L"}`:                 "cannot exceed the container size",
+		`{"name": "Ethanol", "CAS": 64175, "container_size": "500 mL", "reorder_threshold": "100 g"}`:      "Reorder threshold must use a unit compatible",
+		`{"name": "Ethanol", "CAS": 64175, "container_size": "500 g", "remaining": {"amount": 1, "unit": "L"}}`: "compatible units",
+	}
+	for body, message := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader([]byte(body)))
+		authorize(req)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+	}
+}
+
+// Test CAS check-digit validation and canonical formatting
+func TestParseCAS(t *testing.T) {
+	for input, canonical := range map[string]string{
+		"7647-14-5":  "7647-14-5",
+		"7647145":    "7647-14-5",
+		"64-17-5":    "64-17-5",
+		"7732-18-5":  "7732-18-5",
+		"1333-74-0":  "1333-74-0",
+		"9002-93-1 ": "9002-93-1",
+	} {
+		cas, err := models.ParseCAS(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, canonical, cas, input)
+	}
+
+	_, err := models.ParseCAS("7647-14-6")
+	assert.ErrorIs(t, err, models.ErrCASCheckDigit)
+	for _, input := range []string{"1234", "76-4714-5", "7647-14-X", "12345678901", "0064-17-5"} {
+		_, err := models.ParseCAS(input)
+		assert.ErrorIs(t, err, models.ErrInvalidCAS, input)
+	}
+}
+
+// Test that AddChemical accepts the CAS number as a plain integer and stores it hyphenated
+func TestAddChemical_NumericCAS(t *testing.T) {
+	body := []byte(`{"name": "Sodium chloride", "CAS": 7647145, "school": "Test School"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(body))
+	authorize(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Chemical Chemical `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	stored, err := repos.Chemicals.Get(context.Background(), response.Chemical.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "7647-14-5", stored.CAS)
+}
+
+// Test that a CAS number with a wrong check digit is rejected on create and update
+func TestChemical_InvalidCASCheckDigit(t *testing.T) {
+	body := []byte(`{"name": "Sodium chloride", "CAS": "7647-14-6", "school": "Test School"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals", bytes.NewReader(body))
+	authorize(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "check digit")
+
+	chemicalID := seedChemical(t, models.Chemical{Name: "Sodium chloride", CAS: "7647-14-5", School: "Test School"})
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/chemicals/"+chemicalID, bytes.NewReader([]byte(`{"CAS": 7647146}`)))
+	authorize(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Equal(t, "7647-14-5", stored.CAS)
+}
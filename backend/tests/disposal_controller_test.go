@@ -0,0 +1,134 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a chemical going from flagged to disposed, with the approving admin and manifest recorded
+func TestDisposalWorkflow(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "disposal-workflow")
+	path := "/api/v1/chemicals/" + chemicalID + "/disposal"
+
+	// Steps cannot be skipped
+	w := sendAs(http.MethodPost, path+"/approve", admin, map[string]interface{}{"method": "Lab pack", "vendor": "Clean Harbors"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = sendAs(http.MethodPost, path+"/flag", teacher, map[string]interface{}{"reason": "Expired"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodPost, path+"/flag", teacher, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Only admins approve, and they must say how and by whom
+	w = sendAs(http.MethodPost, path+"/approve", teacher, map[string]interface{}{"method": "Lab pack", "vendor": "Clean Harbors"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPost, path+"/approve", admin, map[string]interface{}{"method": "Lab pack"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodPost, path+"/approve", admin, map[string]interface{}{"method": 42})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "Invalid input"}`, w.Body.String())
+	w = sendAs(http.MethodPost, path+"/approve", admin, map[string]interface{}{"method": "Lab pack", "vendor": "Clean Harbors"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = sendAs(http.MethodPost, path+"/complete", admin, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodPost, path+"/complete", admin, map[string]interface{}{"manifest_number": "012345678JJK"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	if assert.NotNil(t, stored.Disposal) {
+		d := stored.Disposal
+		assert.Equal(t, models.DisposalDisposed, d.State)
+		assert.Equal(t, "Expired", d.Reason)
+		assert.Equal(t, teacher.UserID, d.FlaggedBy)
+		assert.Equal(t, admin.UserID, d.ApprovedBy)
+		assert.Equal(t, "Clean Harbors", d.Vendor)
+		assert.Equal(t, "012345678JJK", d.ManifestNumber)
+		assert.False(t, d.DisposedAt.IsZero())
+	}
+	assert.Equal(t, 0.0, stored.Remaining.Amount)
+
+	// A disposed chemical can neither be used nor taken back
+	w = postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "5 mL"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = sendAs(http.MethodDelete, path, admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Every step is in the history
+	entries := historyOf(t, chemicalID, teacher)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, admin.UserID, entries[0].Actor)
+		assert.NotNil(t, fieldChange(entries[0], "disposal").After)
+	}
+}
+
+// Test that a flagged chemical can be kept after all
+func TestCancelDisposal(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "disposal-cancel")
+	path := "/api/v1/chemicals/" + chemicalID + "/disposal"
+
+	sendAs(http.MethodPost, path+"/flag", teacher, nil)
+	w := sendAs(http.MethodDelete, path, teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodDelete, path, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Nil(t, stored.Disposal)
+	assert.Equal(t, 500.0, stored.Remaining.Amount)
+}
+
+// Test the disposal report of a school over a date range
+func TestGetDisposalReport(t *testing.T) {
+	for _, id := range []string{"disposal-report-1", "disposal-report-2"} {
+		chemicalID := seedUsageChemical(t, id)
+		path := "/api/v1/chemicals/" + chemicalID + "/disposal"
+		sendAs(http.MethodPost, path+"/flag", teacher, nil)
+		sendAs(http.MethodPost, path+"/approve", admin, map[string]interface{}{"method": "Incineration", "vendor": "Veolia"})
+	}
+	sendAs(http.MethodPost, "/api/v1/chemicals/disposal-report-1/disposal/complete", admin, map[string]interface{}{"manifest_number": "M-1"})
+
+	w := sendAs(http.MethodGet, "/api/v1/disposals", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	other := auth.Principal{UserID: "admin-2", School: "Other School", Role: auth.RoleAdmin}
+	w = sendAs(http.MethodGet, "/api/v1/disposals?school=Test%20School", other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/disposals?state=gone", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/disposals", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report controllers.DisposalReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "Test School", report.School)
+	assert.Equal(t, models.DisposalDisposed, report.State)
+	assert.Contains(t, chemicalIDs(report.Chemicals), "disposal-report-1")
+	assert.NotContains(t, chemicalIDs(report.Chemicals), "disposal-report-2")
+	assert.Equal(t, report.Count, len(report.Chemicals))
+	assert.GreaterOrEqual(t, report.ByMethod["Incineration"], 1)
+
+	w = sendAs(http.MethodGet, "/api/v1/disposals?state=pending_pickup", admin, nil)
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Contains(t, chemicalIDs(report.Chemicals), "disposal-report-2")
+
+	w = sendAs(http.MethodGet, "/api/v1/disposals?from=2000-01-01&to=2000-12-31", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 0, report.Count)
+}
+
+// chemicalIDs returns the IDs of the chemicals
+func chemicalIDs(chemicals []models.Chemical) []string {
+	ids := make([]string, len(chemicals))
+	for i, chemical := range chemicals {
+		ids[i] = chemical.ID
+	}
+	return ids
+}
@@ -18,13 +18,14 @@ func TestSendEmail(t *testing.T) {
 
 	// Create request body
 	emailRequest := map[string]interface{}{
-		"body":    "This is a test email.",
+		"body":    "This is a <test> email.\nSecond line",
 		"subject": "Test Subject",
 		"to":      "eshinh@csus.edu",
 	}
 
 	jsonValue, _ := json.Marshal(emailRequest)
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/email/send", bytes.NewReader(jsonValue))
+	authorize(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
@@ -33,4 +34,17 @@ func TestSendEmail(t *testing.T) {
 	// Assertions
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Email sent successfully")
+
+	// The email goes out from the configured sender, the body escaped in its HTML part
+	sent := mail.To("eshinh@csus.edu")
+	if assert.NotEmpty(t, sent) {
+		last := sent[len(sent)-1]
+		assert.Equal(t, "Test Subject", last.Subject)
+		assert.Equal(t, "noreply@chemtrack.test", last.From.Address)
+		if assert.NotNil(t, last.ReplyTo) {
+			assert.Equal(t, "support@chemtrack.test", last.ReplyTo.Address)
+		}
+		assert.Equal(t, "This is a <test> email.\nSecond line", last.Text)
+		assert.Equal(t, "<p>This is a &lt;test&gt; email.<br>\nSecond line</p>", last.HTML)
+	}
 }
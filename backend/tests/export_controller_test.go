@@ -0,0 +1,96 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+var exportTeacher = auth.Principal{UserID: "export-teacher", School: "Export School", Role: auth.RoleUser}
+
+// Test that the inventory exports in every format with locations and hazards, honoring the list filters
+func TestExportChemicals(t *testing.T) {
+	expiration, _ := models.ParseDate("2027-06-30")
+	addSearchChemical(t, master, map[string]interface{}{"name": "Acetone", "CAS": "67-64-1", "school": "Export School",
+		"room": "Prep Room", "cabinet": 1, "shelf": 2, "remaining": "400 mL", "container_size": "500 mL",
+		"expiration_date": expiration.String(), "hazards": map[string]interface{}{"classes": []string{"flammable_liquid"}, "signal_word": "Danger"}})
+	seedChemical(t, models.Chemical{ID: "export-formula", Name: "=HYPERLINK(\"x\")", CAS: "64-17-5", School: "Export School", Status: "Off-site",
+		Notes: "-2+3", SDSURL: "\tcmd"})
+	seedChemical(t, models.Chemical{ID: "export-other", Name: "Glycerol", CAS: "56-81-5", School: "Other Export School"})
+
+	w := sendAs(http.MethodGet, "/api/v1/chemicals/export?sort=name", exportTeacher, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "inventory-export_school-")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		column := map[string]int{}
+		for i, header := range rows[0] {
+			column[header] = i
+		}
+		acetone := rows[2]
+		assert.Equal(t, "'=HYPERLINK(\"x\")", rows[1][column["name"]]) // not run as a formula
+		assert.Equal(t, "'-2+3", rows[1][column["notes"]])
+		assert.Equal(t, "'\tcmd", rows[1][column["sds_url"]])
+		assert.Equal(t, "Acetone", acetone[column["name"]])
+		assert.Equal(t, "Prep Room / Cabinet 1 / Shelf 2", acetone[column["location"]])
+		assert.Equal(t, "400 mL", acetone[column["remaining"]])
+		assert.Equal(t, "2027-06-30", acetone[column["expiration_date"]])
+		assert.Equal(t, "flammable_liquid", acetone[column["hazard_classes"]])
+		assert.Equal(t, "flammable", acetone[column["storage_groups"]])
+		assert.Equal(t, "Danger", acetone[column["signal_word"]])
+	}
+
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/export?format=json&status=off-site", exportTeacher, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var records []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "export-formula", records[0]["id"]) // JSON keeps values as they are
+		assert.Equal(t, []interface{}{}, records[0]["hazard_classes"])
+	}
+
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/export?format=xlsx&school=Other+Export+School", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	workbook, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	if assert.NoError(t, err) {
+		sheet, _ := workbook.GetRows("Inventory")
+		if assert.Len(t, sheet, 2) {
+			assert.Equal(t, "Glycerol", sheet[1][1])
+		}
+	}
+
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/export?school=Other+Export+School", exportTeacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/export?format=pdf", exportTeacher, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/export?sort=colour", exportTeacher, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test that an export reads through every page of a large inventory
+func TestExportChemicals_Pages(t *testing.T) {
+	var batch []*models.Chemical
+	for i := 0; i < 1001; i++ {
+		batch = append(batch, &models.Chemical{Name: fmt.Sprintf("Sample %04d", i), CAS: "7732-18-5", School: "Bulk Export School"})
+	}
+	assert.NoError(t, repos.Chemicals.CreateMany(context.Background(), batch))
+
+	w := sendAs(http.MethodGet, "/api/v1/chemicals/export?format=json&school=Bulk+Export+School&sort=-name", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var records []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+	if assert.Len(t, records, 1001) {
+		assert.Equal(t, "Sample 1000", records[0]["name"])
+		assert.Equal(t, "Sample 0000", records[1000]["name"])
+	}
+}
@@ -3,12 +3,12 @@ package controllers_test
 import (
 	"bytes"
     "mime/multipart"
-    "context"
     "testing"
 	"io"
 	"os"
 	"net/http"
 	"net/http/httptest"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
     "github.com/stretchr/testify/assert"
 )
 
@@ -69,19 +69,18 @@ func createMultipartFormDataForProfile(t *testing.T, filePath string) (*bytes.Bu
 
 // Test case for successful SDS upload
 func TestAddSDS_Success(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing
+	// Seed the repository with a chemical record for testing
 	chemicalID := "12345TestAddSDS_Success"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{"name": "Chemical Test"})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
+	})
 
 	// Mock a file upload request
 	filePath := "./dummy.pdf" 
 	body, writer := createMultipartFormData(t, filePath)
 	req := httptest.NewRequest("POST", "/api/v1/files/sds/12345TestAddSDS_Success", body)
+	authorize(req)
 
 	// Set Content-Type header properly using the writer's FormDataContentType
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -99,17 +98,16 @@ func TestAddSDS_Success(t *testing.T) {
 
 // Test case for failed SDS upload due to missing file
 func TestAddSDS_MissingFile(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing
+	// Seed the repository with a chemical record for testing
 	chemicalID := "12345TestAddSDS_MissingFile"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{"name": "Chemical Test"})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
+	})
 
 	// Mock a POST request without the file
 	req := httptest.NewRequest("POST", "/api/v1/files/sds/12345TestAddSDS_MissingFile", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the POST request)
@@ -120,12 +118,13 @@ func TestAddSDS_MissingFile(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Failed to retrieve file")
 }
 
-// Test case for chemical not found in Firestore
+// Test case for chemical not found in the repository
 func TestAddSDS_ChemicalNotFound(t *testing.T) {
 	// Mock a file upload request
 	filePath := "./dummy.pdf" 
 	body, writer := createMultipartFormData(t, filePath)
 	req := httptest.NewRequest("POST", "/api/v1/files/sds/99999TestAddSDS_ChemicalNotFound", body)
+	authorize(req)
 
 	// Set Content-Type header properly using the writer's FormDataContentType
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -143,20 +142,17 @@ func TestAddSDS_ChemicalNotFound(t *testing.T) {
 
 // Test case for successful retrieval of SDS URL
 func TestGetSDS_Success(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing
+	// Seed the repository with a chemical record for testing
 	chemicalID := "12345TestGetSDS_Success"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{
-		"name":   "Chemical Test",
-		"sdsURL": "https://storage.googleapis.com/chemtrack-deployment/sds/12345TestGetSDS_Success.pdf", // Mock the SDS URL
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
+		SDSURL: "https://storage.googleapis.com/chemtrack-deployment/sds/12345TestGetSDS_Success.pdf", // Mock the SDS URL
 	})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
 
 	// Mock a GET request for the SDS URL
 	req := httptest.NewRequest("GET", "/api/v1/files/sds/12345TestGetSDS_Success", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the GET request)
@@ -172,6 +168,7 @@ func TestGetSDS_Success(t *testing.T) {
 func TestGetSDS_ChemicalNotFound(t *testing.T) {
 	// Mock a GET request for a non-existent chemical
 	req := httptest.NewRequest("GET", "/api/v1/files/sds/99999TestGetSDS_ChemicalNotFound", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the GET request)
@@ -184,19 +181,16 @@ func TestGetSDS_ChemicalNotFound(t *testing.T) {
 
 // Test case for SDS URL not found for the given chemical
 func TestGetSDS_MissingSDSURL(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing (but no SDS URL)
+	// Seed the repository with a chemical record for testing (but no SDS URL)
 	chemicalID := "12345TestGetSDS_MissingSDSURL"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{
-		"name": "Chemical Test", // No SDS URL provided
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
 	})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
 
 	// Mock a GET request for the SDS URL
 	req := httptest.NewRequest("GET", "/api/v1/files/sds/12345TestGetSDS_MissingSDSURL", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the GET request)
@@ -208,20 +202,17 @@ func TestGetSDS_MissingSDSURL(t *testing.T) {
 }
 
 func TestDeleteSDS_Success(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing
+	// Seed the repository with a chemical record for testing
 	chemicalID := "12345TestDeleteSDS_Success"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{
-		"name":   "Chemical Test",
-		"sdsURL": "https://storage.googleapis.com/chemtrack-deployment/sds/12345.pdf", // Mock the SDS URL
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
+		SDSURL: "https://storage.googleapis.com/chemtrack-deployment/sds/12345.pdf", // Mock the SDS URL
 	})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
 
 	// Mock a DELETE request for the SDS file
 	req := httptest.NewRequest("DELETE", "/api/v1/files/sds/12345TestDeleteSDS_Success", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the DELETE request)
@@ -235,6 +226,7 @@ func TestDeleteSDS_Success(t *testing.T) {
 func TestDeleteSDS_ChemicalNotFound(t *testing.T) {
 	// Mock a DELETE request for a non-existent chemical ID
 	req := httptest.NewRequest("DELETE", "/api/v1/files/sds/99999TestDeleteSDS_ChemicalNotFound", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the DELETE request)
@@ -246,19 +238,16 @@ func TestDeleteSDS_ChemicalNotFound(t *testing.T) {
 }
 
 func TestDeleteSDS_FileNotFound(t *testing.T) {
-	// Set up the Firestore collection with a chemical record, but no SDS URL
+	// Seed the repository with a chemical record, but no SDS URL
 	chemicalID := "12345TestDeleteSDS_FileNotFound"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{
-		"name": "Chemical Test",
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
 	})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
 
 	// Mock a DELETE request for the SDS file
 	req := httptest.NewRequest("DELETE", "/api/v1/files/sds/12345TestDeleteSDS_FileNotFound", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	// Call the handler function (route handler for the DELETE request)
@@ -269,42 +258,18 @@ func TestDeleteSDS_FileNotFound(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "SDS file not found for this chemical")
 }
 
-func TestDeleteSDS_InvalidSDSURLFormat(t *testing.T) {
-	// Set up the Firestore collection with a chemical record and an invalid SDS URL format
-	chemicalID := "12345"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{
-		"name":   "Chemical Test",
-		"sdsURL": 12345, // Invalid format (not a string)
-	})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
-
-	// Mock a DELETE request for the SDS file
-	req := httptest.NewRequest("DELETE", "/api/v1/files/sds/12345", nil)
-	w := httptest.NewRecorder()
-
-	// Call the handler function (route handler for the DELETE request)
-	r.ServeHTTP(w, req)
-
-	// Validate the response
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "Invalid SDS URL format")
-}
-
 // Section 2 - Test cases for Profile
 func TestAddProfilePicture_Success(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123"
-	client.Collection("users").Doc("123TestAddProfilePicture_Success").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestAddProfilePicture_Success",
+	// Seed the repository: Create a mock user with ID "123"
+	seedUser(t, models.User{
+		ID: "123TestAddProfilePicture_Success",
 	})
 
 	filePath := "./dummy.pdf"
 	body, writer := createMultipartFormDataForProfile(t, filePath)	
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/profile/123TestAddProfilePicture_Success", body)
+	authorize(req)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	w := httptest.NewRecorder()
@@ -317,15 +282,16 @@ func TestAddProfilePicture_Success(t *testing.T) {
 }
 
 func TestAddProfilePicture_FileNotFound(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123"
-	client.Collection("users").Doc("123TestAddProfilePicture_FileNotFound").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestAddProfilePicture_FileNotFound",
+	// Seed the repository: Create a mock user with ID "123"
+	seedUser(t, models.User{
+		ID: "123TestAddProfilePicture_FileNotFound",
 	})
 
 	// Prepare the request without a file
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/profile/123TestAddProfilePicture_FileNotFound", body)
+	authorize(req)
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
@@ -340,7 +306,7 @@ func TestAddProfilePicture_FileNotFound(t *testing.T) {
 }
 
 func TestAddProfilePicture_UserNotExists(t *testing.T) {
-	// Set up Firestore mocks: Do not create a user with invalid user ID format "user!@#"
+	// Seed the repository: Do not create a user with invalid user ID format "user!@#"
 	
 	// Prepare a dummy file path for testing
 	filePath := "./dummy.pdf"
@@ -348,6 +314,7 @@ func TestAddProfilePicture_UserNotExists(t *testing.T) {
 
 	// Prepare the request: use the body generated from the multipart form
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/profile/9999", body)
+	authorize(req)
 
 	// Set Content-Type header properly using the writer's FormDataContentType
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -365,9 +332,9 @@ func TestAddProfilePicture_UserNotExists(t *testing.T) {
 
 
 func TestUpdateProfilePicture_Success(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123"
-	client.Collection("users").Doc("123TestUpdateProfilePicture_Success").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestUpdateProfilePicture_Success",
+	// Seed the repository: Create a mock user with ID "123"
+	seedUser(t, models.User{
+		ID: "123TestUpdateProfilePicture_Success",
 	})
 
 	filePath := "./dummy.pdf"
@@ -375,6 +342,7 @@ func TestUpdateProfilePicture_Success(t *testing.T) {
 
 	// Prepare the request: use the body generated from the multipart form
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/files/profile/123TestUpdateProfilePicture_Success", body)
+	authorize(req)
 
 	// Set Content-Type header properly using the writer's FormDataContentType
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -388,7 +356,7 @@ func TestUpdateProfilePicture_Success(t *testing.T) {
 
 	// Check that the response contains the success message
 	assert.Contains(t, w.Body.String(), "Profile picture updated successfully")
-	assert.Contains(t, w.Body.String(), "testURL")
+	assert.Contains(t, w.Body.String(), "/blobs/profile_pictures/123TestUpdateProfilePicture_Success.jpg")
 }
 
 func TestUpdateProfilePicture_UserNotFound(t *testing.T) {
@@ -398,6 +366,7 @@ func TestUpdateProfilePicture_UserNotFound(t *testing.T) {
 
 	// Prepare the request with a non-existing user ID
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/files/profile/123TestUpdateProfilePicture_UserNotFound", body)
+	authorize(req)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	w := httptest.NewRecorder()
@@ -409,13 +378,14 @@ func TestUpdateProfilePicture_UserNotFound(t *testing.T) {
 }
 
 func TestUpdateProfilePicture_FileNotProvided(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123"
-	client.Collection("users").Doc("123TestUpdateProfilePicture_FileNotProvided").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestUpdateProfilePicture_FileNotProvided",
+	// Seed the repository: Create a mock user with ID "123"
+	seedUser(t, models.User{
+		ID: "123TestUpdateProfilePicture_FileNotProvided",
 	})
 
 	// Prepare the request without a file
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/files/profile/123TestUpdateProfilePicture_FileNotProvided", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -428,14 +398,15 @@ func TestUpdateProfilePicture_FileNotProvided(t *testing.T) {
 }
 
 func TestDeleteProfilePicture_Success(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123TestDeleteProfilePicture_Success"
-	client.Collection("users").Doc("123TestDeleteProfilePicture_Success").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestDeleteProfilePicture_Success",
-		"profilePictureURL": "https://storage.googleapis.com/chemtrack-testing/profile_pictures/12345.jpg?t=123",
+	// Seed the repository: Create a mock user with ID "123TestDeleteProfilePicture_Success"
+	seedUser(t, models.User{
+		ID:                "123TestDeleteProfilePicture_Success",
+		ProfilePictureURL: "https://storage.googleapis.com/chemtrack-testing/profile_pictures/12345.jpg?t=123",
 	})
 
 	// Prepare the request
 	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/profile/123TestDeleteProfilePicture_Success", nil)
+	authorize(req)
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -451,6 +422,7 @@ func TestDeleteProfilePicture_Success(t *testing.T) {
 func TestDeleteProfilePicture_UserNotFound(t *testing.T) {
 	// Prepare the request for a non-existing user
 	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/profile/123TestDeleteProfilePicture_UserNotFound", nil)
+	authorize(req)
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -462,14 +434,15 @@ func TestDeleteProfilePicture_UserNotFound(t *testing.T) {
 }
 
 func TestDeleteProfilePicture_ProfileNotFound(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123TestDeleteProfilePicture_ProfileNotFound"
-	client.Collection("users").Doc("123TestDeleteProfilePicture_ProfileNotFound").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestDeleteProfilePicture_ProfileNotFound",
+	// Seed the repository: Create a mock user with ID "123TestDeleteProfilePicture_ProfileNotFound"
+	seedUser(t, models.User{
+		ID: "123TestDeleteProfilePicture_ProfileNotFound",
 		// No profilePictureURL field
 	})
 
 	// Prepare the request
 	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/profile/123TestDeleteProfilePicture_ProfileNotFound", nil)
+	authorize(req)
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -482,39 +455,17 @@ func TestDeleteProfilePicture_ProfileNotFound(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Profile picture not found for this user")
 }
 
-func TestDeleteProfilePicture_InvalidProfilePictureURLFormat(t *testing.T) {
-	// Set up Firestore mocks: Create a mock user with ID "123TestDeleteProfilePicture_InvalidFormat"
-	client.Collection("users").Doc("123TestDeleteProfilePicture_InvalidFormat").Set(context.Background(), map[string]interface{}{
-		"userId": "123TestDeleteProfilePicture_InvalidFormat",
-		"profilePictureURL": 12345, // Invalid URL format
-	})
-
-	// Prepare the request
-	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/profile/123TestDeleteProfilePicture_InvalidFormat", nil)
-
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
-
-	// Assertions
-	// Check that the response status is Internal Server Error (500)
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-
-	// Check that the response contains the error message
-	assert.Contains(t, w.Body.String(), "Invalid profile picture URL format")
-}
-
 // Label tests
 func TestAddLabel_Success(t *testing.T) {
-	// Set up the Firestore collection with a chemical record for testing
+	// Seed the repository with a chemical record for testing
 	chemicalID := "12345TestAddLabel_Success"
-	collection := client.Collection("chemicals")
-	docRef := collection.Doc(chemicalID)
-	_, err := docRef.Set(context.Background(), map[string]interface{}{"name": "Chemical Test"})
-	if err != nil {
-		t.Fatalf("Failed to set chemical data in Firestore: %v", err)
-	}
+	seedChemical(t, models.Chemical{
+		ID:     chemicalID,
+		Name:   "Chemical Test",
+	})
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/label/12345TestAddLabel_Success", nil)
+	authorize(req)
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -524,20 +475,48 @@ func TestAddLabel_Success(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Label created and uploaded successfully")
 }
 
+// createLabel adds a chemical and generates its label through the API
+func createLabel(t *testing.T, chemicalID string) {
+	seedChemical(t, models.Chemical{ID: chemicalID, Name: "Chemical Test"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/label/"+chemicalID, nil)
+	authorize(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to create label: %s", w.Body.String())
+	}
+}
+
 func TestGetLabel_Success(t *testing.T) {
+	createLabel(t, "12345TestGetLabel_Success")
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/label/12345TestGetLabel_Success", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	// Assert that the response status code is 200 OK and content type is PDF
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")))
+}
+
+func TestGetLabel_NotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/label/12345TestGetLabel_NotFound", nil)
+	authorize(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Label not found")
 }
 
 func TestDeleteLabel_Success(t *testing.T) {
+	createLabel(t, "12345TestDeleteLabel_Success")
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/label/12345TestDeleteLabel_Success", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
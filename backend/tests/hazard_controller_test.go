@@ -0,0 +1,134 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that hazard codes are canonicalized and unknown ones rejected
+func TestHazards_Normalize(t *testing.T) {
+	hazards, err := models.Hazards{
+		Classes:                 []string{"Flammable_Liquid", "Eye_Damage", "flammable_liquid"},
+		HazardStatements:        []string{"h319", "H225", "H300+H310"},
+		PrecautionaryStatements: []string{"p303 + p361 + p353", "P210"},
+		SignalWord:              "danger",
+		Pictograms:              []string{"ghs07", "GHS02"},
+	}.Normalize()
+	assert.NoError(t, err)
+	assert.Equal(t, models.Hazards{
+		Classes:                 []string{"eye_damage", "flammable_liquid"},
+		HazardStatements:        []string{"H225", "H300", "H310", "H319"},
+		PrecautionaryStatements: []string{"P210", "P303+P361+P353"},
+		SignalWord:              models.SignalDanger,
+		Pictograms:              []string{"GHS02", "GHS07"},
+	}, hazards)
+
+	for _, invalid := range []models.Hazards{
+		{Classes: []string{"radioactive"}},
+		{HazardStatements: []string{"H999"}},
+		{PrecautionaryStatements: []string{"P210+P999"}},
+		{SignalWord: "Caution"},
+		{Pictograms: []string{"GHS10"}},
+	} {
+		_, err := invalid.Normalize()
+		assert.ErrorIs(t, err, models.ErrInvalidHazard, "%+v", invalid)
+	}
+}
+
+// Test that chemicals are created and updated with validated hazard data
+func TestAddChemical_Hazards(t *testing.T) {
+	chemical := map[string]interface{}{
+		"name":   "Acetone",
+		"CAS":    "67-64-1",
+		"school": "Test School",
+		"hazards": map[string]interface{}{
+			"classes":           []string{"flammable_liquid", "eye_damage"},
+			"hazard_statements": []string{"h225", "H319", "H336"},
+			"signal_word":       "danger",
+			"pictograms":        []string{"GHS02", "GHS07"},
+		},
+	}
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", admin, chemical)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Chemical struct {
+			ID      string         `json:"id"`
+			Hazards models.Hazards `json:"hazards"`
+		} `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	chemicalID := created.Chemical.ID
+	assert.Equal(t, []string{"H225", "H319", "H336"}, created.Chemical.Hazards.HazardStatements)
+
+	stored, err := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SignalDanger, stored.Hazards.SignalWord)
+	assert.Equal(t, []string{"eye_damage", "flammable_liquid"}, stored.Hazards.Classes)
+
+	chemical["hazards"] = map[string]interface{}{"pictograms": []string{"GHS11"}}
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, chemical)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{
+		"hazards": map[string]interface{}{"hazard_statements": []string{"H000"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An update replaces the whole classification, and leaves it alone when hazards are absent
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{
+		"hazards": map[string]interface{}{"classes": []string{"eye_damage"}, "signal_word": "Warning"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, admin, map[string]interface{}{"room": "204"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ = repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Equal(t, models.Hazards{Classes: []string{"eye_damage"}, SignalWord: models.SignalWarning}, stored.Hazards)
+}
+
+// Test searching the inventory by hazard class, statement, pictogram and signal word
+func TestGetChemicals_HazardFilters(t *testing.T) {
+	seedChemical(t, models.Chemical{ID: "hazard-acetone", Name: "Acetone", School: "Test School", Hazards: models.Hazards{
+		Classes: []string{"flammable_liquid"}, HazardStatements: []string{"H225", "H319"}, SignalWord: models.SignalDanger, Pictograms: []string{"GHS02", "GHS07"},
+	}})
+	seedChemical(t, models.Chemical{ID: "hazard-bleach", Name: "Bleach", School: "Test School", Hazards: models.Hazards{
+		Classes: []string{"skin_corrosion"}, HazardStatements: []string{"H314"}, SignalWord: models.SignalDanger, Pictograms: []string{"GHS05"},
+	}})
+	seedChemical(t, models.Chemical{ID: "hazard-salt", Name: "Sodium chloride", School: "Test School"})
+
+	search := func(query string) []string {
+		w := sendAs(http.MethodGet, "/api/v1/chemicals?"+query, teacher, nil)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var chemicals []models.Chemical
+		json.Unmarshal(w.Body.Bytes(), &chemicals)
+		return chemicalIDs(chemicals)
+	}
+
+	assert.Contains(t, search("hazard_class=flammable_liquid"), "hazard-acetone")
+	assert.NotContains(t, search("hazard_class=flammable_liquid"), "hazard-bleach")
+	assert.Contains(t, search("hazard_statement=h314"), "hazard-bleach")
+	assert.NotContains(t, search("hazard_statement=H314"), "hazard-acetone")
+	assert.Contains(t, search("pictogram=ghs07"), "hazard-acetone")
+	assert.NotContains(t, search("signal_word=danger"), "hazard-salt")
+	assert.Empty(t, search("pictogram=GHS05&hazard_class=flammable_liquid"))
+
+	for _, query := range []string{"hazard_class=radioactive", "pictogram=GHS10", "signal_word=Caution", "hazard_statement=H999"} {
+		w := sendAs(http.MethodGet, "/api/v1/chemicals?"+query, teacher, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+// Test that the GHS reference table is served
+func TestGetGHSReference(t *testing.T) {
+	w := sendAs(http.MethodGet, "/api/v1/ghs", teacher, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var reference models.GHSReference
+	json.Unmarshal(w.Body.Bytes(), &reference)
+	assert.Len(t, reference.Pictograms, 9)
+	assert.Contains(t, reference.HazardStatements, models.GHSEntry{Code: "H225", Description: "Highly flammable liquid and vapour"})
+	assert.Equal(t, []string{models.SignalDanger, models.SignalWarning}, reference.SignalWords)
+}
@@ -0,0 +1,172 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+var importAdmin = auth.Principal{UserID: "import-admin", School: "Import School", Role: auth.RoleAdmin}
+
+// importFile uploads a file to the import endpoint as the principal
+func importFile(t *testing.T, p auth.Principal, query, name string, data []byte, mapping string) (int, controllers.ImportReport) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write(data)
+	if mapping != "" {
+		form.WriteField("mapping", mapping)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals/import"+query, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	authorizeAs(req, p)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		Report controllers.ImportReport `json:"report"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response.Report
+}
+
+func importErrors(report controllers.ImportReport) map[int][]string {
+	out := map[int][]string{}
+	for _, row := range report.Results {
+		for _, problem := range row.Errors {
+			out[row.Row] = append(out[row.Row], problem.Column)
+		}
+	}
+	return out
+}
+
+// Test that a CSV import previews every row with its errors, then imports all or only the valid rows
+func TestImportChemicals_CSV(t *testing.T) {
+	ctx := context.Background()
+	csv := "\ufeffChemical Name,CAS No,Expiry,Room,Cabinet,Shelf,Container Size,Storage Group,Colour\n" +
+		"Acetone,67-64-1,2027-06-30,Prep Room,1,2,500 mL,flammable,clear\n" +
+		"Ethanol,64175,6/30/2027,Prep Room,1,2,1 L,flammable,clear\n" +
+		",,,,,,,,\n" +
+		"Mystery,64-17-6,someday,Prep Room,one,2,,,\n" +
+		"Acetic acid,64-19-7,,Prep Room,1,2,,acid,clear\n"
+
+	code, report := importFile(t, importAdmin, "?dry_run=true", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, "name", report.Columns["Chemical Name"])
+	assert.Equal(t, "expiration_date", report.Columns["Expiry"])
+	assert.Equal(t, []string{"Colour"}, report.Ignored)
+	assert.Equal(t, 4, report.Rows) // the blank row is skipped
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, map[int][]string{5: {"CAS No", "Expiry", "Cabinet"}}, importErrors(report))
+	if assert.Len(t, report.Results, 4) {
+		ethanol := report.Results[1].Chemical
+		assert.Equal(t, "64-17-5", ethanol.CAS)
+		assert.Equal(t, "2027-06-30", ethanol.ExpirationDate.String())
+		assert.Equal(t, "1 L", ethanol.Remaining.String()) // a new container is full
+		assert.NotEmpty(t, report.Results[3].StorageWarnings) // acids next to flammables are a warning only
+	}
+	stored, _ := repos.Chemicals.List(ctx, repository.ChemicalFilter{School: "Import School"})
+	assert.Empty(t, stored)
+
+	// Invalid rows stop the import unless they are skipped
+	code, _ = importFile(t, importAdmin, "", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	stored, _ = repos.Chemicals.List(ctx, repository.ChemicalFilter{School: "Import School"})
+	assert.Empty(t, stored)
+
+	code, report = importFile(t, importAdmin, "?skip_invalid=true", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, report.Imported)
+	stored, _ = repos.Chemicals.List(ctx, repository.ChemicalFilter{School: "Import School", Sort: repository.SortByName})
+	if assert.Len(t, stored, 3) {
+		assert.Equal(t, "Acetone", stored[1].Name)
+		assert.NotEmpty(t, stored[1].LocationID) // filed under the location of its room, cabinet and shelf
+		assert.Equal(t, stored[1].LocationID, stored[2].LocationID)
+		assert.Equal(t, stored[1].ID, report.Results[0].Chemical.ID)
+		assert.Len(t, historyOf(t, stored[1].ID, importAdmin), 1)
+	}
+
+	// Teachers cannot import, and admins only into their own school
+	code, _ = importFile(t, teacher, "", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = importFile(t, importAdmin, "?school=Test+School", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// A master without a school has to say which one to import into
+	district := auth.Principal{UserID: "district-master", Role: auth.RoleMaster}
+	code, _ = importFile(t, district, "?dry_run=true", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = importFile(t, master, "?school=&dry_run=true", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, report = importFile(t, district, "?school=Import+School&dry_run=true", "inventory.csv", []byte(csv), "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, report.Valid)
+}
+
+// Test an Excel import with a column mapping, Excel dates and a missing column
+func TestImportChemicals_XLSX(t *testing.T) {
+	workbook := excelize.NewFile()
+	sheet := workbook.GetSheetName(0)
+	workbook.SetSheetRow(sheet, "A1", &[]interface{}{"Bottle", "CAS", "Expiration date", "Notes"})
+	workbook.SetSheetRow(sheet, "A2", &[]interface{}{"Glycerol", "56-81-5", 46203, "Top shelf"})
+	workbook.SetSheetRow(sheet, "A3", &[]interface{}{"Glucose", 50997, "2028-01-01", ""})
+	var data bytes.Buffer
+	workbook.Write(&data)
+
+	code, _ := importFile(t, importAdmin, "?dry_run=true", "stock.xlsx", data.Bytes(), "")
+	assert.Equal(t, http.StatusBadRequest, code) // no name column without the mapping
+
+	code, report := importFile(t, importAdmin, "?dry_run=true", "stock.xlsx", data.Bytes(), `{"Bottle": "name"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, report.Valid)
+	if assert.Len(t, report.Results, 2) {
+		assert.Equal(t, "2026-06-30", report.Results[0].Chemical.ExpirationDate.String())
+		assert.Equal(t, "Top shelf", report.Results[0].Chemical.Notes)
+		assert.Equal(t, "50-99-7", report.Results[1].Chemical.CAS)
+	}
+
+	code, _ = importFile(t, importAdmin, "", "stock.xlsx", data.Bytes(), `{"Bottle": "volume"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = importFile(t, importAdmin, "", "stock.pdf", data.Bytes(), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// Test that chemicals are created together or not at all
+func TestRepositoryChemicals_CreateMany(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			batch := []*models.Chemical{
+				{Name: "Acetone", CAS: "67-64-1", School: "Batch School"},
+				{Name: "Ethanol", CAS: "64-17-5", School: "Batch School"},
+			}
+			assert.NoError(t, backend.Chemicals.CreateMany(ctx, batch))
+			assert.NotEmpty(t, batch[0].ID)
+			assert.NotEqual(t, batch[0].ID, batch[1].ID)
+
+			clash := []*models.Chemical{
+				{Name: "Glycerol", CAS: "56-81-5", School: "Batch School"},
+				{ID: batch[0].ID, Name: "Acetone", CAS: "67-64-1", School: "Batch School"},
+			}
+			assert.Error(t, backend.Chemicals.CreateMany(ctx, clash))
+			stored, err := backend.Chemicals.List(ctx, repository.ChemicalFilter{School: "Batch School"})
+			assert.NoError(t, err)
+			assert.Len(t, stored, 2)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// createLocation adds a location as the principal and returns it
+func createLocation(t *testing.T, p auth.Principal, body map[string]interface{}) models.Location {
+	t.Helper()
+	w := sendAs(http.MethodPost, "/api/v1/locations", p, body)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var location models.Location
+	json.Unmarshal(w.Body.Bytes(), &location)
+	return location
+}
+
+// Test building a hierarchy and the rules on where each kind of location goes
+func TestLocations_Hierarchy(t *testing.T) {
+	harborAdmin := auth.Principal{UserID: "harbor-admin", School: "Harbor School", Role: auth.RoleAdmin}
+
+	// Only masters add schools
+	w := sendAs(http.MethodPost, "/api/v1/locations", admin, map[string]interface{}{"kind": "school", "name": "Harbor School"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	school := createLocation(t, master, map[string]interface{}{"kind": "school", "name": "Harbor School"})
+
+	room := createLocation(t, harborAdmin, map[string]interface{}{"kind": "room", "name": "Room 101", "parent_id": school.ID,
+		"attributes": []string{"Ventilated", "ventilated"}})
+	assert.Equal(t, "Harbor School", room.School)
+	assert.Equal(t, []string{"ventilated"}, room.Attributes)
+
+	// The same room spelled differently is refused
+	w = sendAs(http.MethodPost, "/api/v1/locations", harborAdmin, map[string]interface{}{"kind": "room", "name": "rm 101", "parent_id": school.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	// Shelves go in cabinets
+	w = sendAs(http.MethodPost, "/api/v1/locations", harborAdmin, map[string]interface{}{"kind": "shelf", "name": "Shelf 1", "parent_id": room.ID})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Other schools are off limits
+	w = sendAs(http.MethodPost, "/api/v1/locations", admin, map[string]interface{}{"kind": "cabinet", "name": "Cabinet 1", "parent_id": room.ID})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/locations/"+room.ID, admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	cabinet := createLocation(t, harborAdmin, map[string]interface{}{"kind": "cabinet", "name": "Cabinet 4", "parent_id": room.ID})
+	createLocation(t, harborAdmin, map[string]interface{}{"kind": "shelf", "name": "Shelf 1", "parent_id": cabinet.ID})
+
+	w = sendAs(http.MethodGet, "/api/v1/locations/"+cabinet.ID, harborAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var details controllers.LocationDetails
+	json.Unmarshal(w.Body.Bytes(), &details)
+	assert.Equal(t, cabinet.ID, details.ID)
+	if assert.Len(t, details.Path, 2) {
+		assert.Equal(t, school.ID, details.Path[0].ID)
+		assert.Equal(t, room.ID, details.Path[1].ID)
+	}
+	assert.Len(t, details.Children, 1)
+
+	w = sendAs(http.MethodGet, "/api/v1/locations?kind=cabinet", harborAdmin, nil)
+	var cabinets []models.Location
+	json.Unmarshal(w.Body.Bytes(), &cabinets)
+	assert.Len(t, cabinets, 1)
+
+	// The new school is listed with the schools of users
+	w = sendAs(http.MethodGet, "/api/v1/users/schools", master, nil)
+	assert.Contains(t, w.Body.String(), "Harbor School")
+
+	// Non-empty locations cannot be deleted, empty ones can
+	w = sendAs(http.MethodDelete, "/api/v1/locations/"+cabinet.ID, harborAdmin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	empty := createLocation(t, harborAdmin, map[string]interface{}{"kind": "room", "name": "Store", "parent_id": school.ID})
+	w = sendAs(http.MethodDelete, "/api/v1/locations/"+empty.ID, harborAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// Test placing chemicals by location, capacity limits and renames reaching the chemicals
+func TestLocations_Chemicals(t *testing.T) {
+	ctx := context.Background()
+	admin := auth.Principal{UserID: "valley-admin", School: "Valley School", Role: auth.RoleAdmin}
+	teacher := auth.Principal{UserID: "valley-teacher", School: "Valley School", Role: auth.RoleUser}
+	school := createLocation(t, master, map[string]interface{}{"kind": "school", "name": "Valley School"})
+	room := createLocation(t, admin, map[string]interface{}{"kind": "room", "name": "Prep Room", "parent_id": school.ID})
+	cabinet := createLocation(t, admin, map[string]interface{}{"kind": "cabinet", "name": "Cabinet 7", "parent_id": room.ID, "capacity": 1})
+	shelf := createLocation(t, admin, map[string]interface{}{"kind": "shelf", "name": "Shelf 2", "parent_id": cabinet.ID})
+
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{"name": "Acetic acid", "CAS": "64-19-7", "location_id": shelf.ID})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var created struct {
+		Chemical struct {
+			ID string `json:"id"`
+		} `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	stored, _ := repos.Chemicals.Get(ctx, created.Chemical.ID)
+	assert.Equal(t, shelf.ID, stored.LocationID)
+	assert.Equal(t, "Prep Room", stored.Room)
+	assert.Equal(t, 7, stored.Cabinet)
+	assert.Equal(t, 2, stored.Shelf)
+
+	// The cabinet only holds one container
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{"name": "Acetic acid", "CAS": "64-19-7", "location_id": cabinet.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	// Chemicals are not kept in a school directly
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{"name": "Acetic acid", "CAS": "64-19-7", "location_id": school.ID})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Chemicals given by room, cabinet and shelf are filed under the matching location
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{"name": "Acetic acid", "CAS": "64-19-7", "room": "prep room", "cabinet": 8})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &created)
+	filed, _ := repos.Chemicals.Get(ctx, created.Chemical.ID)
+	placed, _ := repos.Locations.Get(ctx, filed.LocationID)
+	assert.Equal(t, "Cabinet 8", placed.Name)
+	assert.Equal(t, room.ID, placed.ParentID)
+
+	// Renaming the room moves its chemicals with it
+	w = sendAs(http.MethodPut, "/api/v1/locations/"+room.ID, admin, map[string]interface{}{"name": "Chemistry Prep"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ = repos.Chemicals.Get(ctx, stored.ID)
+	assert.Equal(t, "Chemistry Prep", stored.Room)
+	assert.Equal(t, []models.FieldChange{{Field: "room", Before: "Prep Room", After: "Chemistry Prep"}}, historyOf(t, stored.ID, admin)[0].Changes)
+
+	w = sendAs(http.MethodPut, "/api/v1/locations/"+school.ID, master, map[string]interface{}{"name": "Renamed School"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodPut, "/api/v1/locations/"+room.ID, teacher, map[string]interface{}{"capacity": 5})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
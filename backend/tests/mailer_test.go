@@ -0,0 +1,192 @@
+package controllers_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+)
+
+// Test the defaults the capture transport fills in from the sender of the deployment
+func TestCaptureMailer(t *testing.T) {
+	ctx := context.Background()
+	_, err := mailer.ParseSender("not an address", "")
+	assert.Error(t, err)
+	sender, err := mailer.ParseSender("Lab Office <lab@example.org>", "")
+	assert.NoError(t, err)
+	assert.Nil(t, sender.ReplyTo)
+
+	capture := mailer.NewCapture(sender)
+	assert.NoError(t, capture.Send(ctx, mailer.Message{To: "Ana <ana@example.org>", Subject: "Hello", HTML: "<p>Hi <b>Ana</b></p>"}))
+	reply := &netmail.Address{Address: "replies@example.org"}
+	assert.NoError(t, capture.Send(ctx, mailer.Message{To: "ben@example.org", ReplyTo: reply, Subject: "Plain", HTML: "<p>Hi</p>", Text: "Hi Ben"}))
+	err = capture.Send(ctx, mailer.Message{To: "nobody", Subject: "Lost"})
+	assert.True(t, errors.Is(err, mailer.ErrNoRecipient))
+
+	sent := capture.Messages()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "ana@example.org", sent[0].To)
+		assert.Equal(t, netmail.Address{Name: "Lab Office", Address: "lab@example.org"}, sent[0].From)
+		assert.Equal(t, "Hi Ana\n", sent[0].Text)
+		assert.Equal(t, "replies@example.org", sent[1].ReplyTo.Address)
+		assert.Equal(t, "Hi Ben", sent[1].Text)
+	}
+	capture.Reset()
+	assert.Empty(t, capture.Messages())
+}
+
+// smtpServer accepts one email over SMTP and hands its data to received
+func smtpServer(t *testing.T, received chan<- []byte) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 test ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 test")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- data
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// Test that the SMTP transport sends a multipart email with the headers of the deployment
+func TestSMTPMailer(t *testing.T) {
+	received := make(chan []byte, 1)
+	host, port := smtpServer(t, received)
+	sender, err := mailer.ParseSender("ChemTrack <alerts@example.org>", "Science Office <science@example.org>")
+	assert.NoError(t, err)
+	transport := mailer.NewSMTP(mailer.SMTPConfig{Host: host, Port: port}, sender)
+
+	err = transport.Send(context.Background(), mailer.Message{To: "admin@example.org", Subject: "Alertas críticas",
+		HTML: "<h1>Alerts</h1><p>Ethanol is low.</p>", Text: "Alerts\n\nEthanol is low.\n"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	msg, err := netmail.ReadMessage(bufio.NewReader(bytes.NewReader(<-received)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `"ChemTrack" <alerts@example.org>`, msg.Header.Get("From"))
+	assert.Equal(t, "admin@example.org", msg.Header.Get("To"))
+	assert.Equal(t, `"Science Office" <science@example.org>`, msg.Header.Get("Reply-To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Alertas críticas", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	assert.Equal(t, "Alerts\n\nEthanol is low.\n", parts["text/plain"])
+	assert.Equal(t, "<h1>Alerts</h1><p>Ethanol is low.</p>", parts["text/html"])
+}
+
+// Test that the SMTP transport gives up on a server that accepts the connection but never answers
+func TestSMTPMailerTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	sender, _ := mailer.ParseSender("ChemTrack <alerts@example.org>", "")
+	transport := mailer.NewSMTP(mailer.SMTPConfig{Host: addr.IP.String(), Port: addr.Port, Timeout: 200 * time.Millisecond}, sender)
+
+	start := time.Now()
+	err = transport.Send(context.Background(), mailer.Message{To: "admin@example.org", Subject: "Alerts", Text: "Ethanol is low."})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// Test that a password reset is emailed in the language the app asks for
+func TestForgotPasswordEmail(t *testing.T) {
+	seedUser(t, models.User{ID: "reset-user", Email: "reset@example.com", School: "Test School"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/forgot-password", strings.NewReader(`{"email":"reset@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es-MX,es;q=0.9")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	sent := mail.To("reset@example.com")
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Restablecimiento de contraseña de ChemTrack", sent[0].Subject)
+		assert.Contains(t, sent[0].Text, "caducará en 15 minutos")
+		assert.Contains(t, sent[0].HTML, "chemtrack://resetPassword?token=")
+		assert.Equal(t, "support@chemtrack.test", sent[0].ReplyTo.Address)
+	}
+
+	// Nothing is sent for an unknown address
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/forgot-password", strings.NewReader(`{"email":"missing@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, mail.To("missing@example.com"))
+}
@@ -0,0 +1,169 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/notify"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/services"
+)
+
+// Test that every template renders each part in every language
+func TestRenderNotifications(t *testing.T) {
+	templates := notify.Default()
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"en", "es"}, templates.Locales())
+
+	for _, name := range notify.Templates() {
+		data, ok := notify.Sample(name, now)
+		if !assert.True(t, ok, name) {
+			continue
+		}
+		english, err := templates.Render("", data)
+		assert.NoError(t, err, name)
+		spanish, err := templates.Render("es", data)
+		assert.NoError(t, err, name)
+
+		for _, msg := range []notify.Message{english, spanish} {
+			assert.Equal(t, name, msg.Template)
+			assert.NotEmpty(t, msg.Subject, name)
+			assert.NotEmpty(t, msg.PushTitle, name)
+			assert.NotEmpty(t, msg.PushBody, name)
+			assert.Contains(t, msg.HTML, `<html lang="`+msg.Locale+`">`)
+			assert.NotContains(t, msg.Text, "<", name)
+			assert.NotContains(t, msg.Text, "%!", name)
+			assert.NotContains(t, msg.HTML, "%!", name)
+		}
+		assert.Equal(t, "en", english.Locale)
+		assert.Equal(t, "es", spanish.Locale)
+		assert.NotEqual(t, english.Subject, spanish.Subject, name)
+	}
+
+	// Regional variants use their language, and others the default one
+	assert.Equal(t, "es", templates.Locale("es-MX"))
+	assert.Equal(t, "en", templates.Locale("fr"))
+	assert.Equal(t, "es", templates.Negotiate("fr-FR,es-MX;q=0.8,en;q=0.5"))
+	assert.Equal(t, "en", templates.Negotiate(""))
+	_, err := notify.NewRenderer("xx")
+	assert.ErrorIs(t, err, notify.ErrUnknownLocale)
+	spanish, err := notify.NewRenderer("es")
+	assert.NoError(t, err)
+	msg, _ := spanish.Render("", notify.PasswordResetData{Token: "abc", URL: "chemtrack://resetPassword?token=abc", ExpiresIn: time.Minute})
+	assert.Equal(t, "Restablecimiento de contraseña de ChemTrack", msg.Subject)
+	assert.Contains(t, msg.Text, "Este código caducará en 1 minuto.")
+
+	// Data is escaped in the HTML only
+	expires := models.NewDate(now.AddDate(0, 0, 1))
+	msg, err = templates.Render("en", notify.AlertsData{School: "North <High>", Now: now, Alerts: []models.Alert{
+		{Kind: models.AlertExpiring, Severity: models.SeverityWarning, School: "North <High>", ChemicalName: "Acid & Base", ExpirationDate: &expires},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Chemical Alert Report for North <High>", msg.Subject)
+	assert.Equal(t, "Acid & Base expires tomorrow", msg.PushBody)
+	assert.Contains(t, msg.HTML, "Acid &amp; Base expires tomorrow")
+	assert.Contains(t, msg.HTML, "North &lt;High&gt;")
+	assert.Contains(t, msg.Text, "There is 1 new or escalated chemical alert at North <High>.")
+	assert.Contains(t, msg.Text, "- Chemical: Acid & Base\n- School: North <High>\n")
+}
+
+func TestPlainText(t *testing.T) {
+	html := `<html><head><style>p { color: red; }</style></head><body>
+		<h2>Transfer offered</h2>
+		<p>North &amp; South offered <strong>Ethanol</strong>.<br>Accept it in the app.</p>
+		<ul><li>250 mL</li><li>Room 12</li></ul></body></html>`
+	assert.Equal(t, "Transfer offered\n\nNorth & South offered Ethanol.\nAccept it in the app.\n\n- 250 mL\n- Room 12\n", notify.PlainText(html))
+	assert.Equal(t, "Just text\n", notify.PlainText("Just text"))
+}
+
+// Test that the monitor sends the alerts of a school once, and digests them
+func TestChemicalMonitor(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemory()
+	for _, chemical := range alertChemicals(t, "Monitor School", time.Now()) {
+		chemical := chemical
+		assert.NoError(t, store.Chemicals.Create(ctx, &chemical))
+	}
+	admin := models.User{Email: "monitor-admin@example.com", School: "Monitor School", IsAdmin: true, AllowEmail: true}
+	assert.NoError(t, store.Users.Create(ctx, &admin))
+	sent := mailer.NewCapture(mailer.Sender{})
+	monitor := services.NewChemicalMonitor(store, notify.Default(), sent)
+
+	assert.NoError(t, monitor.CheckCriticalChemicalStatus(ctx))
+	found, err := store.Alerts.List(ctx, repository.AlertFilter{School: "Monitor School"})
+	assert.NoError(t, err)
+	assert.Len(t, found, 4)
+	notified := map[string]time.Time{}
+	for _, alert := range found {
+		if assert.NotNil(t, alert.NotifiedAt, alert.Key()) {
+			notified[alert.ID] = *alert.NotifiedAt
+		}
+	}
+
+	assert.NoError(t, monitor.CheckCriticalChemicalStatus(ctx))
+	found, _ = store.Alerts.List(ctx, repository.AlertFilter{School: "Monitor School"})
+	assert.Len(t, found, 4)
+	for _, alert := range found {
+		assert.True(t, alert.NotifiedAt.Equal(notified[alert.ID]), "%s sent again", alert.Key())
+	}
+	assert.Len(t, sent.To("monitor-admin@example.com"), 1)
+	assert.NoError(t, monitor.SendAlertDigest(ctx))
+	assert.Len(t, sent.To("monitor-admin@example.com"), 2)
+
+	// Each recipient reads the digest in their own language
+	spanish := models.User{Email: "monitor-es@example.com", School: "Monitor School", IsAdmin: true, AllowEmail: true, Locale: "es"}
+	assert.NoError(t, store.Users.Create(ctx, &spanish))
+	assert.NoError(t, monitor.SendAlertDigest(ctx))
+	english, translated := sent.To("monitor-admin@example.com"), sent.To("monitor-es@example.com")
+	if assert.Len(t, english, 3) && assert.Len(t, translated, 1) {
+		assert.Equal(t, "Weekly chemical alert digest for Monitor School", english[2].Subject)
+		assert.Equal(t, "Resumen semanal de alertas de sustancias químicas de Monitor School", translated[0].Subject)
+	}
+}
+
+// Test the routes admins use to preview the notification templates
+func TestNotificationPreviewRoutes(t *testing.T) {
+	w := sendAs(http.MethodGet, "/api/v1/notifications/templates", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Templates     []string `json:"templates"`
+		Locales       []string `json:"locales"`
+		DefaultLocale string   `json:"default_locale"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.Equal(t, notify.Templates(), listed.Templates)
+	assert.Contains(t, listed.Locales, "es")
+	assert.Equal(t, "en", listed.DefaultLocale)
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/alerts/preview?locale=es-MX", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var msg notify.Message
+	json.Unmarshal(w.Body.Bytes(), &msg)
+	assert.Equal(t, "es", msg.Locale)
+	assert.Contains(t, msg.Subject, "Informe de alertas")
+	assert.NotEmpty(t, msg.Text)
+
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/password_reset/preview?format=html", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Open in App")
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/digest/preview?format=text", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/digest/preview?format=pdf", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/newsletter/preview", admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/notifications/templates/invitation/preview", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,211 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	master  = auth.Principal{UserID: "master-1", School: "Test School", Role: auth.RoleMaster}
+	admin   = auth.Principal{UserID: "admin-1", School: "Test School", Role: auth.RoleAdmin}
+	teacher = auth.Principal{UserID: "teacher-1", School: "Test School", Role: auth.RoleUser}
+	nobody  = auth.Principal{}
+)
+
+func TestPolicy_Chemicals(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal auth.Principal
+		school    string
+		view      bool
+		manage    bool
+		logUsage  bool
+	}{
+		{"master own school", master, "Test School", true, true, true},
+		{"master other school", master, "Other School", true, true, true},
+		{"admin own school", admin, "Test School", true, true, true},
+		{"admin other school", admin, "Other School", false, false, false},
+		{"user own school", teacher, "Test School", true, false, true},
+		{"user other school", teacher, "Other School", false, false, false},
+		{"anonymous", nobody, "", false, false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.view, policy.CanViewSchool(tc.principal, tc.school))
+			assert.Equal(t, tc.manage, policy.CanManageChemicals(tc.principal, tc.school))
+			assert.Equal(t, tc.logUsage, policy.CanLogUsage(tc.principal, tc.school))
+		})
+	}
+}
+
+func TestPolicy_Users(t *testing.T) {
+	sameSchoolUser := policy.Subject{ID: "user-2", School: "Test School"}
+	otherSchoolUser := policy.Subject{ID: "user-3", School: "Other School"}
+	masterUser := policy.Subject{ID: "master-2", School: "Test School", IsMaster: true}
+	self := policy.Subject{ID: teacher.UserID, School: "Test School"}
+
+	// masters manage everyone
+	assert.True(t, policy.CanManageUser(master, otherSchoolUser))
+	assert.True(t, policy.CanManageUser(master, masterUser))
+
+	// admins manage their own school, but never masters
+	assert.True(t, policy.CanManageUser(admin, sameSchoolUser))
+	assert.False(t, policy.CanManageUser(admin, otherSchoolUser))
+	assert.False(t, policy.CanManageUser(admin, masterUser))
+
+	// regular users can only edit their own profile
+	assert.False(t, policy.CanManageUser(teacher, sameSchoolUser))
+	assert.True(t, policy.CanEditProfile(teacher, self))
+	assert.False(t, policy.CanEditProfile(teacher, sameSchoolUser))
+	assert.True(t, policy.CanViewUser(teacher, sameSchoolUser))
+	assert.False(t, policy.CanViewUser(teacher, otherSchoolUser))
+}
+
+func TestPolicy_AssignUser(t *testing.T) {
+	// sign up can only create regular users
+	assert.True(t, policy.CanAssignUser(nobody, "Test School", false, false))
+	assert.False(t, policy.CanAssignUser(nobody, "Test School", true, false))
+	assert.False(t, policy.CanAssignUser(nobody, "Test School", false, true))
+
+	// regular users cannot create users or promote anyone, themselves included
+	assert.False(t, policy.CanAssignUser(teacher, "Test School", false, false))
+	assert.False(t, policy.CanAssignUser(teacher, "Test School", true, false))
+
+	// admins can create admins in their own school only, and never masters
+	assert.True(t, policy.CanAssignUser(admin, "Test School", true, false))
+	assert.False(t, policy.CanAssignUser(admin, "Other School", false, false))
+	assert.False(t, policy.CanAssignUser(admin, "Test School", false, true))
+
+	assert.True(t, policy.CanAssignUser(master, "Other School", true, true))
+}
+
+func TestPolicy_ListSchool(t *testing.T) {
+	school, err := policy.ListSchool(master, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", school)
+
+	school, err = policy.ListSchool(teacher, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test School", school)
+
+	_, err = policy.ListSchool(admin, "Other School")
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+}
+
+// Test that sign up cannot be used to create an admin
+func TestAddUser_SignUpCannotAssignRoles(t *testing.T) {
+	user := User{
+		First:    "Sneaky",
+		Last:     "Admin",
+		Email:    "sneaky.admin@example.com",
+		Password: "password123",
+		School:   "Test School",
+		IsAdmin:  true,
+	}
+
+	jsonValue, _ := json.Marshal(user)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(jsonValue))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Test that a regular user cannot list another school's chemicals
+func TestGetChemicals_OtherSchoolForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals?school=Other%20School", nil)
+	authorizeAs(req, teacher)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Test that requests without a token are rejected
+func TestGetChemicals_Unauthenticated(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// Test that only admins and masters can send emails
+func TestSendEmail_UserForbidden(t *testing.T) {
+	emailRequest := map[string]interface{}{
+		"body":    "This is a test email.",
+		"subject": "Test Subject",
+		"to":      "someone@example.com",
+	}
+
+	jsonValue, _ := json.Marshal(emailRequest)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/email/send", bytes.NewReader(jsonValue))
+	authorizeAs(req, teacher)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Test that a regular user can read but not delete a chemical of their school
+func TestDeleteChemical_UserForbidden(t *testing.T) {
+	chemicalID := seedChemical(t, models.Chemical{
+		Name:   "Policy Chemical",
+		School: "Test School",
+	})
+
+	reqGet := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+chemicalID, nil)
+	authorizeAs(reqGet, teacher)
+	wGet := httptest.NewRecorder()
+	r.ServeHTTP(wGet, reqGet)
+	assert.Equal(t, http.StatusOK, wGet.Code)
+
+	reqDel := httptest.NewRequest(http.MethodDelete, "/api/v1/chemicals/"+chemicalID, nil)
+	authorizeAs(reqDel, teacher)
+	wDel := httptest.NewRecorder()
+	r.ServeHTTP(wDel, reqDel)
+	assert.Equal(t, http.StatusForbidden, wDel.Code)
+}
+
+// Test that an admin cannot read a chemical from another school
+func TestGetChemical_AdminOtherSchoolForbidden(t *testing.T) {
+	chemicalID := seedChemical(t, models.Chemical{
+		Name:   "Other School Chemical",
+		School: "Other School",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+chemicalID, nil)
+	authorizeAs(req, admin)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Test that a regular user cannot make themselves master
+func TestUpdateUser_SelfPromotionForbidden(t *testing.T) {
+	seedUser(t, models.User{
+		ID:     teacher.UserID,
+		First:  "Regular",
+		Last:   "Teacher",
+		Email:  "regular.teacher@example.com",
+		School: "Test School",
+	})
+
+	jsonValue, _ := json.Marshal(User{IsMaster: true})
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/"+teacher.UserID, bytes.NewReader(jsonValue))
+	authorizeAs(req, teacher)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,768 @@
+package controllers_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// repositoryBackends returns an empty set of repositories for every storage backend that runs offline
+func repositoryBackends(t *testing.T) map[string]repository.Repositories {
+	t.Helper()
+	db, err := repository.OpenSQL(context.Background(), repository.SQLite, filepath.Join(t.TempDir(), "chemtrack.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return map[string]repository.Repositories{
+		"memory": repository.NewMemory(),
+		"sqlite": repository.NewSQL(db, repository.SQLite),
+	}
+}
+
+// Test the create, read, update and delete cycle of the chemical repository
+func TestRepositoryChemicals_CRUD(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemicals := backend.Chemicals
+
+			expirationDate, _ := models.ParseDate("2026-01-31")
+			chemical := models.Chemical{
+				Name:           "Acetone",
+				Synonyms:       []string{"Propan-2-one", "Dimethyl ketone, technical"},
+				School:         "Test School",
+				ExpirationDate: expirationDate,
+				Remaining:      models.Quantity{Amount: 250, Unit: models.Milliliter},
+				Notes:          "Opened in March",
+			}
+			assert.NoError(t, chemicals.Create(ctx, &chemical))
+			assert.NotEmpty(t, chemical.ID)
+
+			stored, err := chemicals.Get(ctx, chemical.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, chemical, stored)
+
+			stored.Status = "Good"
+			assert.NoError(t, chemicals.Update(ctx, stored))
+			updated, _ := chemicals.Get(ctx, chemical.ID)
+			assert.Equal(t, "Good", updated.Status)
+
+			assert.NoError(t, chemicals.Delete(ctx, chemical.ID))
+			_, err = chemicals.Get(ctx, chemical.ID)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test that missing and duplicate records are reported with the repository errors
+func TestRepositoryChemicals_Errors(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemicals := backend.Chemicals
+
+			assert.ErrorIs(t, chemicals.Update(ctx, models.Chemical{ID: "missing"}), repository.ErrNotFound)
+			assert.ErrorIs(t, chemicals.Delete(ctx, "missing"), repository.ErrNotFound)
+
+			assert.NoError(t, chemicals.Create(ctx, &models.Chemical{ID: "dup"}))
+			assert.ErrorIs(t, chemicals.Create(ctx, &models.Chemical{ID: "dup"}), repository.ErrAlreadyExists)
+		})
+	}
+}
+
+// Test that listing filters by school and orders by ID
+func TestRepositoryChemicals_ListFilter(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemicals := backend.Chemicals
+
+			chemicals.Create(ctx, &models.Chemical{ID: "b", School: "Test School"})
+			chemicals.Create(ctx, &models.Chemical{ID: "a", School: "Test School"})
+			chemicals.Create(ctx, &models.Chemical{ID: "c", School: "Other School"})
+
+			all, err := chemicals.List(ctx, repository.ChemicalFilter{})
+			assert.NoError(t, err)
+			assert.Len(t, all, 3)
+
+			school, err := chemicals.List(ctx, repository.ChemicalFilter{School: "Test School"})
+			assert.NoError(t, err)
+			if assert.Len(t, school, 2) {
+				assert.Equal(t, "a", school[0].ID)
+				assert.Equal(t, "b", school[1].ID)
+			}
+		})
+	}
+}
+
+// Test sorting and paging chemicals and users with cursors
+func TestRepositoryChemicals_Pages(t *testing.T) {
+	ctx := context.Background()
+	date := func(s string) models.Date { d, _ := models.ParseDate(s); return d }
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemicals := backend.Chemicals
+			chemicals.Create(ctx, &models.Chemical{ID: "1", Name: "ethanol", CAS: "64-17-5", School: "Test School", Room: "B", Status: "Good", ExpirationDate: date("2027-03-01")})
+			chemicals.Create(ctx, &models.Chemical{ID: "2", Name: "Acetone", CAS: "67-64-1", School: "Test School", Room: "A", Cabinet: 2, Status: "Good"})
+			chemicals.Create(ctx, &models.Chemical{ID: "3", Name: "Benzene", CAS: "71-43-2", School: "Test School", Room: "a", Cabinet: 1, Status: "Fair", ExpirationDate: date("2026-01-01"),
+				Remaining: models.Quantity{Amount: 10, Unit: models.Milliliter}, ReorderThreshold: models.Quantity{Amount: 50, Unit: models.Milliliter}})
+			chemicals.Create(ctx, &models.Chemical{ID: "4", Name: "acetic acid", CAS: "64-19-7", School: "Test School", Room: "B", Status: "Good", ExpirationDate: date("2027-03-01")})
+
+			// Page through every sort order two chemicals at a time, in both directions
+			for sort, want := range map[repository.ChemicalSort][]string{
+				repository.SortByID:         {"1", "2", "3", "4"},
+				repository.SortByName:       {"4", "2", "3", "1"},
+				repository.SortByExpiration: {"3", "1", "4", "2"},
+				repository.SortByCAS:        {"1", "4", "2", "3"},
+				repository.SortByLocation:   {"3", "2", "1", "4"},
+			} {
+				for _, desc := range []bool{false, true} {
+					filter := repository.ChemicalFilter{School: "Test School", Sort: sort, Descending: desc, Limit: 2}
+					var got []string
+					for i := 0; i < 3; i++ {
+						page, err := chemicals.List(ctx, filter)
+						assert.NoError(t, err)
+						got = append(got, chemicalIDs(page)...)
+						if len(page) < 2 {
+							break
+						}
+						filter.After = repository.ChemicalCursor(filter, page[len(page)-1])
+					}
+					expected := append([]string(nil), want...)
+					if desc {
+						for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+							expected[i], expected[j] = expected[j], expected[i]
+						}
+					}
+					assert.Equal(t, expected, got, "sort %q descending %v", sort, desc)
+				}
+			}
+
+			// A cursor only works with the sort order it was issued for
+			byName := repository.ChemicalFilter{Sort: repository.SortByName}
+			first, _ := chemicals.List(ctx, byName)
+			_, err := chemicals.List(ctx, repository.ChemicalFilter{After: repository.ChemicalCursor(byName, first[0])})
+			assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+			_, err = chemicals.List(ctx, repository.ChemicalFilter{After: "not a cursor"})
+			assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+
+			for _, tc := range []struct {
+				filter repository.ChemicalFilter
+				want   []string
+			}{
+				{repository.ChemicalFilter{Status: "good"}, []string{"1", "2", "4"}},
+				{repository.ChemicalFilter{Room: "A"}, []string{"2", "3"}},
+				{repository.ChemicalFilter{ExpiringBefore: date("2027-03-01").Time}, []string{"3"}},
+				{repository.ChemicalFilter{LowStock: true}, []string{"3"}},
+				{repository.ChemicalFilter{Room: "b", Status: "Good", Limit: 1}, []string{"1"}},
+				// Filters matched after the query read on until the page is full
+				{repository.ChemicalFilter{LowStock: true, Limit: 1}, []string{"3"}},
+				{repository.ChemicalFilter{LowStock: true, Sort: repository.SortByName, Descending: true, Limit: 1}, []string{"3"}},
+				{repository.ChemicalFilter{ExpiringBefore: date("2028-01-01").Time, Sort: repository.SortByExpiration, Limit: 2}, []string{"3", "1"}},
+			} {
+				list, err := chemicals.List(ctx, tc.filter)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, chemicalIDs(list), "%+v", tc.filter)
+			}
+
+			for _, id := range []string{"u3", "u1", "u2"} {
+				backend.Users.Create(ctx, &models.User{ID: id, Email: id + "@example.com", School: "Test School"})
+			}
+			users, err := backend.Users.List(ctx, repository.UserFilter{Limit: 2})
+			assert.NoError(t, err)
+			if assert.Len(t, users, 2) {
+				rest, err := backend.Users.List(ctx, repository.UserFilter{After: repository.UserCursor(users[1]), Limit: 2})
+				assert.NoError(t, err)
+				if assert.Len(t, rest, 1) {
+					assert.Equal(t, "u3", rest[0].ID)
+				}
+			}
+			_, err = backend.Users.List(ctx, repository.UserFilter{After: repository.ChemicalCursor(repository.ChemicalFilter{}, first[0])})
+			assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+			_, err = chemicals.List(ctx, repository.ChemicalFilter{After: repository.UserCursor(users[0])})
+			assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+		})
+	}
+}
+
+// Test the user lookups by email and reset token
+func TestRepositoryUsers_Lookups(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			users := backend.Users
+
+			user := models.User{First: "Jane", Email: "jane@example.com", ResetToken: "hash", Locale: "es"}
+			assert.NoError(t, users.Create(ctx, &user))
+
+			byEmail, err := users.GetByEmail(ctx, "jane@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, byEmail.ID)
+			assert.Equal(t, "es", byEmail.Locale)
+
+			byToken, err := users.GetByResetToken(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, byToken.ID)
+
+			_, err = users.GetByEmail(ctx, "nobody@example.com")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+			_, err = users.GetByResetToken(ctx, "")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test that consuming a refresh token revokes it and reports the previous state
+func TestRepositoryRefreshTokens_Consume(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			refreshTokens := backend.RefreshTokens
+
+			token := models.RefreshToken{Hash: "h1", UserID: "u1", FamilyID: "f1", ExpiresAt: time.Now().Add(time.Hour)}
+			assert.NoError(t, refreshTokens.Create(ctx, token))
+			assert.ErrorIs(t, refreshTokens.Create(ctx, token), repository.ErrAlreadyExists)
+
+			first, err := refreshTokens.Consume(ctx, "h1")
+			assert.NoError(t, err)
+			assert.False(t, first.Revoked)
+
+			second, err := refreshTokens.Consume(ctx, "h1")
+			assert.NoError(t, err)
+			assert.True(t, second.Revoked)
+
+			_, err = refreshTokens.Consume(ctx, "missing")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test revoking refresh tokens by family and by user
+func TestRepositoryRefreshTokens_Revoke(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			refreshTokens := backend.RefreshTokens
+
+			refreshTokens.Create(ctx, models.RefreshToken{Hash: "a", UserID: "u1", FamilyID: "f1"})
+			refreshTokens.Create(ctx, models.RefreshToken{Hash: "b", UserID: "u1", FamilyID: "f2"})
+			refreshTokens.Create(ctx, models.RefreshToken{Hash: "c", UserID: "u2", FamilyID: "f3"})
+
+			assert.NoError(t, refreshTokens.RevokeFamily(ctx, "f1"))
+			a, _ := refreshTokens.Get(ctx, "a")
+			b, _ := refreshTokens.Get(ctx, "b")
+			assert.True(t, a.Revoked)
+			assert.False(t, b.Revoked)
+
+			assert.NoError(t, refreshTokens.RevokeUser(ctx, "u1"))
+			b, _ = refreshTokens.Get(ctx, "b")
+			c, _ := refreshTokens.Get(ctx, "c")
+			assert.True(t, b.Revoked)
+			assert.False(t, c.Revoked)
+		})
+	}
+}
+
+// Test that concurrent consumers of the same refresh token see exactly one first use
+func TestRepositoryRefreshTokens_ConcurrentConsume(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			refreshTokens := backend.RefreshTokens
+			refreshTokens.Create(ctx, models.RefreshToken{Hash: "h", UserID: "u", FamilyID: "f"})
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			fresh := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					token, err := refreshTokens.Consume(ctx, "h")
+					if err == nil && !token.Revoked {
+						mu.Lock()
+						fresh++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, 1, fresh)
+		})
+	}
+}
+
+// Test that concurrent withdrawals never spend more than the container holds
+func TestRepositoryUsage_ConcurrentRecord(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemical := models.Chemical{School: "Test School", Remaining: models.Quantity{Amount: 100, Unit: models.Gram}}
+			backend.Chemicals.Create(ctx, &chemical)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			recorded := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					event := models.UsageEvent{
+						ChemicalID: chemical.ID,
+						Kind:       models.UsageWithdrawal,
+						UserID:     "u1",
+						Amount:     models.Quantity{Amount: 15, Unit: models.Gram},
+						CreatedAt:  time.Now(),
+					}
+					_, err := backend.Usage.Record(ctx, &event)
+					if err == nil {
+						mu.Lock()
+						recorded++
+						mu.Unlock()
+					} else {
+						assert.ErrorIs(t, err, models.ErrInsufficientStock)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, 6, recorded)
+			stored, _ := backend.Chemicals.Get(ctx, chemical.ID)
+			assert.Equal(t, 10.0, stored.Remaining.Amount)
+			events, err := backend.Usage.List(ctx, repository.UsageFilter{ChemicalID: chemical.ID})
+			assert.NoError(t, err)
+			assert.Len(t, events, 6)
+		})
+	}
+}
+
+// Test filtering the usage ledger by user and time
+func TestRepositoryUsage_ListFilter(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemical := models.Chemical{School: "Test School", Remaining: models.Quantity{Amount: 1, Unit: models.Liter}}
+			backend.Chemicals.Create(ctx, &chemical)
+			day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+			for i, user := range []string{"u1", "u2", "u1"} {
+				event := models.UsageEvent{
+					ChemicalID: chemical.ID,
+					Kind:       models.UsageWithdrawal,
+					UserID:     user,
+					Amount:     models.Quantity{Amount: 100, Unit: models.Milliliter},
+					CreatedAt:  day.AddDate(0, 0, i),
+				}
+				_, err := backend.Usage.Record(ctx, &event)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, event.ID)
+			}
+
+			events, _ := backend.Usage.List(ctx, repository.UsageFilter{UserID: "u1"})
+			if assert.Len(t, events, 2) {
+				assert.True(t, events[0].CreatedAt.Before(events[1].CreatedAt))
+				assert.Equal(t, models.Quantity{Amount: 0.7, Unit: models.Liter}, events[1].Remaining)
+			}
+			events, _ = backend.Usage.List(ctx, repository.UsageFilter{School: "Test School", From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 2)})
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, "u2", events[0].UserID)
+			}
+		})
+	}
+}
+
+// Test that audit entries keep their changes and are listed newest first
+func TestRepositoryAudit_AppendList(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			for i, school := range []string{"Test School", "Other School", "Test School"} {
+				entry := models.AuditEntry{
+					EntityType: models.AuditChemical,
+					EntityID:   "c1",
+					School:     school,
+					Action:     models.AuditUpdate,
+					Actor:      "u1",
+					Changes:    []models.FieldChange{{Field: "shelf", Before: float64(i), After: float64(i + 1)}},
+					CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+				}
+				assert.NoError(t, backend.Audit.Append(ctx, &entry))
+				assert.NotEmpty(t, entry.ID)
+			}
+
+			entries, err := backend.Audit.List(ctx, repository.AuditFilter{School: "Test School"})
+			assert.NoError(t, err)
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, []models.FieldChange{{Field: "shelf", Before: 2.0, After: 3.0}}, entries[0].Changes)
+				assert.True(t, entries[0].CreatedAt.After(entries[1].CreatedAt))
+			}
+
+			entries, _ = backend.Audit.List(ctx, repository.AuditFilter{EntityID: "c1", Before: start.Add(2 * time.Minute), Limit: 1})
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "Other School", entries[0].School)
+			}
+		})
+	}
+}
+
+// Test that trashed records are hidden from lookups and lists until they are restored
+func TestRepositoryTrash(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemical := models.Chemical{ID: "c1", Name: "Acetone", School: "Test School"}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &chemical))
+			user := models.User{ID: "u1", Email: "ada@example.com", School: "Test School"}
+			assert.NoError(t, backend.Users.Create(ctx, &user))
+
+			deletion := models.Deletion{By: "admin-1", At: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+			assert.NoError(t, backend.Chemicals.Trash(ctx, "c1", deletion))
+			assert.ErrorIs(t, backend.Chemicals.Trash(ctx, "c1", deletion), repository.ErrNotFound)
+			assert.NoError(t, backend.Users.Trash(ctx, "u1", deletion))
+
+			_, err := backend.Chemicals.Get(ctx, "c1")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+			_, err = backend.Users.GetByEmail(ctx, "ada@example.com")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+			live, _ := backend.Chemicals.List(ctx, repository.ChemicalFilter{School: "Test School"})
+			assert.Empty(t, live)
+
+			trashed, err := backend.Chemicals.List(ctx, repository.ChemicalFilter{School: "Test School", Trashed: true})
+			assert.NoError(t, err)
+			if assert.Len(t, trashed, 1) {
+				assert.Equal(t, &deletion, trashed[0].Deleted)
+			}
+			trashedUser, err := backend.Users.GetTrashed(ctx, "u1")
+			assert.NoError(t, err)
+			assert.Equal(t, &deletion, trashedUser.Deleted)
+
+			// Updates leave the trash state alone
+			trashed[0].Room = "B12"
+			assert.NoError(t, backend.Chemicals.Update(ctx, trashed[0]))
+			assert.NoError(t, backend.Chemicals.Restore(ctx, "c1"))
+			assert.ErrorIs(t, backend.Chemicals.Restore(ctx, "c1"), repository.ErrNotFound)
+			restored, err := backend.Chemicals.Get(ctx, "c1")
+			assert.NoError(t, err)
+			assert.Nil(t, restored.Deleted)
+			assert.Equal(t, "B12", restored.Room)
+
+			_, err = backend.Chemicals.GetTrashed(ctx, "c1")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test that the disposal of a chemical is stored and can be listed by step
+func TestRepositoryChemicals_Disposal(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			flaggedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			chemical := models.Chemical{ID: "c1", Name: "Acetone", School: "Test School"}
+			assert.NoError(t, chemical.FlagForDisposal("teacher-1", "Expired", flaggedAt))
+			assert.NoError(t, backend.Chemicals.Create(ctx, &chemical))
+			kept := models.Chemical{ID: "c2", Name: "Ethanol", School: "Test School"}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &kept))
+
+			assert.NoError(t, chemical.ApproveDisposal("admin-1", "Lab pack", "Clean Harbors", flaggedAt.Add(time.Hour)))
+			assert.NoError(t, backend.Chemicals.Update(ctx, chemical))
+
+			stored, err := backend.Chemicals.Get(ctx, "c1")
+			assert.NoError(t, err)
+			assert.Equal(t, chemical.Disposal, stored.Disposal)
+
+			pending, err := backend.Chemicals.List(ctx, repository.ChemicalFilter{Disposal: models.DisposalPendingPickup})
+			assert.NoError(t, err)
+			if assert.Len(t, pending, 1) {
+				assert.Equal(t, "c1", pending[0].ID)
+			}
+			stored, _ = backend.Chemicals.Get(ctx, "c2")
+			assert.Nil(t, stored.Disposal)
+		})
+	}
+}
+
+// Test that hazard data and storage groups round-trip and chemicals can be found by hazard
+func TestRepositoryChemicals_Hazards(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			hazards := models.Hazards{
+				Classes:                 []string{"eye_damage", "flammable_liquid"},
+				HazardStatements:        []string{"H225", "H319"},
+				PrecautionaryStatements: []string{"P210", "P303+P361+P353"},
+				SignalWord:              models.SignalDanger,
+				Pictograms:              []string{"GHS02", "GHS07"},
+			}
+			acetone := models.Chemical{ID: "c1", Name: "Acetone", School: "Test School", Hazards: hazards,
+				StorageGroups: []string{models.StorageToxic}}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &acetone))
+			salt := models.Chemical{ID: "c2", Name: "Sodium chloride", School: "Test School"}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &salt))
+
+			stored, err := backend.Chemicals.Get(ctx, "c1")
+			assert.NoError(t, err)
+			assert.Equal(t, hazards, stored.Hazards)
+			assert.Equal(t, []string{models.StorageToxic}, stored.StorageGroups)
+			stored, _ = backend.Chemicals.Get(ctx, "c2")
+			assert.True(t, stored.Hazards.IsZero())
+
+			for _, filter := range []repository.ChemicalFilter{
+				{HazardClass: "flammable_liquid"},
+				{HazardStatement: "H319"},
+				{Pictogram: "GHS07", SignalWord: models.SignalDanger},
+			} {
+				found, err := backend.Chemicals.List(ctx, filter)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"c1"}, chemicalIDs(found), "%+v", filter)
+			}
+			// Codes match whole, H31 is not a prefix match for H319
+			found, _ := backend.Chemicals.List(ctx, repository.ChemicalFilter{HazardStatement: "H31"})
+			assert.Empty(t, found)
+
+			acetone.Hazards = models.Hazards{}
+			assert.NoError(t, backend.Chemicals.Update(ctx, acetone))
+			found, _ = backend.Chemicals.List(ctx, repository.ChemicalFilter{Pictogram: "GHS02"})
+			assert.Empty(t, found)
+		})
+	}
+}
+
+// Test that locations round-trip, filter and chemicals can be found by location
+func TestRepositoryLocations(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			school := models.Location{Kind: models.LocationSchool, Name: "Test School", School: "Test School", Attributes: []string{}}
+			assert.NoError(t, backend.Locations.Create(ctx, &school))
+			assert.NotEmpty(t, school.ID)
+			room := models.Location{ParentID: school.ID, Kind: models.LocationRoom, Name: "Room 101", School: "Test School",
+				Capacity: 20, Attributes: []string{"ventilated"}}
+			assert.NoError(t, backend.Locations.Create(ctx, &room))
+			other := models.Location{Kind: models.LocationSchool, Name: "Other School", School: "Other School", Attributes: []string{}}
+			assert.NoError(t, backend.Locations.Create(ctx, &other))
+
+			stored, err := backend.Locations.Get(ctx, room.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, room, stored)
+
+			found, err := backend.Locations.List(ctx, repository.LocationFilter{School: "Test School"})
+			assert.NoError(t, err)
+			assert.Len(t, found, 2)
+			found, _ = backend.Locations.List(ctx, repository.LocationFilter{Kind: models.LocationSchool})
+			assert.Len(t, found, 2)
+			found, _ = backend.Locations.List(ctx, repository.LocationFilter{ParentID: school.ID})
+			if assert.Len(t, found, 1) {
+				assert.Equal(t, room.ID, found[0].ID)
+			}
+
+			room.Name, room.Attributes = "Lab 101", []string{}
+			assert.NoError(t, backend.Locations.Update(ctx, room))
+			stored, _ = backend.Locations.Get(ctx, room.ID)
+			assert.Equal(t, "Lab 101", stored.Name)
+			assert.Empty(t, stored.Attributes)
+
+			assert.NoError(t, backend.Chemicals.Create(ctx, &models.Chemical{ID: "c1", School: "Test School", Room: "Lab 101", LocationID: room.ID}))
+			assert.NoError(t, backend.Chemicals.Create(ctx, &models.Chemical{ID: "c2", School: "Test School", Room: "102"}))
+			chemicals, err := backend.Chemicals.List(ctx, repository.ChemicalFilter{LocationID: room.ID})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"c1"}, chemicalIDs(chemicals))
+
+			assert.NoError(t, backend.Locations.Delete(ctx, other.ID))
+			_, err = backend.Locations.Get(ctx, other.ID)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+// Test that transfers round-trip and accepting one moves the chemical in a single step
+func TestRepositoryTransfers(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			chemical := models.Chemical{ID: "c1", Name: "Acetone", School: "North School", Room: "Lab",
+				Remaining: models.Quantity{Amount: 1, Unit: models.Liter}}
+			assert.NoError(t, backend.Chemicals.Create(ctx, &chemical))
+
+			requested := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+			transfer := models.Transfer{ChemicalID: "c1", ChemicalName: "Acetone", FromSchool: "North School", ToSchool: "South School",
+				Amount: models.Quantity{Amount: 200, Unit: models.Milliliter}, Status: models.TransferPending, RequestedBy: "u1", RequestedAt: requested}
+			assert.NoError(t, backend.Transfers.Create(ctx, &transfer))
+			assert.NotEmpty(t, transfer.ID)
+			second := transfer
+			assert.ErrorIs(t, backend.Transfers.Create(ctx, &second), models.ErrTransferPending)
+
+			stored, err := backend.Transfers.Get(ctx, transfer.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, transfer, stored)
+			for _, school := range []string{"North School", "South School"} {
+				found, err := backend.Transfers.List(ctx, repository.TransferFilter{School: school, Status: models.TransferPending})
+				assert.NoError(t, err)
+				assert.Len(t, found, 1)
+			}
+
+			decided := requested.Add(time.Hour)
+			transfer.Status, transfer.DecidedBy, transfer.DecidedAt = models.TransferAccepted, "u2", &decided
+			transfer.Room = "Store"
+			outcome, err := backend.Transfers.Accept(ctx, &transfer, func(outcome repository.TransferOutcome) []models.AuditEntry {
+				return []models.AuditEntry{
+					{EntityType: models.AuditChemical, EntityID: outcome.Sent.ID, School: "North School", Action: models.AuditTransferOut, CreatedAt: decided},
+					{EntityType: models.AuditChemical, EntityID: outcome.Received.ID, School: "South School", Action: models.AuditTransferIn, CreatedAt: decided},
+				}
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1.0, outcome.Before.Remaining.Amount)
+			assert.Equal(t, 0.8, outcome.Sent.Remaining.Amount)
+			assert.NotEmpty(t, transfer.ReceivedID)
+
+			// The audit entries are stored with the move
+			arrivals, err := backend.Audit.List(ctx, repository.AuditFilter{EntityType: models.AuditChemical, EntityID: transfer.ReceivedID})
+			assert.NoError(t, err)
+			if assert.Len(t, arrivals, 1) {
+				assert.Equal(t, models.AuditTransferIn, arrivals[0].Action)
+				assert.Equal(t, "South School", arrivals[0].School)
+				assert.NotEmpty(t, arrivals[0].ID)
+			}
+
+			source, _ := backend.Chemicals.Get(ctx, "c1")
+			assert.Equal(t, 0.8, source.Remaining.Amount)
+			received, err := backend.Chemicals.Get(ctx, transfer.ReceivedID)
+			assert.NoError(t, err)
+			assert.Equal(t, "South School", received.School)
+			assert.Equal(t, "Store", received.Room)
+			assert.Equal(t, models.Quantity{Amount: 200, Unit: models.Milliliter}, received.Remaining)
+
+			stored, _ = backend.Transfers.Get(ctx, transfer.ID)
+			assert.Equal(t, models.TransferAccepted, stored.Status)
+			assert.Equal(t, transfer.ReceivedID, stored.ReceivedID)
+			assert.True(t, decided.Equal(*stored.DecidedAt))
+
+			// Decided transfers stay decided
+			_, err = backend.Transfers.Accept(ctx, &transfer, nil)
+			assert.ErrorIs(t, err, models.ErrTransferDecided)
+			transfer.Status = models.TransferRejected
+			assert.ErrorIs(t, backend.Transfers.Decide(ctx, transfer), models.ErrTransferDecided)
+			assert.ErrorIs(t, backend.Transfers.Decide(ctx, models.Transfer{ID: "missing"}), repository.ErrNotFound)
+
+			// A new transfer can be proposed once the last one is decided
+			next := models.Transfer{ChemicalID: "c1", FromSchool: "North School", ToSchool: "South School", Status: models.TransferPending, RequestedAt: decided}
+			assert.NoError(t, backend.Transfers.Create(ctx, &next))
+			all, _ := backend.Transfers.List(ctx, repository.TransferFilter{ChemicalID: "c1"})
+			if assert.Len(t, all, 2) {
+				assert.Equal(t, next.ID, all[0].ID)
+			}
+		})
+	}
+}
+
+// Test that the hierarchy is built from free-text locations, merging names for the same place
+func TestBuildLocations(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	assert.NoError(t, repos.Users.Create(ctx, &models.User{ID: "u1", School: "Quiet School"}))
+	for _, chemical := range []models.Chemical{
+		{ID: "c1", School: "Test School", Room: "Room 101", Cabinet: 2, Shelf: 1},
+		{ID: "c2", School: "Test School", Room: "rm 101", Cabinet: 2, Shelf: 1},
+		{ID: "c3", School: "Test School", Room: "101", Cabinet: 3},
+		{ID: "c4", School: "Test School"},
+	} {
+		assert.NoError(t, repos.Chemicals.Create(ctx, &chemical))
+	}
+
+	placed, err := repository.BuildLocations(ctx, repos)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, placed)
+
+	schools, _ := repos.Locations.List(ctx, repository.LocationFilter{Kind: models.LocationSchool})
+	assert.Len(t, schools, 2)
+	rooms, _ := repos.Locations.List(ctx, repository.LocationFilter{Kind: models.LocationRoom})
+	if assert.Len(t, rooms, 1) {
+		assert.Equal(t, "Room 101", rooms[0].Name)
+	}
+
+	first, _ := repos.Chemicals.Get(ctx, "c1")
+	second, _ := repos.Chemicals.Get(ctx, "c2")
+	assert.NotEmpty(t, first.LocationID)
+	assert.Equal(t, first.LocationID, second.LocationID)
+	path, err := repository.LocationPath(ctx, repos.Locations, first.LocationID)
+	assert.NoError(t, err)
+	if assert.Len(t, path, 4) {
+		assert.Equal(t, "Cabinet 2", path[2].Name)
+		assert.Equal(t, models.LocationShelf, path[3].Kind)
+	}
+	third, _ := repos.Chemicals.Get(ctx, "c3")
+	cabinet, _ := repos.Locations.Get(ctx, third.LocationID)
+	assert.Equal(t, models.LocationCabinet, cabinet.Kind)
+
+	// Running it again changes nothing
+	placed, err = repository.BuildLocations(ctx, repos)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, placed)
+	all, _ := repos.Locations.List(ctx, repository.LocationFilter{})
+	assert.Len(t, all, 6)
+
+	assert.Equal(t, "101", models.LocationKey("Room 101"))
+	assert.Equal(t, "101", models.LocationKey("rm. 101"))
+	assert.Equal(t, "north lab", models.LocationKey("North_Lab"))
+}
+
+// Test that migrations are recorded and running them again is a no-op
+func TestMigrate_Idempotent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "chemtrack.db")
+
+	db, err := repository.OpenSQL(ctx, repository.SQLite, path)
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer db.Close()
+	assert.NoError(t, repository.NewSQL(db, repository.SQLite).Chemicals.Create(ctx, &models.Chemical{ID: "kept"}))
+
+	assert.NoError(t, repository.Migrate(ctx, db, repository.SQLite))
+
+	var applied int
+	assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Greater(t, applied, 0)
+	_, err = repository.NewSQL(db, repository.SQLite).Chemicals.Get(ctx, "kept")
+	assert.NoError(t, err)
+}
+
+// Test that legacy text dates are converted and the unparseable ones are reported
+func TestMigrateSQLDates(t *testing.T) {
+	ctx := context.Background()
+	db, err := repository.OpenSQL(ctx, repository.SQLite, filepath.Join(t.TempDir(), "chemtrack.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	// Rows as they looked before dates were typed
+	_, err = db.Exec(`INSERT INTO chemicals (id, legacy_purchase_date, legacy_expiration_date) VALUES
+		('good', '2023-03-01', '2025-03-01T00:00:00Z'),
+		('bad', '2023-03-01', 'next spring')`)
+	if err != nil {
+		t.Fatalf("Failed to insert legacy rows: %v", err)
+	}
+
+	converted, problems, err := repository.MigrateSQLDates(ctx, db, repository.SQLite)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, converted)
+	assert.Equal(t, []repository.ConversionProblem{{ChemicalID: "bad", Field: "expiration_date", Value: "next spring"}}, problems)
+
+	chemicals := repository.NewSQL(db, repository.SQLite).Chemicals
+	good, _ := chemicals.Get(ctx, "good")
+	assert.Equal(t, "2023-03-01", good.PurchaseDate.String())
+	assert.Equal(t, "2025-03-01", good.ExpirationDate.String())
+	bad, _ := chemicals.Get(ctx, "bad")
+	assert.Equal(t, "2023-03-01", bad.PurchaseDate.String())
+	assert.True(t, bad.ExpirationDate.IsZero())
+}
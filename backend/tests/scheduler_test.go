@@ -0,0 +1,238 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test when cron schedules next come due
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 18, 13, 45, 30, 0, time.UTC) // a Sunday
+	for spec, want := range map[string]string{
+		"* * * * *":       "2026-10-18 13:46",
+		"*/15 * * * *":    "2026-10-18 14:00",
+		"0 7 * * *":       "2026-10-19 07:00",
+		"30 13 * * *":     "2026-10-19 13:30",
+		"0 7 1 * *":       "2026-11-01 07:00",
+		"0 7 * * 1-5":     "2026-10-19 07:00",
+		"0 9 * * 6,7":     "2026-10-24 09:00", // Sunday as 7 is today, already past
+		"0 0 13 * 5":      "2026-10-23 00:00", // the 13th or a Friday
+		"0 0 29 2 *":      "2028-02-29 00:00",
+		"5/20 9-10 * * *": "2026-10-19 09:05",
+		"@daily":          "2026-10-19 00:00",
+		"@hourly":         "2026-10-18 14:00",
+		"@weekly":         "2026-10-25 00:00",
+	} {
+		schedule, err := scheduler.ParseSchedule(spec)
+		if assert.NoError(t, err, spec) {
+			assert.Equal(t, want, schedule.Next(start).Format("2006-01-02 15:04"), spec)
+		}
+	}
+
+	never, _ := scheduler.ParseSchedule("0 0 30 2 *")
+	assert.True(t, never.Next(start).IsZero())
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		_, err := scheduler.ParseSchedule(spec)
+		assert.ErrorIs(t, err, scheduler.ErrInvalidSchedule, spec)
+	}
+}
+
+// Test that each run of a job is claimed by one holder at a time and only once
+func TestRepositoryJobs_Claim(t *testing.T) {
+	ctx := context.Background()
+	slot := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	for name, backend := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			jobs := backend.Jobs
+			claimed, err := jobs.Claim(ctx, "monitor", "replica-a", slot, time.Hour)
+			assert.NoError(t, err)
+			assert.True(t, claimed)
+			// Another replica due at the same time, and even a later run, wait for the lock
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-b", slot, time.Hour)
+			assert.False(t, claimed)
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-b", slot.Add(time.Hour), time.Hour)
+			assert.False(t, claimed)
+			// Other jobs have their own locks
+			claimed, _ = jobs.Claim(ctx, "purge", "replica-b", slot, time.Hour)
+			assert.True(t, claimed)
+
+			// Only the holder can release the lock, and a run already claimed stays done
+			assert.NoError(t, jobs.Release(ctx, "monitor", "replica-b"))
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-b", slot.Add(time.Hour), time.Hour)
+			assert.False(t, claimed)
+			assert.NoError(t, jobs.Release(ctx, "monitor", "replica-a"))
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-b", slot, time.Hour)
+			assert.False(t, claimed)
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-b", slot.Add(time.Hour), time.Millisecond)
+			assert.True(t, claimed)
+
+			// An expired lease, as left by a replica that died mid-run, can be taken over
+			time.Sleep(10 * time.Millisecond)
+			claimed, _ = jobs.Claim(ctx, "monitor", "replica-a", slot.Add(2*time.Hour), time.Hour)
+			assert.True(t, claimed)
+
+			run := models.JobRun{Job: "monitor", Trigger: models.JobScheduled, Instance: "replica-a", ScheduledAt: slot,
+				StartedAt: slot.Add(time.Second), Status: models.JobRunning}
+			assert.NoError(t, jobs.CreateRun(ctx, &run))
+			later := run
+			later.ScheduledAt, later.StartedAt = slot.Add(time.Hour), slot.Add(time.Hour)
+			assert.NoError(t, jobs.CreateRun(ctx, &later))
+			run.Finish(slot.Add(3*time.Second), errors.New("mail server down"))
+			assert.NoError(t, jobs.UpdateRun(ctx, run))
+
+			runs, err := jobs.ListRuns(ctx, repository.JobRunFilter{Job: "monitor"})
+			assert.NoError(t, err)
+			if assert.Len(t, runs, 2) {
+				assert.Equal(t, later.ID, runs[0].ID)
+				assert.Equal(t, models.JobFailed, runs[1].Status)
+				assert.Equal(t, int64(2000), runs[1].DurationMS)
+				assert.Equal(t, "mail server down", runs[1].Error)
+				assert.True(t, runs[1].FinishedAt.Equal(slot.Add(3*time.Second)))
+			}
+			runs, _ = jobs.ListRuns(ctx, repository.JobRunFilter{Job: "monitor", Limit: 1})
+			assert.Len(t, runs, 1)
+			runs, _ = jobs.ListRuns(ctx, repository.JobRunFilter{Job: "purge"})
+			assert.Empty(t, runs)
+		})
+	}
+}
+
+// waitForRun waits for the newest run of a job to finish
+func waitForRun(t *testing.T, s *scheduler.Scheduler, job string) models.JobRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, _ := s.Runs(context.Background(), repository.JobRunFilter{Job: job, Limit: 1})
+		if len(runs) == 1 && runs[0].Status != models.JobRunning {
+			return runs[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", job)
+	return models.JobRun{}
+}
+
+// Test manual runs, their history, and that replicas sharing a repository do not run a job twice
+func TestScheduler_Trigger(t *testing.T) {
+	ctx := context.Background()
+	shared := repository.NewMemory().Jobs
+	replicaA := scheduler.New(shared, scheduler.Options{Instance: "a"})
+	replicaB := scheduler.New(shared, scheduler.Options{Instance: "b"})
+
+	release := make(chan struct{})
+	slow := scheduler.Job{Name: "slow", Run: func(ctx context.Context) error { <-release; return nil }}
+	assert.NoError(t, replicaA.Add(slow))
+	assert.NoError(t, replicaB.Add(slow))
+	assert.Error(t, replicaA.Add(slow))
+	assert.Error(t, replicaA.Add(scheduler.Job{Name: "bad", Schedule: "every day"}))
+
+	run, err := replicaA.Trigger(ctx, "slow", "master-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobRunning, run.Status)
+	assert.Equal(t, models.JobManual, run.Trigger)
+	_, err = replicaA.Trigger(ctx, "slow", "master-1")
+	assert.ErrorIs(t, err, scheduler.ErrJobRunning)
+	_, err = replicaB.Trigger(ctx, "slow", "master-1")
+	assert.ErrorIs(t, err, scheduler.ErrJobRunning)
+	close(release)
+	finished := waitForRun(t, replicaA, "slow")
+	assert.Equal(t, models.JobSucceeded, finished.Status)
+	assert.Equal(t, "a", finished.Instance)
+	assert.Equal(t, "master-1", finished.TriggeredBy)
+	assert.NotNil(t, finished.FinishedAt)
+
+	// Once released, any replica can run it again
+	_, err = replicaB.Trigger(ctx, "slow", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", waitForRun(t, replicaB, "slow").Instance)
+
+	assert.NoError(t, replicaA.Add(scheduler.Job{Name: "broken", Run: func(ctx context.Context) error { panic("nil map") }}))
+	_, err = replicaA.Trigger(ctx, "broken", "")
+	assert.NoError(t, err)
+	broken := waitForRun(t, replicaA, "broken")
+	assert.Equal(t, models.JobFailed, broken.Status)
+	assert.Equal(t, "panic: nil map", broken.Error)
+
+	_, err = replicaA.Trigger(ctx, "missing", "")
+	assert.ErrorIs(t, err, scheduler.ErrUnknownJob)
+}
+
+// Test that stopping waits for runs in progress, and cancels them once out of time
+func TestScheduler_Stop(t *testing.T) {
+	ctx := context.Background()
+	s := scheduler.New(repository.NewMemory().Jobs, scheduler.Options{Instance: "a"})
+	assert.NoError(t, s.Add(scheduler.Job{Name: "minutely", Schedule: "* * * * *", Run: func(ctx context.Context) error { return nil }}))
+	assert.NoError(t, s.Add(scheduler.Job{Name: "stubborn", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}))
+	s.Start()
+	jobs := s.Jobs()
+	if assert.Len(t, jobs, 2) {
+		assert.Eventually(t, func() bool { return s.Jobs()[0].NextRun != nil }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "* * * * *", jobs[0].Schedule)
+		assert.Empty(t, jobs[1].Schedule)
+	}
+
+	_, err := s.Trigger(ctx, "stubborn", "")
+	assert.NoError(t, err)
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(short), context.DeadlineExceeded)
+	stubborn := waitForRun(t, s, "stubborn")
+	assert.Equal(t, models.JobFailed, stubborn.Status)
+	assert.Equal(t, context.Canceled.Error(), stubborn.Error)
+
+	_, err = s.Trigger(ctx, "minutely", "")
+	assert.ErrorIs(t, err, scheduler.ErrStopped)
+	assert.NoError(t, s.Stop(ctx))
+}
+
+// Test the job routes masters use to watch and start the background jobs
+func TestJobRoutes(t *testing.T) {
+	ran := make(chan string, 1)
+	jobs.Add(scheduler.Job{Name: "test_report", Schedule: "0 7 * * *", Run: func(ctx context.Context) error {
+		ran <- "report"
+		return nil
+	}})
+
+	w := sendAs(http.MethodGet, "/api/v1/jobs", admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/jobs", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed []scheduler.JobInfo
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.Contains(t, listed, scheduler.JobInfo{Name: "test_report", Schedule: "0 7 * * *"})
+
+	w = sendAs(http.MethodPost, "/api/v1/jobs/test_report/run", admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPost, "/api/v1/jobs/test_report/run", master, nil)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "report", <-ran)
+	finished := waitForRun(t, jobs, "test_report")
+	assert.Equal(t, master.UserID, finished.TriggeredBy)
+
+	w = sendAs(http.MethodGet, "/api/v1/jobs/test_report/runs", master, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var runs []models.JobRun
+	json.Unmarshal(w.Body.Bytes(), &runs)
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, models.JobSucceeded, runs[0].Status)
+	}
+
+	w = sendAs(http.MethodPost, "/api/v1/jobs/nothing/run", master, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/jobs/nothing/runs", master, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/jobs/test_report/runs?limit=0", master, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,152 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/search"
+	"github.com/stretchr/testify/assert"
+)
+
+var searchAdmin = auth.Principal{UserID: "search-admin", School: "Search School", Role: auth.RoleAdmin}
+
+// addSearchChemical adds a chemical through the API and returns its ID
+func addSearchChemical(t *testing.T, p auth.Principal, body map[string]interface{}) string {
+	t.Helper()
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", p, body)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var response struct {
+		Chemical struct {
+			ID string `json:"id"`
+		} `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Chemical.ID
+}
+
+// searchNames searches as the principal and returns the response code and the names found, best first
+func searchNames(p auth.Principal, query string) (int, []string) {
+	w := sendAs(http.MethodGet, "/api/v1/chemicals/search?q="+url.QueryEscape(query), p, nil)
+	var results []controllers.SearchResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.Chemical.Name)
+	}
+	return w.Code, names
+}
+
+// Test that search follows chemicals as they are added, changed, deleted and restored, within the caller's school
+func TestSearchChemicals(t *testing.T) {
+	acetone := addSearchChemical(t, searchAdmin, map[string]interface{}{"name": "Acetone", "CAS": "67-64-1",
+		"synonyms": []string{"Propan-2-one", " dimethyl  ketone ", "Dimethyl ketone"}, "room": "Prep Room"})
+	ethanol := addSearchChemical(t, searchAdmin, map[string]interface{}{"name": "Ethanol", "CAS": "64175",
+		"notes": "Denatured, keep away from the acetone", "room": "Lab 2", "cabinet": 3})
+	addSearchChemical(t, master, map[string]interface{}{"name": "Acetone", "CAS": "67-64-1", "school": "Other Search School"})
+
+	for query, want := range map[string][]string{
+		"acetone":        {"Acetone", "Ethanol"}, // the name ranks above the notes
+		"acetn":          {"Acetone", "Ethanol"}, // a typo in a partly typed word
+		"aceton":         {"Acetone", "Ethanol"},
+		"dimethyl ketne": {"Acetone"},
+		"64-17-5":        {"Ethanol"},
+		"6417":           {"Ethanol"},
+		"lab 2 cabinet":  {"Ethanol"},
+		"denatured":      {"Ethanol"},
+		"acetone lab":    {"Ethanol"}, // every word has to match
+		"benzene":        {},
+	} {
+		code, names := searchNames(searchAdmin, query)
+		assert.Equal(t, http.StatusOK, code, query)
+		assert.Equal(t, want, names, query)
+	}
+
+	var stored models.Chemical
+	w := sendAs(http.MethodGet, "/api/v1/chemicals/"+acetone, searchAdmin, nil)
+	json.Unmarshal(w.Body.Bytes(), &stored)
+	assert.Equal(t, []string{"Propan-2-one", "dimethyl ketone"}, stored.Synonyms)
+
+	// Changes are searchable at once
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+ethanol, searchAdmin, map[string]interface{}{"name": "Ethyl alcohol", "notes": ""})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, names := searchNames(searchAdmin, "acetone")
+	assert.Equal(t, []string{"Acetone"}, names)
+	_, names = searchNames(searchAdmin, "alcohl")
+	assert.Equal(t, []string{"Ethyl alcohol"}, names)
+
+	w = sendAs(http.MethodDelete, "/api/v1/chemicals/"+acetone, searchAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, names = searchNames(searchAdmin, "acetone")
+	assert.Empty(t, names)
+	w = sendAs(http.MethodPost, "/api/v1/chemicals/"+acetone+"/restore", searchAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, names = searchNames(searchAdmin, "acetone")
+	assert.Equal(t, []string{"Acetone"}, names)
+
+	// Chemicals written by another replica of the API are found too
+	seedChemical(t, models.Chemical{ID: "search-elsewhere", Name: "Toluene", CAS: "108-88-3", School: "Search School"})
+	_, names = searchNames(searchAdmin, "tolune")
+	assert.Equal(t, []string{"Toluene"}, names)
+	assert.NoError(t, repos.Chemicals.Delete(context.Background(), "search-elsewhere"))
+	_, names = searchNames(searchAdmin, "toluene")
+	assert.Empty(t, names)
+
+	// Only masters can search other schools, or every school at once
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/search?q=acetone&school=Other+Search+School", searchAdmin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/search?q=acetone&school=Other+Search+School", master, nil)
+	var results []controllers.SearchResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Other Search School", results[0].Chemical.School)
+		assert.Equal(t, []string{search.FieldName}, results[0].Matched)
+	}
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/search?q=acetone", master, nil)
+	json.Unmarshal(w.Body.Bytes(), &results)
+	schools := map[string]bool{}
+	for _, result := range results {
+		schools[result.Chemical.School] = true
+	}
+	assert.True(t, schools["Search School"] && schools["Other Search School"], "%v", schools)
+
+	code, _ := searchNames(searchAdmin, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// Test the ranking and typo tolerance of the search index
+func TestSearchIndex(t *testing.T) {
+	index := search.Build([]models.Chemical{
+		{ID: "1", Name: "Sodium chloride", CAS: "7647-14-5", School: "A"},
+		{ID: "2", Name: "Sodium hydroxide", CAS: "1310-73-2", School: "A", Synonyms: []string{"Caustic soda", "Lye"}},
+		{ID: "3", Name: "Soda lime", School: "A", Notes: "Absorbs carbon dioxide"},
+		{ID: "4", Name: "Sodium chloride", CAS: "7647-14-5", School: "B"},
+	})
+
+	ids := func(hits []search.Hit) []string {
+		out := []string{}
+		for _, hit := range hits {
+			out = append(out, hit.ID)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"1", "2"}, ids(index.Search(search.Query{Text: "sodium", School: "A"})))
+	assert.Equal(t, []string{"1", "4", "2"}, ids(index.Search(search.Query{Text: "sodum"})))
+	// The name matches exactly, the synonym exactly and the name with a typo
+	assert.Equal(t, []string{"3", "2", "1"}, ids(index.Search(search.Query{Text: "soda", School: "A"})))
+	assert.Equal(t, []string{"1"}, ids(index.Search(search.Query{Text: "7647145", School: "A"})))
+	assert.Equal(t, []string{"1"}, ids(index.Search(search.Query{Text: "sodium", School: "A", Limit: 1})))
+	assert.Empty(t, index.Search(search.Query{Text: "lie", School: "A"})) // short words must match exactly
+
+	hits := index.Search(search.Query{Text: "lye", School: "A"})
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, []string{search.FieldSynonyms}, hits[0].Fields)
+	}
+}
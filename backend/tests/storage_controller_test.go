@@ -0,0 +1,141 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the storage groups implied by hazards and the conflicts between chemicals on a shelf
+func TestChemical_StorageConflicts(t *testing.T) {
+	flammable := models.Chemical{ID: "ethanol", Name: "Ethanol", School: "Test School", Room: "101", Cabinet: 1, Shelf: 1,
+		Hazards: models.Hazards{Classes: []string{"flammable_liquid", "eye_damage"}}}
+	assert.Equal(t, []string{models.StorageFlammable}, flammable.EffectiveStorageGroups())
+
+	oxidizer := models.Chemical{ID: "nitric", Name: "Nitric acid", School: "Test School", Room: "101", Cabinet: 1, Shelf: 1,
+		StorageGroups: []string{models.StorageAcid}, Hazards: models.Hazards{Classes: []string{"oxidizing_liquid"}}}
+	assert.Equal(t, []string{models.StorageAcid, models.StorageOxidizer}, oxidizer.EffectiveStorageGroups())
+
+	// The most severe conflict between the two is reported
+	conflicts := oxidizer.StorageConflicts([]models.Chemical{flammable, oxidizer})
+	if assert.Len(t, conflicts, 1) {
+		assert.Equal(t, models.StorageBlocked, conflicts[0].Severity)
+		assert.Equal(t, models.StorageOxidizer, conflicts[0].Group)
+		assert.Equal(t, "ethanol", conflicts[0].OtherID)
+	}
+
+	// Another shelf is fine
+	flammable.Shelf = 2
+	assert.Empty(t, oxidizer.StorageConflicts([]models.Chemical{flammable}))
+
+	base := models.Chemical{ID: "ammonia", Room: "101", School: "Test School", Cabinet: 1, Shelf: 2, StorageGroups: []string{models.StorageBase}}
+	toxic := models.Chemical{ID: "cyanide", Room: "101", School: "Test School", Cabinet: 1, Shelf: 2, Hazards: models.Hazards{Classes: []string{"acute_toxicity"}}}
+	assert.Empty(t, base.StorageConflicts([]models.Chemical{flammable, toxic}))
+
+	_, err := models.NormalizeStorageGroups([]string{"Acid", "corrosive"})
+	assert.ErrorIs(t, err, models.ErrInvalidStorageGroup)
+	groups, err := models.NormalizeStorageGroups([]string{"Oxidizer", " acid", "acid"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.StorageAcid, models.StorageOxidizer}, groups)
+}
+
+// Test that adding or moving a chemical next to an incompatible one is refused or warned about
+func TestAddChemical_StorageCompatibility(t *testing.T) {
+	seedChemical(t, models.Chemical{ID: "storage-ethanol", Name: "Ethanol", School: "Test School", Room: "Storage 1", Cabinet: 2, Shelf: 3,
+		Hazards: models.Hazards{Classes: []string{"flammable_liquid"}}})
+
+	oxidizer := map[string]interface{}{
+		"name": "Potassium nitrate", "CAS": "7757-79-1", "school": "Test School", "room": "Storage 1", "cabinet": 2, "shelf": 3,
+		"hazards": map[string]interface{}{"classes": []string{"oxidizing_solid"}},
+	}
+	w := sendAs(http.MethodPost, "/api/v1/chemicals", admin, oxidizer)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var refused struct {
+		Conflicts []models.StorageConflict `json:"conflicts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &refused)
+	if assert.Len(t, refused.Conflicts, 1) {
+		assert.Equal(t, "storage-ethanol", refused.Conflicts[0].OtherID)
+	}
+
+	// Another shelf of the cabinet is fine
+	oxidizer["shelf"] = 4
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, oxidizer)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Chemical struct {
+			ID string `json:"id"`
+		} `json:"chemical"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	// Moving it onto the flammable's shelf is refused too
+	w = sendAs(http.MethodPut, "/api/v1/chemicals/"+created.Chemical.ID, admin, map[string]interface{}{"shelf": 3})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	stored, _ := repos.Chemicals.Get(context.Background(), created.Chemical.ID)
+	assert.Equal(t, 4, stored.Shelf)
+
+	// Lesser conflicts are saved with a warning
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{
+		"name": "Acetic acid", "CAS": "64-19-7", "school": "Test School", "room": "Storage 1", "cabinet": 2, "shelf": 3, "storage_groups": []string{"acid"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var warned struct {
+		Warnings []models.StorageConflict `json:"storage_warnings"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &warned)
+	if assert.Len(t, warned.Warnings, 1) {
+		assert.Equal(t, models.StorageWarning, warned.Warnings[0].Severity)
+	}
+
+	w = sendAs(http.MethodPost, "/api/v1/chemicals", admin, map[string]interface{}{"name": "Mystery", "CAS": "64-19-7", "school": "Test School", "storage_groups": []string{"spicy"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test that the school scan lists every shelf holding incompatible chemicals
+func TestGetStorageConflicts(t *testing.T) {
+	for _, chemical := range []models.Chemical{
+		{ID: "scan-acid", Name: "Hydrochloric acid", School: "Scan School", Room: "Lab", Cabinet: 1, Shelf: 1, StorageGroups: []string{models.StorageAcid}},
+		{ID: "scan-base", Name: "Sodium hydroxide", School: "Scan School", Room: "Lab", Cabinet: 1, Shelf: 1, StorageGroups: []string{models.StorageBase}},
+		{ID: "scan-water", Name: "Water", School: "Scan School", Room: "Lab", Cabinet: 1, Shelf: 1},
+		{ID: "scan-ethanol", Name: "Ethanol", School: "Scan School", Room: "Lab", Cabinet: 2, Shelf: 1, Hazards: models.Hazards{Classes: []string{"flammable_liquid"}}},
+		{ID: "scan-vinegar", Name: "Acetic acid", School: "Scan School", Room: "lab", Cabinet: 2, Shelf: 1, StorageGroups: []string{models.StorageAcid}},
+		{ID: "scan-other", Name: "Sodium hydroxide", School: "Scan School", Room: "Lab", Cabinet: 3, Shelf: 1, StorageGroups: []string{models.StorageBase}},
+		// Filed under a location, next to a chemical placed before the location hierarchy
+		{ID: "scan-filed", Name: "Nitric acid", School: "Scan School", LocationID: "scan-lab-3-1", Room: "Lab", Cabinet: 3, Shelf: 1, StorageGroups: []string{models.StorageAcid}},
+	} {
+		seedChemical(t, chemical)
+	}
+
+	scanAdmin := auth.Principal{UserID: "scan-admin", School: "Scan School", Role: auth.RoleAdmin}
+	w := sendAs(http.MethodGet, "/api/v1/storage/conflicts?school=Scan%20School", admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/storage/conflicts", scanAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report controllers.StorageReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 3, report.Count)
+	assert.Equal(t, 2, report.Blocked)
+	if assert.Len(t, report.Locations, 3) {
+		assert.Equal(t, 1, report.Locations[0].Cabinet)
+		assert.Equal(t, models.StorageBlocked, report.Locations[0].Conflicts[0].Severity)
+		assert.Equal(t, 2, report.Locations[1].Cabinet)
+		assert.Equal(t, models.StorageWarning, report.Locations[1].Conflicts[0].Severity)
+		assert.Equal(t, 3, report.Locations[2].Cabinet)
+		assert.Equal(t, models.StorageBlocked, report.Locations[2].Conflicts[0].Severity)
+	}
+
+	// The check when placing a chemical agrees with the scan
+	filed := models.Chemical{School: "Scan School", LocationID: "scan-lab-3-1", Room: "Lab", Cabinet: 3, Shelf: 1}
+	legacy := models.Chemical{School: "Scan School", Room: "lab", Cabinet: 3, Shelf: 1}
+	assert.True(t, filed.SameShelf(legacy))
+	assert.False(t, filed.SameShelf(models.Chemical{School: "Scan School", LocationID: "scan-lab-3-2", Room: "Lab", Cabinet: 3, Shelf: 2}))
+	assert.False(t, filed.SameShelf(models.Chemical{School: "Other School", Room: "Lab", Cabinet: 3, Shelf: 1}))
+}
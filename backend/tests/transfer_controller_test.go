@@ -0,0 +1,163 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	northAdmin = auth.Principal{UserID: "north-admin", School: "North School", Role: auth.RoleAdmin}
+	southAdmin = auth.Principal{UserID: "south-admin", School: "South School", Role: auth.RoleAdmin}
+)
+
+// seedTransferSchools stores the admins of the two schools trading chemicals
+func seedTransferSchools(t *testing.T) {
+	seedUser(t, models.User{ID: northAdmin.UserID, Email: "north@example.com", School: "North School", IsAdmin: true, AllowEmail: true})
+	seedUser(t, models.User{ID: southAdmin.UserID, Email: "south@example.com", School: "South School", IsAdmin: true, AllowEmail: true})
+}
+
+// proposeTransfer offers a chemical to the other school and returns the response and transfer
+func proposeTransfer(p auth.Principal, body map[string]interface{}) (int, models.Transfer) {
+	w := sendAs(http.MethodPost, "/api/v1/transfers", p, body)
+	var transfer models.Transfer
+	json.Unmarshal(w.Body.Bytes(), &transfer)
+	return w.Code, transfer
+}
+
+// Test that a whole container only moves once the receiving school accepts it
+func TestTransfer_WholeContainer(t *testing.T) {
+	ctx := context.Background()
+	seedTransferSchools(t)
+	chemicalID := seedChemical(t, models.Chemical{ID: "transfer-whole", Name: "Ethanol", CAS: "64-17-5", School: "North School", Room: "Lab", Cabinet: 1,
+		Remaining: models.Quantity{Amount: 1, Unit: models.Liter}})
+
+	// Changing the school directly is no longer possible, even for masters
+	w := sendAs(http.MethodPut, "/api/v1/chemicals/"+chemicalID, master, map[string]interface{}{"school": "South School"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	code, _ := proposeTransfer(southAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "Nowhere School"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, transfer := proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "notes": "Surplus"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.TransferPending, transfer.Status)
+	assert.Equal(t, "Ethanol", transfer.ChemicalName)
+	code, _ = proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School"})
+	assert.Equal(t, http.StatusConflict, code)
+
+	// Nothing has moved yet, and only the receiving school can accept
+	stored, _ := repos.Chemicals.Get(ctx, chemicalID)
+	assert.Equal(t, "North School", stored.School)
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+transfer.ID+"/accept", northAdmin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/transfers?status=pending", southAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var pending []models.Transfer
+	json.Unmarshal(w.Body.Bytes(), &pending)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, transfer.ID, pending[0].ID)
+	}
+
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+transfer.ID+"/accept", southAdmin, map[string]interface{}{"room": "Store", "cabinet": 2})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, _ = repos.Chemicals.Get(ctx, chemicalID)
+	assert.Equal(t, "South School", stored.School)
+	assert.Equal(t, "Store", stored.Room)
+	assert.Equal(t, 2, stored.Cabinet)
+	assert.NotEmpty(t, stored.LocationID)
+	accepted, _ := repos.Transfers.Get(ctx, transfer.ID)
+	assert.Equal(t, models.TransferAccepted, accepted.Status)
+	assert.Equal(t, southAdmin.UserID, accepted.DecidedBy)
+	assert.Equal(t, chemicalID, accepted.ReceivedID)
+
+	// Both schools' activity records their side of the transfer
+	for school, action := range map[string]models.AuditAction{"North School": models.AuditTransferOut, "South School": models.AuditTransferIn} {
+		entries, _ := repos.Audit.List(ctx, repository.AuditFilter{School: school, EntityID: chemicalID})
+		if assert.NotEmpty(t, entries, school) {
+			assert.Equal(t, action, entries[0].Action)
+			assert.Contains(t, entries[0].Changes, models.FieldChange{Field: "school", Before: "North School", After: "South School"})
+		}
+	}
+
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+transfer.ID+"/reject", southAdmin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// Test sending part of a container, and that rejected and cancelled transfers move nothing
+func TestTransfer_PartialAmount(t *testing.T) {
+	ctx := context.Background()
+	seedTransferSchools(t)
+	chemicalID := seedChemical(t, models.Chemical{ID: "transfer-part", Name: "Acetone", CAS: "67-64-1", School: "North School",
+		ContainerSize: models.Quantity{Amount: 1, Unit: models.Liter}, Remaining: models.Quantity{Amount: 1, Unit: models.Liter}})
+
+	code, _ := proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "amount": "2 L"})
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "amount": "3 kg"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, rejected := proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "amount": "250 mL"})
+	w := sendAs(http.MethodPost, "/api/v1/transfers/"+rejected.ID+"/reject", southAdmin, map[string]interface{}{"reason": "No room for <b>Acetone</b>"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &rejected)
+	assert.Equal(t, models.TransferRejected, rejected.Status)
+	assert.Equal(t, "No room for <b>Acetone</b>", rejected.Reason)
+
+	// The reason is escaped in the email to the sending school, and kept as it is in the text
+	sent := mail.To("north@example.com")
+	if assert.NotEmpty(t, sent) {
+		last := sent[len(sent)-1]
+		assert.Equal(t, "Transfer to South School rejected", last.Subject)
+		assert.Contains(t, last.HTML, "No room for &lt;b&gt;Acetone&lt;/b&gt;")
+		assert.NotContains(t, last.HTML, "<b>Acetone</b>")
+		assert.Contains(t, last.Text, "South School rejected 250 mL of Acetone.")
+		assert.Contains(t, last.Text, "Reason: No room for <b>Acetone</b>")
+	}
+
+	_, cancelled := proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "amount": "250 mL"})
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+cancelled.ID+"/cancel", southAdmin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+cancelled.ID+"/cancel", northAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, _ := repos.Chemicals.Get(ctx, chemicalID)
+	assert.Equal(t, 1.0, stored.Remaining.Amount)
+
+	_, transfer := proposeTransfer(northAdmin, map[string]interface{}{"chemical_id": chemicalID, "to_school": "South School", "amount": "250 mL"})
+	w = sendAs(http.MethodPost, "/api/v1/transfers/"+transfer.ID+"/accept", southAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	stored, _ = repos.Chemicals.Get(ctx, chemicalID)
+	assert.Equal(t, "North School", stored.School)
+	assert.Equal(t, models.Quantity{Amount: 0.75, Unit: models.Liter}, stored.Remaining)
+	accepted, _ := repos.Transfers.Get(ctx, transfer.ID)
+	received, err := repos.Chemicals.Get(ctx, accepted.ReceivedID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, chemicalID, received.ID)
+	assert.Equal(t, "South School", received.School)
+	assert.Equal(t, "Acetone", received.Name)
+	assert.Equal(t, models.Quantity{Amount: 250, Unit: models.Milliliter}, received.Remaining)
+
+	// The new container gets its own QR code and label
+	for _, key := range []string{"QRcodes/" + received.ID + ".png", "label/" + received.ID + ".pdf"} {
+		file, err := blobs.Get(ctx, key)
+		if assert.NoError(t, err, key) {
+			file.Close()
+		}
+	}
+
+	// The receiving school sees the transfer, other schools do not
+	w = sendAs(http.MethodGet, "/api/v1/transfers/"+transfer.ID, southAdmin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/transfers/"+transfer.ID, admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
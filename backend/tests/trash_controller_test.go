@@ -0,0 +1,154 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
+	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that a deleted chemical leaves the inventory for the trash and can be restored
+func TestDeleteChemical_Restore(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "trash-restore")
+
+	w := sendAs(http.MethodDelete, "/api/v1/chemicals/"+chemicalID, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = sendAs(http.MethodGet, "/api/v1/chemicals/"+chemicalID, teacher, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/chemicals", admin, nil)
+	assert.NotContains(t, w.Body.String(), chemicalID)
+	w = postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "5 mL"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Only admins see the trash
+	w = sendAs(http.MethodGet, "/api/v1/trash/chemicals", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/trash/chemicals", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var trashed []models.Chemical
+	json.Unmarshal(w.Body.Bytes(), &trashed)
+	var found *models.Chemical
+	for i := range trashed {
+		if trashed[i].ID == chemicalID {
+			found = &trashed[i]
+		}
+	}
+	if assert.NotNil(t, found) && assert.NotNil(t, found.Deleted) {
+		assert.Equal(t, admin.UserID, found.Deleted.By)
+	}
+
+	w = sendAs(http.MethodPost, "/api/v1/chemicals/"+chemicalID+"/restore", teacher, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodPost, "/api/v1/chemicals/"+chemicalID+"/restore", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodPost, "/api/v1/chemicals/"+chemicalID+"/restore", admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	stored, err := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.Deleted)
+	assert.Equal(t, models.AuditRestore, historyOf(t, chemicalID, teacher)[0].Action)
+}
+
+// Test that purging a chemical removes it and its files for good
+func TestPurgeChemical(t *testing.T) {
+	ctx := context.Background()
+	chemicalID := seedUsageChemical(t, "trash-purge")
+	blobs.Put(ctx, "sds/"+chemicalID+".pdf", bytes.NewReader([]byte("%PDF")), "application/pdf")
+
+	// Only chemicals in the trash can be purged
+	w := sendAs(http.MethodDelete, "/api/v1/chemicals/"+chemicalID+"/purge", admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	sendAs(http.MethodDelete, "/api/v1/chemicals/"+chemicalID, admin, nil)
+	_, err := blobs.Stat(ctx, "sds/"+chemicalID+".pdf")
+	assert.NoError(t, err, "files are kept while the chemical can be restored")
+
+	w = sendAs(http.MethodDelete, "/api/v1/chemicals/"+chemicalID+"/purge", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err = repos.Chemicals.GetTrashed(ctx, chemicalID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = blobs.Stat(ctx, "sds/"+chemicalID+".pdf")
+	assert.ErrorIs(t, err, blobstore.ErrNotExist)
+	assert.Equal(t, models.AuditPurge, historyOf(t, chemicalID, teacher)[0].Action)
+}
+
+// Test that a deleted user cannot log in, and is restored unless their email was taken
+func TestDeleteUser_Restore(t *testing.T) {
+	userID := seedUser(t, models.User{ID: "trash-user", First: "Ada", Email: "trash@example.com", School: "Test School"})
+
+	w := sendAs(http.MethodDelete, "/api/v1/users/"+userID, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/users/"+userID, admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendAs(http.MethodGet, "/api/v1/trash/users", admin, nil)
+	assert.Contains(t, w.Body.String(), userID)
+
+	// The email address is free again while the user is in the trash
+	otherID := seedUser(t, models.User{ID: "trash-user-2", First: "Bea", Email: "trash@example.com", School: "Test School"})
+	w = sendAs(http.MethodPost, "/api/v1/users/"+userID+"/restore", admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	repos.Users.Delete(context.Background(), otherID)
+	w = sendAs(http.MethodPost, "/api/v1/users/"+userID+"/restore", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, err := repos.Users.GetByEmail(context.Background(), "trash@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+
+	other := auth.Principal{UserID: "admin-2", School: "Other School", Role: auth.RoleAdmin}
+	sendAs(http.MethodDelete, "/api/v1/users/"+userID, admin, nil)
+	w = sendAs(http.MethodDelete, "/api/v1/users/"+userID+"/purge", other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = sendAs(http.MethodDelete, "/api/v1/users/"+userID+"/purge", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = repos.Users.GetTrashed(context.Background(), userID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+// Test that the retention job only purges records deleted before the cutoff
+func TestPurgeExpiredTrash(t *testing.T) {
+	ctx := context.Background()
+	store, _ := blobstore.NewLocalStore(t.TempDir(), "http://localhost:8080")
+	backend := repository.NewMemory()
+	h := controllers.NewHandler(controllers.Dependencies{Repositories: backend, Tokens: tokens, Blobs: store})
+
+	now := time.Now().UTC()
+	for id, deletedAt := range map[string]time.Time{"old": now.AddDate(0, 0, -40), "recent": now.AddDate(0, 0, -2)} {
+		chemical := models.Chemical{ID: id, Name: "Acetone", School: "Test School"}
+		backend.Chemicals.Create(ctx, &chemical)
+		backend.Chemicals.Trash(ctx, id, models.Deletion{By: admin.UserID, At: deletedAt})
+	}
+	user := models.User{ID: "old-user", School: "Test School"}
+	backend.Users.Create(ctx, &user)
+	backend.Users.Trash(ctx, user.ID, models.Deletion{By: admin.UserID, At: now.AddDate(0, 0, -40)})
+	store.Put(ctx, "QRcodes/old.png", bytes.NewReader([]byte("png")), "image/png")
+
+	purged, err := h.PurgeExpiredTrash(ctx, now.AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	_, err = backend.Chemicals.GetTrashed(ctx, "old")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = backend.Chemicals.GetTrashed(ctx, "recent")
+	assert.NoError(t, err)
+	_, err = store.Stat(ctx, "QRcodes/old.png")
+	assert.ErrorIs(t, err, blobstore.ErrNotExist)
+
+	entries, _ := backend.Audit.List(ctx, repository.AuditFilter{EntityID: "old"})
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditPurge, entries[0].Action)
+		assert.Equal(t, models.SystemActor, entries[0].Actor)
+	}
+}
@@ -0,0 +1,186 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// postUsage sends a usage request for a chemical as the given caller
+func postUsage(chemicalID, action string, p auth.Principal, body map[string]interface{}) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chemicals/"+chemicalID+"/"+action, bytes.NewReader(jsonValue))
+	authorizeAs(req, p)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// seedUsageChemical stores a 500 mL bottle of acetone in the test school
+func seedUsageChemical(t *testing.T, id string) string {
+	return seedChemical(t, models.Chemical{
+		ID:            id,
+		Name:          "Acetone",
+		CAS:           "67-64-1",
+		School:        "Test School",
+		ContainerSize: models.Quantity{Amount: 500, Unit: models.Milliliter},
+		Remaining:     models.Quantity{Amount: 500, Unit: models.Milliliter},
+	})
+}
+
+// Test that logging usage decrements the remaining amount and records who used it
+func TestLogUsage(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "usage-log")
+
+	w := postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "50 mL", "notes": "Lab 3"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Amounts in other units of the same dimension are converted
+	w = postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": map[string]interface{}{"amount": 0.1, "unit": "L"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Equal(t, models.Quantity{Amount: 350, Unit: models.Milliliter}, stored.Remaining)
+
+	events, _ := repos.Usage.List(context.Background(), repository.UsageFilter{ChemicalID: chemicalID})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, models.UsageWithdrawal, events[0].Kind)
+		assert.Equal(t, teacher.UserID, events[0].UserID)
+		assert.Equal(t, "Test School", events[0].School)
+		assert.Equal(t, models.Quantity{Amount: 450, Unit: models.Milliliter}, events[0].Remaining)
+		assert.Equal(t, "Lab 3", events[0].Notes)
+	}
+
+	// Each withdrawal shows up in the chemical's history
+	entries := historyOf(t, chemicalID, teacher)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.AuditUpdate, entries[0].Action)
+		assert.Equal(t, teacher.UserID, entries[0].Actor)
+		remaining := fieldChange(entries[0], "remaining")
+		assert.Equal(t, map[string]interface{}{"amount": 450.0, "unit": "mL"}, remaining.Before)
+		assert.Equal(t, map[string]interface{}{"amount": 350.0, "unit": "mL"}, remaining.After)
+	}
+}
+
+// Test that usage beyond the remaining amount, or in an incompatible unit, is rejected
+func TestLogUsage_Rejected(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "usage-rejected")
+
+	w := postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "600 mL"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "5 g"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postUsage(chemicalID, "usage", teacher, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	other := auth.Principal{UserID: "teacher-2", School: "Other School", Role: auth.RoleUser}
+	w = postUsage(chemicalID, "usage", other, map[string]interface{}{"amount": "5 mL"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Equal(t, 500.0, stored.Remaining.Amount)
+}
+
+// Test checking a container out and back in
+func TestCheckOutAndIn(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "usage-checkout")
+
+	w := postUsage(chemicalID, "checkin", teacher, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postUsage(chemicalID, "checkout", teacher, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postUsage(chemicalID, "checkout", teacher, map[string]interface{}{"room": "204"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ := repos.Chemicals.Get(context.Background(), chemicalID)
+	if assert.NotNil(t, stored.CheckedOut) {
+		assert.Equal(t, teacher.UserID, stored.CheckedOut.UserID)
+		assert.Equal(t, "204", stored.CheckedOut.Room)
+	}
+
+	w = postUsage(chemicalID, "checkout", teacher, map[string]interface{}{"room": "205"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postUsage(chemicalID, "checkin", teacher, map[string]interface{}{"amount": "120 mL"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ = repos.Chemicals.Get(context.Background(), chemicalID)
+	assert.Nil(t, stored.CheckedOut)
+	assert.Equal(t, 380.0, stored.Remaining.Amount)
+
+	// The rejected attempts change nothing, so only the check-out and check-in are in the history
+	entries := historyOf(t, chemicalID, teacher)
+	if assert.Len(t, entries, 2) {
+		assert.NotNil(t, fieldChange(entries[0], "checked_out").Before)
+		assert.Nil(t, fieldChange(entries[0], "checked_out").After)
+		assert.NotNil(t, fieldChange(entries[0], "remaining").After)
+		assert.Nil(t, fieldChange(entries[1], "checked_out").Before)
+		assert.NotNil(t, fieldChange(entries[1], "checked_out").After)
+	}
+}
+
+// Test that only admins can check a container out to another user of the school
+func TestCheckOut_ToOtherUser(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "usage-checkout-other")
+	studentID := seedUser(t, models.User{ID: "usage-student", First: "Sam", School: "Test School"})
+
+	w := postUsage(chemicalID, "checkout", teacher, map[string]interface{}{"room": "204", "user_id": studentID})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postUsage(chemicalID, "checkout", admin, map[string]interface{}{"room": "204", "user_id": studentID})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	events, _ := repos.Usage.List(context.Background(), repository.UsageFilter{ChemicalID: chemicalID})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, studentID, events[0].UserID)
+		assert.Equal(t, admin.UserID, events[0].RecordedBy)
+	}
+}
+
+// Test querying the ledger by chemical, user and date range
+func TestGetUsage(t *testing.T) {
+	chemicalID := seedUsageChemical(t, "usage-query")
+	postUsage(chemicalID, "usage", teacher, map[string]interface{}{"amount": "10 mL"})
+	postUsage(chemicalID, "usage", admin, map[string]interface{}{"amount": "20 mL"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chemicals/"+chemicalID+"/usage?user_id="+admin.UserID, nil)
+	authorizeAs(req, teacher)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var events []models.UsageEvent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, 20.0, events[0].Amount.Amount)
+	}
+
+	// Usage far in the past matches nothing
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/usage?chemical_id="+chemicalID+"&from=2000-01-01&to=2000-12-31", nil)
+	authorizeAs(req, teacher)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "null", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/usage?from=yesterday", nil)
+	authorizeAs(req, teacher)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/usage?school=Other%20School", nil)
+	authorizeAs(req, teacher)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -1,7 +1,6 @@
 package controllers_test
 
 import (
-	"cloud.google.com/go/firestore"
 	"context"
 	"log"
 	"os"
@@ -12,101 +11,224 @@ import (
 	"net/http/httptest"
 	"encoding/json"
 	"bytes"
+	"github.com/ekjyotshinh/ChemTrack/backend/auth"
+	"github.com/ekjyotshinh/ChemTrack/backend/blobstore"
 	"github.com/ekjyotshinh/ChemTrack/backend/controllers"
-	"google.golang.org/api/option" 
+	"github.com/ekjyotshinh/ChemTrack/backend/mailer"
+	"github.com/ekjyotshinh/ChemTrack/backend/middleware"
+	"github.com/ekjyotshinh/ChemTrack/backend/models"
+	"github.com/ekjyotshinh/ChemTrack/backend/repository"
+	"github.com/ekjyotshinh/ChemTrack/backend/scheduler"
+	"time"
 )
-// Declare the Firestore client and router as global variables
-var client *firestore.Client
+// Declare the repositories and router as global variables
+var repos repository.Repositories
 var r *gin.Engine
+var tokens *auth.TokenManager
+var blobs *blobstore.LocalStore
+var jobs *scheduler.Scheduler
+var mail *mailer.Capture
 
-// Set up the Firestore client and the router once for the entire test suite
+// Set up the repositories and the router once for the entire test suite
 func TestMain(m *testing.M) {
 
-	// Set the FIRESTORE_EMULATOR_HOST environment variable
-	os.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
-	os.Setenv("ENVIRONMENT", "test")
-	// Set up Firestore client
-	client = setupFirestoreClient()
+	// Keep every record in memory so the suite runs offline
+	repos = repository.NewMemory()
 
-	// addditial checks
-	if client == nil {
-		log.Fatalf("Failed to create Firestore client")
+	// Set up token signing with a fixed test secret
+	tokens = auth.NewTokenManager("test-secret", 15*time.Minute, time.Hour)
+
+	// Keep uploaded and generated files in a temporary directory
+	blobDir, err := os.MkdirTemp("", "chemtrack-blobs")
+	if err != nil {
+		log.Fatalf("Failed to create blob directory: %v", err)
+	}
+	blobs, err = blobstore.NewLocalStore(blobDir, "http://localhost:8080")
+	if err != nil {
+		log.Fatalf("Failed to create blob store: %v", err)
 	}
 
-	// Set the Firestore client in the controllers
-	controllers.SetClient(client)
+	// Run background jobs only when a test triggers them
+	jobs = scheduler.New(repos.Jobs, scheduler.Options{Instance: "test"})
+
+	// Capture the emails the handlers send instead of sending them
+	sender, err := mailer.ParseSender("ChemTrack <noreply@chemtrack.test>", "Support <support@chemtrack.test>")
+	if err != nil {
+		log.Fatalf("Failed to parse the test sender: %v", err)
+	}
+	mail = mailer.NewCapture(sender)
 
 	// Initialize the router
-	r = setupRouter()
+	r = setupRouter(controllers.NewHandler(controllers.Dependencies{Repositories: repos, Tokens: tokens, Blobs: blobs, Jobs: jobs, Mailer: mail}))
 
 	// Run tests
 	exitCode := m.Run()
 
 	// Clean up after tests
-	client.Close()
+	os.RemoveAll(blobDir)
 
 	// Exit with the code from the test run
 	os.Exit(exitCode)
 }
 
-// Helper function to set up the Firestore client
-func setupFirestoreClient() *firestore.Client {
-	ctx := context.Background()
-	client, err := firestore.NewClient(ctx, "chemtrack-3857a", option.WithoutAuthentication()) // Use WithoutAuthentication for emulator
-	// chemtrack-3857a -- my temp project
-	if err != nil {
-		log.Fatalf("Failed to create Firestore client: %v", err)
-		return nil
-	}
-	return client
-}
-
 // set up the  router
-func setupRouter() *gin.Engine {
+func setupRouter(h *controllers.Handler) *gin.Engine {
 	r := gin.Default()
+	r.Use(middleware.RequestID())
 
 	// Set up routes for testing
 
+	// stored files
+	r.GET("/blobs/*key", h.ServeBlob)
+
+	// public routes
+	public := r.Group("/api/v1", middleware.OptionalAuth(tokens))
+	public.POST("/users", h.AddUser)
+	public.GET("/users/schools", h.GetUserSchools)
+	public.POST("/login", h.Login)
+	public.POST("/auth/forgot-password", h.ForgotPassword)
+	public.POST("/auth/refresh", h.RefreshToken)
+
+	// every other route requires an access token
+	api := r.Group("/api/v1", middleware.RequireAuth(tokens))
+
 	// user routes
-	r.POST("/api/v1/users", controllers.AddUser)
-	r.GET("/api/v1/users", controllers.GetUsers)
-	r.GET("/api/v1/users/:id", controllers.GetUser)
-	r.GET("/api/v1/users/schools", controllers.GetUserSchools)
-	r.PUT("/api/v1/users/:id", controllers.UpdateUser)
-	r.DELETE("/api/v1/users/:id", controllers.DeleteUser)
-	r.POST("/api/v1/login", controllers.Login)
+	api.GET("/users", h.GetUsers)
+	api.GET("/users/:id", h.GetUser)
+	api.PUT("/users/:id", h.UpdateUser)
+	api.DELETE("/users/:id", h.DeleteUser)
 
 	// Chemical routes
-	r.POST("/api/v1/chemicals", controllers.AddChemical)        // Create a new chemical
-	r.GET("/api/v1/chemicals", controllers.GetChemicals)        // Get all chemicals
-	r.GET("/api/v1/chemicals/:id", controllers.GetChemical)     // Get a specific chemical by ID
-	r.PUT("/api/v1/chemicals/:id", controllers.UpdateChemical)  // Update a chemical by ID
-	r.DELETE("/api/v1/chemicals/:id", controllers.DeleteChemical) // Delete a chemical by ID
+	api.POST("/chemicals", h.AddChemical)          // Create a new chemical
+	api.GET("/chemicals", h.GetChemicals)          // Get all chemicals
+	api.GET("/chemicals/search", h.SearchChemicals) // Search chemicals
+	api.POST("/chemicals/import", h.ImportChemicals) // Import chemicals from a file
+	api.GET("/chemicals/export", h.ExportChemicals)  // Export chemicals
+	api.GET("/chemicals/:id", h.GetChemical)       // Get a specific chemical by ID
+	api.PUT("/chemicals/:id", h.UpdateChemical)    // Update a chemical by ID
+	api.DELETE("/chemicals/:id", h.DeleteChemical) // Delete a chemical by ID
+	api.GET("/ghs", h.GetGHSReference)
+	api.GET("/storage/conflicts", h.GetStorageConflicts)
+
+	// location routes
+	api.POST("/locations", h.CreateLocation)
+	api.GET("/locations", h.GetLocations)
+	api.GET("/locations/:id", h.GetLocation)
+	api.PUT("/locations/:id", h.UpdateLocation)
+	api.DELETE("/locations/:id", h.DeleteLocation)
+
+	// transfer routes
+	api.POST("/transfers", h.ProposeTransfer)
+	api.GET("/transfers", h.GetTransfers)
+	api.GET("/transfers/:id", h.GetTransfer)
+	api.POST("/transfers/:id/accept", h.AcceptTransfer)
+	api.POST("/transfers/:id/reject", h.RejectTransfer)
+	api.POST("/transfers/:id/cancel", h.CancelTransfer)
+	api.GET("/alerts", h.GetAlerts)
+	api.GET("/alerts/rules", h.GetAlertRules)
+	api.PUT("/alerts/rules", h.UpdateAlertRules)
+	api.DELETE("/alerts/rules", h.ResetAlertRules)
+	api.GET("/alerts/:id", h.GetAlert)
+	api.POST("/alerts/:id/acknowledge", h.AcknowledgeAlert)
+	api.POST("/alerts/:id/snooze", h.SnoozeAlert)
+	api.POST("/alerts/:id/resolve", h.ResolveAlert)
+
+	// job routes
+	api.GET("/jobs", h.GetJobs)
+	api.GET("/jobs/:name/runs", h.GetJobRuns)
+	api.POST("/jobs/:name/run", h.RunJob)
+
+	// usage routes
+	api.POST("/chemicals/:id/usage", h.LogUsage)
+	api.GET("/chemicals/:id/usage", h.GetChemicalUsage)
+	api.POST("/chemicals/:id/checkout", h.CheckOutChemical)
+	api.POST("/chemicals/:id/checkin", h.CheckInChemical)
+	api.GET("/usage", h.GetUsage)
+
+	// audit routes
+	api.GET("/chemicals/:id/history", h.GetChemicalHistory)
+	api.GET("/audit", h.GetAuditLog)
+
+	// Trash routes
+	api.GET("/trash/chemicals", h.GetTrashedChemicals)
+	api.GET("/trash/users", h.GetTrashedUsers)
+	api.POST("/chemicals/:id/restore", h.RestoreChemical)
+	api.DELETE("/chemicals/:id/purge", h.PurgeChemical)
+	api.POST("/users/:id/restore", h.RestoreUser)
+	api.DELETE("/users/:id/purge", h.PurgeUser)
+
+	// Disposal routes
+	api.POST("/chemicals/:id/disposal/flag", h.FlagChemicalForDisposal)
+	api.POST("/chemicals/:id/disposal/approve", h.ApproveChemicalDisposal)
+	api.POST("/chemicals/:id/disposal/complete", h.CompleteChemicalDisposal)
+	api.DELETE("/chemicals/:id/disposal", h.CancelChemicalDisposal)
+	api.GET("/disposals", h.GetDisposalReport)
 
 	// email routes
-	r.POST("/api/v1/email/send", controllers.SendEmail)
+	api.POST("/email/send", h.SendEmail)
+	api.GET("/notifications/templates", h.GetNotificationTemplates)
+	api.GET("/notifications/templates/:name/preview", h.PreviewNotificationTemplate)
 
 	// file routes
-		// sds routes
-	r.POST("/api/v1/files/sds/:chemicalIdNumber", controllers.AddSDS)      // Create a new chemical
-	r.GET("/api/v1/files/sds/:chemicalIdNumber", controllers.GetSDS)       // Retrieve SDS URL
-	r.DELETE("/api/v1/files/sds/:chemicalIdNumber", controllers.DeleteSDS) // Delete SDS
+	// sds routes
+	api.POST("/files/sds/:chemicalIdNumber", h.AddSDS)      // Create a new chemical
+	api.GET("/files/sds/:chemicalIdNumber", h.GetSDS)       // Retrieve SDS URL
+	api.DELETE("/files/sds/:chemicalIdNumber", h.DeleteSDS) // Delete SDS
 
 	//profile picture routes
-	r.POST("/api/v1/files/profile/:userId", controllers.AddProfilePicture)      // Add a new profile picture
-	r.GET("/api/v1/files/profile/:userId", controllers.GetProfilePicture)       // Get the profile picture URL
-	r.DELETE("/api/v1/files/profile/:userId", controllers.DeleteProfilePicture) // Delete profile picture
-	r.PUT("/api/v1/files/profile/:userId", controllers.UpdateProfilePicture)    // Update existing profile picture
+	api.POST("/files/profile/:userId", h.AddProfilePicture)      // Add a new profile picture
+	api.GET("/files/profile/:userId", h.GetProfilePicture)       // Get the profile picture URL
+	api.DELETE("/files/profile/:userId", h.DeleteProfilePicture) // Delete profile picture
+	api.PUT("/files/profile/:userId", h.UpdateProfilePicture)    // Update existing profile picture
 
 	// label routes
-	r.POST("/api/v1/files/label/:chemicalIdNumber", controllers.AddLabel) // Create a new label
-	r.GET("/api/v1/files/label/:chemicalIdNumber", controllers.GetLabel) // Retrieve label URL
-	r.DELETE("/api/v1/files/label/:chemicalIdNumber", controllers.DeleteLabel) // Delete label
-
+	api.POST("/files/label/:chemicalIdNumber", h.AddLabel)      // Create a new label
+	api.GET("/files/label/:chemicalIdNumber", h.GetLabel)       // Retrieve label URL
+	api.DELETE("/files/label/:chemicalIdNumber", h.DeleteLabel) // Delete label
 
 	return r
 }
 
+// authorize signs the request as a master user, who can reach every route
+func authorize(req *http.Request) {
+	authorizeAs(req, auth.Principal{UserID: "test-master", School: "Test School", Role: auth.RoleMaster})
+}
+
+// authorizeAs signs the request as the given caller
+func authorizeAs(req *http.Request, p auth.Principal) {
+	token, err := tokens.IssueAccessToken(p)
+	if err != nil {
+		log.Fatalf("Failed to issue test token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// seedUser stores a user directly in the repository, replacing any user with the same ID
+func seedUser(t *testing.T, user models.User) string {
+	t.Helper()
+	ctx := context.Background()
+	if user.ID != "" {
+		repos.Users.Delete(ctx, user.ID)
+	}
+	if err := repos.Users.Create(ctx, &user); err != nil {
+		t.Fatalf("Failed to add mock user: %v", err)
+	}
+	return user.ID
+}
+
+// seedChemical stores a chemical directly in the repository, replacing any chemical with the same ID
+func seedChemical(t *testing.T, chemical models.Chemical) string {
+	t.Helper()
+	ctx := context.Background()
+	if chemical.ID != "" {
+		repos.Chemicals.Delete(ctx, chemical.ID)
+	}
+	if err := repos.Chemicals.Create(ctx, &chemical); err != nil {
+		t.Fatalf("Failed to add mock chemical: %v", err)
+	}
+	return chemical.ID
+}
+
 // User structure for tests
 type User struct {
 	First         string `json:"first"`
@@ -201,12 +323,45 @@ func TestAddUser_Success(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(user)
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "User added successfully")
+
+	// The new user is sent an invitation
+	sent := mail.To("john.success@example.com")
+	if assert.Len(t, sent, 1) {
+		assert.Contains(t, sent[0].Text, "Test School")
+		assert.NotEmpty(t, sent[0].HTML)
+	}
+}
+
+// Test that users are invited in the language chosen for them, which must have a catalog
+func TestAddUser_Locale(t *testing.T) {
+	body := map[string]interface{}{"first": "Ana", "email": "ana.locale@example.com", "password": "secure123",
+		"school": "Test School", "allow_email": true, "locale": "tlh"}
+	w := sendAs(http.MethodPost, "/api/v1/users", master, body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "locale must be one of")
+
+	body["locale"] = "es-MX"
+	w = sendAs(http.MethodPost, "/api/v1/users", master, body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	sent := mail.To("ana.locale@example.com")
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Le han añadido a ChemTrack en Test School", sent[0].Subject)
+	}
+	stored, err := repos.Users.GetByEmail(context.Background(), "ana.locale@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "es", stored.Locale)
+
+	w = sendAs(http.MethodPut, "/api/v1/users/"+stored.ID, master, map[string]interface{}{"locale": "en"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ = repos.Users.Get(context.Background(), stored.ID)
+	assert.Equal(t, "en", stored.Locale)
 }
 
 func TestAddUser_DuplicateEmail(t *testing.T) {
@@ -226,11 +381,13 @@ func TestAddUser_DuplicateEmail(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(existingUser)
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	// Try to add user with same email
 	req2 := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(jsonValue))
+	authorize(req2)
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req2)
 
@@ -242,6 +399,7 @@ func TestAddUser_InvalidJSON(t *testing.T) {
 	invalidJSON := `{"first": "Missing end"`
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(invalidJSON))
+	authorize(req)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -252,18 +410,15 @@ func TestAddUser_InvalidJSON(t *testing.T) {
 // Test GetUser
 func TestGetUser(t *testing.T) {
 	// Add mock data for a user
-	userID := "12345"
-	userRef := client.Collection("users").Doc(userID)
-	_, err := userRef.Set(context.Background(), map[string]interface{}{
-		"first": "Jane",
-		"last":  "Doe",
-		"email": "jane.doe@example.com",
+	userID := seedUser(t, models.User{
+		ID:    "12345",
+		First: "Jane",
+		Last:  "Doe",
+		Email: "jane.doe@example.com",
 	})
-	if err != nil {
-		t.Fatalf("Failed to add mock user: %v", err)
-	}
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+userID, nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -281,19 +436,17 @@ func TestGetUsers(t *testing.T) {
 		{First: "Jane", Last: "Smith", Email: "jane.smith@example.com", Password: "password123", School: "Test School"},
 	}
 
-	// Insert multiple users into Firestore
+	// Insert multiple users into the repository
 	for _, user := range users {
-		_, _, err := client.Collection("users").Add(context.Background(), map[string]interface{}{
-			"first": user.First,
-			"last":  user.Last,
-			"email": user.Email,
+		seedUser(t, models.User{
+			First: user.First,
+			Last:  user.Last,
+			Email: user.Email,
 		})
-		if err != nil {
-			t.Fatalf("Failed to add user: %v", err)
-		}
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -310,6 +463,7 @@ func TestGetUserWithInvalidID(t *testing.T) {
 	invalidUserID := "nonexistent123"
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+invalidUserID, nil)
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -319,16 +473,12 @@ func TestGetUserWithInvalidID(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "User not found")
 }
 func TestUpdateUserValid(t *testing.T) {
-	userID := "12345"
-	userRef := client.Collection("users").Doc(userID)
-	_, err := userRef.Set(context.Background(), map[string]interface{}{
-		"first": "Jane",
-		"last":  "Doe",
-		"email": "jane.doe@example.com",
+	userID := seedUser(t, models.User{
+		ID:    "12345",
+		First: "Jane",
+		Last:  "Doe",
+		Email: "jane.doe@example.com",
 	})
-	if err != nil {
-		t.Fatalf("Failed to add mock user: %v", err)
-	}
 
 	updatedUser := User{
 		First:         "Janet",
@@ -345,6 +495,7 @@ func TestUpdateUserValid(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(updatedUser)
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/"+userID, bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -356,27 +507,19 @@ func TestUpdateUserValid(t *testing.T) {
 
 func TestUpdateUserEmailAlreadyInUse(t *testing.T) {
 	// Add two users with different emails first
-	userID1 := "12345"
-	userRef1 := client.Collection("users").Doc(userID1)
-	_, err := userRef1.Set(context.Background(), map[string]interface{}{
-		"first": "Jane",
-		"last":  "Doe",
-		"email": "email.inuse@example.com",
+	seedUser(t, models.User{
+		ID:    "12345",
+		First: "Jane",
+		Last:  "Doe",
+		Email: "email.inuse@example.com",
 	})
-	if err != nil {
-		t.Fatalf("Failed to add mock user: %v", err)
-	}
 
-	userID2 := "67890"
-	userRef2 := client.Collection("users").Doc(userID2)
-	_, err = userRef2.Set(context.Background(), map[string]interface{}{
-		"first": "John",
-		"last":  "Doe",
-		"email": "email.notinuse@example.com",
+	userID2 := seedUser(t, models.User{
+		ID:    "67890",
+		First: "John",
+		Last:  "Doe",
+		Email: "email.notinuse@example.com",
 	})
-	if err != nil {
-		t.Fatalf("Failed to add mock user: %v", err)
-	}
 
 	// Attempt to update user2 with user1's email
 	updatedUser := User{
@@ -385,6 +528,7 @@ func TestUpdateUserEmailAlreadyInUse(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(updatedUser)
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/"+userID2, bytes.NewReader(jsonValue))
+	authorize(req)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -413,6 +557,7 @@ func TestDeleteUser(t *testing.T) {
 
 	jsonValue, _ := json.Marshal(user)
 	reqAdd := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(jsonValue))
+	authorize(reqAdd)
 	wAdd := httptest.NewRecorder()
 	r.ServeHTTP(wAdd, reqAdd)
 
@@ -433,6 +578,7 @@ func TestDeleteUser(t *testing.T) {
 	// Step 3: Delete the user
 	deleteURL := "/api/v1/users/" + addResp.User.ID
 	reqDel := httptest.NewRequest(http.MethodDelete, deleteURL, nil)
+	authorize(reqDel)
 	wDel := httptest.NewRecorder()
 	r.ServeHTTP(wDel, reqDel)
 
@@ -458,6 +604,7 @@ func TestLogin(t *testing.T) {
 
 	userJSON, _ := json.Marshal(user)
 	reqAdd := httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewReader(userJSON))
+	authorize(reqAdd)
 	reqAdd.Header.Set("Content-Type", "application/json")
 	wAdd := httptest.NewRecorder()
 	r.ServeHTTP(wAdd, reqAdd)
@@ -490,9 +637,12 @@ func TestLogin(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, wLogin.Code)
 	assert.Contains(t, wLogin.Body.String(), "Login successful")
+	assert.Contains(t, wLogin.Body.String(), "access_token")
+	assert.Contains(t, wLogin.Body.String(), "refresh_token")
 
 	// Step 3: Delete the user
 	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+addResp.User.ID, nil)
+	authorize(deleteReq)
 	wDelete := httptest.NewRecorder()
 	r.ServeHTTP(wDelete, deleteReq)
 
@@ -130,10 +130,12 @@ export default function EditChemicals() {
         const quantityArray = data.quantity ? data.quantity.split(' ') : ['', ''];
         setQuantity(quantityArray[0] || '');
         setUnit(quantityArray[1] || '');
+        // CAS numbers are returned hyphenated (7647-14-5), older records as plain digits
+        const cas = data.CAS ? data.CAS.toString().replace(/-/g, '') : '';
         setCasParts([
-          data.CAS?.toString().substring(0, data.CAS?.toString().length - 3),  // First part: all digits except last 3
-          data.CAS?.toString().substring(data.CAS?.toString().length - 3, data.CAS?.toString().length - 1),  // Second part: 2 digits before last digit
-          data.CAS?.toString().substring(data.CAS?.toString().length - 1)  // Last part: last digit
+          cas.substring(0, cas.length - 3),  // First part: all digits except last 3
+          cas.substring(cas.length - 3, cas.length - 1),  // Second part: 2 digits before last digit
+          cas.substring(cas.length - 1)  // Last part: last digit
         ]);
 
         setPurchaseDate(data.purchase_date ? new Date(data.purchase_date) : undefined);